@@ -0,0 +1,195 @@
+package ninjabot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/xhit/go-str2duration/v2"
+	"gonum.org/v1/gonum/stat"
+
+	"github.com/rodrigo-brito/ninjabot/exchange"
+	"github.com/rodrigo-brito/ninjabot/model"
+	"github.com/rodrigo-brito/ninjabot/storage"
+	"github.com/rodrigo-brito/ninjabot/strategy"
+)
+
+// BenchmarkResult compares the strategy return against returns of random entries and exits
+type BenchmarkResult struct {
+	Strategy    float64   // strategy return in percent
+	Returns     []float64 // return in percent of each random run
+	EntryChance float64   // probability of a random entry per candle
+	Holding     int       // holding time of random trades in candles
+
+	Mean float64
+	P5   float64
+	P25  float64
+	P50  float64
+	P75  float64
+	P95  float64
+}
+
+// Rank returns the fraction of random runs with a return lower than the strategy
+func (b BenchmarkResult) Rank() float64 {
+	if len(b.Returns) == 0 {
+		return 0
+	}
+
+	lower := 0
+	for _, value := range b.Returns {
+		if value < b.Strategy {
+			lower++
+		}
+	}
+	return float64(lower) / float64(len(b.Returns))
+}
+
+func (b BenchmarkResult) Print() {
+	fmt.Println("------ RANDOM BENCHMARK -------")
+	fmt.Printf("RUNS                = %d\n", len(b.Returns))
+	fmt.Printf("ENTRY CHANCE        = %.2f%% per candle\n", b.EntryChance*100)
+	fmt.Printf("HOLDING             = %d candles\n", b.Holding)
+	fmt.Printf("RANDOM MEAN         = %.2f%%\n", b.Mean)
+	fmt.Printf("RANDOM PERCENTILES  = P5 %.2f%% | P25 %.2f%% | P50 %.2f%% | P75 %.2f%% | P95 %.2f%%\n",
+		b.P5, b.P25, b.P50, b.P75, b.P95)
+	fmt.Printf("STRATEGY            = %.2f%% (better than %.1f%% of random runs)\n", b.Strategy, b.Rank()*100)
+}
+
+// Benchmark runs a backtest of the given strategy followed by `runs` backtests with random entries and
+// exits, matching the strategy trade frequency and average holding time. Each random run uses `seed + run`
+// as seed, so results are reproducible. newWallet must return a new paper wallet with a fresh data feed.
+func Benchmark(ctx context.Context, settings model.Settings, str strategy.Strategy, runs int, seed int64,
+	newWallet func() (*exchange.PaperWallet, error)) (*BenchmarkResult, error) {
+
+	if runs < 1 {
+		return nil, errors.New("benchmark requires at least one run")
+	}
+
+	// notifications are not useful for simulations
+	settings.Telegram.Enabled = false
+
+	strategyReturn, bot, wallet, err := benchmarkRun(ctx, settings, str, newWallet)
+	if err != nil {
+		return nil, err
+	}
+
+	timeframe, err := str2duration.ParseDuration(str.Timeframe())
+	if err != nil {
+		return nil, err
+	}
+
+	orders, err := bot.storage.Orders(storage.WithStatus(model.OrderStatusTypeFilled))
+	if err != nil {
+		return nil, err
+	}
+
+	// equity is recorded for each complete candle of all pairs
+	candles := len(wallet.EquityValues())
+	trades, holding := tradeFrequency(orders, timeframe)
+	idle := candles - trades*holding
+	if idle < 1 {
+		idle = 1
+	}
+
+	result := &BenchmarkResult{
+		Strategy:    strategyReturn,
+		EntryChance: float64(trades) / float64(idle),
+		Holding:     holding,
+	}
+
+	var fee float64
+	for _, pair := range settings.Pairs {
+		_, taker := wallet.FeeRate(pair)
+		fee = max(fee, taker)
+	}
+
+	for i := 0; i < runs; i++ {
+		random := strategy.NewRandom(str.Timeframe(), str.WarmupPeriod(), result.EntryChance, holding, seed+int64(i))
+		random.Pairs, random.Fee = len(settings.Pairs), fee
+		value, _, _, err := benchmarkRun(ctx, settings, random, newWallet)
+		if err != nil {
+			return nil, err
+		}
+		result.Returns = append(result.Returns, value)
+	}
+
+	sorted := make([]float64, len(result.Returns))
+	copy(sorted, result.Returns)
+	sort.Float64s(sorted)
+
+	result.Mean = stat.Mean(sorted, nil)
+	result.P5 = stat.Quantile(0.05, stat.Empirical, sorted, nil)
+	result.P25 = stat.Quantile(0.25, stat.Empirical, sorted, nil)
+	result.P50 = stat.Quantile(0.50, stat.Empirical, sorted, nil)
+	result.P75 = stat.Quantile(0.75, stat.Empirical, sorted, nil)
+	result.P95 = stat.Quantile(0.95, stat.Empirical, sorted, nil)
+
+	return result, nil
+}
+
+// benchmarkRun executes a silent backtest and returns the wallet return in percent
+func benchmarkRun(ctx context.Context, settings model.Settings, str strategy.Strategy,
+	newWallet func() (*exchange.PaperWallet, error)) (float64, *NinjaBot, *exchange.PaperWallet, error) {
+
+	wallet, err := newWallet()
+	if err != nil {
+		return 0, nil, nil, err
+	}
+
+	db, err := storage.FromMemory()
+	if err != nil {
+		return 0, nil, nil, err
+	}
+
+	bot, err := NewBot(ctx, settings, wallet, str, WithBacktest(wallet), WithStorage(db))
+	if err != nil {
+		return 0, nil, nil, err
+	}
+	bot.hideProgress = true
+
+	initial := wallet.Equity()
+	if initial == 0 {
+		return 0, nil, nil, errors.New("benchmark requires a paper wallet with initial funds")
+	}
+
+	if err := bot.Run(ctx); err != nil {
+		return 0, nil, nil, err
+	}
+
+	return (wallet.Equity()/initial - 1) * 100, bot, wallet, nil
+}
+
+// tradeFrequency returns the number of closed trades and their average holding time in candles
+func tradeFrequency(orders []*model.Order, timeframe time.Duration) (int, int) {
+	sort.Slice(orders, func(i, j int) bool {
+		return orders[i].UpdatedAt.Before(orders[j].UpdatedAt)
+	})
+
+	entries := make(map[string]time.Time)
+	trades := 0
+	var holding time.Duration
+	for _, order := range orders {
+		entry, open := entries[order.Pair]
+		switch {
+		case order.Side == model.SideTypeBuy && !open:
+			entries[order.Pair] = order.UpdatedAt
+		case order.Side == model.SideTypeSell && open:
+			trades++
+			holding += order.UpdatedAt.Sub(entry)
+			delete(entries, order.Pair)
+		}
+	}
+
+	if trades == 0 || timeframe <= 0 {
+		return trades, 1
+	}
+
+	candles := int(math.Round(float64(holding) / float64(trades) / float64(timeframe)))
+	if candles < 1 {
+		candles = 1
+	}
+	return trades, candles
+}
@@ -0,0 +1,50 @@
+package ninjabot
+
+import (
+	"time"
+
+	"github.com/rodrigo-brito/ninjabot/model"
+	"github.com/rodrigo-brito/ninjabot/tools/log"
+)
+
+// candleCloser closes each candle of a pair exactly once, on the final update of the feed or on an update
+// received within the tolerance of the end of the period. Updates of a closed candle, early or late final
+// events straddling the boundary, are ignored by the strategy.
+type candleCloser struct {
+	timeframe time.Duration
+	tolerance time.Duration
+	now       func() time.Time
+	closed    map[string]time.Time // open time of the last closed candle by pair
+	logger    *log.Logger
+}
+
+func newCandleCloser(timeframe, tolerance time.Duration, logger *log.Logger) *candleCloser {
+	return &candleCloser{
+		timeframe: timeframe,
+		tolerance: tolerance,
+		now:       time.Now,
+		closed:    make(map[string]time.Time),
+		logger:    logger,
+	}
+}
+
+// check returns the candle with its close status, and false if the candle is already closed
+func (c *candleCloser) check(candle model.Candle) (model.Candle, bool) {
+	if last, ok := c.closed[candle.Pair]; ok && !candle.Time.After(last) {
+		if candle.Complete {
+			c.logger.Debugf("[CANDLE] %s candle of %s already closed, update ignored", candle.Pair, candle.Time)
+		}
+		return candle, false
+	}
+
+	if !candle.Complete && c.tolerance > 0 && c.timeframe > 0 &&
+		!c.now().Before(candle.Time.Add(c.timeframe-c.tolerance)) {
+		c.logger.Debugf("[CANDLE] %s candle of %s closed within the tolerance", candle.Pair, candle.Time)
+		candle.Complete = true
+	}
+
+	if candle.Complete {
+		c.closed[candle.Pair] = candle.Time
+	}
+	return candle, true
+}
@@ -2,6 +2,9 @@ package main
 
 import (
 	"context"
+	"errors"
+	"os"
+	"os/signal"
 
 	"github.com/rodrigo-brito/ninjabot"
 	"github.com/rodrigo-brito/ninjabot/examples/strategies"
@@ -90,11 +93,14 @@ func main() {
 		log.Fatal(err)
 	}
 
-	// Initializer simulation
-	err = bot.Run(ctx)
-	if err != nil {
+	// Initializer simulation, Ctrl+C stops it and keeps the results up to the interruption
+	runCtx, stop := signal.NotifyContext(ctx, os.Interrupt)
+	defer stop()
+	err = bot.Run(runCtx)
+	if err != nil && !errors.Is(err, context.Canceled) {
 		log.Fatal(err)
 	}
+	stop()
 
 	// Print bot results
 	bot.Summary()
@@ -50,16 +50,6 @@ func main() {
 		log.Fatal(err)
 	}
 
-	// creating a paper wallet to simulate an exchange waller for fake operataions
-	// paper wallet is simulation of a real exchange wallet
-	paperWallet := exchange.NewPaperWallet(
-		ctx,
-		"USDT",
-		exchange.WithPaperFee(0.001, 0.001),
-		exchange.WithPaperAsset("USDT", 10000),
-		exchange.WithDataFeed(binance),
-	)
-
 	// initializing my strategy
 	strategy := new(strategies.CrossEMA)
 
@@ -77,10 +67,14 @@ func main() {
 	bot, err := ninjabot.NewBot(
 		ctx,
 		settings,
-		paperWallet,
+		binance,
 		strategy,
 		ninjabot.WithStorage(storage),
-		ninjabot.WithPaperWallet(paperWallet),
+		// paper trading simulates fills in a paper wallet with the live data from binance
+		ninjabot.WithPaperTrading("USDT",
+			exchange.WithPaperFee(0.001, 0.001),
+			exchange.WithPaperAsset("USDT", 10000),
+		),
 		ninjabot.WithCandleSubscription(chart),
 		ninjabot.WithOrderSubscription(chart),
 	)
@@ -0,0 +1,34 @@
+package strategies
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/rodrigo-brito/ninjabot"
+	"github.com/rodrigo-brito/ninjabot/exchange"
+	"github.com/rodrigo-brito/ninjabot/tools/regression"
+)
+
+func TestCrossEMA_Regression(t *testing.T) {
+	settings := ninjabot.Settings{Pairs: []string{"BTCUSDT", "ETHUSDT"}}
+	result, err := ninjabot.Regression(context.Background(), settings, new(CrossEMA),
+		func() (*exchange.PaperWallet, error) {
+			feed, err := exchange.NewCSVFeed("4h",
+				exchange.PairFeed{Pair: "BTCUSDT", File: "../../testdata/btc-1h.csv", Timeframe: "1h"},
+				exchange.PairFeed{Pair: "ETHUSDT", File: "../../testdata/eth-1h.csv", Timeframe: "1h"},
+			)
+			if err != nil {
+				return nil, err
+			}
+			return exchange.NewPaperWallet(context.Background(), "USDT",
+				exchange.WithPaperAsset("USDT", 10000),
+				exchange.WithDataFeed(feed),
+			), nil
+		})
+	require.NoError(t, err)
+	require.NotEmpty(t, result.Trades)
+
+	regression.Check(t, "testdata/emacross.golden.json", result, 1e-9)
+}
@@ -2,8 +2,13 @@ package exchange
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"math"
+	"net/http"
+	"net/url"
 	"strconv"
+	"sync"
 	"time"
 
 	"github.com/adshao/go-binance/v2"
@@ -11,9 +16,21 @@ import (
 	"github.com/jpillora/backoff"
 
 	"github.com/rodrigo-brito/ninjabot/model"
+	"github.com/rodrigo-brito/ninjabot/service"
 	"github.com/rodrigo-brito/ninjabot/tools/log"
 )
 
+// wsEndpointMtx guards the go-binance endpoint and proxy globals, read when a websocket connection is
+// opened. Each instance sets its own endpoint during the connection, so testnet and main bots can coexist.
+var wsEndpointMtx sync.Mutex
+
+// binanceEndpoint is a set of custom Binance API endpoints
+type binanceEndpoint struct {
+	api      string
+	ws       string
+	combined string
+}
+
 type MetadataFetchers func(pair string, t time.Time) (string, float64)
 
 type Binance struct {
@@ -23,10 +40,19 @@ type Binance struct {
 	HeikinAshi bool
 	Testnet    bool
 
-	APIKey    string
-	APISecret string
+	APIKey       string
+	APISecret    string
+	OrderTimeout time.Duration
+	OrderRetries int
 
 	MetadataFetchers []MetadataFetchers
+
+	mainEndpoint    binanceEndpoint
+	testnetEndpoint binanceEndpoint
+
+	httpClient  *http.Client
+	proxyURL    string
+	rateLimiter *RateLimiter
 }
 
 type BinanceOption func(*Binance)
@@ -56,8 +82,8 @@ func WithMetadataFetcher(fetcher MetadataFetchers) BinanceOption {
 
 // WithTestNet activate Bianance testnet
 func WithTestNet() BinanceOption {
-	return func(_ *Binance) {
-		binance.UseTestnet = true
+	return func(b *Binance) {
+		b.Testnet = true
 	}
 }
 
@@ -67,10 +93,8 @@ func WithCustomMainAPIEndpoint(apiURL, wsURL, combinedURL string) BinanceOption
 		log.Fatal("missing url parameters for custom endpoint configuration")
 	}
 
-	return func(_ *Binance) {
-		binance.BaseAPIMainURL = apiURL
-		binance.BaseWsMainURL = wsURL
-		binance.BaseCombinedMainURL = combinedURL
+	return func(b *Binance) {
+		b.mainEndpoint = binanceEndpoint{api: apiURL, ws: wsURL, combined: combinedURL}
 	}
 }
 
@@ -80,23 +104,71 @@ func WithCustomTestnetAPIEndpoint(apiURL, wsURL, combinedURL string) BinanceOpti
 		log.Fatal("missing url parameters for custom endpoint configuration")
 	}
 
-	return func(_ *Binance) {
-		binance.BaseAPITestnetURL = apiURL
-		binance.BaseWsTestnetURL = wsURL
-		binance.BaseCombinedTestnetURL = combinedURL
+	return func(b *Binance) {
+		b.testnetEndpoint = binanceEndpoint{api: apiURL, ws: wsURL, combined: combinedURL}
+	}
+}
+
+// WithBinanceOrderRetries sets how many times an order is submitted again after a lost response (default 0).
+// Before a new attempt, the order is looked up by its client order id to prevent duplicates.
+func WithBinanceOrderRetries(retries int) BinanceOption {
+	return func(b *Binance) {
+		b.OrderRetries = retries
+	}
+}
+
+// WithBinanceOrderTimeout sets the maximum time to wait for the exchange to answer an order request (default 5s)
+func WithBinanceOrderTimeout(timeout time.Duration) BinanceOption {
+	return func(b *Binance) {
+		b.OrderTimeout = timeout
+	}
+}
+
+// WithBinanceHTTPClient sets the HTTP client of API requests, eg: with custom timeouts or TLS settings
+func WithBinanceHTTPClient(client *http.Client) BinanceOption {
+	return func(b *Binance) {
+		b.httpClient = client
+	}
+}
+
+// WithBinanceProxy routes API requests and websockets through the given proxy, eg: http://proxy:8080
+// With a custom HTTP client, the proxy is used only by websockets.
+func WithBinanceProxy(proxyURL string) BinanceOption {
+	return func(b *Binance) {
+		b.proxyURL = proxyURL
+	}
+}
+
+// WithBinanceRateLimiter sets the limiter pausing requests after rate limit responses (429 and 418),
+// eg: shared with a futures exchange. By default, each exchange has its own limiter.
+func WithBinanceRateLimiter(limiter *RateLimiter) BinanceOption {
+	return func(b *Binance) {
+		b.rateLimiter = limiter
 	}
 }
 
 // NewBinance create a new Binance exchange instance
 func NewBinance(ctx context.Context, options ...BinanceOption) (*Binance, error) {
+	// global of the client library, shared by every exchange instance of the process with the same value
 	binance.WebsocketKeepalive = true
-	exchange := &Binance{ctx: ctx}
+	exchange := &Binance{
+		ctx:          ctx,
+		OrderTimeout: defaultOrderTimeout,
+		rateLimiter:  NewRateLimiter(defaultRetryAfter),
+	}
 	for _, option := range options {
 		option(exchange)
 	}
 
 	exchange.client = binance.NewClient(exchange.APIKey, exchange.APISecret)
-	err := exchange.client.NewPingService().Do(ctx)
+	exchange.client.BaseURL = exchange.endpoint().api
+	httpClient, err := newHTTPClient(exchange.httpClient, exchange.proxyURL)
+	if err != nil {
+		return nil, err
+	}
+	exchange.client.HTTPClient = exchange.rateLimiter.Client(httpClient)
+
+	err = exchange.client.NewPingService().Do(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("binance ping fail: %w", err)
 	}
@@ -138,6 +210,74 @@ func NewBinance(ctx context.Context, options ...BinanceOption) (*Binance, error)
 	return exchange, nil
 }
 
+// endpoint returns the endpoints of the instance, according to the testnet flag and custom endpoints
+func (b *Binance) endpoint() binanceEndpoint {
+	endpoint := binanceEndpoint{
+		api:      binance.BaseAPIMainURL,
+		ws:       binance.BaseWsMainURL,
+		combined: binance.BaseCombinedMainURL,
+	}
+	custom := b.mainEndpoint
+
+	if b.Testnet {
+		endpoint = binanceEndpoint{
+			api:      binance.BaseAPITestnetURL,
+			ws:       binance.BaseWsTestnetURL,
+			combined: binance.BaseCombinedTestnetURL,
+		}
+		custom = b.testnetEndpoint
+	}
+
+	if custom.api != "" {
+		endpoint = custom
+	}
+	return endpoint
+}
+
+// newHTTPClient returns the custom client, a client with the proxy, or the default client
+func newHTTPClient(client *http.Client, proxyURL string) (*http.Client, error) {
+	if client != nil {
+		return client, nil
+	}
+
+	if proxyURL == "" {
+		return http.DefaultClient, nil
+	}
+
+	proxy, err := url.Parse(proxyURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy url: %w", err)
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = http.ProxyURL(proxy)
+	return &http.Client{Transport: transport}, nil
+}
+
+// SetNotifier registers a notifier for IP bans by rate limit violations
+func (b *Binance) SetNotifier(notifier service.Notifier) {
+	b.rateLimiter.SetNotifier(notifier)
+}
+
+// wsKlineServe opens a kline websocket with the endpoint and proxy of the instance
+func (b *Binance) wsKlineServe(pair, period string, handler binance.WsKlineHandler,
+	errHandler binance.ErrHandler) (chan struct{}, error) {
+
+	wsEndpointMtx.Lock()
+	defer wsEndpointMtx.Unlock()
+
+	useTestnet, wsMainURL, proxyURL := binance.UseTestnet, binance.BaseWsMainURL, binance.ProxyUrl
+	defer func() {
+		binance.UseTestnet, binance.BaseWsMainURL, binance.ProxyUrl = useTestnet, wsMainURL, proxyURL
+	}()
+
+	binance.UseTestnet = false
+	binance.BaseWsMainURL = b.endpoint().ws
+	binance.ProxyUrl = b.proxyURL
+	done, _, err := binance.WsKlineServe(pair, period, handler, errHandler)
+	return done, err
+}
+
 func (b *Binance) LastQuote(ctx context.Context, pair string) (float64, error) {
 	candles, err := b.CandlesByLimit(ctx, pair, "1m", 1)
 	if err != nil || len(candles) < 1 {
@@ -146,6 +286,56 @@ func (b *Binance) LastQuote(ctx context.Context, pair string) (float64, error) {
 	return candles[0].Close, nil
 }
 
+// BookTicker returns the best bid and ask prices of the order book
+func (b *Binance) BookTicker(ctx context.Context, pair string) (bid, ask float64, err error) {
+	tickers, err := b.client.NewListBookTickersService().Symbol(pair).Do(ctx)
+	if err != nil {
+		return 0, 0, err
+	}
+	if len(tickers) < 1 {
+		return 0, 0, ErrInvalidAsset
+	}
+
+	bid, err = strconv.ParseFloat(tickers[0].BidPrice, 64)
+	if err != nil {
+		return 0, 0, err
+	}
+	ask, err = strconv.ParseFloat(tickers[0].AskPrice, 64)
+	if err != nil {
+		return 0, 0, err
+	}
+	return bid, ask, nil
+}
+
+// EstimateFill returns the average price of a market order of the given size, walking the order book
+func (b *Binance) EstimateFill(ctx context.Context, side model.SideType, pair string, size float64) (float64, error) {
+	depth, err := b.client.NewDepthService().Symbol(pair).Limit(100).Do(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	levels := depth.Asks
+	if side == model.SideTypeSell {
+		levels = depth.Bids
+	}
+
+	var filled, total float64
+	for _, level := range levels {
+		price, quantity, err := level.Parse()
+		if err != nil {
+			return 0, err
+		}
+
+		quantity = math.Min(quantity, size-filled)
+		filled += quantity
+		total += price * quantity
+		if filled >= size {
+			return total / filled, nil
+		}
+	}
+	return 0, fmt.Errorf("order book of %s without depth for %f", pair, size)
+}
+
 func (b *Binance) AssetsInfo(pair string) model.AssetInfo {
 	return b.assetsInfo[pair]
 }
@@ -176,6 +366,9 @@ func (b *Binance) CreateOrderOCO(side model.SideType, pair string,
 		return nil, err
 	}
 
+	ctx, cancel := b.orderContext()
+	defer cancel()
+
 	ocoOrder, err := b.client.NewCreateOCOService().
 		Side(binance.SideType(side)).
 		Quantity(b.formatQuantity(pair, quantity)).
@@ -184,9 +377,9 @@ func (b *Binance) CreateOrderOCO(side model.SideType, pair string,
 		StopLimitPrice(b.formatPrice(pair, stopLimit)).
 		StopLimitTimeInForce(binance.TimeInForceTypeGTC).
 		Symbol(pair).
-		Do(b.ctx)
+		Do(ctx)
 	if err != nil {
-		return nil, err
+		return nil, orderTimeoutError(err, pair, quantity)
 	}
 
 	orders := make([]model.Order, 0, len(ocoOrder.Orders))
@@ -194,16 +387,17 @@ func (b *Binance) CreateOrderOCO(side model.SideType, pair string,
 		price, _ := strconv.ParseFloat(order.Price, 64)
 		quantity, _ := strconv.ParseFloat(order.OrigQuantity, 64)
 		item := model.Order{
-			ExchangeID: order.OrderID,
-			CreatedAt:  time.Unix(0, ocoOrder.TransactionTime*int64(time.Millisecond)),
-			UpdatedAt:  time.Unix(0, ocoOrder.TransactionTime*int64(time.Millisecond)),
-			Pair:       pair,
-			Side:       model.SideType(order.Side),
-			Type:       model.OrderType(order.Type),
-			Status:     model.OrderStatusType(order.Status),
-			Price:      price,
-			Quantity:   quantity,
-			GroupID:    &order.OrderListID,
+			ExchangeID:    order.OrderID,
+			ClientOrderID: order.ClientOrderID,
+			CreatedAt:     time.Unix(0, ocoOrder.TransactionTime*int64(time.Millisecond)),
+			UpdatedAt:     time.Unix(0, ocoOrder.TransactionTime*int64(time.Millisecond)),
+			Pair:          pair,
+			Side:          model.SideType(order.Side),
+			Type:          model.OrderType(order.Type),
+			Status:        model.OrderStatusType(order.Status),
+			Price:         price,
+			Quantity:      quantity,
+			GroupID:       &order.OrderListID,
 		}
 
 		if item.Type == model.OrderTypeStopLossLimit || item.Type == model.OrderTypeStopLoss {
@@ -222,13 +416,12 @@ func (b *Binance) CreateOrderStop(pair string, quantity float64, limit float64)
 		return model.Order{}, err
 	}
 
-	order, err := b.client.NewCreateOrderService().Symbol(pair).
+	order, err := b.submitOrder(pair, quantity, b.client.NewCreateOrderService().Symbol(pair).
 		Type(binance.OrderTypeStopLoss).
 		TimeInForce(binance.TimeInForceTypeGTC).
 		Side(binance.SideTypeSell).
 		Quantity(b.formatQuantity(pair, quantity)).
-		Price(b.formatPrice(pair, limit)).
-		Do(b.ctx)
+		Price(b.formatPrice(pair, limit)))
 	if err != nil {
 		return model.Order{}, err
 	}
@@ -237,18 +430,96 @@ func (b *Binance) CreateOrderStop(pair string, quantity float64, limit float64)
 	quantity, _ = strconv.ParseFloat(order.OrigQuantity, 64)
 
 	return model.Order{
-		ExchangeID: order.OrderID,
-		CreatedAt:  time.Unix(0, order.TransactTime*int64(time.Millisecond)),
-		UpdatedAt:  time.Unix(0, order.TransactTime*int64(time.Millisecond)),
-		Pair:       pair,
-		Side:       model.SideType(order.Side),
-		Type:       model.OrderType(order.Type),
-		Status:     model.OrderStatusType(order.Status),
-		Price:      price,
-		Quantity:   quantity,
+		ExchangeID:    order.OrderID,
+		ClientOrderID: order.ClientOrderID,
+		CreatedAt:     time.Unix(0, order.TransactTime*int64(time.Millisecond)),
+		UpdatedAt:     time.Unix(0, order.TransactTime*int64(time.Millisecond)),
+		Pair:          pair,
+		Side:          model.SideType(order.Side),
+		Type:          model.OrderType(order.Type),
+		Status:        model.OrderStatusType(order.Status),
+		Price:         price,
+		Quantity:      quantity,
 	}, nil
 }
 
+// submitOrder sends an order with a client order id. When the response is lost, the order is looked up
+// by its client order id before a new attempt, so a retry never creates a second order.
+func (b *Binance) submitOrder(pair string, quantity float64,
+	service *binance.CreateOrderService) (*binance.CreateOrderResponse, error) {
+
+	clientID := newClientOrderID()
+	service = service.NewClientOrderID(clientID)
+
+	order, err := b.doOrder(service)
+	for attempt := 0; attempt < b.OrderRetries && isLostResponse(err); attempt++ {
+		existing, found, lookupErr := b.orderByClientID(pair, clientID)
+		if lookupErr != nil {
+			break
+		}
+
+		if found {
+			log.Infof("[ORDER] order %s already registered, skipping retry", clientID)
+			return existing, nil
+		}
+
+		order, err = b.doOrder(service)
+	}
+
+	if err != nil {
+		return nil, orderTimeoutError(err, pair, quantity)
+	}
+
+	return order, nil
+}
+
+func (b *Binance) doOrder(service *binance.CreateOrderService) (*binance.CreateOrderResponse, error) {
+	ctx, cancel := b.orderContext()
+	defer cancel()
+	return service.Do(ctx)
+}
+
+// orderByClientID returns the order registered with the given client order id, if any
+func (b *Binance) orderByClientID(pair, clientID string) (*binance.CreateOrderResponse, bool, error) {
+	ctx, cancel := b.orderContext()
+	defer cancel()
+
+	order, err := b.client.NewGetOrderService().
+		Symbol(pair).
+		OrigClientOrderID(clientID).
+		Do(ctx)
+	if err != nil {
+		var apiErr *common.APIError
+		if errors.As(err, &apiErr) && apiErr.Code == orderNotFoundCode {
+			return nil, false, nil
+		}
+		return nil, false, err
+	}
+
+	return &binance.CreateOrderResponse{
+		Symbol:                   order.Symbol,
+		OrderID:                  order.OrderID,
+		ClientOrderID:            order.ClientOrderID,
+		TransactTime:             order.UpdateTime,
+		Price:                    order.Price,
+		OrigQuantity:             order.OrigQuantity,
+		ExecutedQuantity:         order.ExecutedQuantity,
+		CummulativeQuoteQuantity: order.CummulativeQuoteQuantity,
+		Status:                   order.Status,
+		TimeInForce:              order.TimeInForce,
+		Type:                     order.Type,
+		Side:                     order.Side,
+	}, true, nil
+}
+
+// orderContext returns a context bounded by the order timeout
+func (b *Binance) orderContext() (context.Context, context.CancelFunc) {
+	if b.OrderTimeout <= 0 {
+		return context.WithCancel(b.ctx)
+	}
+	return context.WithTimeout(b.ctx, b.OrderTimeout)
+}
+
 func (b *Binance) formatPrice(pair string, value float64) string {
 	if info, ok := b.assetsInfo[pair]; ok {
 		value = common.AmountToLotSize(info.TickSize, info.QuotePrecision, value)
@@ -271,14 +542,13 @@ func (b *Binance) CreateOrderLimit(side model.SideType, pair string,
 		return model.Order{}, err
 	}
 
-	order, err := b.client.NewCreateOrderService().
+	order, err := b.submitOrder(pair, quantity, b.client.NewCreateOrderService().
 		Symbol(pair).
 		Type(binance.OrderTypeLimit).
 		TimeInForce(binance.TimeInForceTypeGTC).
 		Side(binance.SideType(side)).
 		Quantity(b.formatQuantity(pair, quantity)).
-		Price(b.formatPrice(pair, limit)).
-		Do(b.ctx)
+		Price(b.formatPrice(pair, limit)))
 	if err != nil {
 		return model.Order{}, err
 	}
@@ -294,15 +564,16 @@ func (b *Binance) CreateOrderLimit(side model.SideType, pair string,
 	}
 
 	return model.Order{
-		ExchangeID: order.OrderID,
-		CreatedAt:  time.Unix(0, order.TransactTime*int64(time.Millisecond)),
-		UpdatedAt:  time.Unix(0, order.TransactTime*int64(time.Millisecond)),
-		Pair:       pair,
-		Side:       model.SideType(order.Side),
-		Type:       model.OrderType(order.Type),
-		Status:     model.OrderStatusType(order.Status),
-		Price:      price,
-		Quantity:   quantity,
+		ExchangeID:    order.OrderID,
+		ClientOrderID: order.ClientOrderID,
+		CreatedAt:     time.Unix(0, order.TransactTime*int64(time.Millisecond)),
+		UpdatedAt:     time.Unix(0, order.TransactTime*int64(time.Millisecond)),
+		Pair:          pair,
+		Side:          model.SideType(order.Side),
+		Type:          model.OrderType(order.Type),
+		Status:        model.OrderStatusType(order.Status),
+		Price:         price,
+		Quantity:      quantity,
 	}, nil
 }
 
@@ -312,13 +583,12 @@ func (b *Binance) CreateOrderMarket(side model.SideType, pair string, quantity f
 		return model.Order{}, err
 	}
 
-	order, err := b.client.NewCreateOrderService().
+	order, err := b.submitOrder(pair, quantity, b.client.NewCreateOrderService().
 		Symbol(pair).
 		Type(binance.OrderTypeMarket).
 		Side(binance.SideType(side)).
 		Quantity(b.formatQuantity(pair, quantity)).
-		NewOrderRespType(binance.NewOrderRespTypeFULL).
-		Do(b.ctx)
+		NewOrderRespType(binance.NewOrderRespTypeFULL))
 	if err != nil {
 		return model.Order{}, err
 	}
@@ -334,15 +604,16 @@ func (b *Binance) CreateOrderMarket(side model.SideType, pair string, quantity f
 	}
 
 	return model.Order{
-		ExchangeID: order.OrderID,
-		CreatedAt:  time.Unix(0, order.TransactTime*int64(time.Millisecond)),
-		UpdatedAt:  time.Unix(0, order.TransactTime*int64(time.Millisecond)),
-		Pair:       order.Symbol,
-		Side:       model.SideType(order.Side),
-		Type:       model.OrderType(order.Type),
-		Status:     model.OrderStatusType(order.Status),
-		Price:      cost / quantity,
-		Quantity:   quantity,
+		ExchangeID:    order.OrderID,
+		ClientOrderID: order.ClientOrderID,
+		CreatedAt:     time.Unix(0, order.TransactTime*int64(time.Millisecond)),
+		UpdatedAt:     time.Unix(0, order.TransactTime*int64(time.Millisecond)),
+		Pair:          order.Symbol,
+		Side:          model.SideType(order.Side),
+		Type:          model.OrderType(order.Type),
+		Status:        model.OrderStatusType(order.Status),
+		Price:         cost / quantity,
+		Quantity:      quantity,
 	}, nil
 }
 
@@ -352,13 +623,12 @@ func (b *Binance) CreateOrderMarketQuote(side model.SideType, pair string, quant
 		return model.Order{}, err
 	}
 
-	order, err := b.client.NewCreateOrderService().
+	order, err := b.submitOrder(pair, quantity, b.client.NewCreateOrderService().
 		Symbol(pair).
 		Type(binance.OrderTypeMarket).
 		Side(binance.SideType(side)).
 		QuoteOrderQty(b.formatQuantity(pair, quantity)).
-		NewOrderRespType(binance.NewOrderRespTypeFULL).
-		Do(b.ctx)
+		NewOrderRespType(binance.NewOrderRespTypeFULL))
 	if err != nil {
 		return model.Order{}, err
 	}
@@ -374,24 +644,28 @@ func (b *Binance) CreateOrderMarketQuote(side model.SideType, pair string, quant
 	}
 
 	return model.Order{
-		ExchangeID: order.OrderID,
-		CreatedAt:  time.Unix(0, order.TransactTime*int64(time.Millisecond)),
-		UpdatedAt:  time.Unix(0, order.TransactTime*int64(time.Millisecond)),
-		Pair:       order.Symbol,
-		Side:       model.SideType(order.Side),
-		Type:       model.OrderType(order.Type),
-		Status:     model.OrderStatusType(order.Status),
-		Price:      cost / quantity,
-		Quantity:   quantity,
+		ExchangeID:    order.OrderID,
+		ClientOrderID: order.ClientOrderID,
+		CreatedAt:     time.Unix(0, order.TransactTime*int64(time.Millisecond)),
+		UpdatedAt:     time.Unix(0, order.TransactTime*int64(time.Millisecond)),
+		Pair:          order.Symbol,
+		Side:          model.SideType(order.Side),
+		Type:          model.OrderType(order.Type),
+		Status:        model.OrderStatusType(order.Status),
+		Price:         cost / quantity,
+		Quantity:      quantity,
 	}, nil
 }
 
 func (b *Binance) Cancel(order model.Order) error {
+	ctx, cancel := b.orderContext()
+	defer cancel()
+
 	_, err := b.client.NewCancelOrderService().
 		Symbol(order.Pair).
 		OrderID(order.ExchangeID).
-		Do(b.ctx)
-	return err
+		Do(ctx)
+	return orderTimeoutError(err, order.Pair, order.Quantity)
 }
 
 func (b *Binance) Orders(pair string, limit int) ([]model.Order, error) {
@@ -436,15 +710,16 @@ func newOrder(order *binance.Order) model.Order {
 	}
 
 	return model.Order{
-		ExchangeID: order.OrderID,
-		Pair:       order.Symbol,
-		CreatedAt:  time.Unix(0, order.Time*int64(time.Millisecond)),
-		UpdatedAt:  time.Unix(0, order.UpdateTime*int64(time.Millisecond)),
-		Side:       model.SideType(order.Side),
-		Type:       model.OrderType(order.Type),
-		Status:     model.OrderStatusType(order.Status),
-		Price:      price,
-		Quantity:   quantity,
+		ExchangeID:    order.OrderID,
+		ClientOrderID: order.ClientOrderID,
+		Pair:          order.Symbol,
+		CreatedAt:     time.Unix(0, order.Time*int64(time.Millisecond)),
+		UpdatedAt:     time.Unix(0, order.UpdateTime*int64(time.Millisecond)),
+		Side:          model.SideType(order.Side),
+		Type:          model.OrderType(order.Type),
+		Status:        model.OrderStatusType(order.Status),
+		Price:         price,
+		Quantity:      quantity,
 	}
 }
 
@@ -500,7 +775,7 @@ func (b *Binance) CandlesSubscription(ctx context.Context, pair, period string)
 		}
 
 		for {
-			done, _, err := binance.WsKlineServe(pair, period, func(event *binance.WsKlineEvent) {
+			done, err := b.wsKlineServe(pair, period, func(event *binance.WsKlineEvent) {
 				ba.Reset()
 				candle := CandleFromWsKline(pair, event.Kline)
 
@@ -2,7 +2,9 @@ package exchange
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net/http"
 	"strconv"
 	"strings"
 	"time"
@@ -13,6 +15,7 @@ import (
 	"github.com/jpillora/backoff"
 
 	"github.com/rodrigo-brito/ninjabot/model"
+	"github.com/rodrigo-brito/ninjabot/service"
 	"github.com/rodrigo-brito/ninjabot/tools/log"
 )
 
@@ -38,11 +41,17 @@ type BinanceFuture struct {
 	HeikinAshi bool
 	Testnet    bool
 
-	APIKey    string
-	APISecret string
+	APIKey       string
+	APISecret    string
+	OrderTimeout time.Duration
+	OrderRetries int
 
 	MetadataFetchers []MetadataFetchers
 	PairOptions      []PairOption
+
+	httpClient  *http.Client
+	proxyURL    string
+	rateLimiter *RateLimiter
 }
 
 type BinanceFutureOption func(*BinanceFuture)
@@ -73,16 +82,65 @@ func WithBinanceFutureLeverage(pair string, leverage int, marginType MarginType)
 	}
 }
 
+// WithBinanceFutureOrderRetries sets how many times an order is submitted again after a lost response (default 0).
+// Before a new attempt, the order is looked up by its client order id to prevent duplicates.
+func WithBinanceFutureOrderRetries(retries int) BinanceFutureOption {
+	return func(b *BinanceFuture) {
+		b.OrderRetries = retries
+	}
+}
+
+// WithBinanceFutureOrderTimeout sets the maximum time to wait for the exchange to answer an order request (default 5s)
+func WithBinanceFutureOrderTimeout(timeout time.Duration) BinanceFutureOption {
+	return func(b *BinanceFuture) {
+		b.OrderTimeout = timeout
+	}
+}
+
+// WithBinanceFutureHTTPClient sets the HTTP client of API requests, eg: with custom timeouts or TLS settings
+func WithBinanceFutureHTTPClient(client *http.Client) BinanceFutureOption {
+	return func(b *BinanceFuture) {
+		b.httpClient = client
+	}
+}
+
+// WithBinanceFutureProxy routes API requests and websockets through the given proxy, eg: http://proxy:8080
+// With a custom HTTP client, the proxy is used only by websockets.
+func WithBinanceFutureProxy(proxyURL string) BinanceFutureOption {
+	return func(b *BinanceFuture) {
+		b.proxyURL = proxyURL
+	}
+}
+
+// WithBinanceFutureRateLimiter sets the limiter pausing requests after rate limit responses, eg: shared with
+// a spot exchange. By default, each exchange has its own limiter.
+func WithBinanceFutureRateLimiter(limiter *RateLimiter) BinanceFutureOption {
+	return func(b *BinanceFuture) {
+		b.rateLimiter = limiter
+	}
+}
+
 // NewBinanceFuture will create a new BinanceFuture instance
 func NewBinanceFuture(ctx context.Context, options ...BinanceFutureOption) (*BinanceFuture, error) {
+	// global of the client library, shared by every exchange instance of the process with the same value
 	binance.WebsocketKeepalive = true
-	exchange := &BinanceFuture{ctx: ctx}
+	exchange := &BinanceFuture{
+		ctx:          ctx,
+		OrderTimeout: defaultOrderTimeout,
+		rateLimiter:  NewRateLimiter(defaultRetryAfter),
+	}
 	for _, option := range options {
 		option(exchange)
 	}
 
 	exchange.client = futures.NewClient(exchange.APIKey, exchange.APISecret)
-	err := exchange.client.NewPingService().Do(ctx)
+	httpClient, err := newHTTPClient(exchange.httpClient, exchange.proxyURL)
+	if err != nil {
+		return nil, err
+	}
+	exchange.client.HTTPClient = exchange.rateLimiter.Client(httpClient)
+
+	err = exchange.client.NewPingService().Do(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("binance ping fail: %w", err)
 	}
@@ -179,13 +237,12 @@ func (b *BinanceFuture) CreateOrderStop(pair string, quantity float64, limit flo
 		return model.Order{}, err
 	}
 
-	order, err := b.client.NewCreateOrderService().Symbol(pair).
+	order, err := b.submitOrder(pair, quantity, b.client.NewCreateOrderService().Symbol(pair).
 		Type(futures.OrderTypeStopMarket).
 		TimeInForce(futures.TimeInForceTypeGTC).
 		Side(futures.SideTypeSell).
 		Quantity(b.formatQuantity(pair, quantity)).
-		Price(b.formatPrice(pair, limit)).
-		Do(b.ctx)
+		Price(b.formatPrice(pair, limit)))
 	if err != nil {
 		return model.Order{}, err
 	}
@@ -194,18 +251,96 @@ func (b *BinanceFuture) CreateOrderStop(pair string, quantity float64, limit flo
 	quantity, _ = strconv.ParseFloat(order.OrigQuantity, 64)
 
 	return model.Order{
-		ExchangeID: order.OrderID,
-		CreatedAt:  time.Unix(0, order.UpdateTime*int64(time.Millisecond)),
-		UpdatedAt:  time.Unix(0, order.UpdateTime*int64(time.Millisecond)),
-		Pair:       pair,
-		Side:       model.SideType(order.Side),
-		Type:       model.OrderType(order.Type),
-		Status:     model.OrderStatusType(order.Status),
-		Price:      price,
-		Quantity:   quantity,
+		ExchangeID:    order.OrderID,
+		ClientOrderID: order.ClientOrderID,
+		CreatedAt:     time.Unix(0, order.UpdateTime*int64(time.Millisecond)),
+		UpdatedAt:     time.Unix(0, order.UpdateTime*int64(time.Millisecond)),
+		Pair:          pair,
+		Side:          model.SideType(order.Side),
+		Type:          model.OrderType(order.Type),
+		Status:        model.OrderStatusType(order.Status),
+		Price:         price,
+		Quantity:      quantity,
 	}, nil
 }
 
+// submitOrder sends an order with a client order id. When the response is lost, the order is looked up
+// by its client order id before a new attempt, so a retry never creates a second order.
+func (b *BinanceFuture) submitOrder(pair string, quantity float64,
+	service *futures.CreateOrderService) (*futures.CreateOrderResponse, error) {
+
+	clientID := newClientOrderID()
+	service = service.NewClientOrderID(clientID)
+
+	order, err := b.doOrder(service)
+	for attempt := 0; attempt < b.OrderRetries && isLostResponse(err); attempt++ {
+		existing, found, lookupErr := b.orderByClientID(pair, clientID)
+		if lookupErr != nil {
+			break
+		}
+
+		if found {
+			log.Infof("[ORDER] order %s already registered, skipping retry", clientID)
+			return existing, nil
+		}
+
+		order, err = b.doOrder(service)
+	}
+
+	if err != nil {
+		return nil, orderTimeoutError(err, pair, quantity)
+	}
+
+	return order, nil
+}
+
+func (b *BinanceFuture) doOrder(service *futures.CreateOrderService) (*futures.CreateOrderResponse, error) {
+	ctx, cancel := b.orderContext()
+	defer cancel()
+	return service.Do(ctx)
+}
+
+// orderByClientID returns the order registered with the given client order id, if any
+func (b *BinanceFuture) orderByClientID(pair, clientID string) (*futures.CreateOrderResponse, bool, error) {
+	ctx, cancel := b.orderContext()
+	defer cancel()
+
+	order, err := b.client.NewGetOrderService().
+		Symbol(pair).
+		OrigClientOrderID(clientID).
+		Do(ctx)
+	if err != nil {
+		var apiErr *common.APIError
+		if errors.As(err, &apiErr) && apiErr.Code == orderNotFoundCode {
+			return nil, false, nil
+		}
+		return nil, false, err
+	}
+
+	return &futures.CreateOrderResponse{
+		Symbol:           order.Symbol,
+		OrderID:          order.OrderID,
+		ClientOrderID:    order.ClientOrderID,
+		UpdateTime:       order.UpdateTime,
+		Price:            order.Price,
+		OrigQuantity:     order.OrigQuantity,
+		ExecutedQuantity: order.ExecutedQuantity,
+		CumQuote:         order.CumQuote,
+		Status:           order.Status,
+		TimeInForce:      order.TimeInForce,
+		Type:             order.Type,
+		Side:             order.Side,
+	}, true, nil
+}
+
+// orderContext returns a context bounded by the order timeout
+func (b *BinanceFuture) orderContext() (context.Context, context.CancelFunc) {
+	if b.OrderTimeout <= 0 {
+		return context.WithCancel(b.ctx)
+	}
+	return context.WithTimeout(b.ctx, b.OrderTimeout)
+}
+
 func (b *BinanceFuture) formatPrice(pair string, value float64) string {
 	if info, ok := b.assetsInfo[pair]; ok {
 		value = common.AmountToLotSize(info.TickSize, info.QuotePrecision, value)
@@ -228,14 +363,13 @@ func (b *BinanceFuture) CreateOrderLimit(side model.SideType, pair string,
 		return model.Order{}, err
 	}
 
-	order, err := b.client.NewCreateOrderService().
+	order, err := b.submitOrder(pair, quantity, b.client.NewCreateOrderService().
 		Symbol(pair).
 		Type(futures.OrderTypeLimit).
 		TimeInForce(futures.TimeInForceTypeGTC).
 		Side(futures.SideType(side)).
 		Quantity(b.formatQuantity(pair, quantity)).
-		Price(b.formatPrice(pair, limit)).
-		Do(b.ctx)
+		Price(b.formatPrice(pair, limit)))
 	if err != nil {
 		return model.Order{}, err
 	}
@@ -251,15 +385,16 @@ func (b *BinanceFuture) CreateOrderLimit(side model.SideType, pair string,
 	}
 
 	return model.Order{
-		ExchangeID: order.OrderID,
-		CreatedAt:  time.Unix(0, order.UpdateTime*int64(time.Millisecond)),
-		UpdatedAt:  time.Unix(0, order.UpdateTime*int64(time.Millisecond)),
-		Pair:       pair,
-		Side:       model.SideType(order.Side),
-		Type:       model.OrderType(order.Type),
-		Status:     model.OrderStatusType(order.Status),
-		Price:      price,
-		Quantity:   quantity,
+		ExchangeID:    order.OrderID,
+		ClientOrderID: order.ClientOrderID,
+		CreatedAt:     time.Unix(0, order.UpdateTime*int64(time.Millisecond)),
+		UpdatedAt:     time.Unix(0, order.UpdateTime*int64(time.Millisecond)),
+		Pair:          pair,
+		Side:          model.SideType(order.Side),
+		Type:          model.OrderType(order.Type),
+		Status:        model.OrderStatusType(order.Status),
+		Price:         price,
+		Quantity:      quantity,
 	}, nil
 }
 
@@ -269,13 +404,12 @@ func (b *BinanceFuture) CreateOrderMarket(side model.SideType, pair string, quan
 		return model.Order{}, err
 	}
 
-	order, err := b.client.NewCreateOrderService().
+	order, err := b.submitOrder(pair, quantity, b.client.NewCreateOrderService().
 		Symbol(pair).
 		Type(futures.OrderTypeMarket).
 		Side(futures.SideType(side)).
 		Quantity(b.formatQuantity(pair, quantity)).
-		NewOrderResponseType(futures.NewOrderRespTypeRESULT).
-		Do(b.ctx)
+		NewOrderResponseType(futures.NewOrderRespTypeRESULT))
 	if err != nil {
 		return model.Order{}, err
 	}
@@ -291,15 +425,16 @@ func (b *BinanceFuture) CreateOrderMarket(side model.SideType, pair string, quan
 	}
 
 	return model.Order{
-		ExchangeID: order.OrderID,
-		CreatedAt:  time.Unix(0, order.UpdateTime*int64(time.Millisecond)),
-		UpdatedAt:  time.Unix(0, order.UpdateTime*int64(time.Millisecond)),
-		Pair:       order.Symbol,
-		Side:       model.SideType(order.Side),
-		Type:       model.OrderType(order.Type),
-		Status:     model.OrderStatusType(order.Status),
-		Price:      cost / quantity,
-		Quantity:   quantity,
+		ExchangeID:    order.OrderID,
+		ClientOrderID: order.ClientOrderID,
+		CreatedAt:     time.Unix(0, order.UpdateTime*int64(time.Millisecond)),
+		UpdatedAt:     time.Unix(0, order.UpdateTime*int64(time.Millisecond)),
+		Pair:          order.Symbol,
+		Side:          model.SideType(order.Side),
+		Type:          model.OrderType(order.Type),
+		Status:        model.OrderStatusType(order.Status),
+		Price:         cost / quantity,
+		Quantity:      quantity,
 	}, nil
 }
 
@@ -308,11 +443,14 @@ func (b *BinanceFuture) CreateOrderMarketQuote(_ model.SideType, _ string, _ flo
 }
 
 func (b *BinanceFuture) Cancel(order model.Order) error {
+	ctx, cancel := b.orderContext()
+	defer cancel()
+
 	_, err := b.client.NewCancelOrderService().
 		Symbol(order.Pair).
 		OrderID(order.ExchangeID).
-		Do(b.ctx)
-	return err
+		Do(ctx)
+	return orderTimeoutError(err, order.Pair, order.Quantity)
 }
 
 func (b *BinanceFuture) Orders(pair string, limit int) ([]model.Order, error) {
@@ -362,15 +500,16 @@ func newFutureOrder(order *futures.Order) model.Order {
 	}
 
 	return model.Order{
-		ExchangeID: order.OrderID,
-		Pair:       order.Symbol,
-		CreatedAt:  time.Unix(0, order.Time*int64(time.Millisecond)),
-		UpdatedAt:  time.Unix(0, order.UpdateTime*int64(time.Millisecond)),
-		Side:       model.SideType(order.Side),
-		Type:       model.OrderType(order.Type),
-		Status:     model.OrderStatusType(order.Status),
-		Price:      price,
-		Quantity:   quantity,
+		ExchangeID:    order.OrderID,
+		ClientOrderID: order.ClientOrderID,
+		Pair:          order.Symbol,
+		CreatedAt:     time.Unix(0, order.Time*int64(time.Millisecond)),
+		UpdatedAt:     time.Unix(0, order.UpdateTime*int64(time.Millisecond)),
+		Side:          model.SideType(order.Side),
+		Type:          model.OrderType(order.Type),
+		Status:        model.OrderStatusType(order.Status),
+		Price:         price,
+		Quantity:      quantity,
 	}
 }
 
@@ -442,6 +581,28 @@ func (b *BinanceFuture) Position(pair string) (asset, quote float64, err error)
 	return assetBalance.Free + assetBalance.Lock, quoteBalance.Free + quoteBalance.Lock, nil
 }
 
+// SetNotifier registers a notifier for IP bans by rate limit violations
+func (b *BinanceFuture) SetNotifier(notifier service.Notifier) {
+	b.rateLimiter.SetNotifier(notifier)
+}
+
+// wsKlineServe opens a kline websocket with the proxy of the instance
+func (b *BinanceFuture) wsKlineServe(pair, period string, handler futures.WsKlineHandler,
+	errHandler futures.ErrHandler) (chan struct{}, error) {
+
+	wsEndpointMtx.Lock()
+	defer wsEndpointMtx.Unlock()
+
+	proxyURL := futures.ProxyUrl
+	defer func() {
+		futures.ProxyUrl = proxyURL
+	}()
+
+	futures.ProxyUrl = b.proxyURL
+	done, _, err := futures.WsKlineServe(pair, period, handler, errHandler)
+	return done, err
+}
+
 func (b *BinanceFuture) CandlesSubscription(ctx context.Context, pair, period string) (chan model.Candle, chan error) {
 	ccandle := make(chan model.Candle)
 	cerr := make(chan error)
@@ -454,7 +615,7 @@ func (b *BinanceFuture) CandlesSubscription(ctx context.Context, pair, period st
 		}
 
 		for {
-			done, _, err := futures.WsKlineServe(pair, period, func(event *futures.WsKlineEvent) {
+			done, err := b.wsKlineServe(pair, period, func(event *futures.WsKlineEvent) {
 				ba.Reset()
 				candle := FutureCandleFromWsKline(pair, event.Kline)
 
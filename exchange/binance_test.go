@@ -1,9 +1,17 @@
 package exchange
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	"github.com/adshao/go-binance/v2"
 	"github.com/stretchr/testify/require"
 
 	"github.com/rodrigo-brito/ninjabot/model"
@@ -51,3 +59,241 @@ func TestFormatQuantity(t *testing.T) {
 		})
 	}
 }
+
+func TestBinance_OrderTimeout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(time.Second):
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	client := binance.NewClient("", "")
+	client.BaseURL = server.URL
+
+	exchange := &Binance{
+		ctx:          context.Background(),
+		client:       client,
+		OrderTimeout: 50 * time.Millisecond,
+		assetsInfo: map[string]model.AssetInfo{
+			"BTCUSDT": {MaxQuantity: 100, StepSize: 0.00001, BaseAssetPrecision: 5},
+		},
+	}
+
+	start := time.Now()
+	_, err := exchange.CreateOrderMarket(model.SideTypeBuy, "BTCUSDT", 1)
+	require.Less(t, time.Since(start), time.Second)
+	require.ErrorIs(t, err, ErrOrderTimeout)
+
+	var orderErr *OrderError
+	require.True(t, errors.As(err, &orderErr))
+	require.Equal(t, "BTCUSDT", orderErr.Pair)
+	require.Equal(t, 1.0, orderErr.Quantity)
+
+	err = exchange.Cancel(model.Order{Pair: "BTCUSDT", ExchangeID: 1})
+	require.ErrorIs(t, err, ErrOrderTimeout)
+}
+
+func TestBinance_OrderRetry(t *testing.T) {
+	var submitted atomic.Int32
+	var clientID atomic.Value
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = r.ParseForm()
+		switch r.Method {
+		case http.MethodPost:
+			// order is registered by the exchange, but the response is lost
+			submitted.Add(1)
+			clientID.Store(r.Form.Get("newClientOrderId"))
+			<-r.Context().Done()
+		case http.MethodGet:
+			if r.Form.Get("origClientOrderId") != clientID.Load() {
+				w.WriteHeader(http.StatusBadRequest)
+				fmt.Fprint(w, `{"code":-2013,"msg":"Order does not exist."}`)
+				return
+			}
+			fmt.Fprintf(w, `{"symbol":"BTCUSDT","orderId":42,"clientOrderId":%q,"price":"0",`+
+				`"origQty":"1","executedQty":"1","cummulativeQuoteQty":"100","status":"FILLED",`+
+				`"type":"MARKET","side":"BUY"}`, clientID.Load())
+		}
+	}))
+	defer server.Close()
+
+	client := binance.NewClient("", "")
+	client.BaseURL = server.URL
+
+	exchange := &Binance{
+		ctx:          context.Background(),
+		client:       client,
+		OrderTimeout: 50 * time.Millisecond,
+		OrderRetries: 2,
+		assetsInfo: map[string]model.AssetInfo{
+			"BTCUSDT": {MaxQuantity: 100, StepSize: 0.00001, BaseAssetPrecision: 5},
+		},
+	}
+
+	order, err := exchange.CreateOrderMarket(model.SideTypeBuy, "BTCUSDT", 1)
+	require.NoError(t, err)
+	require.Equal(t, int32(1), submitted.Load())
+	require.Equal(t, int64(42), order.ExchangeID)
+	require.Equal(t, 100.0, order.Price)
+	require.NotEmpty(t, order.ClientOrderID)
+	require.Equal(t, clientID.Load(), order.ClientOrderID)
+}
+
+func TestBinance_Proxy(t *testing.T) {
+	var hosts []string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hosts = append(hosts, r.Host)
+		switch r.URL.Path {
+		case "/api/v3/exchangeInfo":
+			fmt.Fprint(w, `{"symbols":[]}`)
+		default:
+			fmt.Fprint(w, `{}`)
+		}
+	}))
+	defer proxy.Close()
+
+	_, err := NewBinance(context.Background(),
+		WithCustomMainAPIEndpoint("http://binance.test", "ws://binance.test/ws", "ws://binance.test/stream?streams="),
+		WithBinanceProxy(proxy.URL),
+	)
+	require.NoError(t, err)
+	require.Equal(t, []string{"binance.test", "binance.test"}, hosts)
+
+	t.Run("invalid proxy", func(t *testing.T) {
+		_, err := NewBinance(context.Background(), WithBinanceProxy("://invalid"))
+		require.Error(t, err)
+	})
+
+	t.Run("custom client", func(t *testing.T) {
+		hosts = nil
+		proxyURL, err := url.Parse(proxy.URL)
+		require.NoError(t, err)
+		client := &http.Client{Transport: &http.Transport{Proxy: http.ProxyURL(proxyURL)}}
+
+		_, err = NewBinance(context.Background(),
+			WithCustomMainAPIEndpoint("http://binance.test", "ws://binance.test/ws", "ws://binance.test/stream?streams="),
+			WithBinanceHTTPClient(client),
+		)
+		require.NoError(t, err)
+		require.Len(t, hosts, 2)
+	})
+}
+
+func TestBinance_EstimateFill(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"lastUpdateId":1,"bids":[["99","1"],["98","2"]],"asks":[["101","1"],["103","1"]]}`)
+	}))
+	defer server.Close()
+
+	client := binance.NewClient("", "")
+	client.BaseURL = server.URL
+	exchange := &Binance{ctx: context.Background(), client: client}
+
+	price, err := exchange.EstimateFill(context.Background(), model.SideTypeBuy, "BTCUSDT", 2)
+	require.NoError(t, err)
+	require.Equal(t, 102.0, price)
+
+	price, err = exchange.EstimateFill(context.Background(), model.SideTypeSell, "BTCUSDT", 2)
+	require.NoError(t, err)
+	require.Equal(t, 98.5, price)
+
+	_, err = exchange.EstimateFill(context.Background(), model.SideTypeBuy, "BTCUSDT", 3)
+	require.Error(t, err)
+}
+
+type errorNotifier struct {
+	errors []error
+}
+
+func (n *errorNotifier) Notify(string)       {}
+func (n *errorNotifier) OnOrder(model.Order) {}
+func (n *errorNotifier) OnError(err error)   { n.errors = append(n.errors, err) }
+
+func TestBinance_RateLimit(t *testing.T) {
+	var (
+		requests []time.Time
+		status   = http.StatusTooManyRequests
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, time.Now())
+		if len(requests) == 1 {
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(status)
+			fmt.Fprint(w, `{"code":-1003,"msg":"Too many requests"}`)
+			return
+		}
+		fmt.Fprint(w, `[{"symbol":"BTCUSDT","bidPrice":"99","bidQty":"1","askPrice":"101","askQty":"1"}]`)
+	}))
+	defer server.Close()
+
+	setup := func() (*Binance, *errorNotifier) {
+		requests = nil
+		notifier := &errorNotifier{}
+		limiter := NewRateLimiter(defaultRetryAfter)
+		limiter.SetNotifier(notifier)
+
+		client := binance.NewClient("", "")
+		client.BaseURL = server.URL
+		client.HTTPClient = limiter.Client(http.DefaultClient)
+		return &Binance{ctx: context.Background(), client: client, rateLimiter: limiter}, notifier
+	}
+
+	t.Run("too many requests", func(t *testing.T) {
+		exchange, notifier := setup()
+		_, _, err := exchange.BookTicker(context.Background(), "BTCUSDT")
+		require.Error(t, err)
+		require.WithinDuration(t, time.Now().Add(time.Second), exchange.rateLimiter.Until(), 100*time.Millisecond)
+
+		// a canceled caller gives up without a request
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		_, _, err = exchange.BookTicker(ctx, "BTCUSDT")
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+		require.Len(t, requests, 1)
+
+		bid, ask, err := exchange.BookTicker(context.Background(), "BTCUSDT")
+		require.NoError(t, err)
+		require.Equal(t, 99.0, bid)
+		require.Equal(t, 101.0, ask)
+		require.Len(t, requests, 2)
+		require.GreaterOrEqual(t, requests[1].Sub(requests[0]), 900*time.Millisecond)
+		require.Empty(t, notifier.errors)
+	})
+
+	t.Run("ip banned", func(t *testing.T) {
+		status = http.StatusTeapot
+		exchange, notifier := setup()
+		_, _, err := exchange.BookTicker(context.Background(), "BTCUSDT")
+		require.Error(t, err)
+		require.Len(t, notifier.errors, 1)
+		require.Contains(t, notifier.errors[0].Error(), "IP banned")
+	})
+
+	t.Run("long pause", func(t *testing.T) {
+		status = http.StatusTeapot
+		exchange, _ := setup()
+		exchange.rateLimiter.maxWait = 100 * time.Millisecond
+		_, _, err := exchange.BookTicker(context.Background(), "BTCUSDT")
+		require.Error(t, err)
+
+		// the pause is longer than the wait, callers fail fast with the retry time
+		_, _, err = exchange.BookTicker(context.Background(), "BTCUSDT")
+		require.ErrorIs(t, err, ErrRateLimited)
+		var rateLimitErr *RateLimitError
+		require.ErrorAs(t, err, &rateLimitErr)
+		require.Equal(t, exchange.rateLimiter.Until(), rateLimitErr.Until)
+		require.Len(t, requests, 1)
+	})
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	require.Equal(t, 30*time.Second, parseRetryAfter("30", time.Minute))
+	require.Equal(t, time.Minute, parseRetryAfter("", time.Minute))
+	require.Equal(t, time.Minute, parseRetryAfter("invalid", time.Minute))
+	require.InDelta(t, float64(time.Hour), float64(parseRetryAfter(time.Now().Add(time.Hour).UTC().Format(http.TimeFormat),
+		time.Minute)), float64(time.Second))
+}
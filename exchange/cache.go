@@ -0,0 +1,118 @@
+package exchange
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/rodrigo-brito/ninjabot/model"
+)
+
+// DatasetCache keeps parsed and resampled CSV candles in memory, so repeated backtests
+// (eg: optimization runs) read each file only once. Cached slices are shared among feeds
+// and must be handled as read-only. It is safe for concurrent use.
+type DatasetCache struct {
+	mtx     sync.Mutex
+	entries map[string]*datasetEntry
+}
+
+type datasetEntry struct {
+	once   sync.Once
+	source []model.Candle
+	target []model.Candle
+	err    error
+}
+
+func NewDatasetCache() *DatasetCache {
+	return &DatasetCache{
+		entries: make(map[string]*datasetEntry),
+	}
+}
+
+// NewCSVFeed creates a CSV feed with candles between start and end, loading each file once.
+// Zero start or end keeps the full range of the file.
+func (d *DatasetCache) NewCSVFeed(targetTimeframe string, start, end time.Time,
+	feeds ...PairFeed) (*CSVFeed, error) {
+
+	csvFeed := &CSVFeed{
+		Feeds:               make(map[string]PairFeed),
+		CandlePairTimeFrame: make(map[string][]model.Candle),
+	}
+
+	for _, feed := range feeds {
+		entry := d.entry(feed, targetTimeframe, start, end)
+		entry.once.Do(func() {
+			entry.source, entry.target, entry.err = loadDataset(feed, targetTimeframe, start, end)
+		})
+
+		if entry.err != nil {
+			d.remove(feed, targetTimeframe, start, end)
+			return nil, entry.err
+		}
+
+		csvFeed.Feeds[feed.Pair] = feed
+		csvFeed.CandlePairTimeFrame[csvFeed.feedTimeframeKey(feed.Pair, feed.Timeframe)] = entry.source
+		csvFeed.CandlePairTimeFrame[csvFeed.feedTimeframeKey(feed.Pair, targetTimeframe)] = entry.target
+	}
+
+	return csvFeed, nil
+}
+
+// Len returns the number of cached datasets
+func (d *DatasetCache) Len() int {
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+	return len(d.entries)
+}
+
+func (d *DatasetCache) key(feed PairFeed, targetTimeframe string, start, end time.Time) string {
+	return fmt.Sprintf("%s|%s|%s|%s|%t|%s|%d|%d", feed.File, feed.Pair, feed.Timeframe, targetTimeframe,
+		feed.HeikinAshi, feed.Offset, start.Unix(), end.Unix())
+}
+
+func (d *DatasetCache) entry(feed PairFeed, targetTimeframe string, start, end time.Time) *datasetEntry {
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+
+	key := d.key(feed, targetTimeframe, start, end)
+	entry, ok := d.entries[key]
+	if !ok {
+		entry = &datasetEntry{}
+		d.entries[key] = entry
+	}
+	return entry
+}
+
+func (d *DatasetCache) remove(feed PairFeed, targetTimeframe string, start, end time.Time) {
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+	delete(d.entries, d.key(feed, targetTimeframe, start, end))
+}
+
+func loadDataset(feed PairFeed, targetTimeframe string, start, end time.Time) ([]model.Candle, []model.Candle, error) {
+	candles, err := readCSV(feed)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	filtered := make([]model.Candle, 0, len(candles))
+	for _, candle := range candles {
+		if (!start.IsZero() && candle.Time.Before(start)) || (!end.IsZero() && candle.Time.After(end)) {
+			continue
+		}
+		filtered = append(filtered, candle)
+	}
+
+	if len(filtered) == 0 {
+		return nil, nil, fmt.Errorf("%w: %s", ErrInsufficientData, feed.Pair)
+	}
+
+	csvFeed := &CSVFeed{CandlePairTimeFrame: make(map[string][]model.Candle)}
+	sourceKey := csvFeed.feedTimeframeKey(feed.Pair, feed.Timeframe)
+	csvFeed.CandlePairTimeFrame[sourceKey] = filtered
+	if err := csvFeed.resample(feed.Pair, feed.Timeframe, targetTimeframe, feed.Offset); err != nil {
+		return nil, nil, err
+	}
+
+	return filtered, csvFeed.CandlePairTimeFrame[csvFeed.feedTimeframeKey(feed.Pair, targetTimeframe)], nil
+}
@@ -0,0 +1,93 @@
+package exchange
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/rodrigo-brito/ninjabot/model"
+)
+
+var cacheFeed = PairFeed{
+	Pair:      "BTCUSDT",
+	File:      "../testdata/btc-1h.csv",
+	Timeframe: "1h",
+}
+
+func TestDatasetCache(t *testing.T) {
+	cache := NewDatasetCache()
+
+	expected, err := NewCSVFeed("1d", cacheFeed)
+	require.NoError(t, err)
+
+	var wg sync.WaitGroup
+	feeds := make([]*CSVFeed, 10)
+	for i := range feeds {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			feed, err := cache.NewCSVFeed("1d", time.Time{}, time.Time{}, cacheFeed)
+			require.NoError(t, err)
+			feeds[i] = feed
+		}(i)
+	}
+	wg.Wait()
+
+	require.Equal(t, 1, cache.Len())
+	for _, feed := range feeds {
+		require.Equal(t, expected.CandlePairTimeFrame, feed.CandlePairTimeFrame)
+	}
+
+	// consuming a feed does not change the cached dataset
+	_, err = feeds[0].CandlesByLimit(context.Background(), "BTCUSDT", "1d", 5)
+	require.NoError(t, err)
+	require.Equal(t, expected.CandlePairTimeFrame["BTCUSDT--1d"], feeds[1].CandlePairTimeFrame["BTCUSDT--1d"])
+
+	t.Run("range", func(t *testing.T) {
+		start := expected.CandlePairTimeFrame["BTCUSDT--1d"][0].Time.Add(24 * time.Hour)
+		end := start.Add(3*24*time.Hour + 23*time.Hour)
+
+		var candles []model.Candle
+		for _, candle := range expected.CandlePairTimeFrame["BTCUSDT--1d"] {
+			if !candle.Time.Before(start) && !candle.Time.After(end) {
+				candles = append(candles, candle)
+			}
+		}
+
+		feed, err := cache.NewCSVFeed("1d", start, end, cacheFeed)
+		require.NoError(t, err)
+		require.Equal(t, 2, cache.Len())
+		require.Equal(t, candles, feed.CandlePairTimeFrame["BTCUSDT--1d"])
+		require.Equal(t, start, feed.CandlePairTimeFrame["BTCUSDT--1h"][0].Time)
+	})
+
+	t.Run("invalid file", func(t *testing.T) {
+		_, err := cache.NewCSVFeed("1d", time.Time{}, time.Time{}, PairFeed{
+			Pair:      "BTCUSDT",
+			File:      "invalid.csv",
+			Timeframe: "1h",
+		})
+		require.Error(t, err)
+		require.Equal(t, 2, cache.Len())
+	})
+}
+
+func BenchmarkCSVFeed(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		if _, err := NewCSVFeed("1d", cacheFeed); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkDatasetCache(b *testing.B) {
+	cache := NewDatasetCache()
+	for i := 0; i < b.N; i++ {
+		if _, err := cache.NewCSVFeed("1d", time.Time{}, time.Time{}, cacheFeed); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -23,6 +23,9 @@ type PairFeed struct {
 	File       string
 	Timeframe  string
 	HeikinAshi bool
+	// Offset shifts the boundaries of candles resampled from the file, eg: 17h for daily candles rolling at
+	// 17:00 UTC. Candles of the exchange, in the warmup or live trading, keep the exchange boundaries.
+	Offset time.Duration
 }
 
 type CSVFeed struct {
@@ -74,89 +77,100 @@ func NewCSVFeed(targetTimeframe string, feeds ...PairFeed) (*CSVFeed, error) {
 	for _, feed := range feeds {
 		csvFeed.Feeds[feed.Pair] = feed
 
-		csvFile, err := os.Open(feed.File)
+		candles, err := readCSV(feed)
 		if err != nil {
 			return nil, err
 		}
 
-		csvLines, err := csv.NewReader(csvFile).ReadAll()
+		csvFeed.CandlePairTimeFrame[csvFeed.feedTimeframeKey(feed.Pair, feed.Timeframe)] = candles
+
+		err = csvFeed.resample(feed.Pair, feed.Timeframe, targetTimeframe, feed.Offset)
 		if err != nil {
 			return nil, err
 		}
+	}
 
-		var candles []model.Candle
-		ha := model.NewHeikinAshi()
-
-		// map each header label with its index
-		headerMap, additionalHeaders, hasCustomHeaders := parseHeaders(csvLines[0])
-		if hasCustomHeaders {
-			csvLines = csvLines[1:]
-		}
-
-		for _, line := range csvLines {
-			timestamp, err := strconv.Atoi(line[headerMap["time"]])
-			if err != nil {
-				return nil, err
-			}
+	return csvFeed, nil
+}
 
-			candle := model.Candle{
-				Time:      time.Unix(int64(timestamp), 0).UTC(),
-				UpdatedAt: time.Unix(int64(timestamp), 0).UTC(),
-				Pair:      feed.Pair,
-				Complete:  true,
-			}
+// readCSV parses the candles of a CSV file
+func readCSV(feed PairFeed) ([]model.Candle, error) {
+	csvFile, err := os.Open(feed.File)
+	if err != nil {
+		return nil, err
+	}
+	defer csvFile.Close()
 
-			candle.Open, err = strconv.ParseFloat(line[headerMap["open"]], 64)
-			if err != nil {
-				return nil, err
-			}
+	csvLines, err := csv.NewReader(csvFile).ReadAll()
+	if err != nil {
+		return nil, err
+	}
 
-			candle.Close, err = strconv.ParseFloat(line[headerMap["close"]], 64)
-			if err != nil {
-				return nil, err
-			}
+	var candles []model.Candle
+	ha := model.NewHeikinAshi()
 
-			candle.Low, err = strconv.ParseFloat(line[headerMap["low"]], 64)
-			if err != nil {
-				return nil, err
-			}
+	// map each header label with its index
+	headerMap, additionalHeaders, hasCustomHeaders := parseHeaders(csvLines[0])
+	if hasCustomHeaders {
+		csvLines = csvLines[1:]
+	}
 
-			candle.High, err = strconv.ParseFloat(line[headerMap["high"]], 64)
-			if err != nil {
-				return nil, err
-			}
+	for _, line := range csvLines {
+		timestamp, err := strconv.Atoi(line[headerMap["time"]])
+		if err != nil {
+			return nil, err
+		}
 
-			candle.Volume, err = strconv.ParseFloat(line[headerMap["volume"]], 64)
-			if err != nil {
-				return nil, err
-			}
+		candle := model.Candle{
+			Time:      time.Unix(int64(timestamp), 0).UTC(),
+			UpdatedAt: time.Unix(int64(timestamp), 0).UTC(),
+			Pair:      feed.Pair,
+			Complete:  true,
+		}
 
-			if hasCustomHeaders {
-				candle.Metadata = make(map[string]float64)
-				for _, header := range additionalHeaders {
-					candle.Metadata[header], err = strconv.ParseFloat(line[headerMap[header]], 64)
-					if err != nil {
-						return nil, err
-					}
-				}
-			}
+		candle.Open, err = strconv.ParseFloat(line[headerMap["open"]], 64)
+		if err != nil {
+			return nil, err
+		}
 
-			if feed.HeikinAshi {
-				candle = candle.ToHeikinAshi(ha)
-			}
+		candle.Close, err = strconv.ParseFloat(line[headerMap["close"]], 64)
+		if err != nil {
+			return nil, err
+		}
 
-			candles = append(candles, candle)
+		candle.Low, err = strconv.ParseFloat(line[headerMap["low"]], 64)
+		if err != nil {
+			return nil, err
 		}
 
-		csvFeed.CandlePairTimeFrame[csvFeed.feedTimeframeKey(feed.Pair, feed.Timeframe)] = candles
+		candle.High, err = strconv.ParseFloat(line[headerMap["high"]], 64)
+		if err != nil {
+			return nil, err
+		}
 
-		err = csvFeed.resample(feed.Pair, feed.Timeframe, targetTimeframe)
+		candle.Volume, err = strconv.ParseFloat(line[headerMap["volume"]], 64)
 		if err != nil {
 			return nil, err
 		}
+
+		if hasCustomHeaders {
+			candle.Metadata = make(map[string]float64)
+			for _, header := range additionalHeaders {
+				candle.Metadata[header], err = strconv.ParseFloat(line[headerMap[header]], 64)
+				if err != nil {
+					return nil, err
+				}
+			}
+		}
+
+		if feed.HeikinAshi {
+			candle = candle.ToHeikinAshi(ha)
+		}
+
+		candles = append(candles, candle)
 	}
 
-	return csvFeed, nil
+	return candles, nil
 }
 
 func (c CSVFeed) feedTimeframeKey(pair, timeframe string) string {
@@ -228,13 +242,14 @@ func isLastCandlePeriod(t time.Time, fromTimeframe, targetTimeframe string) (boo
 	return false, fmt.Errorf("invalid timeframe: %s", targetTimeframe)
 }
 
-func (c *CSVFeed) resample(pair, sourceTimeframe, targetTimeframe string) error {
+// resample aggregates the source candles in the target timeframe, with boundaries shifted by offset
+func (c *CSVFeed) resample(pair, sourceTimeframe, targetTimeframe string, offset time.Duration) error {
 	sourceKey := c.feedTimeframeKey(pair, sourceTimeframe)
 	targetKey := c.feedTimeframeKey(pair, targetTimeframe)
 
 	var i int
 	for ; i < len(c.CandlePairTimeFrame[sourceKey]); i++ {
-		if ok, err := isFistCandlePeriod(c.CandlePairTimeFrame[sourceKey][i].Time, sourceTimeframe,
+		if ok, err := isFistCandlePeriod(c.CandlePairTimeFrame[sourceKey][i].Time.Add(-offset), sourceTimeframe,
 			targetTimeframe); err != nil {
 			return err
 		} else if ok {
@@ -245,7 +260,7 @@ func (c *CSVFeed) resample(pair, sourceTimeframe, targetTimeframe string) error
 	candles := make([]model.Candle, 0)
 	for ; i < len(c.CandlePairTimeFrame[sourceKey]); i++ {
 		candle := c.CandlePairTimeFrame[sourceKey][i]
-		if last, err := isLastCandlePeriod(candle.Time, sourceTimeframe, targetTimeframe); err != nil {
+		if last, err := isLastCandlePeriod(candle.Time.Add(-offset), sourceTimeframe, targetTimeframe); err != nil {
 			return err
 		} else if last {
 			candle.Complete = true
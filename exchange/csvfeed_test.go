@@ -8,6 +8,8 @@ import (
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+
+	"github.com/rodrigo-brito/ninjabot/model"
 )
 
 func TestNewCSVFeed(t *testing.T) {
@@ -116,6 +118,39 @@ func TestCSVFeed_resample(t *testing.T) {
 		require.Equal(t, 180, totalComplete)
 	})
 
+	t.Run("5m to 1h with offset", func(t *testing.T) {
+		start := time.Date(2021, 5, 13, 0, 0, 0, 0, time.UTC)
+		source := make([]model.Candle, 0)
+		for i := 0; i < 36; i++ {
+			source = append(source, model.Candle{
+				Pair:   "BTCUSDT",
+				Time:   start.Add(time.Duration(i) * 5 * time.Minute),
+				Open:   float64(i),
+				Close:  float64(i + 1),
+				High:   float64(i + 1),
+				Low:    float64(i),
+				Volume: 1,
+			})
+		}
+
+		feed := &CSVFeed{CandlePairTimeFrame: map[string][]model.Candle{"BTCUSDT--5m": source}}
+		require.NoError(t, feed.resample("BTCUSDT", "5m", "1h", 15*time.Minute))
+
+		// hourly candles from 00:15 to 01:15, the last hour is not complete
+		var complete []model.Candle
+		for _, candle := range feed.CandlePairTimeFrame["BTCUSDT--1h"] {
+			if candle.Complete {
+				complete = append(complete, candle)
+			}
+		}
+		require.Len(t, complete, 2)
+		require.Equal(t, start.Add(15*time.Minute), complete[0].Time)
+		require.Equal(t, start.Add(75*time.Minute), complete[1].Time)
+		require.Equal(t, 3.0, complete[0].Open)
+		require.Equal(t, 15.0, complete[0].Close)
+		require.Equal(t, 12.0, complete[0].Volume)
+	})
+
 	t.Run("invalid timeframe", func(t *testing.T) {
 		feed, err := NewCSVFeed(
 			"1d",
@@ -4,10 +4,16 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/StudioSol/set"
+	"github.com/adshao/go-binance/v2/common"
+	"github.com/xhit/go-str2duration/v2"
 
 	"github.com/rodrigo-brito/ninjabot/model"
 	"github.com/rodrigo-brito/ninjabot/service"
@@ -18,18 +24,60 @@ var (
 	ErrInvalidQuantity   = errors.New("invalid quantity")
 	ErrInsufficientFunds = errors.New("insufficient funds or locked")
 	ErrInvalidAsset      = errors.New("invalid asset")
+	ErrOrderTimeout      = errors.New("order submission timeout")
+	ErrRateLimited       = errors.New("rate limited")
 )
 
+// defaultOrderTimeout is the maximum time to wait for the exchange to answer an order request
+const defaultOrderTimeout = 5 * time.Second
+
+// orderNotFoundCode is the Binance API error code for unknown orders
+const orderNotFoundCode = -2013
+
 type DataFeed struct {
 	Data chan model.Candle
 	Err  chan error
 }
 
 type DataFeedSubscription struct {
+	sync.Mutex
 	exchange                service.Exchange
 	Feeds                   *set.LinkedHashSetString
 	DataFeeds               map[string]*DataFeed
 	SubscriptionsByDataFeed map[string][]Subscription
+	status                  map[string]*FeedStatus
+}
+
+// FeedStatus is a snapshot of the health of a pair/timeframe candle feed
+type FeedStatus struct {
+	Pair       string
+	Timeframe  string
+	Connected  bool      // false after an error or the end of the feed, until the next message
+	LastCandle time.Time // open time of the last closed candle
+	LastUpdate time.Time // wall clock of the last message, including partial candles
+}
+
+// Age returns how long ago the last closed candle was opened
+func (f FeedStatus) Age() time.Duration {
+	if f.LastCandle.IsZero() {
+		return 0
+	}
+	return time.Since(f.LastCandle)
+}
+
+// Stale returns true when no closed candle was received in the expected interval for the timeframe.
+// A closed candle is opened between one and two timeframes ago, older candles indicate a stalled feed.
+func (f FeedStatus) Stale() bool {
+	if f.LastCandle.IsZero() {
+		return true
+	}
+
+	duration, err := str2duration.ParseDuration(f.Timeframe)
+	if err != nil {
+		return false
+	}
+
+	return f.Age() > 2*duration
 }
 
 type Subscription struct {
@@ -47,6 +95,53 @@ func (o *OrderError) Error() string {
 	return fmt.Sprintf("order error: %v", o.Err)
 }
 
+func (o *OrderError) Unwrap() error {
+	return o.Err
+}
+
+// orderTimeoutError converts a deadline exceeded error into an OrderError with ErrOrderTimeout
+func orderTimeoutError(err error, pair string, quantity float64) error {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return &OrderError{
+			Err:      ErrOrderTimeout,
+			Pair:     pair,
+			Quantity: quantity,
+		}
+	}
+	return err
+}
+
+// clientOrderPrefix identifies orders of this process, keeping ids unique across restarts
+var (
+	clientOrderPrefix = "nb" + strconv.FormatInt(time.Now().UnixMilli(), 36)
+	clientOrderSeq    atomic.Int64
+)
+
+// newClientOrderID returns the client order id of a new logical order, reused among its retries
+func newClientOrderID() string {
+	return fmt.Sprintf("%s-%d", clientOrderPrefix, clientOrderSeq.Add(1))
+}
+
+// IsClientOrderID returns true when the client order id was created by this process, orders placed manually
+// or by other processes don't match it
+func IsClientOrderID(id string) bool {
+	return strings.HasPrefix(id, clientOrderPrefix+"-")
+}
+
+// AmountToLotSize rounds down the quantity to the step size of the pair, quantities of pairs without step
+// size are kept
+func AmountToLotSize(info model.AssetInfo, quantity float64) float64 {
+	if info.StepSize <= 0 {
+		return quantity
+	}
+	return common.AmountToLotSize(info.StepSize, info.BaseAssetPrecision, quantity)
+}
+
+// isLostResponse returns true when the order may have been registered by the exchange without response
+func isLostResponse(err error) bool {
+	return errors.Is(err, context.DeadlineExceeded) || errors.Is(err, ErrOrderTimeout)
+}
+
 type DataFeedConsumer func(model.Candle)
 
 func NewDataFeed(exchange service.Exchange) *DataFeedSubscription {
@@ -55,6 +150,7 @@ func NewDataFeed(exchange service.Exchange) *DataFeedSubscription {
 		Feeds:                   set.NewLinkedHashSetString(),
 		DataFeeds:               make(map[string]*DataFeed),
 		SubscriptionsByDataFeed: make(map[string][]Subscription),
+		status:                  make(map[string]*FeedStatus),
 	}
 }
 
@@ -70,6 +166,13 @@ func (d *DataFeedSubscription) pairTimeframeFromKey(key string) (pair, timeframe
 func (d *DataFeedSubscription) Subscribe(pair, timeframe string, consumer DataFeedConsumer, onCandleClose bool) {
 	key := d.feedKey(pair, timeframe)
 	d.Feeds.Add(key)
+
+	d.Lock()
+	if _, ok := d.status[key]; !ok {
+		d.status[key] = &FeedStatus{Pair: pair, Timeframe: timeframe}
+	}
+	d.Unlock()
+
 	d.SubscriptionsByDataFeed[key] = append(d.SubscriptionsByDataFeed[key], Subscription{
 		onCandleClose: onCandleClose,
 		consumer:      consumer,
@@ -90,6 +193,33 @@ func (d *DataFeedSubscription) Preload(pair, timeframe string, candles []model.C
 	}
 }
 
+// Status returns the current state of each subscribed feed, sorted by pair and timeframe
+func (d *DataFeedSubscription) Status() []FeedStatus {
+	d.Lock()
+	defer d.Unlock()
+
+	keys := make([]string, 0, len(d.status))
+	for key := range d.status {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	result := make([]FeedStatus, 0, len(keys))
+	for _, key := range keys {
+		result = append(result, *d.status[key])
+	}
+	return result
+}
+
+func (d *DataFeedSubscription) updateStatus(key string, update func(status *FeedStatus)) {
+	d.Lock()
+	defer d.Unlock()
+
+	if status, ok := d.status[key]; ok {
+		update(status)
+	}
+}
+
 func (d *DataFeedSubscription) Connect() {
 	log.Infof("Connecting to the exchange.")
 	for feed := range d.Feeds.Iter() {
@@ -107,14 +237,27 @@ func (d *DataFeedSubscription) Start(loadSync bool) {
 	wg := new(sync.WaitGroup)
 	for key, feed := range d.DataFeeds {
 		wg.Add(1)
+		d.updateStatus(key, func(status *FeedStatus) {
+			status.Connected = true
+		})
 		go func(key string, feed *DataFeed) {
 			for {
 				select {
 				case candle, ok := <-feed.Data:
 					if !ok {
+						d.updateStatus(key, func(status *FeedStatus) {
+							status.Connected = false
+						})
 						wg.Done()
 						return
 					}
+					d.updateStatus(key, func(status *FeedStatus) {
+						status.Connected = true
+						status.LastUpdate = time.Now()
+						if candle.Complete {
+							status.LastCandle = candle.Time
+						}
+					})
 					for _, subscription := range d.SubscriptionsByDataFeed[key] {
 						if subscription.onCandleClose && !candle.Complete {
 							continue
@@ -124,6 +267,10 @@ func (d *DataFeedSubscription) Start(loadSync bool) {
 				case err := <-feed.Err:
 					if err != nil {
 						log.Error("dataFeedSubscription/start: ", err)
+						// the exchange reconnects after errors, the next message restores the status
+						d.updateStatus(key, func(status *FeedStatus) {
+							status.Connected = false
+						})
 					}
 				}
 			}
@@ -0,0 +1,85 @@
+package exchange
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/rodrigo-brito/ninjabot/model"
+	"github.com/rodrigo-brito/ninjabot/service"
+)
+
+func TestDataFeedSubscription_Status(t *testing.T) {
+	feed, err := NewCSVFeed("1d", PairFeed{
+		Pair:      "BTCUSDT",
+		File:      "../testdata/btc-1d.csv",
+		Timeframe: "1d",
+	})
+	require.NoError(t, err)
+
+	dataFeed := NewDataFeed(&PaperWallet{feeder: feed})
+	var last model.Candle
+	dataFeed.Subscribe("BTCUSDT", "1d", func(candle model.Candle) {
+		last = candle
+	}, false)
+
+	status := dataFeed.Status()
+	require.Len(t, status, 1)
+	require.Equal(t, "BTCUSDT", status[0].Pair)
+	require.Equal(t, "1d", status[0].Timeframe)
+	require.True(t, status[0].LastCandle.IsZero())
+	require.True(t, status[0].Stale())
+
+	dataFeed.Start(true)
+
+	status = dataFeed.Status()
+	require.Len(t, status, 1)
+	require.False(t, status[0].Connected) // CSV feed is closed after last candle
+	require.Equal(t, last.Time, status[0].LastCandle)
+	require.True(t, status[0].Stale())
+
+	fresh := FeedStatus{Timeframe: "1h", LastCandle: time.Now().Add(-90 * time.Minute)}
+	require.False(t, fresh.Stale())
+}
+
+// streamExchange is an exchange with a candle stream controlled by the test
+type streamExchange struct {
+	service.Exchange
+	data chan model.Candle
+	errs chan error
+}
+
+func (s streamExchange) CandlesSubscription(_ context.Context, _, _ string) (chan model.Candle, chan error) {
+	return s.data, s.errs
+}
+
+func TestDataFeedSubscription_Connected(t *testing.T) {
+	stream := streamExchange{data: make(chan model.Candle), errs: make(chan error)}
+	dataFeed := NewDataFeed(stream)
+	dataFeed.Subscribe("BTCUSDT", "1h", func(model.Candle) {}, false)
+	dataFeed.Start(false)
+
+	connected := func() bool {
+		return dataFeed.Status()[0].Connected
+	}
+	require.True(t, connected())
+
+	// disconnection of the websocket, restored by the next message
+	stream.errs <- errors.New("connection reset")
+	require.Eventually(t, func() bool { return !connected() }, time.Second, time.Millisecond)
+	stream.data <- model.Candle{Pair: "BTCUSDT", Time: time.Now()}
+	require.Eventually(t, connected, time.Second, time.Millisecond)
+
+	close(stream.data)
+	require.Eventually(t, func() bool { return !connected() }, time.Second, time.Millisecond)
+}
+
+func TestAmountToLotSize(t *testing.T) {
+	info := model.AssetInfo{StepSize: 0.001, BaseAssetPrecision: 3}
+	require.Equal(t, 0.003, AmountToLotSize(info, 0.0033333))
+	require.Equal(t, 0.0, AmountToLotSize(info, 0.0009))
+	require.Equal(t, 0.0033333, AmountToLotSize(model.AssetInfo{}, 0.0033333))
+}
@@ -5,12 +5,11 @@ import (
 	"errors"
 	"fmt"
 	"math"
+	"sort"
 	"strings"
 	"sync"
 	"time"
 
-	"github.com/adshao/go-binance/v2/common"
-
 	"github.com/rodrigo-brito/ninjabot/model"
 	"github.com/rodrigo-brito/ninjabot/service"
 	"github.com/rodrigo-brito/ninjabot/tools/log"
@@ -33,6 +32,11 @@ type PaperWallet struct {
 	counter       int64
 	takerFee      float64
 	makerFee      float64
+	pairFees      map[string]feeRate
+	bnbFee        bool
+	bnbDiscount   float64
+	fees          map[string]float64
+	bnbFees       float64
 	initialValue  float64
 	feeder        service.Feeder
 	orders        []model.Order
@@ -44,6 +48,7 @@ type PaperWallet struct {
 	fistCandle    map[string]model.Candle
 	assetValues   map[string][]AssetValue
 	equityValues  []AssetValue
+	subAccounts   map[string]*PaperWallet
 }
 
 func (p *PaperWallet) AssetsInfo(pair string) model.AssetInfo {
@@ -78,6 +83,29 @@ func WithPaperFee(maker, taker float64) PaperWalletOption {
 	}
 }
 
+// feeRate are the maker and taker fees of a pair
+type feeRate struct {
+	maker float64
+	taker float64
+}
+
+// WithPaperPairFee overrides the fees of a pair, eg: for a pair with a promotional rate.
+// Pairs without override are charged with the rates of WithPaperFee.
+func WithPaperPairFee(pair string, maker, taker float64) PaperWalletOption {
+	return func(wallet *PaperWallet) {
+		wallet.pairFees[pair] = feeRate{maker: maker, taker: taker}
+	}
+}
+
+// WithPaperBNBFee pays fees in BNB with the given discount (eg: 0.25 on Binance), as long as the
+// wallet holds enough BNB. When BNB runs out, the standard rate is charged in the quote asset.
+func WithPaperBNBFee(discount float64) PaperWalletOption {
+	return func(wallet *PaperWallet) {
+		wallet.bnbFee = true
+		wallet.bnbDiscount = discount
+	}
+}
+
 func WithDataFeed(feeder service.Feeder) PaperWalletOption {
 	return func(wallet *PaperWallet) {
 		wallet.feeder = feeder
@@ -97,6 +125,9 @@ func NewPaperWallet(ctx context.Context, baseCoin string, options ...PaperWallet
 		volume:        make(map[string]float64),
 		assetValues:   make(map[string][]AssetValue),
 		equityValues:  make([]AssetValue, 0),
+		subAccounts:   make(map[string]*PaperWallet),
+		fees:          make(map[string]float64),
+		pairFees:      make(map[string]feeRate),
 	}
 
 	for _, option := range options {
@@ -110,6 +141,100 @@ func NewPaperWallet(ctx context.Context, baseCoin string, options ...PaperWallet
 	return &wallet
 }
 
+// SubAccount carves out an isolated allocation of the base coin for a strategy.
+// The sub-account is a paper wallet with its own balance, orders and PnL, and it must be attached to
+// its own bot (e.g. `ninjabot.WithBacktest(subAccount)`), so it receives the candles of its pairs.
+func (p *PaperWallet) SubAccount(name string, allocation float64) (*PaperWallet, error) {
+	p.Lock()
+	defer p.Unlock()
+
+	if _, ok := p.subAccounts[name]; ok {
+		return nil, fmt.Errorf("sub-account %s already exists", name)
+	}
+
+	if allocation <= 0 {
+		return nil, ErrInvalidQuantity
+	}
+
+	baseCoin, ok := p.assets[p.baseCoin]
+	if !ok || baseCoin.Free < allocation {
+		return nil, ErrInsufficientFunds
+	}
+
+	baseCoin.Free -= allocation
+	p.initialValue -= allocation
+
+	log.Infof("[SETUP] Sub-account %s", name)
+	subAccount := NewPaperWallet(p.ctx, p.baseCoin,
+		WithPaperAsset(p.baseCoin, allocation),
+		WithPaperFee(p.makerFee, p.takerFee),
+		WithDataFeed(p.feeder),
+		func(wallet *PaperWallet) {
+			for pair, rate := range p.pairFees {
+				wallet.pairFees[pair] = rate
+			}
+			wallet.bnbFee = p.bnbFee
+			wallet.bnbDiscount = p.bnbDiscount
+		},
+	)
+	p.subAccounts[name] = subAccount
+
+	return subAccount, nil
+}
+
+// SubAccounts returns the sub-accounts created from this wallet, indexed by name
+func (p *PaperWallet) SubAccounts() map[string]*PaperWallet {
+	p.Lock()
+	defer p.Unlock()
+
+	subAccounts := make(map[string]*PaperWallet, len(p.subAccounts))
+	for name, subAccount := range p.subAccounts {
+		subAccounts[name] = subAccount
+	}
+	return subAccounts
+}
+
+// Equity returns the current value of the wallet in base coin, without sub-accounts
+func (p *PaperWallet) Equity() float64 {
+	p.Lock()
+	defer p.Unlock()
+
+	return p.equity()
+}
+
+// CombinedEquity returns the current value of the wallet including all sub-accounts
+func (p *PaperWallet) CombinedEquity() float64 {
+	total := p.Equity()
+	for _, subAccount := range p.SubAccounts() {
+		total += subAccount.CombinedEquity()
+	}
+	return total
+}
+
+func (p *PaperWallet) equity() float64 {
+	var total float64
+	for asset, info := range p.assets {
+		if asset == p.baseCoin {
+			continue
+		}
+
+		amount := info.Free + info.Lock
+		pair := strings.ToUpper(asset + p.baseCoin)
+		if amount < 0 {
+			v := math.Abs(amount)
+			total += 2*v*p.avgShortPrice[pair] - v*p.lastCandle[pair].Close
+		} else {
+			total += amount * p.lastCandle[pair].Close
+		}
+	}
+
+	if baseCoinInfo, ok := p.assets[p.baseCoin]; ok {
+		total += baseCoinInfo.Free + baseCoinInfo.Lock
+	}
+
+	return total
+}
+
 func (p *PaperWallet) ID() int64 {
 	p.counter++
 	return p.counter
@@ -127,6 +252,30 @@ func (p *PaperWallet) LastQuote(ctx context.Context, pair string) (float64, erro
 	return p.feeder.LastQuote(ctx, pair)
 }
 
+// BookTicker approximates the best bid and ask with the close of the last candle, without spread
+func (p *PaperWallet) BookTicker(_ context.Context, pair string) (bid, ask float64, err error) {
+	p.Lock()
+	defer p.Unlock()
+
+	candle, ok := p.lastCandle[pair]
+	if !ok {
+		return 0, 0, ErrInvalidAsset
+	}
+	return candle.Close, candle.Close, nil
+}
+
+// EstimateFill returns the close of the last candle, the price of market orders in simulations
+func (p *PaperWallet) EstimateFill(_ context.Context, _ model.SideType, pair string, _ float64) (float64, error) {
+	p.Lock()
+	defer p.Unlock()
+
+	candle, ok := p.lastCandle[pair]
+	if !ok {
+		return 0, ErrInvalidAsset
+	}
+	return candle.Close, nil
+}
+
 func (p *PaperWallet) AssetValues(pair string) []AssetValue {
 	return p.assetValues[pair]
 }
@@ -222,9 +371,46 @@ func (p *PaperWallet) Summary() {
 	}
 	fmt.Printf("TOTAL           = %.2f %s\n", volume, p.baseCoin)
 	fmt.Println("-------------------")
+
+	if len(p.fees) > 0 {
+		var fees float64
+		fmt.Println("------- FEES ------")
+		for pair, fee := range p.fees {
+			fees += fee
+			fmt.Printf("%s         = %.4f %s\n", pair, fee, p.baseCoin)
+		}
+		fmt.Printf("TOTAL           = %.4f %s\n", fees, p.baseCoin)
+		if p.bnbFees > 0 {
+			fmt.Printf("PAID IN BNB     = %.6f BNB\n", p.bnbFees)
+		}
+		fmt.Println("-------------------")
+	}
+
+	subAccounts := p.SubAccounts()
+	if len(subAccounts) > 0 {
+		names := make([]string, 0, len(subAccounts))
+		for name := range subAccounts {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		fmt.Println("--- SUB ACCOUNTS --")
+		for _, name := range names {
+			subAccount := subAccounts[name]
+			equity := subAccount.CombinedEquity()
+			fmt.Printf("%s = %.2f %s (%.2f%%)\n", name, equity, p.baseCoin,
+				(equity-subAccount.initialValue)/subAccount.initialValue*100)
+		}
+		fmt.Printf("COMBINED EQUITY = %.2f %s\n", p.CombinedEquity(), p.baseCoin)
+		fmt.Println("-------------------")
+	}
 }
 
-func (p *PaperWallet) validateFunds(side model.SideType, pair string, amount, value float64, fill bool) error {
+// validateFunds checks the funds of an order and locks them or fills the order. As in Binance spot, the fee
+// of buy orders is paid with the asset bought and sell orders pay it from the quote received, so only sells
+// opening a short position include the fee charged in quote with the given rate.
+func (p *PaperWallet) validateFunds(side model.SideType, pair string, amount, value, rate float64,
+	fill bool) error {
 	asset, quote := SplitAssetQuote(pair)
 	if _, ok := p.assets[asset]; !ok {
 		p.assets[asset] = &assetInfo{}
@@ -240,7 +426,8 @@ func (p *PaperWallet) validateFunds(side model.SideType, pair string, amount, va
 			funds += p.assets[asset].Free * value
 		}
 
-		if funds < amount*value {
+		lockedAsset := math.Min(math.Max(p.assets[asset].Free, 0), amount) // ignore negative asset amount to lock
+		if funds < amount*value+(amount-lockedAsset)*value*p.quoteFeeRate(pair, rate) {
 			return &OrderError{
 				Err:      ErrInsufficientFunds,
 				Pair:     pair,
@@ -248,7 +435,6 @@ func (p *PaperWallet) validateFunds(side model.SideType, pair string, amount, va
 			}
 		}
 
-		lockedAsset := math.Min(math.Max(p.assets[asset].Free, 0), amount) // ignore negative asset amount to lock
 		lockedQuote := (amount - lockedAsset) * value
 
 		p.assets[asset].Free -= lockedAsset
@@ -401,6 +587,7 @@ func (p *PaperWallet) OnCandle(candle model.Candle) {
 			p.updateAveragePrice(order.Side, order.Pair, order.Quantity, order.Price)
 			p.assets[asset].Free = p.assets[asset].Free + order.Quantity
 			p.assets[quote].Lock = p.assets[quote].Lock - order.Price*order.Quantity
+			p.chargeFee(&p.orders[i], order.Price, p.feeRate(order.Pair).maker)
 		}
 
 		if order.Side == model.SideTypeSell {
@@ -445,32 +632,28 @@ func (p *PaperWallet) OnCandle(candle model.Candle) {
 			p.updateAveragePrice(order.Side, order.Pair, order.Quantity, orderPrice)
 			p.assets[asset].Lock = p.assets[asset].Lock - order.Quantity
 			p.assets[quote].Free = p.assets[quote].Free + order.Quantity*orderPrice
+
+			fee := p.feeRate(order.Pair).maker
+			if order.Type == model.OrderTypeStopLoss || order.Type == model.OrderTypeStopLossLimit {
+				fee = p.feeRate(order.Pair).taker
+			}
+			p.chargeFee(&p.orders[i], orderPrice, fee)
 		}
 	}
 
 	if candle.Complete {
-		var total float64
 		for asset, info := range p.assets {
 			amount := info.Free + info.Lock
 			pair := strings.ToUpper(asset + p.baseCoin)
-			if amount < 0 {
-				v := math.Abs(amount)
-				liquid := 2*v*p.avgShortPrice[pair] - v*p.lastCandle[pair].Close
-				total += liquid
-			} else {
-				total += amount * p.lastCandle[pair].Close
-			}
-
 			p.assetValues[asset] = append(p.assetValues[asset], AssetValue{
 				Time:  candle.Time,
 				Value: amount * p.lastCandle[pair].Close,
 			})
 		}
 
-		baseCoinInfo := p.assets[p.baseCoin]
 		p.equityValues = append(p.equityValues, AssetValue{
 			Time:  candle.Time,
-			Value: total + baseCoinInfo.Lock + baseCoinInfo.Free,
+			Value: p.equity(),
 		})
 	}
 }
@@ -514,39 +697,41 @@ func (p *PaperWallet) CreateOrderOCO(side model.SideType, pair string,
 		return nil, ErrInvalidQuantity
 	}
 
-	err := p.validateFunds(side, pair, size, price, false)
+	err := p.validateFunds(side, pair, size, price, p.feeRate(pair).maker, false)
 	if err != nil {
 		return nil, err
 	}
 
 	groupID := p.ID()
 	limitMaker := model.Order{
-		ExchangeID: p.ID(),
-		CreatedAt:  p.lastCandle[pair].Time,
-		UpdatedAt:  p.lastCandle[pair].Time,
-		Pair:       pair,
-		Side:       side,
-		Type:       model.OrderTypeLimitMaker,
-		Status:     model.OrderStatusTypeNew,
-		Price:      price,
-		Quantity:   size,
-		GroupID:    &groupID,
-		RefPrice:   p.lastCandle[pair].Close,
+		ExchangeID:    p.ID(),
+		ClientOrderID: newClientOrderID(),
+		CreatedAt:     p.lastCandle[pair].Time,
+		UpdatedAt:     p.lastCandle[pair].Time,
+		Pair:          pair,
+		Side:          side,
+		Type:          model.OrderTypeLimitMaker,
+		Status:        model.OrderStatusTypeNew,
+		Price:         price,
+		Quantity:      size,
+		GroupID:       &groupID,
+		RefPrice:      p.lastCandle[pair].Close,
 	}
 
 	stopOrder := model.Order{
-		ExchangeID: p.ID(),
-		CreatedAt:  p.lastCandle[pair].Time,
-		UpdatedAt:  p.lastCandle[pair].Time,
-		Pair:       pair,
-		Side:       side,
-		Type:       model.OrderTypeStopLoss,
-		Status:     model.OrderStatusTypeNew,
-		Price:      stopLimit,
-		Stop:       &stop,
-		Quantity:   size,
-		GroupID:    &groupID,
-		RefPrice:   p.lastCandle[pair].Close,
+		ExchangeID:    p.ID(),
+		ClientOrderID: newClientOrderID(),
+		CreatedAt:     p.lastCandle[pair].Time,
+		UpdatedAt:     p.lastCandle[pair].Time,
+		Pair:          pair,
+		Side:          side,
+		Type:          model.OrderTypeStopLoss,
+		Status:        model.OrderStatusTypeNew,
+		Price:         stopLimit,
+		Stop:          &stop,
+		Quantity:      size,
+		GroupID:       &groupID,
+		RefPrice:      p.lastCandle[pair].Close,
 	}
 	p.orders = append(p.orders, limitMaker, stopOrder)
 
@@ -563,20 +748,23 @@ func (p *PaperWallet) CreateOrderLimit(side model.SideType, pair string,
 		return model.Order{}, ErrInvalidQuantity
 	}
 
-	err := p.validateFunds(side, pair, size, limit, false)
+	err := p.validateFunds(side, pair, size, limit, p.feeRate(pair).maker, false)
 	if err != nil {
 		return model.Order{}, err
 	}
+
+	clientID := newClientOrderID()
 	order := model.Order{
-		ExchangeID: p.ID(),
-		CreatedAt:  p.lastCandle[pair].Time,
-		UpdatedAt:  p.lastCandle[pair].Time,
-		Pair:       pair,
-		Side:       side,
-		Type:       model.OrderTypeLimit,
-		Status:     model.OrderStatusTypeNew,
-		Price:      limit,
-		Quantity:   size,
+		ExchangeID:    p.ID(),
+		ClientOrderID: clientID,
+		CreatedAt:     p.lastCandle[pair].Time,
+		UpdatedAt:     p.lastCandle[pair].Time,
+		Pair:          pair,
+		Side:          side,
+		Type:          model.OrderTypeLimit,
+		Status:        model.OrderStatusTypeNew,
+		Price:         limit,
+		Quantity:      size,
 	}
 	p.orders = append(p.orders, order)
 	return order, nil
@@ -586,7 +774,16 @@ func (p *PaperWallet) CreateOrderMarket(side model.SideType, pair string, size f
 	p.Lock()
 	defer p.Unlock()
 
-	return p.createOrderMarket(side, pair, size)
+	return p.createOrderMarket(side, pair, size, p.lastCandle[pair].Close)
+}
+
+// CreateOrderMarketAt fills a market order at the given price instead of the last close, eg: at the trigger
+// of a virtual order touched within the candle
+func (p *PaperWallet) CreateOrderMarketAt(side model.SideType, pair string, size, price float64) (model.Order, error) {
+	p.Lock()
+	defer p.Unlock()
+
+	return p.createOrderMarket(side, pair, size, price)
 }
 
 func (p *PaperWallet) CreateOrderStop(pair string, size float64, limit float64) (model.Order, error) {
@@ -597,33 +794,36 @@ func (p *PaperWallet) CreateOrderStop(pair string, size float64, limit float64)
 		return model.Order{}, ErrInvalidQuantity
 	}
 
-	err := p.validateFunds(model.SideTypeSell, pair, size, limit, false)
+	err := p.validateFunds(model.SideTypeSell, pair, size, limit, p.feeRate(pair).taker, false)
 	if err != nil {
 		return model.Order{}, err
 	}
 
+	clientID := newClientOrderID()
 	order := model.Order{
-		ExchangeID: p.ID(),
-		CreatedAt:  p.lastCandle[pair].Time,
-		UpdatedAt:  p.lastCandle[pair].Time,
-		Pair:       pair,
-		Side:       model.SideTypeSell,
-		Type:       model.OrderTypeStopLossLimit,
-		Status:     model.OrderStatusTypeNew,
-		Price:      limit,
-		Stop:       &limit,
-		Quantity:   size,
+		ExchangeID:    p.ID(),
+		ClientOrderID: clientID,
+		CreatedAt:     p.lastCandle[pair].Time,
+		UpdatedAt:     p.lastCandle[pair].Time,
+		Pair:          pair,
+		Side:          model.SideTypeSell,
+		Type:          model.OrderTypeStopLossLimit,
+		Status:        model.OrderStatusTypeNew,
+		Price:         limit,
+		Stop:          &limit,
+		Quantity:      size,
 	}
 	p.orders = append(p.orders, order)
 	return order, nil
 }
 
-func (p *PaperWallet) createOrderMarket(side model.SideType, pair string, size float64) (model.Order, error) {
+// createOrderMarket fills a market order at the given price, the caller must hold the lock
+func (p *PaperWallet) createOrderMarket(side model.SideType, pair string, size, price float64) (model.Order, error) {
 	if size == 0 {
 		return model.Order{}, ErrInvalidQuantity
 	}
 
-	err := p.validateFunds(side, pair, size, p.lastCandle[pair].Close, true)
+	err := p.validateFunds(side, pair, size, price, p.feeRate(pair).taker, true)
 	if err != nil {
 		return model.Order{}, err
 	}
@@ -632,33 +832,111 @@ func (p *PaperWallet) createOrderMarket(side model.SideType, pair string, size f
 		p.volume[pair] = 0
 	}
 
-	p.volume[pair] += p.lastCandle[pair].Close * size
+	p.volume[pair] += price * size
 
 	order := model.Order{
-		ExchangeID: p.ID(),
-		CreatedAt:  p.lastCandle[pair].Time,
-		UpdatedAt:  p.lastCandle[pair].Time,
-		Pair:       pair,
-		Side:       side,
-		Type:       model.OrderTypeMarket,
-		Status:     model.OrderStatusTypeFilled,
-		Price:      p.lastCandle[pair].Close,
-		Quantity:   size,
-	}
+		ExchangeID:    p.ID(),
+		ClientOrderID: newClientOrderID(),
+		CreatedAt:     p.lastCandle[pair].Time,
+		UpdatedAt:     p.lastCandle[pair].Time,
+		Pair:          pair,
+		Side:          side,
+		Type:          model.OrderTypeMarket,
+		Status:        model.OrderStatusTypeFilled,
+		Price:         price,
+		Quantity:      size,
+	}
+	p.chargeFee(&order, order.Price, p.feeRate(pair).taker)
 
 	p.orders = append(p.orders, order)
 
 	return order, nil
 }
 
+// feeRate returns the fees of the pair, or the default fees without override
+func (p *PaperWallet) feeRate(pair string) feeRate {
+	if rate, ok := p.pairFees[pair]; ok {
+		return rate
+	}
+	return feeRate{maker: p.makerFee, taker: p.takerFee}
+}
+
+// FeeRate returns the maker and taker fee rates of the pair
+func (p *PaperWallet) FeeRate(pair string) (maker, taker float64) {
+	rate := p.feeRate(pair)
+	return rate.maker, rate.taker
+}
+
+// quoteFeeRate returns the fee rate charged in the quote asset, zero when fees are paid in BNB
+func (p *PaperWallet) quoteFeeRate(pair string, rate float64) float64 {
+	_, quote := SplitAssetQuote(pair)
+	if bnb, ok := p.assets["BNB"]; ok && p.bnbFee && bnb.Free > 0 && p.lastCandle["BNB"+quote].Close > 0 {
+		return 0
+	}
+	return rate
+}
+
+// chargeFee debits the fee of a filled order, in BNB with discount when enabled and available. Otherwise, as in
+// Binance spot, buy orders pay it with the asset bought and sell orders with the quote received.
+// The fee is registered in the order with its value in quote.
+func (p *PaperWallet) chargeFee(order *model.Order, price, rate float64) {
+	value := order.Quantity * price * rate
+	if value == 0 {
+		return
+	}
+
+	asset, quote := SplitAssetQuote(order.Pair)
+	if p.bnbFee {
+		discounted := value * (1 - p.bnbDiscount)
+		bnbPrice := p.lastCandle["BNB"+quote].Close
+		if order.Pair == "BNB"+quote {
+			bnbPrice = price
+		}
+
+		if bnb, ok := p.assets["BNB"]; ok && bnbPrice > 0 && bnb.Free >= discounted/bnbPrice {
+			bnb.Free -= discounted / bnbPrice
+			p.bnbFees += discounted / bnbPrice
+			p.fees[order.Pair] += discounted
+			order.Fee = discounted
+			order.FeeAsset = "BNB"
+			return
+		}
+	}
+
+	if _, ok := p.assets[quote]; !ok {
+		p.assets[quote] = &assetInfo{}
+	}
+
+	p.fees[order.Pair] += value
+	order.Fee = value
+	if order.Side == model.SideTypeBuy {
+		p.assets[asset].Free -= order.Quantity * rate
+		order.FeeAsset = asset
+		return
+	}
+	p.assets[quote].Free -= value
+	order.FeeAsset = quote
+}
+
+// Fees returns the total of fees paid in quote value and the amount of BNB spent on fees
+func (p *PaperWallet) Fees() (quote float64, bnb float64) {
+	p.Lock()
+	defer p.Unlock()
+
+	for _, value := range p.fees {
+		quote += value
+	}
+	return quote, p.bnbFees
+}
+
 func (p *PaperWallet) CreateOrderMarketQuote(side model.SideType, pair string,
 	quoteQuantity float64) (model.Order, error) {
 	p.Lock()
 	defer p.Unlock()
 
 	info := p.AssetsInfo(pair)
-	quantity := common.AmountToLotSize(info.StepSize, info.BaseAssetPrecision, quoteQuantity/p.lastCandle[pair].Close)
-	return p.createOrderMarket(side, pair, quantity)
+	quantity := AmountToLotSize(info, quoteQuantity/p.lastCandle[pair].Close)
+	return p.createOrderMarket(side, pair, quantity, p.lastCandle[pair].Close)
 }
 
 func (p *PaperWallet) Cancel(order model.Order) error {
@@ -697,6 +975,26 @@ func (p *PaperWallet) Order(_ string, id int64) (model.Order, error) {
 	return model.Order{}, errors.New("order not found")
 }
 
+// OrderByClientID returns the order registered with the given client order id
+func (p *PaperWallet) OrderByClientID(_ string, clientID string) (model.Order, error) {
+	p.Lock()
+	defer p.Unlock()
+
+	if order, ok := p.orderByClientID(clientID); ok {
+		return order, nil
+	}
+	return model.Order{}, errors.New("order not found")
+}
+
+func (p *PaperWallet) orderByClientID(clientID string) (model.Order, bool) {
+	for _, order := range p.orders {
+		if order.ClientOrderID == clientID {
+			return order, true
+		}
+	}
+	return model.Order{}, false
+}
+
 func (p *PaperWallet) CandlesByPeriod(ctx context.Context, pair, period string,
 	start, end time.Time) ([]model.Candle, error) {
 	return p.feeder.CandlesByPeriod(ctx, pair, period, start, end)
@@ -13,7 +13,7 @@ import (
 func TestPaperWallet_ValidateFunds(t *testing.T) {
 	t.Run("simple lock limit", func(t *testing.T) {
 		wallet := NewPaperWallet(context.Background(), "USDT", WithPaperAsset("USDT", 100))
-		err := wallet.validateFunds(model.SideTypeBuy, "BTCUSDT", 1, 100, false)
+		err := wallet.validateFunds(model.SideTypeBuy, "BTCUSDT", 1, 100, 0, false)
 		require.NoError(t, err)
 		require.Equal(t, 0.0, wallet.assets["USDT"].Free)
 		require.Equal(t, 100.0, wallet.assets["USDT"].Lock)
@@ -24,7 +24,7 @@ func TestPaperWallet_ValidateFunds(t *testing.T) {
 	t.Run("simple buy market", func(t *testing.T) {
 		wallet := NewPaperWallet(context.Background(), "USDT", WithPaperAsset("USDT", 100))
 		wallet.lastCandle["BTCUSDT"] = model.Candle{Pair: "BTCUSDT", Close: 100}
-		err := wallet.validateFunds(model.SideTypeBuy, "BTCUSDT", 1, 100, true)
+		err := wallet.validateFunds(model.SideTypeBuy, "BTCUSDT", 1, 100, 0, true)
 		require.NoError(t, err)
 		require.Equal(t, 0.0, wallet.assets["USDT"].Free)
 		require.Equal(t, 0.0, wallet.assets["USDT"].Lock)
@@ -35,7 +35,7 @@ func TestPaperWallet_ValidateFunds(t *testing.T) {
 	t.Run("simple short market", func(t *testing.T) {
 		wallet := NewPaperWallet(context.Background(), "USDT", WithPaperAsset("USDT", 100))
 		wallet.lastCandle["BTCUSDT"] = model.Candle{Pair: "BTCUSDT", Close: 100}
-		err := wallet.validateFunds(model.SideTypeSell, "BTCUSDT", 1, 100, true)
+		err := wallet.validateFunds(model.SideTypeSell, "BTCUSDT", 1, 100, 0, true)
 		require.NoError(t, err)
 		require.Equal(t, 0.0, wallet.assets["USDT"].Free)
 		require.Equal(t, 0.0, wallet.assets["USDT"].Lock)
@@ -45,7 +45,7 @@ func TestPaperWallet_ValidateFunds(t *testing.T) {
 
 	t.Run("simple short limit", func(t *testing.T) {
 		wallet := NewPaperWallet(context.Background(), "USDT", WithPaperAsset("USDT", 100))
-		err := wallet.validateFunds(model.SideTypeSell, "BTCUSDT", 1, 100, false)
+		err := wallet.validateFunds(model.SideTypeSell, "BTCUSDT", 1, 100, 0, false)
 		require.NoError(t, err)
 		require.Equal(t, 0.0, wallet.assets["USDT"].Free)
 		require.Equal(t, 100.0, wallet.assets["USDT"].Lock)
@@ -57,7 +57,7 @@ func TestPaperWallet_ValidateFunds(t *testing.T) {
 		wallet := NewPaperWallet(context.Background(), "USDT", WithPaperAsset("BTC", 1), WithPaperAsset("USDT", 100))
 		wallet.avgLongPrice["BTCUSDT"] = 100
 
-		err := wallet.validateFunds(model.SideTypeSell, "BTCUSDT", 2, 100, true)
+		err := wallet.validateFunds(model.SideTypeSell, "BTCUSDT", 2, 100, 0, true)
 		require.NoError(t, err)
 		require.Equal(t, 0.0, wallet.assets["USDT"].Free)
 		require.Equal(t, 0.0, wallet.assets["USDT"].Lock)
@@ -69,7 +69,7 @@ func TestPaperWallet_ValidateFunds(t *testing.T) {
 		wallet := NewPaperWallet(context.Background(), "USDT", WithPaperAsset("BTC", -1), WithPaperAsset("USDT", 100))
 		wallet.avgShortPrice["BTCUSDT"] = 100
 
-		err := wallet.validateFunds(model.SideTypeBuy, "BTCUSDT", 2, 150, true)
+		err := wallet.validateFunds(model.SideTypeBuy, "BTCUSDT", 2, 150, 0, true)
 		require.NoError(t, err)
 		require.Equal(t, 0.0, wallet.assets["USDT"].Free)
 		require.Equal(t, 0.0, wallet.assets["USDT"].Lock)
@@ -598,3 +598,158 @@ func TestUpdateAveragePrice(t *testing.T) {
 	})
 
 }
+
+func TestPaperWallet_SubAccount(t *testing.T) {
+	wallet := NewPaperWallet(context.Background(), "USDT", WithPaperAsset("USDT", 10000))
+
+	first, err := wallet.SubAccount("first", 3000)
+	require.NoError(t, err)
+	second, err := wallet.SubAccount("second", 2000)
+	require.NoError(t, err)
+
+	_, err = wallet.SubAccount("first", 1000)
+	require.Error(t, err)
+	_, err = wallet.SubAccount("third", 6000)
+	require.ErrorIs(t, err, ErrInsufficientFunds)
+
+	require.Equal(t, 5000.0, wallet.assets["USDT"].Free)
+	require.Len(t, wallet.SubAccounts(), 2)
+
+	candle := model.Candle{Pair: "BTCUSDT", Close: 1000, Complete: true}
+	first.OnCandle(candle)
+	second.OnCandle(candle)
+
+	// first sub-account only sees its own allocation
+	_, err = first.CreateOrderMarket(model.SideTypeBuy, "BTCUSDT", 4)
+	require.ErrorIs(t, err, ErrInsufficientFunds)
+
+	_, err = first.CreateOrderMarket(model.SideTypeBuy, "BTCUSDT", 2)
+	require.NoError(t, err)
+
+	asset, quote, err := first.Position("BTCUSDT")
+	require.NoError(t, err)
+	require.Equal(t, 2.0, asset)
+	require.Equal(t, 1000.0, quote)
+
+	// second sub-account and parent are not affected
+	asset, quote, err = second.Position("BTCUSDT")
+	require.NoError(t, err)
+	require.Equal(t, 0.0, asset)
+	require.Equal(t, 2000.0, quote)
+	require.Equal(t, 5000.0, wallet.assets["USDT"].Free)
+
+	// isolated PnL with combined report
+	candle.Close = 1500
+	first.OnCandle(candle)
+	second.OnCandle(candle)
+
+	require.Equal(t, 4000.0, first.Equity())
+	require.Equal(t, 2000.0, second.Equity())
+	require.Equal(t, 5000.0, wallet.Equity())
+	require.Equal(t, 11000.0, wallet.CombinedEquity())
+}
+
+func TestPaperWallet_ClientOrderID(t *testing.T) {
+	wallet := NewPaperWallet(context.Background(), "USDT", WithPaperAsset("USDT", 100))
+	wallet.OnCandle(model.Candle{Pair: "BTCUSDT", Close: 10})
+
+	order, err := wallet.CreateOrderMarket(model.SideTypeBuy, "BTCUSDT", 1)
+	require.NoError(t, err)
+	require.NotEmpty(t, order.ClientOrderID)
+
+	found, err := wallet.OrderByClientID("BTCUSDT", order.ClientOrderID)
+	require.NoError(t, err)
+	require.Equal(t, order, found)
+
+	other, err := wallet.CreateOrderMarket(model.SideTypeBuy, "BTCUSDT", 1)
+	require.NoError(t, err)
+	require.NotEqual(t, order.ClientOrderID, other.ClientOrderID)
+}
+
+func TestPaperWallet_BNBFee(t *testing.T) {
+	wallet := NewPaperWallet(context.Background(), "USDT",
+		WithPaperAsset("USDT", 10000),
+		WithPaperAsset("BNB", 0.004),
+		WithPaperFee(0.001, 0.001),
+		WithPaperBNBFee(0.25),
+	)
+	wallet.OnCandle(model.Candle{Pair: "BNBUSDT", Close: 300})
+	wallet.OnCandle(model.Candle{Pair: "BTCUSDT", Close: 10000})
+
+	// discounted fee of 0.75 USDT paid with 0.0025 BNB
+	order, err := wallet.CreateOrderMarket(model.SideTypeBuy, "BTCUSDT", 0.1)
+	require.NoError(t, err)
+	require.Equal(t, "BNB", order.FeeAsset)
+	require.InDelta(t, 0.75, order.Fee, 1e-9)
+	require.InDelta(t, 0.0015, wallet.assets["BNB"].Free, 1e-9)
+	require.InDelta(t, 9000, wallet.assets["USDT"].Free, 1e-9)
+
+	// BNB exhausted, standard rate charged in the asset bought
+	order, err = wallet.CreateOrderMarket(model.SideTypeBuy, "BTCUSDT", 0.1)
+	require.NoError(t, err)
+	require.Equal(t, "BTC", order.FeeAsset)
+	require.InDelta(t, 1, order.Fee, 1e-9)
+	require.InDelta(t, 0.0015, wallet.assets["BNB"].Free, 1e-9)
+	require.InDelta(t, 8000, wallet.assets["USDT"].Free, 1e-9)
+	require.InDelta(t, 0.1999, wallet.assets["BTC"].Free, 1e-9)
+
+	quote, bnb := wallet.Fees()
+	require.InDelta(t, 1.75, quote, 1e-9)
+	require.InDelta(t, 0.0025, bnb, 1e-9)
+}
+
+func TestPaperWallet_FeeFunds(t *testing.T) {
+	t.Run("all-in orders", func(t *testing.T) {
+		wallet := NewPaperWallet(context.Background(), "USDT", WithPaperAsset("USDT", 1000),
+			WithPaperFee(0.001, 0.001))
+		wallet.OnCandle(model.Candle{Pair: "BTCUSDT", Close: 1000})
+
+		// the buy fee is paid with the asset bought
+		order, err := wallet.CreateOrderMarketQuote(model.SideTypeBuy, "BTCUSDT", 1000)
+		require.NoError(t, err)
+		require.Equal(t, 1.0, order.Quantity)
+		require.Equal(t, "BTC", order.FeeAsset)
+		require.InDelta(t, 1, order.Fee, 1e-9)
+		require.InDelta(t, 0, wallet.assets["USDT"].Free, 1e-9)
+		require.InDelta(t, 0.999, wallet.assets["BTC"].Free, 1e-9)
+
+		// the sell fee is paid with the quote received
+		order, err = wallet.CreateOrderMarket(model.SideTypeSell, "BTCUSDT", 0.999)
+		require.NoError(t, err)
+		require.Equal(t, "USDT", order.FeeAsset)
+		require.InDelta(t, 0, wallet.assets["BTC"].Free, 1e-9)
+		require.InDelta(t, 999-0.999, wallet.assets["USDT"].Free, 1e-9)
+	})
+
+	t.Run("short order with the fee above the free quote", func(t *testing.T) {
+		wallet := NewPaperWallet(context.Background(), "USDT", WithPaperAsset("USDT", 1000),
+			WithPaperFee(0.001, 0.001))
+		wallet.OnCandle(model.Candle{Pair: "BTCUSDT", Close: 1000})
+
+		_, err := wallet.CreateOrderMarket(model.SideTypeSell, "BTCUSDT", 1)
+		require.ErrorIs(t, err, ErrInsufficientFunds)
+		require.Equal(t, 1000.0, wallet.assets["USDT"].Free)
+	})
+
+	t.Run("limit order filled without free quote", func(t *testing.T) {
+		wallet := NewPaperWallet(context.Background(), "USDT", WithPaperAsset("USDT", 1000),
+			WithPaperFee(0.001, 0))
+		wallet.OnCandle(model.Candle{Pair: "BTCUSDT", Close: 1000})
+
+		_, err := wallet.CreateOrderLimit(model.SideTypeBuy, "BTCUSDT", 0.5, 900)
+		require.NoError(t, err)
+		_, err = wallet.CreateOrderMarket(model.SideTypeBuy, "BTCUSDT", 0.55)
+		require.NoError(t, err)
+		require.InDelta(t, 0, wallet.assets["USDT"].Free, 1e-9)
+
+		// the fee is paid with the asset bought, the quote is never negative
+		wallet.OnCandle(model.Candle{Pair: "BTCUSDT", Close: 900})
+		order, err := wallet.Order("BTCUSDT", 1)
+		require.NoError(t, err)
+		require.Equal(t, model.OrderStatusTypeFilled, order.Status)
+		require.Equal(t, "BTC", order.FeeAsset)
+		require.InDelta(t, 0.45, order.Fee, 1e-9)
+		require.InDelta(t, 0, wallet.assets["USDT"].Free, 1e-9)
+		require.InDelta(t, 1.0495, wallet.assets["BTC"].Free, 1e-9)
+	})
+}
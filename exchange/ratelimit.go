@@ -0,0 +1,153 @@
+package exchange
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/rodrigo-brito/ninjabot/service"
+	"github.com/rodrigo-brito/ninjabot/tools/log"
+)
+
+const (
+	// defaultRetryAfter is the pause after a rate limit response without a valid Retry-After header
+	defaultRetryAfter = time.Minute
+	// defaultMaxWait is the longest pause a request waits, longer pauses return a RateLimitError
+	defaultMaxWait = 5 * time.Second
+)
+
+// RateLimitError is returned without a request when requests are paused longer than the limiter waits,
+// eg: an IP ban, so callers don't hold their locks for the whole pause
+type RateLimitError struct {
+	Until time.Time
+}
+
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("%v, requests paused until %s", ErrRateLimited, e.Until.Format(time.RFC3339))
+}
+
+func (e *RateLimitError) Unwrap() error {
+	return ErrRateLimited
+}
+
+// RateLimiter pauses the requests of all callers after a rate limit response of Binance, 429 (too many
+// requests) or 418 (IP banned), for the time given in the Retry-After header. A limiter can be shared
+// by exchanges using the same IP, eg: spot and futures. Requests wait short pauses, and fail with a
+// RateLimitError when the pause is longer.
+type RateLimiter struct {
+	mtx      sync.Mutex
+	until    time.Time
+	fallback time.Duration
+	maxWait  time.Duration
+	notifier service.Notifier
+}
+
+// NewRateLimiter creates a limiter, with the pause used when the Retry-After header is missing
+func NewRateLimiter(fallback time.Duration) *RateLimiter {
+	return &RateLimiter{fallback: fallback, maxWait: defaultMaxWait}
+}
+
+// SetNotifier registers a notifier for IP bans
+func (r *RateLimiter) SetNotifier(notifier service.Notifier) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	r.notifier = notifier
+}
+
+// Until returns the end of the current pause, it is in the past when requests are not paused
+func (r *RateLimiter) Until() time.Time {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	return r.until
+}
+
+// Client returns a copy of the HTTP client with the requests paused by the limiter
+func (r *RateLimiter) Client(client *http.Client) *http.Client {
+	transport := client.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+
+	limited := *client
+	limited.Transport = rateLimitTransport{limiter: r, transport: transport}
+	return &limited
+}
+
+// wait blocks until the end of the pause, which can be extended while waiting, or the context is done.
+// It returns a RateLimitError when the pause is longer than the maximum wait.
+func (r *RateLimiter) wait(ctx context.Context) error {
+	for {
+		until := r.Until()
+		delay := time.Until(until)
+		if delay <= 0 {
+			return nil
+		}
+		if delay > r.maxWait {
+			return &RateLimitError{Until: until}
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// pause stops the requests for the Retry-After of a rate limit response
+func (r *RateLimiter) pause(status int, retryAfter string) {
+	until := time.Now().Add(parseRetryAfter(retryAfter, r.fallback))
+
+	r.mtx.Lock()
+	if until.After(r.until) {
+		r.until = until
+	}
+	notifier := r.notifier
+	r.mtx.Unlock()
+
+	if status != http.StatusTeapot {
+		log.Warnf("[EXCHANGE] rate limit reached, requests paused until %s", until.Format(time.RFC3339))
+		return
+	}
+
+	err := fmt.Errorf("binance IP banned for rate limit violations, requests paused until %s",
+		until.Format(time.RFC3339))
+	log.Error("[EXCHANGE] ", err)
+	if notifier != nil {
+		notifier.OnError(err)
+	}
+}
+
+// parseRetryAfter returns the pause of a Retry-After header, in seconds or HTTP date
+func parseRetryAfter(value string, fallback time.Duration) time.Duration {
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second
+	}
+
+	if date, err := http.ParseTime(value); err == nil {
+		return time.Until(date)
+	}
+	return fallback
+}
+
+type rateLimitTransport struct {
+	limiter   *RateLimiter
+	transport http.RoundTripper
+}
+
+func (t rateLimitTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := t.limiter.wait(req.Context()); err != nil {
+		return nil, err
+	}
+
+	resp, err := t.transport.RoundTrip(req)
+	if err == nil && (resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusTeapot) {
+		t.limiter.pause(resp.StatusCode, resp.Header.Get("Retry-After"))
+	}
+	return resp, err
+}
@@ -0,0 +1,139 @@
+package exchange
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/rodrigo-brito/ninjabot/model"
+	"github.com/rodrigo-brito/ninjabot/tools/log"
+)
+
+// recordedCandle is a line of a recorded candle stream
+type recordedCandle struct {
+	Timeframe  string       `json:"timeframe"`
+	Warmup     bool         `json:"warmup,omitempty"`
+	ReceivedAt time.Time    `json:"received_at"`
+	Candle     model.Candle `json:"candle"`
+}
+
+// CandleRecorder appends the candles of a live stream to a file, one JSON line by candle,
+// including partial candles. The stream can be replayed in a backtest with NewReplayFeed.
+type CandleRecorder struct {
+	mtx     sync.Mutex
+	file    *os.File
+	encoder *json.Encoder
+}
+
+// NewCandleRecorder creates the file of the recording, it fails if the file exists so a recording
+// holds a single session
+func NewCandleRecorder(file string) (*CandleRecorder, error) {
+	f, err := os.OpenFile(file, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("candleRecorder: use a new file for each session: %w", err)
+	}
+	return &CandleRecorder{file: f, encoder: json.NewEncoder(f)}, nil
+}
+
+// Consumer returns a data feed consumer that records the candles of the given timeframe
+func (r *CandleRecorder) Consumer(timeframe string) DataFeedConsumer {
+	return func(candle model.Candle) {
+		r.record(recordedCandle{Timeframe: timeframe, ReceivedAt: time.Now(), Candle: candle})
+	}
+}
+
+// RecordWarmup records the candles used to warm up the strategy before the stream
+func (r *CandleRecorder) RecordWarmup(timeframe string, candles []model.Candle) {
+	for _, candle := range candles {
+		r.record(recordedCandle{Timeframe: timeframe, Warmup: true, ReceivedAt: time.Now(), Candle: candle})
+	}
+}
+
+func (r *CandleRecorder) record(record recordedCandle) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	if err := r.encoder.Encode(record); err != nil {
+		log.Error("candleRecorder/record: ", err)
+	}
+}
+
+func (r *CandleRecorder) Close() error {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	return r.file.Close()
+}
+
+// ReplayFeed is a data feed of a stream recorded by CandleRecorder. Warmup candles are returned by
+// CandlesByLimit and the stream by CandlesSubscription, with the arrival time as update time.
+type ReplayFeed struct {
+	CSVFeed
+	warmup map[string][]model.Candle
+}
+
+// NewReplayFeed loads a recorded stream. A truncated last line, eg: from a crash while recording, is ignored.
+func NewReplayFeed(file string) (*ReplayFeed, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	feed := &ReplayFeed{
+		CSVFeed: CSVFeed{
+			Feeds:               make(map[string]PairFeed),
+			CandlePairTimeFrame: make(map[string][]model.Candle),
+		},
+		warmup: make(map[string][]model.Candle),
+	}
+
+	var invalid error
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if invalid != nil {
+			return nil, invalid
+		}
+
+		var record recordedCandle
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			invalid = fmt.Errorf("invalid record in line %d: %w", line, err)
+			continue
+		}
+
+		candle := record.Candle
+		key := feed.feedTimeframeKey(candle.Pair, record.Timeframe)
+		feed.Feeds[candle.Pair] = PairFeed{Pair: candle.Pair, File: file, Timeframe: record.Timeframe}
+		if record.Warmup {
+			feed.warmup[key] = append(feed.warmup[key], candle)
+			continue
+		}
+
+		// arrival order of updates in the same candle, the clock of live bots
+		if record.ReceivedAt.After(candle.UpdatedAt) {
+			candle.UpdatedAt = record.ReceivedAt
+		}
+		feed.CandlePairTimeFrame[key] = append(feed.CandlePairTimeFrame[key], candle)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if invalid != nil {
+		log.Warn("[SETUP] replay feed: ", invalid)
+	}
+	return feed, nil
+}
+
+// CandlesByLimit returns the last recorded warmup candles
+func (r *ReplayFeed) CandlesByLimit(_ context.Context, pair, timeframe string, limit int) ([]model.Candle, error) {
+	candles := r.warmup[r.feedTimeframeKey(pair, timeframe)]
+	if len(candles) < limit {
+		return nil, fmt.Errorf("%w: %s", ErrInsufficientData, pair)
+	}
+	return candles[len(candles)-limit:], nil
+}
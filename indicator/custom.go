@@ -0,0 +1,115 @@
+package indicator
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/rodrigo-brito/ninjabot/model"
+)
+
+// ErrInvalidIndicator is returned when a custom indicator is not registered or its output is not aligned
+var ErrInvalidIndicator = errors.New("invalid indicator")
+
+// Func computes an indicator with a value for each candle of the dataframe
+type Func func(df *model.Dataframe) []float64
+
+// Custom is an indicator defined by the user, the first Warmup values are undefined. It is computed for
+// strategies with ninjabot.WithIndicator and plotted with plot/indicator.Custom.
+type Custom struct {
+	Name   string
+	Warmup int
+	Func   Func
+}
+
+// Registry holds the custom indicators of a bot, they are computed in the dataframe metadata of strategies
+// with their name (eg: df.Metadata["vwap"])
+type Registry struct {
+	mtx     sync.RWMutex
+	customs map[string]Custom
+}
+
+func NewRegistry() *Registry {
+	return &Registry{customs: make(map[string]Custom)}
+}
+
+// Register adds a custom indicator, names are unique in the registry
+func (r *Registry) Register(custom Custom) error {
+	if custom.Name == "" || custom.Func == nil || custom.Warmup < 0 {
+		return fmt.Errorf("%w: %q requires a name, a function and a positive warmup", ErrInvalidIndicator,
+			custom.Name)
+	}
+
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	if _, ok := r.customs[custom.Name]; ok {
+		return fmt.Errorf("%w: %q is already registered", ErrInvalidIndicator, custom.Name)
+	}
+	r.customs[custom.Name] = custom
+	return nil
+}
+
+// Unregister removes a custom indicator
+func (r *Registry) Unregister(name string) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	delete(r.customs, name)
+}
+
+// Registered returns the custom indicators, sorted by name
+func (r *Registry) Registered() []Custom {
+	r.mtx.RLock()
+	defer r.mtx.RUnlock()
+
+	indicators := make([]Custom, 0, len(r.customs))
+	for _, custom := range r.customs {
+		indicators = append(indicators, custom)
+	}
+	sort.Slice(indicators, func(i, j int) bool {
+		return indicators[i].Name < indicators[j].Name
+	})
+	return indicators
+}
+
+// Lookup returns the registered custom indicator
+func (r *Registry) Lookup(name string) (Custom, bool) {
+	r.mtx.RLock()
+	defer r.mtx.RUnlock()
+	custom, ok := r.customs[name]
+	return custom, ok
+}
+
+// Compute returns the values of the indicator, which must have the length of the dataframe
+func (c Custom) Compute(df *model.Dataframe) ([]float64, error) {
+	values := c.Func(df)
+	if len(values) != len(df.Close) {
+		return nil, fmt.Errorf("%w: %q returned %d values for %d candles", ErrInvalidIndicator, c.Name,
+			len(values), len(df.Close))
+	}
+	return values, nil
+}
+
+// Load computes the registered indicators in the dataframe metadata, by name. Indicators are skipped
+// while the dataframe is shorter than their warmup.
+func (r *Registry) Load(df *model.Dataframe) error {
+	var errs []error
+	for _, custom := range r.Registered() {
+		if len(df.Close) < custom.Warmup {
+			continue
+		}
+
+		values, err := custom.Compute(df)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		if df.Metadata == nil {
+			df.Metadata = make(map[string]model.Series[float64])
+		}
+		df.Metadata[custom.Name] = values
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,55 @@
+package indicator
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/rodrigo-brito/ninjabot/model"
+)
+
+func TestRegister(t *testing.T) {
+	spread := func(df *model.Dataframe) []float64 {
+		values := make([]float64, len(df.Close))
+		for i := range values {
+			values[i] = df.High[i] - df.Low[i]
+		}
+		return values
+	}
+
+	registry := NewRegistry()
+	require.NoError(t, registry.Register(Custom{Name: "spread", Warmup: 2, Func: spread}))
+	require.ErrorIs(t, registry.Register(Custom{Name: "spread", Warmup: 2, Func: spread}), ErrInvalidIndicator)
+	require.ErrorIs(t, registry.Register(Custom{Warmup: 0, Func: spread}), ErrInvalidIndicator)
+
+	df := &model.Dataframe{Close: []float64{1, 2, 3}, High: []float64{2, 4, 6}, Low: []float64{1, 1, 1}}
+	require.NoError(t, registry.Load(df))
+	require.Equal(t, model.Series[float64]{1, 3, 5}, df.Metadata["spread"])
+
+	// skipped in the warmup
+	short := &model.Dataframe{Close: []float64{1}, High: []float64{2}, Low: []float64{1}}
+	require.NoError(t, registry.Load(short))
+	require.NotContains(t, short.Metadata, "spread")
+
+	t.Run("misaligned", func(t *testing.T) {
+		require.NoError(t, registry.Register(Custom{Name: "short", Func: func(df *model.Dataframe) []float64 {
+			return df.Close[1:]
+		}}))
+		defer registry.Unregister("short")
+
+		err := registry.Load(df)
+		require.ErrorIs(t, err, ErrInvalidIndicator)
+		require.ErrorContains(t, err, `"short" returned 2 values for 3 candles`)
+		require.NotContains(t, df.Metadata, "short")
+	})
+
+	t.Run("scoped", func(t *testing.T) {
+		other := NewRegistry()
+		_, ok := other.Lookup("spread")
+		require.False(t, ok)
+
+		df := &model.Dataframe{Close: []float64{1, 2, 3}, High: []float64{2, 4, 6}, Low: []float64{1, 1, 1}}
+		require.NoError(t, other.Load(df))
+		require.NotContains(t, df.Metadata, "spread")
+	})
+}
@@ -0,0 +1,78 @@
+package indicator
+
+import "math"
+
+// BullishEngulfing - bearish candle followed by a bullish candle whose body covers the previous body
+func BullishEngulfing(open, close []float64) []bool {
+	result := make([]bool, len(close))
+	for i := 1; i < len(close); i++ {
+		result[i] = close[i-1] < open[i-1] &&
+			close[i] > open[i] &&
+			open[i] <= close[i-1] &&
+			close[i] >= open[i-1]
+	}
+	return result
+}
+
+// BearishEngulfing - bullish candle followed by a bearish candle whose body covers the previous body
+func BearishEngulfing(open, close []float64) []bool {
+	result := make([]bool, len(close))
+	for i := 1; i < len(close); i++ {
+		result[i] = close[i-1] > open[i-1] &&
+			close[i] < open[i] &&
+			open[i] >= close[i-1] &&
+			close[i] <= open[i-1]
+	}
+	return result
+}
+
+// Doji - candle with a body smaller than bodyRatio of its full range (e.g. 0.1)
+func Doji(open, high, low, close []float64, bodyRatio float64) []bool {
+	result := make([]bool, len(close))
+	for i := range close {
+		size := high[i] - low[i]
+		if size <= 0 {
+			continue
+		}
+		result[i] = body(open[i], close[i]) <= size*bodyRatio
+	}
+	return result
+}
+
+// Hammer - small body at the top of the range with a lower wick at least wickRatio times the body
+// and an upper wick not larger than the body
+func Hammer(open, high, low, close []float64, bodyRatio, wickRatio float64) []bool {
+	result := make([]bool, len(close))
+	for i := range close {
+		size := high[i] - low[i]
+		if size <= 0 {
+			continue
+		}
+		b := body(open[i], close[i])
+		upper := high[i] - math.Max(open[i], close[i])
+		lower := math.Min(open[i], close[i]) - low[i]
+		result[i] = b <= size*bodyRatio && lower >= b*wickRatio && upper <= b
+	}
+	return result
+}
+
+// ShootingStar - small body at the bottom of the range with an upper wick at least wickRatio times the body
+// and a lower wick not larger than the body
+func ShootingStar(open, high, low, close []float64, bodyRatio, wickRatio float64) []bool {
+	result := make([]bool, len(close))
+	for i := range close {
+		size := high[i] - low[i]
+		if size <= 0 {
+			continue
+		}
+		b := body(open[i], close[i])
+		upper := high[i] - math.Max(open[i], close[i])
+		lower := math.Min(open[i], close[i]) - low[i]
+		result[i] = b <= size*bodyRatio && upper >= b*wickRatio && lower <= b
+	}
+	return result
+}
+
+func body(open, close float64) float64 {
+	return math.Abs(close - open)
+}
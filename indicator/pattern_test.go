@@ -0,0 +1,35 @@
+package indicator
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEngulfing(t *testing.T) {
+	open := []float64{10, 12, 8, 14, 12}
+	close := []float64{12, 9, 13, 7, 8}
+
+	require.Equal(t, []bool{false, false, true, false, false}, BullishEngulfing(open, close))
+	require.Equal(t, []bool{false, true, false, true, false}, BearishEngulfing(open, close))
+}
+
+func TestDoji(t *testing.T) {
+	open := []float64{10, 10, 10}
+	high := []float64{12, 12, 10}
+	low := []float64{8, 8, 10}
+	close := []float64{10.1, 11.5, 10}
+
+	require.Equal(t, []bool{true, false, false}, Doji(open, high, low, close, 0.1))
+}
+
+func TestHammer(t *testing.T) {
+	// hammer, shooting star, marubozu
+	open := []float64{9, 1.2, 1}
+	high := []float64{10.2, 10, 10}
+	low := []float64{1, 0.8, 1}
+	close := []float64{10, 1, 10}
+
+	require.Equal(t, []bool{true, false, false}, Hammer(open, high, low, close, 0.3, 2))
+	require.Equal(t, []bool{false, true, false}, ShootingStar(open, high, low, close, 0.3, 2))
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"math"
 	"strconv"
+	"strings"
 	"time"
 )
 
@@ -11,11 +12,46 @@ type TelegramSettings struct {
 	Enabled bool
 	Token   string
 	Users   []int
+	Balance BalanceSettings
+	// FlattenOnStop closes all positions and cancels resting orders on /stop, as /stop --flatten.
+	// By default, /stop only pauses the bot.
+	FlattenOnStop bool
+	// EditOrderMessages keeps a single message by order, edited from NEW to FILLED or CANCELED,
+	// instead of a new message for each status change.
+	EditOrderMessages bool
+}
+
+// BalanceSettings controls which assets are counted in /balance total.
+// When TradedOnly is false, the default output is kept. Otherwise, totals are grouped by quote.
+type BalanceSettings struct {
+	TradedOnly bool     // count only assets from settings pairs and Include
+	Include    []string // extra assets counted as traded
+	Exclude    []string // assets never counted in total
 }
 
 type Settings struct {
-	Pairs    []string
-	Telegram TelegramSettings
+	Pairs        []string
+	Exchange     ExchangeSettings
+	Telegram     TelegramSettings
+	NumberFormat NumberFormat // display format of numbers in notifications, plain by default
+	// Timezone is the display timezone of human-facing timestamps, eg: "America/Sao_Paulo", UTC by default.
+	// Stored data is always kept in UTC.
+	Timezone string
+	// MinOrderQuote is the minimum value of an order in quote currency by pair, eg: {"BTCUSDT": 20}
+	// Orders adding to a position below the value are rejected, even if they are accepted by the exchange.
+	// Orders reducing a position and internal exits (eg: flatten, virtual orders) are exempt.
+	MinOrderQuote map[string]float64
+	// MaxSlippage is the maximum deviation of the estimated fill of market orders from the last quote,
+	// eg: 0.01 for 1%. Orders above it are rejected, or converted to protective limit orders with SlippageLimit.
+	MaxSlippage   float64
+	SlippageLimit bool
+	// AutoReduceOrders reduces buy orders above the free quote to the maximum affordable size, minus a margin
+	// of 0.5% for fees and price changes, instead of rejecting them, eg: for all-in strategies. Reduced orders
+	// below MinOrderQuote are still rejected.
+	AutoReduceOrders bool
+	// EntryCooldown is the minimum time between two entries of the same side in a pair, eg: two buys,
+	// to avoid stacking positions on a runaway signal. Orders reducing the position are exempt.
+	EntryCooldown time.Duration
 }
 
 type Balance struct {
@@ -213,3 +249,49 @@ func (ha *HeikinAshi) CalculateHeikinAshi(c Candle) Candle {
 
 	return hkCandle
 }
+
+// NumberFormat defines how numbers are displayed in notifications, eg: 1.234,56
+// The zero value keeps the plain format, eg: 1234.56
+type NumberFormat struct {
+	Thousands string // thousands separator, eg: "," or "."
+	Decimal   string // decimal mark, default "."
+}
+
+var (
+	NumberFormatPlain = NumberFormat{}
+	NumberFormatUS    = NumberFormat{Thousands: ",", Decimal: "."}
+	NumberFormatEU    = NumberFormat{Thousands: ".", Decimal: ","}
+)
+
+// Format returns the value with the given number of decimals
+func (f NumberFormat) Format(value float64, precision int) string {
+	text := strconv.FormatFloat(value, 'f', precision, 64)
+	if f.Thousands == "" && (f.Decimal == "" || f.Decimal == ".") {
+		return text
+	}
+
+	sign := ""
+	if strings.HasPrefix(text, "-") {
+		sign, text = "-", text[1:]
+	}
+
+	integer, fraction, hasFraction := strings.Cut(text, ".")
+	if f.Thousands != "" && len(integer) > 3 {
+		var groups []string
+		for len(integer) > 3 {
+			groups = append([]string{integer[len(integer)-3:]}, groups...)
+			integer = integer[:len(integer)-3]
+		}
+		integer = strings.Join(append([]string{integer}, groups...), f.Thousands)
+	}
+
+	if !hasFraction {
+		return sign + integer
+	}
+
+	decimal := f.Decimal
+	if decimal == "" {
+		decimal = "."
+	}
+	return sign + integer + decimal + fraction
+}
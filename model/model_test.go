@@ -139,3 +139,23 @@ func TestDataframe_Sample(t *testing.T) {
 	sample.Metadata["test"] = []float64{10, 11, 12, 13, 14}
 	require.Equal(t, df.Metadata["test"], Series[float64]([]float64{1, 2, 3, 4, 5, 6, 7, 8, 9}))
 }
+
+func TestNumberFormat_Format(t *testing.T) {
+	tt := []struct {
+		format    NumberFormat
+		value     float64
+		precision int
+		expected  string
+	}{
+		{NumberFormatPlain, 1234567.891, 2, "1234567.89"},
+		{NumberFormatUS, 1234567.891, 2, "1,234,567.89"},
+		{NumberFormatEU, 1234567.891, 2, "1.234.567,89"},
+		{NumberFormatEU, -1234.5, 0, "-1.234"},
+		{NumberFormatEU, 123.456, 4, "123,4560"},
+		{NumberFormat{Thousands: " ", Decimal: ","}, 98765.4321, 1, "98 765,4"},
+	}
+
+	for _, tc := range tt {
+		require.Equal(t, tc.expected, tc.format.Format(tc.value, tc.precision))
+	}
+}
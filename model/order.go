@@ -20,6 +20,10 @@ var (
 	OrderTypeStopLossLimit   OrderType = "STOP_LOSS_LIMIT"
 	OrderTypeTakeProfit      OrderType = "TAKE_PROFIT"
 	OrderTypeTakeProfitLimit OrderType = "TAKE_PROFIT_LIMIT"
+	// OrderTypeMarketIfTouched is a virtual order, watched by the bot and sent as market order on touch
+	OrderTypeMarketIfTouched OrderType = "MARKET_IF_TOUCHED"
+	// OrderTypeTrailingTakeProfit is a virtual order, watched by the bot and sent as market order to lock profit
+	OrderTypeTrailingTakeProfit OrderType = "TRAILING_TAKE_PROFIT"
 
 	OrderStatusTypeNew             OrderStatusType = "NEW"
 	OrderStatusTypePartiallyFilled OrderStatusType = "PARTIALLY_FILLED"
@@ -31,14 +35,23 @@ var (
 )
 
 type Order struct {
-	ID         int64           `db:"id" json:"id" gorm:"primaryKey,autoIncrement"`
-	ExchangeID int64           `db:"exchange_id" json:"exchange_id"`
-	Pair       string          `db:"pair" json:"pair"`
-	Side       SideType        `db:"side" json:"side"`
-	Type       OrderType       `db:"type" json:"type"`
-	Status     OrderStatusType `db:"status" json:"status"`
-	Price      float64         `db:"price" json:"price"`
-	Quantity   float64         `db:"quantity" json:"quantity"`
+	ID         int64 `db:"id" json:"id" gorm:"primaryKey,autoIncrement"`
+	ExchangeID int64 `db:"exchange_id" json:"exchange_id"`
+	// ClientOrderID is the idempotency key sent to the exchange
+	ClientOrderID string          `db:"client_order_id" json:"client_order_id"`
+	Pair          string          `db:"pair" json:"pair"`
+	Side          SideType        `db:"side" json:"side"`
+	Type          OrderType       `db:"type" json:"type"`
+	Status        OrderStatusType `db:"status" json:"status"`
+	Price         float64         `db:"price" json:"price"`
+	Quantity      float64         `db:"quantity" json:"quantity"`
+	// Fee is the value paid in quote, debited from FeeAsset (quote or BNB)
+	Fee      float64 `db:"fee" json:"fee"`
+	FeeAsset string  `db:"fee_asset" json:"fee_asset"`
+	// Strategy is the name of the strategy that created the order, empty for orders created outside strategies
+	Strategy string `db:"strategy" json:"strategy"`
+	// Note is a free annotation of the order, eg: "hedge"
+	Note string `db:"note" json:"note"`
 
 	CreatedAt time.Time `db:"created_at" json:"created_at"`
 	UpdatedAt time.Time `db:"updated_at" json:"updated_at"`
@@ -52,9 +65,17 @@ type Order struct {
 	Profit      float64 `json:"profit" gorm:"-"`
 	ProfitValue float64 `json:"profit_value" gorm:"-"`
 	Candle      Candle  `json:"-" gorm:"-"`
+	// Opening is true for fills opening a position, from flat or reversing it, set by the order controller
+	Opening bool `json:"-" gorm:"-"`
 }
 
 func (o Order) String() string {
-	return fmt.Sprintf("[%s] %s %s | ID: %d, Type: %s, %f x $%f (~$%.f)",
-		o.Status, o.Side, o.Pair, o.ID, o.Type, o.Quantity, o.Price, o.Quantity*o.Price)
+	return o.Format(NumberFormatPlain)
+}
+
+// Format returns the order description with numbers in the given format
+func (o Order) Format(f NumberFormat) string {
+	return fmt.Sprintf("[%s] %s %s | ID: %d, Type: %s, %s x $%s (~$%s)",
+		o.Status, o.Side, o.Pair, o.ID, o.Type, f.Format(o.Quantity, 6), f.Format(o.Price, 6),
+		f.Format(o.Quantity*o.Price, 0))
 }
@@ -22,3 +22,17 @@ func TestOrder_String(t *testing.T) {
 	}
 	require.Equal(t, "[FILLED] SELL BNBUSDT | ID: 1, Type: LIMIT, 1.000000 x $10.000000 (~$10)", order.String())
 }
+
+func TestOrder_Format(t *testing.T) {
+	order := Order{
+		ID:       1,
+		Pair:     "BTCUSDT",
+		Side:     SideTypeBuy,
+		Type:     OrderTypeMarket,
+		Status:   OrderStatusTypeFilled,
+		Price:    25000.5,
+		Quantity: 2,
+	}
+	require.Equal(t, "[FILLED] BUY BTCUSDT | ID: 1, Type: MARKET, 2,000000 x $25.000,500000 (~$50.001)",
+		order.Format(NumberFormatEU))
+}
@@ -39,7 +39,12 @@ func (q *PriorityQueue) Push(item Item) {
 	}
 }
 
+// PopLock returns a channel receiving the top item after each push. Consumers may subscribe while
+// other goroutines push to the queue.
 func (q *PriorityQueue) PopLock() <-chan Item {
+	q.Lock()
+	defer q.Unlock()
+
 	ch := make(chan Item)
 	q.notifyCallbacks = append(q.notifyCallbacks, func(_ Item) {
 		ch <- q.Pop()
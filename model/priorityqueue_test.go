@@ -1,6 +1,7 @@
 package model
 
 import (
+	"sync"
 	"testing"
 	"time"
 
@@ -42,3 +43,27 @@ func TestPriorityQueue_Len(t *testing.T) {
 	pq = NewPriorityQueue([]Item{Candle{Pair: "A"}})
 	require.Equal(t, 1, pq.Len())
 }
+
+func TestPriorityQueue_PopLock(t *testing.T) {
+	pq := NewPriorityQueue(nil)
+
+	// consumers subscribe while items are pushed, no subscription is lost
+	const consumers = 50
+	var wg sync.WaitGroup
+	for i := 0; i < consumers; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			pq.PopLock()
+		}()
+		go func() {
+			defer wg.Done()
+			pq.Push(Candle{Time: time.Now()})
+		}()
+	}
+	wg.Wait()
+
+	pq.Lock()
+	defer pq.Unlock()
+	require.Len(t, pq.notifyCallbacks, consumers)
+}
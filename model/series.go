@@ -1,6 +1,7 @@
 package model
 
 import (
+	"math"
 	"strconv"
 	"strings"
 
@@ -58,3 +59,13 @@ func NumDecPlaces(v float64) int64 {
 	}
 	return 0
 }
+
+// WarmupLength returns the number of leading values in the warmup period, the declared period or the
+// leading NaN values, limited to the length of values. Zeros are valid values, not a warmup.
+func WarmupLength(values []float64, period int) int {
+	leading := 0
+	for leading < len(values) && math.IsNaN(values[leading]) {
+		leading++
+	}
+	return min(max(period, leading), len(values))
+}
@@ -2,6 +2,7 @@ package model
 
 import (
 	"fmt"
+	"math"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -61,3 +62,11 @@ func TestNumDecPlaces(t *testing.T) {
 		})
 	}
 }
+
+func TestWarmupLength(t *testing.T) {
+	nan := math.NaN()
+	require.Equal(t, 2, WarmupLength([]float64{nan, nan, 0, 1}, 0))
+	require.Equal(t, 3, WarmupLength([]float64{nan, nan, 0, 1}, 3))
+	require.Equal(t, 0, WarmupLength([]float64{0, 0, 1}, 0))
+	require.Equal(t, 2, WarmupLength([]float64{0, 1}, 5))
+}
@@ -0,0 +1,152 @@
+package model
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rodrigo-brito/ninjabot/tools/log"
+)
+
+// Environment variables of settings, they override the values of the settings file
+const (
+	EnvPairs           = "NINJABOT_PAIRS"            // comma separated, eg: BTCUSDT,ETHUSDT
+	EnvAPIKey          = "NINJABOT_API_KEY"          // exchange API key
+	EnvAPISecret       = "NINJABOT_API_SECRET"       // exchange API secret
+	EnvTelegramEnabled = "NINJABOT_TELEGRAM_ENABLED" // true or false
+	EnvTelegramToken   = "NINJABOT_TELEGRAM_TOKEN"   // Telegram bot token
+	EnvTelegramUsers   = "NINJABOT_TELEGRAM_USERS"   // comma separated user ids, eg: 1234,5678
+)
+
+// ExchangeSettings are the credentials of the exchange, eg: for exchange.WithBinanceCredentials
+type ExchangeSettings struct {
+	APIKey    string
+	APISecret string
+}
+
+// LoadSettings reads the settings from a JSON file, with field names as keys (eg: {"Pairs": ["BTCUSDT"]}),
+// and overrides them with environment variables. The precedence is env over file over defaults,
+// an empty file name loads the settings only from the environment.
+func LoadSettings(file string) (Settings, error) {
+	var settings Settings
+	if file != "" {
+		content, err := os.ReadFile(file)
+		if err != nil {
+			return Settings{}, err
+		}
+
+		if err := json.Unmarshal(content, &settings); err != nil {
+			return Settings{}, fmt.Errorf("invalid settings file %s: %w", file, err)
+		}
+	}
+
+	if err := settings.LoadEnv(); err != nil {
+		return Settings{}, err
+	}
+	return settings, nil
+}
+
+// Location returns the display timezone of the settings, UTC if not set
+func (s Settings) Location() (*time.Location, error) {
+	if s.Timezone == "" {
+		return time.UTC, nil
+	}
+
+	location, err := time.LoadLocation(s.Timezone)
+	if err != nil {
+		return nil, fmt.Errorf("invalid timezone %s: %w", s.Timezone, err)
+	}
+	return location, nil
+}
+
+// Redacted is the value of the secrets in shared settings
+const Redacted = "[REDACTED]"
+
+// Effective returns the settings in use, with the defaults resolved and the secrets redacted, eg: to be
+// shared in support requests. The format of the settings file is kept.
+func (s Settings) Effective() Settings {
+	if s.Timezone == "" {
+		s.Timezone = time.UTC.String()
+	}
+	if s.NumberFormat.Decimal == "" {
+		s.NumberFormat.Decimal = "."
+	}
+
+	redact := func(secret *string) {
+		if *secret != "" {
+			*secret = Redacted
+		}
+	}
+	redact(&s.Exchange.APIKey)
+	redact(&s.Exchange.APISecret)
+	redact(&s.Telegram.Token)
+	return s
+}
+
+// LoadEnv overrides the settings with the defined environment variables. Values are never logged,
+// only the names of the variables in use.
+func (s *Settings) LoadEnv() error {
+	var loaded []string
+	lookup := func(name string) (string, bool) {
+		value, ok := os.LookupEnv(name)
+		if !ok || strings.TrimSpace(value) == "" {
+			return "", false
+		}
+		loaded = append(loaded, name)
+		return strings.TrimSpace(value), true
+	}
+
+	if value, ok := lookup(EnvPairs); ok {
+		s.Pairs = splitList(value)
+	}
+
+	if value, ok := lookup(EnvAPIKey); ok {
+		s.Exchange.APIKey = value
+	}
+
+	if value, ok := lookup(EnvAPISecret); ok {
+		s.Exchange.APISecret = value
+	}
+
+	if value, ok := lookup(EnvTelegramEnabled); ok {
+		enabled, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid %s, expected true or false", EnvTelegramEnabled)
+		}
+		s.Telegram.Enabled = enabled
+	}
+
+	if value, ok := lookup(EnvTelegramToken); ok {
+		s.Telegram.Token = value
+	}
+
+	if value, ok := lookup(EnvTelegramUsers); ok {
+		users := make([]int, 0)
+		for _, item := range splitList(value) {
+			user, err := strconv.Atoi(item)
+			if err != nil {
+				return fmt.Errorf("invalid %s, expected comma separated user ids", EnvTelegramUsers)
+			}
+			users = append(users, user)
+		}
+		s.Telegram.Users = users
+	}
+
+	if len(loaded) > 0 {
+		log.Infof("[SETUP] Settings from environment: %s", strings.Join(loaded, ", "))
+	}
+	return nil
+}
+
+func splitList(value string) []string {
+	items := make([]string, 0)
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -0,0 +1,94 @@
+package model
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadSettings(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "settings.json")
+	content := `{"Pairs": ["BTCUSDT"], "Telegram": {"Enabled": true, "Token": "file-token", "Users": [1]},
+		"Exchange": {"APIKey": "file-key"}, "MaxSlippage": 0.01}`
+	require.NoError(t, os.WriteFile(file, []byte(content), 0o600))
+
+	settings, err := LoadSettings(file)
+	require.NoError(t, err)
+	require.Equal(t, []string{"BTCUSDT"}, settings.Pairs)
+	require.Equal(t, "file-token", settings.Telegram.Token)
+	require.Equal(t, "file-key", settings.Exchange.APIKey)
+
+	t.Run("env over file", func(t *testing.T) {
+		t.Setenv(EnvPairs, "ETHUSDT, BNBUSDT")
+		t.Setenv(EnvTelegramToken, "env-token")
+		t.Setenv(EnvTelegramUsers, "2,3")
+		t.Setenv(EnvAPISecret, "env-secret")
+		t.Setenv(EnvAPIKey, "")
+
+		settings, err := LoadSettings(file)
+		require.NoError(t, err)
+		require.Equal(t, []string{"ETHUSDT", "BNBUSDT"}, settings.Pairs)
+		require.Equal(t, "env-token", settings.Telegram.Token)
+		require.Equal(t, []int{2, 3}, settings.Telegram.Users)
+		require.True(t, settings.Telegram.Enabled)
+		require.Equal(t, "file-key", settings.Exchange.APIKey)
+		require.Equal(t, "env-secret", settings.Exchange.APISecret)
+		require.Equal(t, 0.01, settings.MaxSlippage)
+	})
+
+	t.Run("env only", func(t *testing.T) {
+		t.Setenv(EnvTelegramEnabled, "false")
+		settings, err := LoadSettings("")
+		require.NoError(t, err)
+		require.False(t, settings.Telegram.Enabled)
+		require.Empty(t, settings.Pairs)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		t.Setenv(EnvTelegramUsers, "1,secret")
+		_, err := LoadSettings(file)
+		require.Error(t, err)
+		require.NotContains(t, err.Error(), "secret")
+
+		_, err = LoadSettings(filepath.Join(t.TempDir(), "missing.json"))
+		require.Error(t, err)
+	})
+}
+
+func TestSettings_Location(t *testing.T) {
+	location, err := Settings{}.Location()
+	require.NoError(t, err)
+	require.Equal(t, time.UTC, location)
+
+	location, err = Settings{Timezone: "America/Sao_Paulo"}.Location()
+	require.NoError(t, err)
+	require.Equal(t, "America/Sao_Paulo", location.String())
+
+	_, err = Settings{Timezone: "Mars/Olympus"}.Location()
+	require.Error(t, err)
+}
+
+func TestSettings_Effective(t *testing.T) {
+	settings := Settings{
+		Pairs:    []string{"BTCUSDT"},
+		Exchange: ExchangeSettings{APIKey: "key", APISecret: "secret"},
+		Telegram: TelegramSettings{Enabled: true, Token: "token", Users: []int{1}},
+	}
+
+	effective := settings.Effective()
+	require.Equal(t, Redacted, effective.Exchange.APIKey)
+	require.Equal(t, Redacted, effective.Exchange.APISecret)
+	require.Equal(t, Redacted, effective.Telegram.Token)
+	require.Equal(t, "UTC", effective.Timezone)
+	require.Equal(t, ".", effective.NumberFormat.Decimal)
+	require.Equal(t, settings.Pairs, effective.Pairs)
+
+	// original settings are kept
+	require.Equal(t, "secret", settings.Exchange.APISecret)
+
+	// missing secrets are not flagged
+	require.Empty(t, Settings{}.Effective().Telegram.Token)
+}
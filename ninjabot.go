@@ -3,13 +3,17 @@ package ninjabot
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"strconv"
+	"sync"
+	"time"
 
 	"github.com/aybabtme/uniplot/histogram"
 
 	"github.com/rodrigo-brito/ninjabot/exchange"
+	"github.com/rodrigo-brito/ninjabot/indicator"
 	"github.com/rodrigo-brito/ninjabot/model"
 	"github.com/rodrigo-brito/ninjabot/notification"
 	"github.com/rodrigo-brito/ninjabot/order"
@@ -20,11 +24,17 @@ import (
 	"github.com/rodrigo-brito/ninjabot/tools/metrics"
 
 	"github.com/olekukonko/tablewriter"
+	"github.com/samber/lo"
 	"github.com/schollz/progressbar/v3"
+	"github.com/xhit/go-str2duration/v2"
 )
 
 const defaultDatabase = "ninjabot.db"
 
+// defaultWarmupStartLimit is the maximum number of candles fetched from the warmup start outside backtests,
+// without a warmup limit
+const defaultWarmupStartLimit = 10000
+
 func init() {
 	log.SetFormatter(&log.TextFormatter{
 		FullTimestamp:   true,
@@ -40,8 +50,16 @@ type CandleSubscriber interface {
 	OnCandle(model.Candle)
 }
 
+// notifierSetter is implemented by exchanges with their own notifications, eg: IP bans of Binance
+type notifierSetter interface {
+	SetNotifier(service.Notifier)
+}
+
 type NinjaBot struct {
+	name     string
 	storage  storage.Storage
+	fallback *storage.Fallback
+	retry    time.Duration
 	settings model.Settings
 	exchange service.Exchange
 	strategy strategy.Strategy
@@ -52,10 +70,49 @@ type NinjaBot struct {
 	priorityQueueCandle   *model.PriorityQueue
 	strategiesControllers map[string]*strategy.Controller
 	orderFeed             *order.Feed
+	ticker                *strategy.Ticker
+	candleCloser          *candleCloser
+	closeTolerance        time.Duration
+	strategyMtx           sync.Mutex
 	dataFeed              *exchange.DataFeedSubscription
 	paperWallet           *exchange.PaperWallet
 
-	backtest bool
+	alertRules   []notification.AlertRule
+	customs      []indicator.Custom
+	indicators   *indicator.Registry
+	warmupLimit  int
+	warmupSource service.Feeder
+	warmupStart  time.Time
+	warmupStrict bool
+
+	equityStorage  storage.EquityStorage
+	equityInterval time.Duration
+
+	milestones *order.MilestoneRule
+
+	dailySummary         bool
+	dailySummaryAt       time.Duration
+	dailySummaryLocation *time.Location
+
+	paperTrading bool
+	paperQuote   string
+	paperOptions []exchange.PaperWalletOption
+
+	recordFile string
+	recorder   *exchange.CandleRecorder
+
+	positionSnapshots *notification.PositionSnapshots
+
+	auditFile    string
+	auditOptions []order.AuditOption
+	auditLog     *order.AuditLog
+
+	logger   *log.Logger
+	logLevel *log.Level
+
+	backtest     bool
+	replay       bool
+	hideProgress bool
 }
 
 type Option func(*NinjaBot)
@@ -84,28 +141,138 @@ func NewBot(ctx context.Context, settings model.Settings, exch service.Exchange,
 		option(bot)
 	}
 
-	var err error
-	if bot.storage == nil {
-		bot.storage, err = storage.FromFile(defaultDatabase)
+	if bot.logger == nil {
+		bot.logger = log.New()
+	}
+	if bot.logLevel != nil {
+		bot.logger.SetLevel(*bot.logLevel)
+		log.SetLevel(*bot.logLevel)
+	}
+
+	bot.indicators = indicator.NewRegistry()
+	for _, custom := range bot.customs {
+		if err := bot.indicators.Register(custom); err != nil {
+			return nil, err
+		}
+	}
+
+	timeframe, err := str2duration.ParseDuration(str.Timeframe())
+	if err != nil && bot.closeTolerance > 0 {
+		return nil, fmt.Errorf("invalid strategy timeframe %s: %w", str.Timeframe(), err)
+	}
+
+	// tolerance is based on the wall clock, replayed candles are closed by the feed only
+	tolerance := bot.closeTolerance
+	if bot.replay {
+		tolerance = 0
+	}
+	bot.candleCloser = newCandleCloser(timeframe, tolerance, bot.logger)
+
+	if bot.paperTrading {
+		options := append([]exchange.PaperWalletOption{exchange.WithDataFeed(exch)}, bot.paperOptions...)
+		bot.paperWallet = exchange.NewPaperWallet(ctx, bot.paperQuote, options...)
+		bot.exchange = bot.paperWallet
+	}
+
+	if bot.storage == nil && bot.retry > 0 {
+		bot.fallback, err = storage.NewFallback(func() (storage.Storage, error) {
+			return storage.FromFile(bot.databaseFile())
+		})
+		if err != nil {
+			return nil, err
+		}
+		bot.storage = bot.fallback
+	} else if bot.storage == nil {
+		bot.storage, err = storage.FromFile(bot.databaseFile())
 		if err != nil {
 			return nil, err
 		}
 	}
 
-	bot.orderController = order.NewController(ctx, exch, bot.storage, bot.orderFeed)
+	bot.orderController = order.NewController(ctx, bot.exchange, bot.storage, bot.orderFeed)
+	bot.orderController.SetLogger(bot.logger)
+	bot.orderController.SetNumberFormat(settings.NumberFormat)
+	bot.orderController.SetMinOrderQuote(settings.MinOrderQuote)
+	bot.orderController.SetMaxSlippage(settings.MaxSlippage, settings.SlippageLimit)
+	bot.orderController.SetEntryCooldown(settings.EntryCooldown)
+	bot.orderController.SetAutoReduce(settings.AutoReduceOrders)
+	if bot.milestones != nil {
+		bot.orderController.SetMilestones(*bot.milestones, settings.Pairs)
+	}
 
 	if settings.Telegram.Enabled {
-		bot.telegram, err = notification.NewTelegram(bot.orderController, settings)
+		options := []notification.Option{
+			notification.WithDataFeed(bot.dataFeed), notification.WithEquityStorage(bot.equityStorage),
+			notification.WithPaperTrading(bot.paperWallet != nil && !bot.backtest),
+			notification.WithStrategy(str),
+		}
+		if bot.positionSnapshots != nil {
+			options = append(options, notification.WithPositionSnapshots(*bot.positionSnapshots))
+		}
+		bot.telegram, err = notification.NewTelegram(bot.orderController, settings, options...)
 		if err != nil {
 			return nil, err
 		}
 		// register telegram as notifier
-		WithNotifier(bot.telegram)(bot)
+		bot.notifier = bot.telegram
+	}
+
+	if bot.notifier != nil {
+		bot.orderController.SetNotifier(bot.notifier)
+		bot.SubscribeOrder(bot.notifier)
+		if setter, ok := bot.exchange.(notifierSetter); ok {
+			setter.SetNotifier(bot.notifier)
+		}
+
+		if bot.fallback != nil && bot.fallback.Degraded() {
+			bot.notifier.Notify("[WARNING] Storage unavailable, orders are kept in memory until it is back")
+		}
+	}
+
+	if len(bot.alertRules) > 0 {
+		if bot.notifier == nil {
+			return nil, errors.New("indicator alerts require a notifier")
+		}
+		bot.SubscribeCandle(notification.NewIndicatorWatcher(bot.notifier, bot.alertRules...))
+	}
+
+	if bot.dailySummary && bot.notifier == nil {
+		return nil, errors.New("daily summary requires a notifier")
+	}
+
+	if bot.dailySummary && bot.dailySummaryLocation == nil {
+		bot.dailySummaryLocation, err = settings.Location()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if bot.recordFile != "" {
+		bot.recorder, err = exchange.NewCandleRecorder(bot.recordFile)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if bot.auditFile != "" {
+		bot.auditLog, err = order.NewAuditLog(bot.auditFile, bot.auditOptions...)
+		if err != nil {
+			return nil, err
+		}
+		bot.orderController.SetAuditLog(bot.auditLog)
 	}
 
 	return bot, nil
 }
 
+// databaseFile returns the file of the default storage, unique by bot name
+func (n *NinjaBot) databaseFile() string {
+	if n.name == "" {
+		return defaultDatabase
+	}
+	return fmt.Sprintf("ninjabot-%s.db", n.name)
+}
+
 // WithBacktest sets the bot to run in backtest mode, it is required for backtesting environments
 // Backtest mode optimize the input read for CSV and deal with race conditions
 func WithBacktest(wallet *exchange.PaperWallet) Option {
@@ -116,6 +283,65 @@ func WithBacktest(wallet *exchange.PaperWallet) Option {
 	}
 }
 
+// WithReplay runs the bot like a backtest on a stream recorded with WithCandleRecording, the wallet must be
+// fed by exchange.NewReplayFeed. Unlike backtests, the strategy is warmed up with the recorded warmup candles
+// before the stream, reproducing the decisions of the live session. eg:
+// feed, err := exchange.NewReplayFeed("stream.jsonl")
+// wallet := exchange.NewPaperWallet(ctx, "USDT", exchange.WithDataFeed(feed), exchange.WithPaperAsset("USDT", 10000))
+// ninjabot.NewBot(ctx, settings, wallet, strategy, ninjabot.WithReplay(wallet))
+func WithReplay(wallet *exchange.PaperWallet) Option {
+	return func(bot *NinjaBot) {
+		WithBacktest(wallet)(bot)
+		bot.replay = true
+	}
+}
+
+// WithCandleRecording writes the warmup and live candles of the strategy to a new file, including partial
+// candles, to reproduce the session later with WithReplay. The bot fails to start if the file exists.
+func WithCandleRecording(file string) Option {
+	return func(bot *NinjaBot) {
+		bot.recordFile = file
+	}
+}
+
+// WithPositionSnapshots attaches a chart of the recent candles to the Telegram notifications of fills opening
+// a position, eg: notification.PositionSnapshots{Chart: chart}. The chart must be subscribed to the bot candles
+// and orders, with WithCandleSubscription and WithOrderSubscription.
+func WithPositionSnapshots(snapshots notification.PositionSnapshots) Option {
+	return func(bot *NinjaBot) {
+		bot.positionSnapshots = &snapshots
+	}
+}
+
+// WithAuditLog appends every order event (create, fill, cancel, reject) to the file as JSON lines,
+// separate from the storage, eg: ninjabot.WithAuditLog("audit.jsonl", order.WithAuditDailyRotation())
+func WithAuditLog(file string, options ...order.AuditOption) Option {
+	return func(bot *NinjaBot) {
+		bot.auditFile = file
+		bot.auditOptions = options
+	}
+}
+
+// WithPaperTrading executes orders in a paper wallet fed by the live data of the bot exchange.
+// Candles and quotes come from the exchange, but fills are simulated, without risk. eg:
+// ninjabot.NewBot(ctx, settings, binance, strategy, ninjabot.WithPaperTrading("USDT",
+// exchange.WithPaperAsset("USDT", 10000)))
+func WithPaperTrading(baseCoin string, options ...exchange.PaperWalletOption) Option {
+	return func(bot *NinjaBot) {
+		bot.paperTrading = true
+		bot.paperQuote = baseCoin
+		bot.paperOptions = options
+	}
+}
+
+// WithName identifies the bot instance, required to run multiple bots with the default storage in the
+// same process. The default storage file of a named bot is ninjabot-<name>.db
+func WithName(name string) Option {
+	return func(bot *NinjaBot) {
+		bot.name = name
+	}
+}
+
 // WithStorage sets the storage for the bot, by default it uses a local file called ninjabot.db
 func WithStorage(storage storage.Storage) Option {
 	return func(bot *NinjaBot) {
@@ -123,10 +349,29 @@ func WithStorage(storage storage.Storage) Option {
 	}
 }
 
-// WithLogLevel sets the log level. eg: log.DebugLevel, log.InfoLevel, log.WarnLevel, log.ErrorLevel, log.FatalLevel
+// WithStorageFallback keeps the bot running with a memory storage when the database file can't be
+// opened, retrying in each interval and flushing the orders once it is back. By default, the bot
+// fails to start. It has no effect with a custom storage.
+func WithStorageFallback(retryInterval time.Duration) Option {
+	return func(bot *NinjaBot) {
+		bot.retry = retryInterval
+	}
+}
+
+// WithLogLevel sets the log level of the bot. eg: log.DebugLevel, log.InfoLevel, log.WarnLevel, log.ErrorLevel,
+// log.FatalLevel. The standard logger, used by the packages logging globally, is set to the same level, so
+// with multiple bots the last level set applies to those packages.
 func WithLogLevel(level log.Level) Option {
-	return func(_ *NinjaBot) {
-		log.SetLevel(level)
+	return func(bot *NinjaBot) {
+		bot.logLevel = &level
+	}
+}
+
+// WithLogger sets the logger of the bot, its orders and strategy. By default, each bot has its own logger
+// with the format and level of the standard logger.
+func WithLogger(logger *log.Logger) Option {
+	return func(bot *NinjaBot) {
+		bot.logger = logger
 	}
 }
 
@@ -134,8 +379,96 @@ func WithLogLevel(level log.Level) Option {
 func WithNotifier(notifier service.Notifier) Option {
 	return func(bot *NinjaBot) {
 		bot.notifier = notifier
-		bot.orderController.SetNotifier(notifier)
-		bot.SubscribeOrder(notifier)
+	}
+}
+
+// WithIndicatorAlerts registers indicator-threshold rules evaluated in each candle, independent of strategy.
+// Alerts are sent to the registered notifier, eg: "RSI(14) on BTCUSDT crossed above 70"
+func WithIndicatorAlerts(rules ...notification.AlertRule) Option {
+	return func(bot *NinjaBot) {
+		bot.alertRules = append(bot.alertRules, rules...)
+	}
+}
+
+// WithIndicator computes a custom indicator in the dataframe metadata of the strategy, by name, before the
+// strategy Indicators method. Indicators are scoped to the bot, plot them with plot/indicator.Custom.
+func WithIndicator(custom indicator.Custom) Option {
+	return func(bot *NinjaBot) {
+		bot.customs = append(bot.customs, custom)
+	}
+}
+
+// WithWarmupLimit sets the maximum number of candles fetched to warm up the strategy.
+// The bot fails to start if the strategy warmup period, or the candles from the warmup start in live
+// trading, exceed the limit.
+func WithWarmupLimit(limit int) Option {
+	return func(bot *NinjaBot) {
+		bot.warmupLimit = limit
+	}
+}
+
+// WithStrictWarmup fails to start the bot when the candles available don't cover the strategy warmup period.
+// By default, a warning is logged and the strategy is warmed up with the candles available, eg: newly listed pairs.
+func WithStrictWarmup() Option {
+	return func(bot *NinjaBot) {
+		bot.warmupStrict = true
+	}
+}
+
+// WithWarmupStart warms up the strategy with the candles from the given date forward, instead of the last
+// candles of the warmup period. The candles should cover at least the warmup period. In backtests, candles
+// before the date are skipped and the trading starts after the warmup from the date. In live trading, all
+// candles from the date to now are fetched at startup, up to the warmup limit (10000 candles by default).
+func WithWarmupStart(start time.Time) Option {
+	return func(bot *NinjaBot) {
+		bot.warmupStart = start
+	}
+}
+
+// WithCandleCloseTolerance closes a candle on an update received within the tolerance of the end of its
+// period, eg: 2s, when the final event of the feed arrives late. Each candle is closed once for the strategy,
+// with or without tolerance, and updates received after the close are ignored. It is ignored in backtests.
+func WithCandleCloseTolerance(tolerance time.Duration) Option {
+	return func(bot *NinjaBot) {
+		bot.closeTolerance = tolerance
+	}
+}
+
+// WithWarmupSource loads warmup candles from a local source (eg: CSV feed) instead of the exchange
+// The most recent candles of the source are used.
+func WithWarmupSource(source service.Feeder) Option {
+	return func(bot *NinjaBot) {
+		bot.warmupSource = source
+	}
+}
+
+// WithEquitySnapshots records the account equity, in quote currency, in the given storage at each interval.
+// Snapshots are displayed as a chart by the Telegram /equity command. It is ignored in backtest mode.
+// The storage is closed when the bot stops.
+func WithEquitySnapshots(equity storage.EquityStorage, interval time.Duration) Option {
+	return func(bot *NinjaBot) {
+		bot.equityStorage = equity
+		bot.equityInterval = interval
+	}
+}
+
+// WithDailySummary sends a digest of the day trades and the current equity each day, at the given
+// time of day (eg: 0 for midnight) in the given location (the display timezone of the settings if nil).
+// It requires a notifier.
+func WithDailySummary(at time.Duration, location *time.Location) Option {
+	return func(bot *NinjaBot) {
+		bot.dailySummary = true
+		bot.dailySummaryAt = at
+		bot.dailySummaryLocation = location
+	}
+}
+
+// WithEquityMilestones reserves a fraction of the equity in quote each time it grows by a step from
+// the start, eg: order.MilestoneRule{Step: 0.1, Sweep: 0.05}. Buy orders spending the reserved capital are
+// rejected. Live bots keep the progress in the bot storage across restarts.
+func WithEquityMilestones(rule order.MilestoneRule) Option {
+	return func(bot *NinjaBot) {
+		bot.milestones = &rule
 	}
 }
 
@@ -238,6 +571,11 @@ func (n *NinjaBot) Summary() {
 	table.Render()
 
 	fmt.Println(buffer.String())
+	if strategies := n.orderController.Strategies(); len(strategies) > 1 {
+		fmt.Println("------ STRATEGIES -------")
+		fmt.Println(n.strategiesSummary(strategies))
+	}
+
 	fmt.Println("------ RETURN -------")
 	totalReturn := 0.0
 	returnsPercent := make([]float64, len(returns))
@@ -273,7 +611,42 @@ func (n *NinjaBot) Summary() {
 
 }
 
-func (n NinjaBot) SaveReturns(outputDir string) error {
+// strategiesSummary returns a table with the results of each strategy, all pairs combined
+func (n *NinjaBot) strategiesSummary(strategies []string) string {
+	buffer := bytes.NewBuffer(nil)
+	table := tablewriter.NewWriter(buffer)
+	table.SetHeader([]string{"Strategy", "Trades", "Win", "Loss", "% Win", "Profit", "Volume"})
+	for _, name := range strategies {
+		var (
+			profit, volume float64
+			wins, loses    int
+		)
+		for _, summary := range n.orderController.StrategyResults(name) {
+			profit += summary.Profit()
+			volume += summary.Volume
+			wins += len(summary.Win())
+			loses += len(summary.Lose())
+		}
+
+		winPercent := 0.0
+		if wins+loses > 0 {
+			winPercent = float64(wins) / float64(wins+loses) * 100
+		}
+		table.Append([]string{
+			name,
+			strconv.Itoa(wins + loses),
+			strconv.Itoa(wins),
+			strconv.Itoa(loses),
+			fmt.Sprintf("%.1f %%", winPercent),
+			fmt.Sprintf("%.2f", profit),
+			fmt.Sprintf("%.2f", volume),
+		})
+	}
+	table.Render()
+	return buffer.String()
+}
+
+func (n *NinjaBot) SaveReturns(outputDir string) error {
 	for _, summary := range n.orderController.Results {
 		outputFile := fmt.Sprintf("%s/%s.csv", outputDir, summary.Pair)
 		if err := summary.SaveReturns(outputFile); err != nil {
@@ -288,10 +661,18 @@ func (n *NinjaBot) onCandle(candle model.Candle) {
 }
 
 func (n *NinjaBot) processCandle(candle model.Candle) {
+	n.strategyMtx.Lock()
+	defer n.strategyMtx.Unlock()
+
 	if n.paperWallet != nil {
 		n.paperWallet.OnCandle(candle)
 	}
 
+	candle, open := n.candleCloser.check(candle)
+	if !open {
+		return
+	}
+
 	n.strategiesControllers[candle.Pair].OnPartialCandle(candle)
 	if candle.Complete {
 		n.strategiesControllers[candle.Pair].OnCandle(candle)
@@ -299,6 +680,30 @@ func (n *NinjaBot) processCandle(candle model.Candle) {
 	}
 }
 
+// candleClock returns the time of the latest update of the candle
+func candleClock(candle model.Candle) time.Time {
+	if candle.UpdatedAt.IsZero() {
+		return candle.Time
+	}
+	return candle.UpdatedAt
+}
+
+// runTicker advances the strategy ticker with the wall clock, until the context is done
+func (n *NinjaBot) runTicker(ctx context.Context) {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			n.strategyMtx.Lock()
+			n.ticker.Advance(now)
+			n.strategyMtx.Unlock()
+		}
+	}
+}
+
 // Process pending candles in buffer
 func (n *NinjaBot) processCandles() {
 	for item := range n.priorityQueueCandle.PopLock() {
@@ -307,15 +712,40 @@ func (n *NinjaBot) processCandles() {
 }
 
 // Start the backtest process and create a progress bar
-// backtestCandles will process candles from a prirority queue in chronological order
-func (n *NinjaBot) backtestCandles() {
-	log.Info("[SETUP] Starting backtesting")
+// backtestCandles will process candles from a prirority queue in chronological order, until the
+// queue is empty or the context is done. Results of the processed candles are kept on cancellation.
+func (n *NinjaBot) backtestCandles(ctx context.Context) error {
+	n.logger.Info("[SETUP] Starting backtesting")
+
+	newProgressBar := progressbar.Default
+	if n.hideProgress {
+		newProgressBar = progressbar.DefaultSilent
+	}
 
-	progressBar := progressbar.Default(int64(n.priorityQueueCandle.Len()))
+	progressBar := newProgressBar(int64(n.priorityQueueCandle.Len()))
+	var last time.Time
 	for n.priorityQueueCandle.Len() > 0 {
+		if err := ctx.Err(); err != nil {
+			n.logger.Warnf("[BACKTEST] canceled at %s, %d candles not processed",
+				last.UTC().Format(time.RFC3339), n.priorityQueueCandle.Len())
+			return err
+		}
+
 		item := n.priorityQueueCandle.Pop()
 
 		candle := item.(model.Candle)
+		if candle.Time.Before(n.warmupStart) {
+			if err := progressBar.Add(1); err != nil {
+				n.logger.Warnf("update progressbar fail: %v", err)
+			}
+			continue
+		}
+
+		if n.ticker != nil {
+			// candles are the clock of backtests
+			n.ticker.Advance(candleClock(candle))
+		}
+
 		if n.paperWallet != nil {
 			n.paperWallet.OnCandle(candle)
 		}
@@ -323,26 +753,53 @@ func (n *NinjaBot) backtestCandles() {
 		n.strategiesControllers[candle.Pair].OnPartialCandle(candle)
 		if candle.Complete {
 			n.strategiesControllers[candle.Pair].OnCandle(candle)
+			n.orderController.OnCandle(candle)
 		}
 
+		last = candleClock(candle)
 		if err := progressBar.Add(1); err != nil {
-			log.Warnf("update progressbar fail: %v", err)
+			n.logger.Warnf("update progressbar fail: %v", err)
 		}
 	}
+	return nil
 }
 
 // Before Ninjabot start, we need to load the necessary data to fill strategy indicators
 // Then, we need to get the time frame and warmup period to fetch the necessary candles
 func (n *NinjaBot) preload(ctx context.Context, pair string) error {
-	if n.backtest {
+	backtest := n.backtest && !n.replay
+	if backtest && n.warmupStart.IsZero() {
 		return nil
 	}
 
-	candles, err := n.exchange.CandlesByLimit(ctx, pair, n.strategy.Timeframe(), n.strategy.WarmupPeriod())
+	warmup := n.strategy.WarmupPeriod()
+	if n.warmupLimit > 0 && warmup > n.warmupLimit {
+		return fmt.Errorf("strategy warmup of %d candles exceeds the limit of %d candles", warmup, n.warmupLimit)
+	}
+
+	candles, err := n.warmupCandles(ctx, pair, warmup)
 	if err != nil {
 		return err
 	}
 
+	if len(candles) < warmup {
+		err := fmt.Errorf("%w: warmup requires %d candles of %s, got %d", exchange.ErrInsufficientData,
+			warmup, pair, len(candles))
+		if n.warmupStrict {
+			return err
+		}
+		n.logger.Warn(err)
+	}
+
+	// backtest candles from the warmup start are processed by the backtest itself
+	if backtest {
+		return nil
+	}
+
+	if n.recorder != nil {
+		n.recorder.RecordWarmup(n.strategy.Timeframe(), candles)
+	}
+
 	for _, candle := range candles {
 		n.processCandle(candle)
 	}
@@ -352,11 +809,68 @@ func (n *NinjaBot) preload(ctx context.Context, pair string) error {
 	return nil
 }
 
-// Run will initialize the strategy controller, order controller, preload data and start the bot
+func (n *NinjaBot) warmupCandles(ctx context.Context, pair string, warmup int) ([]model.Candle, error) {
+	var source service.Feeder = n.exchange
+	if n.warmupSource != nil {
+		source = n.warmupSource
+	} else if n.warmupStart.IsZero() {
+		return n.exchange.CandlesByLimit(ctx, pair, n.strategy.Timeframe(), warmup)
+	}
+
+	if !n.backtest && !n.warmupStart.IsZero() {
+		limit := n.warmupLimit
+		if limit <= 0 {
+			limit = defaultWarmupStartLimit
+		}
+
+		timeframe, err := str2duration.ParseDuration(n.strategy.Timeframe())
+		if err == nil && time.Since(n.warmupStart) > time.Duration(limit)*timeframe {
+			return nil, fmt.Errorf("warmup start %s exceeds the limit of %d candles of %s",
+				n.warmupStart.Format(time.RFC3339), limit, n.strategy.Timeframe())
+		}
+	}
+
+	candles, err := source.CandlesByPeriod(ctx, pair, n.strategy.Timeframe(), n.warmupStart, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	candles = lo.Filter(candles, func(candle model.Candle, _ int) bool {
+		return candle.Complete
+	})
+
+	// all candles from the warmup start are used
+	if n.warmupStart.IsZero() && len(candles) > warmup {
+		candles = candles[len(candles)-warmup:]
+	}
+	return candles, nil
+}
+
+// reconcile catches up with orders updated in the exchange while the bot was offline, and restores the
+// milestones progress. Backtests and paper trading are skipped, the paper wallet starts empty and doesn't
+// know the orders and milestones of previous sessions.
+func (n *NinjaBot) reconcile() error {
+	if n.backtest || n.paperWallet != nil {
+		return nil
+	}
+
+	if err := n.orderController.RestoreMilestones(); err != nil {
+		return err
+	}
+	return n.orderController.Reconcile()
+}
+
+// Run will initialize the strategy controller, order controller, preload data and start the bot.
+// A backtest stops when the context is done and returns its error, the Summary of the candles
+// processed up to the cancellation is still available.
 func (n *NinjaBot) Run(ctx context.Context) error {
+	// orders created by the strategy are tagged with its name
+	broker := n.orderController.ForStrategy(strategy.Name(n.strategy))
 	for _, pair := range n.settings.Pairs {
 		// setup and subscribe strategy to data feed (candles)
-		n.strategiesControllers[pair] = strategy.NewStrategyController(pair, n.strategy, n.orderController)
+		n.strategiesControllers[pair] = strategy.NewStrategyController(pair, n.strategy, broker)
+		n.strategiesControllers[pair].SetIndicators(n.indicators)
+		n.strategiesControllers[pair].SetLogger(n.logger)
 
 		// preload candles for warmup period
 		err := n.preload(ctx, pair)
@@ -364,6 +878,10 @@ func (n *NinjaBot) Run(ctx context.Context) error {
 			return err
 		}
 
+		if n.recorder != nil {
+			n.dataFeed.Subscribe(pair, n.strategy.Timeframe(), n.recorder.Consumer(n.strategy.Timeframe()), false)
+		}
+
 		// link to ninja bot controller
 		n.dataFeed.Subscribe(pair, n.strategy.Timeframe(), n.onCandle, false)
 
@@ -373,21 +891,140 @@ func (n *NinjaBot) Run(ctx context.Context) error {
 
 	// start order feed and controller
 	n.orderFeed.Start()
+	if err := n.reconcile(); err != nil {
+		return err
+	}
 	n.orderController.Start()
 	defer n.orderController.Stop()
+	if n.recorder != nil {
+		defer n.recorder.Close()
+	}
+	if n.auditLog != nil {
+		defer n.auditLog.Close()
+	}
+	if n.equityStorage != nil {
+		defer n.equityStorage.Close()
+	}
 	if n.telegram != nil {
 		n.telegram.Start()
 	}
 
+	if n.equityStorage != nil && !n.backtest {
+		go n.recordEquity(ctx)
+	}
+
+	if n.fallback != nil && n.fallback.Degraded() {
+		go n.fallback.Retry(ctx, n.retry)
+	}
+
+	if n.dailySummary && !n.backtest {
+		go n.runDailySummary(ctx)
+	}
+
+	if str, ok := n.strategy.(strategy.TickStrategy); ok {
+		n.ticker = strategy.NewTicker(str, broker, n.backtest)
+		if !n.backtest {
+			n.ticker.Advance(time.Now())
+			go n.runTicker(ctx)
+		}
+	}
+
 	// start data feed and receives new candles
 	n.dataFeed.Start(n.backtest)
 
 	// start processing new candles for production or backtesting environment
 	if n.backtest {
-		n.backtestCandles()
-	} else {
-		n.processCandles()
+		return n.backtestCandles(ctx)
 	}
 
+	n.processCandles()
 	return nil
 }
+
+// recordEquity saves a snapshot of the account equity in each interval, until the context is done
+func (n *NinjaBot) recordEquity(ctx context.Context) {
+	interval := n.equityInterval
+	if interval <= 0 {
+		interval = time.Hour
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			value, err := n.equity()
+			if err == nil {
+				err = n.equityStorage.SaveEquity(storage.EquitySnapshot{Time: now, Value: value})
+			}
+			if err != nil {
+				n.logger.Error(err)
+			}
+		}
+	}
+}
+
+// runDailySummary notifies the summary of the last day at the configured time, until the context is done
+func (n *NinjaBot) runDailySummary(ctx context.Context) {
+	for {
+		next := nextDailySummary(time.Now(), n.dailySummaryAt, n.dailySummaryLocation)
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+			n.notifier.Notify(n.dailySummaryMessage(next))
+		}
+	}
+}
+
+// dailySummaryMessage describes the trades closed in the day ending at end
+func (n *NinjaBot) dailySummaryMessage(end time.Time) string {
+	start := end.AddDate(0, 0, -1)
+	equity, err := n.equity()
+	if err != nil {
+		n.logger.Error(err)
+	}
+	return notification.DailySummary(start, n.orderController.Trades(start, end), equity, n.settings.NumberFormat)
+}
+
+// nextDailySummary returns the next time after now at the given time of day in the location
+func nextDailySummary(now time.Time, at time.Duration, location *time.Location) time.Time {
+	local := now.In(location)
+	next := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, location).Add(at)
+	if !next.After(local) {
+		next = time.Date(local.Year(), local.Month(), local.Day()+1, 0, 0, 0, 0, location).Add(at)
+	}
+	return next
+}
+
+// equity returns the value of settings pairs assets and quotes, in quote currency
+func (n *NinjaBot) equity() (float64, error) {
+	account, err := n.orderController.Account()
+	if err != nil {
+		return 0, err
+	}
+
+	total := 0.0
+	quotes := make(map[string]float64)
+	for _, pair := range n.settings.Pairs {
+		asset, quote := exchange.SplitAssetQuote(pair)
+		assetBalance, quoteBalance := account.Balance(asset, quote)
+
+		price, err := n.orderController.LastQuote(pair)
+		if err != nil {
+			return 0, err
+		}
+
+		total += (assetBalance.Free + assetBalance.Lock) * price
+		quotes[quote] = quoteBalance.Free + quoteBalance.Lock
+	}
+
+	for _, value := range quotes {
+		total += value
+	}
+	return total, nil
+}
@@ -1,8 +1,12 @@
 package ninjabot
 
 import (
+	"bytes"
 	"context"
+	"os"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/rodrigo-brito/ninjabot/strategy"
 
@@ -11,6 +15,9 @@ import (
 	"github.com/stretchr/testify/require"
 
 	"github.com/rodrigo-brito/ninjabot/exchange"
+	"github.com/rodrigo-brito/ninjabot/indicator"
+	"github.com/rodrigo-brito/ninjabot/model"
+	"github.com/rodrigo-brito/ninjabot/order"
 	"github.com/rodrigo-brito/ninjabot/service"
 	"github.com/rodrigo-brito/ninjabot/storage"
 )
@@ -114,3 +121,833 @@ func TestMarketOrder(t *testing.T) {
 
 	bot.Summary()
 }
+
+// customIndicatorStrategy trades with the ema9 of a registered custom indicator
+type customIndicatorStrategy struct {
+	fakeStrategy
+}
+
+func (s customIndicatorStrategy) Indicators(_ *Dataframe) []strategy.ChartIndicator {
+	return nil
+}
+
+func TestCustomIndicator(t *testing.T) {
+	ctx := context.Background()
+	ema9 := indicator.Custom{Name: "ema9", Warmup: 9, Func: func(df *model.Dataframe) []float64 {
+		return talib.Ema(df.Close, 9)
+	}}
+	backtest := func(str strategy.Strategy, options ...Option) *order.Controller {
+		db, err := storage.FromMemory()
+		require.NoError(t, err)
+
+		csvFeed, err := exchange.NewCSVFeed("1d", exchange.PairFeed{
+			Pair:      "BTCUSDT",
+			File:      "testdata/btc-1h.csv",
+			Timeframe: "1h",
+		})
+		require.NoError(t, err)
+
+		wallet := exchange.NewPaperWallet(ctx, "USDT", exchange.WithPaperAsset("USDT", 10000),
+			exchange.WithDataFeed(csvFeed))
+		options = append(options, WithStorage(db), WithBacktest(wallet), WithLogLevel(log.ErrorLevel))
+		bot, err := NewBot(ctx, Settings{Pairs: []string{"BTCUSDT"}}, wallet, str, options...)
+		require.NoError(t, err)
+		require.NoError(t, bot.Run(ctx))
+		return bot.orderController
+	}
+	expected := backtest(new(fakeStrategy)).Results["BTCUSDT"]
+
+	// same trades of the strategy computing the ema9 in Indicators
+	results := backtest(new(customIndicatorStrategy), WithIndicator(ema9)).Results["BTCUSDT"]
+	require.NotEmpty(t, results.Win())
+	require.Equal(t, expected.Win(), results.Win())
+	require.Equal(t, expected.Lose(), results.Lose())
+
+	t.Run("duplicated", func(t *testing.T) {
+		wallet := exchange.NewPaperWallet(ctx, "USDT", exchange.WithPaperAsset("USDT", 10000))
+		_, err := NewBot(ctx, Settings{Pairs: []string{"BTCUSDT"}}, wallet, new(fakeStrategy),
+			WithIndicator(ema9), WithIndicator(ema9), WithLogLevel(log.ErrorLevel))
+		require.ErrorIs(t, err, indicator.ErrInvalidIndicator)
+	})
+}
+
+// cancelStrategy cancels the backtest after a number of candles
+type cancelStrategy struct {
+	*fakeStrategy
+	cancel  context.CancelFunc
+	candles int
+}
+
+func (s *cancelStrategy) OnCandle(df *Dataframe, broker service.Broker) {
+	s.fakeStrategy.OnCandle(df, broker)
+	s.candles--
+	if s.candles == 0 {
+		s.cancel()
+	}
+}
+
+func TestBacktestCancel(t *testing.T) {
+	ctx := context.Background()
+	db, err := storage.FromMemory()
+	require.NoError(t, err)
+
+	csvFeed, err := exchange.NewCSVFeed("1d", exchange.PairFeed{
+		Pair:      "BTCUSDT",
+		File:      "testdata/btc-1h.csv",
+		Timeframe: "1h",
+	})
+	require.NoError(t, err)
+
+	runCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	str := &cancelStrategy{fakeStrategy: new(fakeStrategy), cancel: cancel, candles: 60}
+
+	wallet := exchange.NewPaperWallet(ctx, "USDT", exchange.WithPaperAsset("USDT", 10000),
+		exchange.WithDataFeed(csvFeed))
+	bot, err := NewBot(ctx, Settings{Pairs: []string{"BTCUSDT"}}, wallet, str,
+		WithStorage(db),
+		WithBacktest(wallet),
+		WithLogLevel(log.ErrorLevel),
+	)
+	require.NoError(t, err)
+
+	err = bot.Run(runCtx)
+	require.ErrorIs(t, err, context.Canceled)
+	require.Equal(t, 0, str.candles)
+	require.Positive(t, bot.priorityQueueCandle.Len())
+
+	// partial results, up to the cancellation
+	results := bot.orderController.Results["BTCUSDT"]
+	require.NotEmpty(t, results.Win())
+	require.Less(t, len(results.Win())+len(results.Lose()), 8)
+
+	account, err := wallet.Account()
+	require.NoError(t, err)
+	require.NotEmpty(t, account.Balances)
+	bot.Summary()
+}
+
+func TestBenchmark(t *testing.T) {
+	ctx := context.Background()
+	log.SetLevel(log.ErrorLevel)
+
+	newWallet := func() (*exchange.PaperWallet, error) {
+		csvFeed, err := exchange.NewCSVFeed("1d", exchange.PairFeed{
+			Pair:      "BTCUSDT",
+			File:      "testdata/btc-1h.csv",
+			Timeframe: "1h",
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		return exchange.NewPaperWallet(ctx, "USDT",
+			exchange.WithPaperAsset("USDT", 10000),
+			exchange.WithDataFeed(csvFeed),
+		), nil
+	}
+
+	settings := Settings{Pairs: []string{"BTCUSDT"}}
+	result, err := Benchmark(ctx, settings, new(fakeStrategy), 5, 42, newWallet)
+	require.NoError(t, err)
+	require.Len(t, result.Returns, 5)
+	require.Greater(t, result.EntryChance, 0.0)
+	require.GreaterOrEqual(t, result.Holding, 1)
+	require.LessOrEqual(t, result.P5, result.P50)
+	require.LessOrEqual(t, result.P50, result.P95)
+
+	// same seed reproduces the same distribution
+	again, err := Benchmark(ctx, settings, new(fakeStrategy), 5, 42, newWallet)
+	require.NoError(t, err)
+	require.Equal(t, result.Returns, again.Returns)
+	require.Equal(t, result.Strategy, again.Strategy)
+
+	result.Print()
+}
+
+type offlineExchange struct {
+	service.Exchange
+}
+
+type candleRecorder struct {
+	candles []model.Candle
+}
+
+func (c *candleRecorder) OnCandle(candle model.Candle) {
+	c.candles = append(c.candles, candle)
+}
+
+func TestWarmupSource(t *testing.T) {
+	ctx := context.Background()
+
+	db, err := storage.FromMemory()
+	require.NoError(t, err)
+
+	csvFeed, err := exchange.NewCSVFeed("1d", exchange.PairFeed{
+		Pair:      "BTCUSDT",
+		File:      "testdata/btc-1h.csv",
+		Timeframe: "1h",
+	})
+	require.NoError(t, err)
+
+	// offline exchange panics on any network call
+	recorder := &candleRecorder{}
+	str := new(fakeStrategy)
+	bot, err := NewBot(ctx, Settings{Pairs: []string{"BTCUSDT"}}, offlineExchange{}, str,
+		WithStorage(db),
+		WithWarmupSource(csvFeed),
+		WithCandleSubscription(recorder),
+		WithLogLevel(log.ErrorLevel),
+	)
+	require.NoError(t, err)
+
+	bot.strategiesControllers["BTCUSDT"] = strategy.NewStrategyController("BTCUSDT", str, bot.orderController)
+	require.NoError(t, bot.preload(ctx, "BTCUSDT"))
+
+	candles := csvFeed.CandlePairTimeFrame["BTCUSDT--1d"]
+	require.Len(t, recorder.candles, str.WarmupPeriod())
+	require.Equal(t, candles[len(candles)-1], recorder.candles[len(recorder.candles)-1])
+
+	t.Run("limit", func(t *testing.T) {
+		bot, err := NewBot(ctx, Settings{Pairs: []string{"BTCUSDT"}}, offlineExchange{}, str,
+			WithStorage(db),
+			WithWarmupSource(csvFeed),
+			WithWarmupLimit(5),
+		)
+		require.NoError(t, err)
+		bot.strategiesControllers["BTCUSDT"] = strategy.NewStrategyController("BTCUSDT", str, bot.orderController)
+		require.ErrorContains(t, bot.preload(ctx, "BTCUSDT"), "exceeds the limit of 5 candles")
+	})
+
+	t.Run("insufficient data", func(t *testing.T) {
+		short, err := exchange.NewCSVFeed("1d", exchange.PairFeed{
+			Pair:      "BTCUSDT",
+			File:      "testdata/btc-1d.csv",
+			Timeframe: "1d",
+		})
+		require.NoError(t, err)
+
+		slow := new(slowWarmupStrategy)
+		bot, err := NewBot(ctx, Settings{Pairs: []string{"BTCUSDT"}}, offlineExchange{}, slow,
+			WithStorage(db),
+			WithWarmupSource(short),
+			WithLogLevel(log.ErrorLevel),
+		)
+		require.NoError(t, err)
+		bot.strategiesControllers["BTCUSDT"] = strategy.NewStrategyController("BTCUSDT", slow, bot.orderController)
+		require.NoError(t, bot.preload(ctx, "BTCUSDT"))
+
+		bot, err = NewBot(ctx, Settings{Pairs: []string{"BTCUSDT"}}, offlineExchange{}, slow,
+			WithStorage(db),
+			WithWarmupSource(short),
+			WithStrictWarmup(),
+		)
+		require.NoError(t, err)
+		bot.strategiesControllers["BTCUSDT"] = strategy.NewStrategyController("BTCUSDT", slow, bot.orderController)
+		require.ErrorIs(t, bot.preload(ctx, "BTCUSDT"), exchange.ErrInsufficientData)
+	})
+}
+
+type slowWarmupStrategy struct {
+	fakeStrategy
+}
+
+func (s slowWarmupStrategy) WarmupPeriod() int {
+	return 100
+}
+
+// firstCandleStrategy records the first candle available for trading
+type firstCandleStrategy struct {
+	fakeStrategy
+	first   time.Time
+	candles int
+}
+
+func (s *firstCandleStrategy) OnCandle(df *Dataframe, broker service.Broker) {
+	if s.first.IsZero() {
+		s.first = df.Time[len(df.Time)-1]
+		s.candles = len(df.Close)
+	}
+	s.fakeStrategy.OnCandle(df, broker)
+}
+
+func TestWarmupStart(t *testing.T) {
+	ctx := context.Background()
+	newFeed := func() *exchange.CSVFeed {
+		csvFeed, err := exchange.NewCSVFeed("1d", exchange.PairFeed{
+			Pair:      "BTCUSDT",
+			File:      "testdata/btc-1h.csv",
+			Timeframe: "1h",
+		})
+		require.NoError(t, err)
+		return csvFeed
+	}
+	var candles []model.Candle
+	for _, candle := range newFeed().CandlePairTimeFrame["BTCUSDT--1d"] {
+		if candle.Complete {
+			candles = append(candles, candle)
+		}
+	}
+	start := candles[40].Time
+
+	t.Run("backtest", func(t *testing.T) {
+		db, err := storage.FromMemory()
+		require.NoError(t, err)
+
+		str := new(firstCandleStrategy)
+		wallet := exchange.NewPaperWallet(ctx, "USDT", exchange.WithPaperAsset("USDT", 10000),
+			exchange.WithDataFeed(newFeed()))
+		bot, err := NewBot(ctx, Settings{Pairs: []string{"BTCUSDT"}}, wallet, str,
+			WithStorage(db),
+			WithBacktest(wallet),
+			WithWarmupStart(start),
+			WithLogLevel(log.ErrorLevel),
+		)
+		require.NoError(t, err)
+		require.NoError(t, bot.Run(ctx))
+
+		// first candle after the warmup from the start
+		require.Equal(t, candles[40+str.WarmupPeriod()-1].Time, str.first)
+		require.Equal(t, str.WarmupPeriod(), str.candles)
+
+		// trades start after the warmup from the date
+		orders, err := db.Orders(storage.WithStatus(model.OrderStatusTypeFilled))
+		require.NoError(t, err)
+		require.NotEmpty(t, orders)
+		for _, order := range orders {
+			require.False(t, order.CreatedAt.Before(str.first))
+		}
+	})
+
+	t.Run("live", func(t *testing.T) {
+		db, err := storage.FromMemory()
+		require.NoError(t, err)
+
+		recorder := &candleRecorder{}
+		str := new(fakeStrategy)
+		bot, err := NewBot(ctx, Settings{Pairs: []string{"BTCUSDT"}}, offlineExchange{}, str,
+			WithStorage(db),
+			WithWarmupSource(newFeed()),
+			WithWarmupStart(start),
+			WithCandleSubscription(recorder),
+			WithLogLevel(log.ErrorLevel),
+		)
+		require.NoError(t, err)
+
+		bot.strategiesControllers["BTCUSDT"] = strategy.NewStrategyController("BTCUSDT", str, bot.orderController)
+		require.NoError(t, bot.preload(ctx, "BTCUSDT"))
+		require.Greater(t, len(recorder.candles), str.WarmupPeriod())
+		require.Equal(t, start, recorder.candles[0].Time)
+		require.Equal(t, candles[len(candles)-1], recorder.candles[len(recorder.candles)-1])
+	})
+
+	t.Run("live limit", func(t *testing.T) {
+		db, err := storage.FromMemory()
+		require.NoError(t, err)
+
+		str := new(fakeStrategy)
+		bot, err := NewBot(ctx, Settings{Pairs: []string{"BTCUSDT"}}, offlineExchange{}, str,
+			WithStorage(db),
+			WithWarmupSource(newFeed()),
+			WithWarmupStart(start),
+			WithWarmupLimit(100),
+			WithLogLevel(log.ErrorLevel),
+		)
+		require.NoError(t, err)
+
+		bot.strategiesControllers["BTCUSDT"] = strategy.NewStrategyController("BTCUSDT", str, bot.orderController)
+		require.ErrorContains(t, bot.preload(ctx, "BTCUSDT"), "exceeds the limit of 100 candles")
+	})
+
+	t.Run("insufficient data", func(t *testing.T) {
+		db, err := storage.FromMemory()
+		require.NoError(t, err)
+
+		wallet := exchange.NewPaperWallet(ctx, "USDT", exchange.WithPaperAsset("USDT", 10000),
+			exchange.WithDataFeed(newFeed()))
+		bot, err := NewBot(ctx, Settings{Pairs: []string{"BTCUSDT"}}, wallet, new(fakeStrategy),
+			WithStorage(db),
+			WithBacktest(wallet),
+			WithWarmupStart(candles[len(candles)-5].Time),
+			WithStrictWarmup(),
+			WithLogLevel(log.ErrorLevel),
+		)
+		require.NoError(t, err)
+		require.ErrorIs(t, bot.Run(ctx), exchange.ErrInsufficientData)
+	})
+}
+
+func TestPaperTrading(t *testing.T) {
+	ctx := context.Background()
+
+	db, err := storage.FromMemory()
+	require.NoError(t, err)
+
+	// live data comes from the exchange, but orders are never sent to it
+	bot, err := NewBot(ctx, Settings{Pairs: []string{"BTCUSDT"}}, offlineExchange{}, new(fakeStrategy),
+		WithStorage(db),
+		WithPaperTrading("USDT", exchange.WithPaperAsset("USDT", 1000)),
+	)
+	require.NoError(t, err)
+	require.NotNil(t, bot.paperWallet)
+	require.Equal(t, bot.paperWallet, bot.exchange)
+
+	bot.paperWallet.OnCandle(model.Candle{Pair: "BTCUSDT", Close: 100, High: 100, Low: 100, Complete: true})
+	_, err = bot.Controller().CreateOrderMarket(model.SideTypeBuy, "BTCUSDT", 2)
+	require.NoError(t, err)
+
+	account, err := bot.Controller().Account()
+	require.NoError(t, err)
+	asset, quote := account.Balance("BTC", "USDT")
+	require.Equal(t, 2.0, asset.Free)
+	require.Equal(t, 800.0, quote.Free)
+
+	t.Run("reconcile", func(t *testing.T) {
+		db, err := storage.FromMemory()
+		require.NoError(t, err)
+
+		// pending order of a previous session, with the id of an order of the new paper wallet
+		previous := &model.Order{ExchangeID: 1, Pair: "BTCUSDT", Side: model.SideTypeBuy,
+			Type: model.OrderTypeLimit, Status: model.OrderStatusTypeNew, Price: 90, Quantity: 1}
+		require.NoError(t, db.CreateOrder(previous))
+
+		bot, err := NewBot(ctx, Settings{Pairs: []string{"BTCUSDT"}}, offlineExchange{}, new(fakeStrategy),
+			WithStorage(db),
+			WithPaperTrading("USDT", exchange.WithPaperAsset("USDT", 1000)),
+		)
+		require.NoError(t, err)
+
+		bot.paperWallet.OnCandle(model.Candle{Pair: "BTCUSDT", Close: 100, High: 100, Low: 100, Complete: true})
+		order, err := bot.paperWallet.CreateOrderMarket(model.SideTypeBuy, "BTCUSDT", 1)
+		require.NoError(t, err)
+		require.Equal(t, previous.ExchangeID, order.ExchangeID)
+
+		require.NoError(t, bot.reconcile())
+		orders, err := db.Orders()
+		require.NoError(t, err)
+		require.Len(t, orders, 1)
+		require.Equal(t, model.OrderStatusTypeNew, orders[0].Status)
+	})
+}
+
+type orderNotifier struct {
+	mtx   sync.Mutex
+	pairs map[string]int
+}
+
+func (n *orderNotifier) Notify(string) {}
+
+func (n *orderNotifier) OnError(error) {}
+
+func (n *orderNotifier) OnOrder(order model.Order) {
+	n.mtx.Lock()
+	defer n.mtx.Unlock()
+	n.pairs[order.Pair]++
+}
+
+func TestMultipleBots(t *testing.T) {
+	ctx := context.Background()
+
+	newBot := func(pair, file string, str strategy.Strategy, notifier service.Notifier) *NinjaBot {
+		csvFeed, err := exchange.NewCSVFeed("1d", exchange.PairFeed{
+			Pair:      pair,
+			File:      file,
+			Timeframe: "1h",
+		})
+		require.NoError(t, err)
+
+		wallet := exchange.NewPaperWallet(ctx, "USDT",
+			exchange.WithPaperAsset("USDT", 10000),
+			exchange.WithDataFeed(csvFeed),
+		)
+
+		db, err := storage.FromMemory()
+		require.NoError(t, err)
+
+		bot, err := NewBot(ctx, Settings{Pairs: []string{pair}}, wallet, str,
+			WithName(pair),
+			WithStorage(db),
+			WithBacktest(wallet),
+			WithNotifier(notifier),
+			WithLogLevel(log.ErrorLevel),
+		)
+		require.NoError(t, err)
+		bot.hideProgress = true
+		return bot
+	}
+
+	run := func() (*NinjaBot, *NinjaBot, *orderNotifier, *orderNotifier) {
+		btcNotifier := &orderNotifier{pairs: make(map[string]int)}
+		ethNotifier := &orderNotifier{pairs: make(map[string]int)}
+		btc := newBot("BTCUSDT", "testdata/btc-1h.csv", new(fakeStrategy), btcNotifier)
+		eth := newBot("ETHUSDT", "testdata/eth-1h.csv", strategy.NewRandom("1d", 10, 0.2, 3, 42), ethNotifier)
+		return btc, eth, btcNotifier, ethNotifier
+	}
+
+	// reference results, running one bot at a time
+	btc, eth, _, _ := run()
+	require.NoError(t, btc.Run(ctx))
+	require.NoError(t, eth.Run(ctx))
+	btcEquity, ethEquity := btc.paperWallet.Equity(), eth.paperWallet.Equity()
+
+	btc, eth, btcNotifier, ethNotifier := run()
+	var wg sync.WaitGroup
+	errs := make([]error, 2)
+	for i, bot := range []*NinjaBot{btc, eth} {
+		wg.Add(1)
+		go func(i int, bot *NinjaBot) {
+			defer wg.Done()
+			errs[i] = bot.Run(ctx)
+		}(i, bot)
+	}
+	wg.Wait()
+	require.NoError(t, errs[0])
+	require.NoError(t, errs[1])
+
+	require.Equal(t, btcEquity, btc.paperWallet.Equity())
+	require.Equal(t, ethEquity, eth.paperWallet.Equity())
+
+	for pair, bot := range map[string]*NinjaBot{"BTCUSDT": btc, "ETHUSDT": eth} {
+		orders, err := bot.storage.Orders()
+		require.NoError(t, err)
+		require.NotEmpty(t, orders)
+		for _, order := range orders {
+			require.Equal(t, pair, order.Pair)
+		}
+	}
+
+	require.Eventually(t, func() bool {
+		btcNotifier.mtx.Lock()
+		defer btcNotifier.mtx.Unlock()
+		ethNotifier.mtx.Lock()
+		defer ethNotifier.mtx.Unlock()
+		return btcNotifier.pairs["BTCUSDT"] > 0 && ethNotifier.pairs["ETHUSDT"] > 0 &&
+			btcNotifier.pairs["ETHUSDT"] == 0 && ethNotifier.pairs["BTCUSDT"] == 0
+	}, time.Second, 10*time.Millisecond)
+
+	require.Equal(t, "ninjabot-BTCUSDT.db", btc.databaseFile())
+}
+
+type tickStrategy struct {
+	fakeStrategy
+	ticks []time.Time
+}
+
+func (s *tickStrategy) TickInterval() time.Duration {
+	return 6 * time.Hour
+}
+
+func (s *tickStrategy) OnTick(now time.Time, _ service.Broker) {
+	s.ticks = append(s.ticks, now)
+}
+
+func TestTickStrategy(t *testing.T) {
+	ctx := context.Background()
+
+	csvFeed, err := exchange.NewCSVFeed("1d", exchange.PairFeed{
+		Pair:      "BTCUSDT",
+		File:      "testdata/btc-1h.csv",
+		Timeframe: "1h",
+	})
+	require.NoError(t, err)
+
+	wallet := exchange.NewPaperWallet(ctx, "USDT",
+		exchange.WithPaperAsset("USDT", 10000),
+		exchange.WithDataFeed(csvFeed),
+	)
+
+	db, err := storage.FromMemory()
+	require.NoError(t, err)
+
+	str := &tickStrategy{}
+	bot, err := NewBot(ctx, Settings{Pairs: []string{"BTCUSDT"}}, wallet, str,
+		WithStorage(db),
+		WithBacktest(wallet),
+		WithLogLevel(log.ErrorLevel),
+	)
+	require.NoError(t, err)
+	bot.hideProgress = true
+	require.NoError(t, bot.Run(ctx))
+
+	// ticks follow the candle clock, every 6 hours, even with daily candles
+	candles := csvFeed.CandlePairTimeFrame["BTCUSDT--1d"]
+	first, last := candleClock(candles[0]), candleClock(candles[len(candles)-1])
+	require.NotEmpty(t, str.ticks)
+	require.Len(t, str.ticks, int(last.Truncate(6*time.Hour).Sub(first.Truncate(6*time.Hour))/(6*time.Hour)))
+	for i := 1; i < len(str.ticks); i++ {
+		require.Equal(t, 6*time.Hour, str.ticks[i].Sub(str.ticks[i-1]))
+	}
+}
+
+func TestNextDailySummary(t *testing.T) {
+	saoPaulo := time.FixedZone("BRT", -3*60*60)
+	now := time.Date(2022, 3, 1, 22, 30, 0, 0, time.UTC)
+
+	// end of the UTC day
+	require.Equal(t, time.Date(2022, 3, 2, 0, 0, 0, 0, time.UTC),
+		nextDailySummary(now, 0, time.UTC).UTC())
+
+	// later today, at 23h UTC
+	require.Equal(t, time.Date(2022, 3, 1, 23, 0, 0, 0, time.UTC),
+		nextDailySummary(now, 23*time.Hour, time.UTC).UTC())
+
+	// 19h in local time (22h UTC), already passed for today
+	require.Equal(t, time.Date(2022, 3, 2, 22, 0, 0, 0, time.UTC),
+		nextDailySummary(now, 19*time.Hour, saoPaulo).UTC())
+}
+
+func TestStorageFallback(t *testing.T) {
+	ctx := context.Background()
+	wallet := exchange.NewPaperWallet(ctx, "USDT", exchange.WithPaperAsset("USDT", 1000))
+
+	// database file in a missing directory, the bot starts with orders in memory
+	bot, err := NewBot(ctx, Settings{Pairs: []string{"BTCUSDT"}}, wallet, new(fakeStrategy),
+		WithName("missing/dir"), WithStorageFallback(time.Minute))
+	require.NoError(t, err)
+	require.True(t, bot.fallback.Degraded())
+
+	wallet.OnCandle(model.Candle{Pair: "BTCUSDT", Close: 100, High: 100, Low: 100, Complete: true})
+	_, err = bot.Controller().CreateOrderMarket(model.SideTypeBuy, "BTCUSDT", 1)
+	require.NoError(t, err)
+
+	orders, err := bot.storage.Orders()
+	require.NoError(t, err)
+	require.Len(t, orders, 1)
+}
+
+// streamExchange is a live exchange fed by a CSV feed: warmup candles are fetched by limit,
+// and the remaining candles are streamed by the subscription
+type streamExchange struct {
+	offlineExchange
+	feed *exchange.CSVFeed
+}
+
+func (s streamExchange) AssetsInfo(pair string) model.AssetInfo {
+	return s.feed.AssetsInfo(pair)
+}
+
+func (s streamExchange) LastQuote(ctx context.Context, pair string) (float64, error) {
+	return s.feed.LastQuote(ctx, pair)
+}
+
+func (s streamExchange) CandlesByLimit(ctx context.Context, pair, period string, limit int) ([]model.Candle, error) {
+	return s.feed.CandlesByLimit(ctx, pair, period, limit)
+}
+
+func (s streamExchange) CandlesSubscription(ctx context.Context, pair, timeframe string) (chan model.Candle,
+	chan error) {
+	return s.feed.CandlesSubscription(ctx, pair, timeframe)
+}
+
+// lastCandleStrategy tracks the time of the last candle received by the strategy
+type lastCandleStrategy struct {
+	fakeStrategy
+	mtx  sync.Mutex
+	last time.Time
+}
+
+func (s *lastCandleStrategy) OnCandle(df *Dataframe, broker service.Broker) {
+	s.fakeStrategy.OnCandle(df, broker)
+
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	s.last = df.Time[len(df.Time)-1]
+}
+
+func (s *lastCandleStrategy) Last() time.Time {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	return s.last
+}
+
+func TestCandleRecordingReplay(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	file := t.TempDir() + "/stream.jsonl"
+
+	csvFeed, err := exchange.NewCSVFeed("1d", exchange.PairFeed{
+		Pair:      "BTCUSDT",
+		File:      "testdata/btc-1h.csv",
+		Timeframe: "1h",
+	})
+	require.NoError(t, err)
+	candles := csvFeed.CandlePairTimeFrame["BTCUSDT--1d"]
+	lastCandle := candles[len(candles)-1].Time
+
+	// live session in paper trading, recording the stream
+	live := new(lastCandleStrategy)
+	liveStorage, err := storage.FromMemory()
+	require.NoError(t, err)
+	liveBot, err := NewBot(ctx, Settings{Pairs: []string{"BTCUSDT"}}, streamExchange{feed: csvFeed}, live,
+		WithStorage(liveStorage),
+		WithPaperTrading("USDT", exchange.WithPaperAsset("USDT", 10000)),
+		WithCandleRecording(file),
+		WithLogLevel(log.ErrorLevel),
+	)
+	require.NoError(t, err)
+	go func() {
+		_ = liveBot.Run(ctx)
+	}()
+	require.Eventually(t, func() bool {
+		return live.Last().Equal(lastCandle)
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// replay of the recorded session
+	feed, err := exchange.NewReplayFeed(file)
+	require.NoError(t, err)
+	wallet := exchange.NewPaperWallet(ctx, "USDT", exchange.WithPaperAsset("USDT", 10000),
+		exchange.WithDataFeed(feed))
+	replayStorage, err := storage.FromMemory()
+	require.NoError(t, err)
+	replayBot, err := NewBot(ctx, Settings{Pairs: []string{"BTCUSDT"}}, wallet, new(fakeStrategy),
+		WithStorage(replayStorage),
+		WithReplay(wallet),
+		WithLogLevel(log.ErrorLevel),
+	)
+	require.NoError(t, err)
+	require.NoError(t, replayBot.Run(ctx))
+
+	type decision struct {
+		Side      model.SideType
+		Quantity  float64
+		Price     float64
+		CreatedAt time.Time
+	}
+	decisions := func(db storage.Storage) []decision {
+		orders, err := db.Orders()
+		require.NoError(t, err)
+		result := make([]decision, 0, len(orders))
+		for _, order := range orders {
+			result = append(result, decision{order.Side, order.Quantity, order.Price, order.CreatedAt})
+		}
+		return result
+	}
+
+	liveDecisions := decisions(liveStorage)
+	require.NotEmpty(t, liveDecisions)
+	require.Equal(t, liveDecisions, decisions(replayStorage))
+
+	// a new session doesn't mix its candles with the recorded one
+	sessionStorage, err := storage.FromMemory()
+	require.NoError(t, err)
+	_, err = NewBot(ctx, Settings{Pairs: []string{"BTCUSDT"}}, streamExchange{feed: csvFeed}, new(fakeStrategy),
+		WithStorage(sessionStorage),
+		WithPaperTrading("USDT", exchange.WithPaperAsset("USDT", 10000)),
+		WithCandleRecording(file),
+		WithLogLevel(log.ErrorLevel),
+	)
+	require.ErrorIs(t, err, os.ErrExist)
+}
+
+// closeStrategy records the time of the closed candles
+type closeStrategy struct {
+	closed []time.Time
+}
+
+func (s *closeStrategy) Timeframe() string {
+	return "1h"
+}
+
+func (s *closeStrategy) WarmupPeriod() int {
+	return 1
+}
+
+func (s *closeStrategy) Indicators(_ *Dataframe) []strategy.ChartIndicator {
+	return nil
+}
+
+func (s *closeStrategy) OnCandle(df *Dataframe, _ service.Broker) {
+	s.closed = append(s.closed, df.Time[len(df.Time)-1])
+}
+
+func TestCandleCloseTolerance(t *testing.T) {
+	ctx := context.Background()
+	db, err := storage.FromMemory()
+	require.NoError(t, err)
+
+	str := new(closeStrategy)
+	bot, err := NewBot(ctx, Settings{Pairs: []string{"BTCUSDT"}}, offlineExchange{}, str,
+		WithStorage(db),
+		WithPaperTrading("USDT", exchange.WithPaperAsset("USDT", 1000)),
+		WithCandleCloseTolerance(2*time.Second),
+	)
+	require.NoError(t, err)
+	bot.strategiesControllers["BTCUSDT"] = strategy.NewStrategyController("BTCUSDT", str, bot.Controller())
+	bot.strategiesControllers["BTCUSDT"].Start()
+
+	start := time.Date(2022, 1, 1, 10, 0, 0, 0, time.UTC)
+	updates := []struct {
+		now    time.Time
+		candle model.Candle
+	}{
+		{now: start.Add(30 * time.Minute), candle: model.Candle{Time: start}},
+		// within the tolerance of the end, closed before the final event
+		{now: start.Add(time.Hour - time.Second), candle: model.Candle{Time: start}},
+		{now: start.Add(time.Hour + time.Second), candle: model.Candle{Time: start, Complete: true}},
+		{now: start.Add(time.Hour + time.Second), candle: model.Candle{Time: start}},
+		{now: start.Add(time.Hour + 2*time.Second), candle: model.Candle{Time: start.Add(time.Hour)}},
+		// final event repeated by the feed
+		{now: start.Add(2 * time.Hour), candle: model.Candle{Time: start.Add(time.Hour), Complete: true}},
+		{now: start.Add(2 * time.Hour), candle: model.Candle{Time: start.Add(time.Hour), Complete: true}},
+	}
+
+	for _, update := range updates {
+		now := update.now
+		bot.candleCloser.now = func() time.Time { return now }
+		update.candle.Pair = "BTCUSDT"
+		update.candle.Close, update.candle.High, update.candle.Low = 100, 100, 100
+		bot.processCandle(update.candle)
+	}
+
+	require.Equal(t, []time.Time{start, start.Add(time.Hour)}, str.closed)
+}
+
+func TestRegressionResult_Compare(t *testing.T) {
+	trade := RegressionTrade{Time: time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC), Pair: "BTCUSDT",
+		Side: model.SideTypeBuy, Type: model.OrderTypeMarket, Quantity: 1, Price: 100}
+	golden := RegressionResult{Trades: []RegressionTrade{trade}, Equity: 1000}
+
+	require.NoError(t, golden.Compare(golden, 0))
+
+	// within the tolerance
+	result := RegressionResult{Trades: []RegressionTrade{trade}, Equity: 1000.5}
+	require.NoError(t, result.Compare(golden, 0.001))
+	require.Error(t, result.Compare(golden, 0))
+
+	// diverging trades
+	moved := trade
+	moved.Time = moved.Time.Add(time.Hour)
+	result = RegressionResult{Trades: []RegressionTrade{moved, trade}, Equity: 1000}
+	err := result.Compare(golden, 0.001)
+	require.ErrorContains(t, err, "trades: got 2, expected 1")
+	require.ErrorContains(t, err, "trade 0:")
+}
+
+func TestWithLogLevel(t *testing.T) {
+	ctx := context.Background()
+	level := log.GetLevel()
+
+	newBot := func(options ...Option) *NinjaBot {
+		db, err := storage.FromMemory()
+		require.NoError(t, err)
+		wallet := exchange.NewPaperWallet(ctx, "USDT", exchange.WithPaperAsset("USDT", 10000))
+		wallet.OnCandle(model.Candle{Pair: "BTCUSDT", Close: 100})
+		bot, err := NewBot(ctx, Settings{Pairs: []string{"BTCUSDT"}}, wallet, new(fakeStrategy),
+			append(options, WithStorage(db))...)
+		require.NoError(t, err)
+		return bot
+	}
+
+	quiet := newBot(WithLogLevel(log.ErrorLevel))
+	require.Equal(t, log.ErrorLevel, quiet.logger.GetLevel())
+	require.Equal(t, level, log.GetLevel())
+
+	// orders of the bot are logged by its own logger
+	var output bytes.Buffer
+	logger := log.New()
+	logger.SetOutput(&output)
+	verbose := newBot(WithLogger(logger), WithLogLevel(log.InfoLevel))
+	_, err := verbose.Controller().CreateOrderMarket(model.SideTypeBuy, "BTCUSDT", 1)
+	require.NoError(t, err)
+	require.Contains(t, output.String(), "[ORDER CREATED]")
+}
@@ -0,0 +1,111 @@
+package notification
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/rodrigo-brito/ninjabot/model"
+	"github.com/rodrigo-brito/ninjabot/service"
+)
+
+// maxAlertCandles is the number of candles kept by pair to calculate indicators
+const maxAlertCandles = 500
+
+type CrossDirection string
+
+const (
+	CrossAbove CrossDirection = "above"
+	CrossBelow CrossDirection = "below"
+)
+
+// AlertRule notifies when an indicator crosses a threshold for a pair
+// eg: RSI(14) on BTCUSDT crossed above 70
+type AlertRule struct {
+	Name      string
+	Pair      string
+	Indicator func(df *model.Dataframe) []float64 // eg: indicator.RSI(df.Close, 14)
+	Direction CrossDirection
+	Threshold float64
+	Cooldown  time.Duration // minimum candle time between two alerts of the same rule
+	Warmup    int           // leading values not calculated by the indicator, eg: 14 for RSI(14), besides NaN
+}
+
+// IndicatorWatcher evaluates alert rules in each complete candle, independent of strategy
+type IndicatorWatcher struct {
+	mtx        sync.Mutex
+	notifier   service.Notifier
+	rules      []AlertRule
+	dataframes map[string]*model.Dataframe
+	lastAlert  map[int]time.Time
+}
+
+func NewIndicatorWatcher(notifier service.Notifier, rules ...AlertRule) *IndicatorWatcher {
+	return &IndicatorWatcher{
+		notifier:   notifier,
+		rules:      rules,
+		dataframes: make(map[string]*model.Dataframe),
+		lastAlert:  make(map[int]time.Time),
+	}
+}
+
+func (w *IndicatorWatcher) OnCandle(candle model.Candle) {
+	if !candle.Complete {
+		return
+	}
+
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+
+	df := w.update(candle)
+	for i, rule := range w.rules {
+		if rule.Pair != candle.Pair {
+			continue
+		}
+
+		values := rule.Indicator(df)
+		if len(values)-2 < model.WarmupLength(values, rule.Warmup) || !crossed(rule, values[len(values)-2], values[len(values)-1]) {
+			continue
+		}
+
+		if last, ok := w.lastAlert[i]; ok && candle.Time.Sub(last) < rule.Cooldown {
+			continue
+		}
+
+		w.lastAlert[i] = candle.Time
+		w.notifier.Notify(fmt.Sprintf("[ALERT] %s on %s crossed %s %.2f (%.2f)",
+			rule.Name, rule.Pair, rule.Direction, rule.Threshold, values[len(values)-1]))
+	}
+}
+
+func (w *IndicatorWatcher) update(candle model.Candle) *model.Dataframe {
+	df, ok := w.dataframes[candle.Pair]
+	if !ok {
+		df = &model.Dataframe{
+			Pair:     candle.Pair,
+			Metadata: make(map[string]model.Series[float64]),
+		}
+		w.dataframes[candle.Pair] = df
+	}
+
+	df.Close = append(df.Close, candle.Close)
+	df.Open = append(df.Open, candle.Open)
+	df.High = append(df.High, candle.High)
+	df.Low = append(df.Low, candle.Low)
+	df.Volume = append(df.Volume, candle.Volume)
+	df.Time = append(df.Time, candle.Time)
+	df.LastUpdate = candle.Time
+
+	if len(df.Time) > maxAlertCandles {
+		*df = df.Sample(maxAlertCandles)
+	}
+
+	return df
+}
+
+func crossed(rule AlertRule, previous, current float64) bool {
+	if rule.Direction == CrossBelow {
+		return previous >= rule.Threshold && current < rule.Threshold
+	}
+	return previous <= rule.Threshold && current > rule.Threshold
+}
@@ -0,0 +1,113 @@
+package notification
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/rodrigo-brito/ninjabot/model"
+)
+
+type fakeNotifier struct {
+	messages []string
+}
+
+func (f *fakeNotifier) Notify(message string) {
+	f.messages = append(f.messages, message)
+}
+
+func (f *fakeNotifier) OnOrder(_ model.Order) {}
+
+func (f *fakeNotifier) OnError(_ error) {}
+
+func TestIndicatorWatcher(t *testing.T) {
+	notifier := &fakeNotifier{}
+	watcher := NewIndicatorWatcher(notifier, AlertRule{
+		Name: "CLOSE",
+		Pair: "BTCUSDT",
+		Indicator: func(df *model.Dataframe) []float64 {
+			return df.Close
+		},
+		Direction: CrossAbove,
+		Threshold: 70,
+		Cooldown:  5 * time.Hour,
+	})
+
+	start := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i, value := range []float64{60, 65, 71, 75, 69, 72, 60, 60, 60, 80} {
+		watcher.OnCandle(model.Candle{
+			Pair:     "BTCUSDT",
+			Time:     start.Add(time.Duration(i) * time.Hour),
+			Close:    value,
+			Complete: true,
+		})
+
+		// partial candles and other pairs are ignored
+		watcher.OnCandle(model.Candle{Pair: "BTCUSDT", Close: 100, Time: start.Add(time.Duration(i) * time.Hour)})
+		watcher.OnCandle(model.Candle{Pair: "ETHUSDT", Close: 100, Complete: true})
+
+		if i == 5 {
+			// second crossing inside cooldown
+			require.Len(t, notifier.messages, 1)
+		}
+	}
+
+	require.Equal(t, []string{
+		"[ALERT] CLOSE on BTCUSDT crossed above 70.00 (71.00)",
+		"[ALERT] CLOSE on BTCUSDT crossed above 70.00 (80.00)",
+	}, notifier.messages)
+}
+
+func TestIndicatorWatcher_Warmup(t *testing.T) {
+	start := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	feed := func(watcher *IndicatorWatcher, values ...float64) {
+		for i, value := range values {
+			watcher.OnCandle(model.Candle{Pair: "BTCUSDT", Time: start.Add(time.Duration(i) * time.Hour),
+				Close: value, Complete: true})
+		}
+	}
+
+	// moving average of 3 candles, NaN in the warmup
+	average := func(df *model.Dataframe) []float64 {
+		values := make([]float64, len(df.Close))
+		for i := range values {
+			values[i] = math.NaN()
+			if i >= 2 {
+				values[i] = (df.Close[i] + df.Close[i-1] + df.Close[i-2]) / 3
+			}
+		}
+		return values
+	}
+
+	t.Run("leading NaN", func(t *testing.T) {
+		notifier := &fakeNotifier{}
+		feed(NewIndicatorWatcher(notifier, AlertRule{Name: "SMA(3)", Pair: "BTCUSDT", Indicator: average,
+			Direction: CrossAbove, Threshold: 50}), 60, 60, 60, 60)
+		require.Empty(t, notifier.messages)
+	})
+
+	t.Run("leading zeros", func(t *testing.T) {
+		notifier := &fakeNotifier{}
+		feed(NewIndicatorWatcher(notifier, AlertRule{Name: "CLOSE", Pair: "BTCUSDT",
+			Indicator: func(df *model.Dataframe) []float64 {
+				return df.Close
+			},
+			Direction: CrossAbove, Threshold: 0}), 0, 0, 10)
+		// zeros are valid values without a declared warmup
+		require.Equal(t, []string{"[ALERT] CLOSE on BTCUSDT crossed above 0.00 (10.00)"}, notifier.messages)
+	})
+
+	t.Run("rule warmup", func(t *testing.T) {
+		notifier := &fakeNotifier{}
+		watcher := NewIndicatorWatcher(notifier, AlertRule{Name: "CLOSE", Pair: "BTCUSDT",
+			Indicator: func(df *model.Dataframe) []float64 {
+				return df.Close
+			},
+			Direction: CrossAbove, Threshold: 70, Warmup: 3})
+		feed(watcher, 60, 80, 60, 80, 60, 80)
+		// crossings before the warmup are ignored
+		require.Equal(t, []string{"[ALERT] CLOSE on BTCUSDT crossed above 70.00 (80.00)"}, notifier.messages)
+	})
+}
@@ -0,0 +1,47 @@
+package notification
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/rodrigo-brito/ninjabot/model"
+	"github.com/rodrigo-brito/ninjabot/order"
+)
+
+// DailySummary returns a digest of the trades closed in the day and the current equity
+func DailySummary(day time.Time, trades []order.Result, equity float64, format model.NumberFormat) string {
+	lines := []string{fmt.Sprintf("[DAILY SUMMARY] %s", day.Format("2006-01-02"))}
+	if len(trades) == 0 {
+		lines = append(lines, "Trades: 0")
+	} else {
+		var pnl float64
+		var wins int
+		best, worst := trades[0], trades[0]
+		for _, trade := range trades {
+			pnl += trade.ProfitValue
+			if trade.ProfitPercent >= 0 {
+				wins++
+			}
+			if trade.ProfitValue > best.ProfitValue {
+				best = trade
+			}
+			if trade.ProfitValue < worst.ProfitValue {
+				worst = trade
+			}
+		}
+
+		lines = append(lines,
+			fmt.Sprintf("Trades: %d (%s%% win)", len(trades),
+				format.Format(float64(wins)/float64(len(trades))*100, 1)),
+			fmt.Sprintf("Realized PnL: %s", format.Format(pnl, 2)),
+			fmt.Sprintf("Best: %s %s (%s%%)", best.Pair, format.Format(best.ProfitValue, 2),
+				format.Format(best.ProfitPercent*100, 2)),
+			fmt.Sprintf("Worst: %s %s (%s%%)", worst.Pair, format.Format(worst.ProfitValue, 2),
+				format.Format(worst.ProfitPercent*100, 2)),
+		)
+	}
+
+	lines = append(lines, fmt.Sprintf("Equity: %s", format.Format(equity, 2)))
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,34 @@
+package notification
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/rodrigo-brito/ninjabot/model"
+	"github.com/rodrigo-brito/ninjabot/order"
+)
+
+func TestDailySummary(t *testing.T) {
+	day := time.Date(2022, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	t.Run("with trades", func(t *testing.T) {
+		trades := []order.Result{
+			{Pair: "BTCUSDT", ProfitValue: 100, ProfitPercent: 0.1},
+			{Pair: "ETHUSDT", ProfitValue: -30, ProfitPercent: -0.03},
+			{Pair: "BTCUSDT", ProfitValue: 50.5, ProfitPercent: 0.05},
+		}
+		require.Equal(t, "[DAILY SUMMARY] 2022-03-01\n"+
+			"Trades: 3 (66.7% win)\n"+
+			"Realized PnL: 120.50\n"+
+			"Best: BTCUSDT 100.00 (10.00%)\n"+
+			"Worst: ETHUSDT -30.00 (-3.00%)\n"+
+			"Equity: 10120.50", DailySummary(day, trades, 10120.5, model.NumberFormatPlain))
+	})
+
+	t.Run("without trades", func(t *testing.T) {
+		require.Equal(t, "[DAILY SUMMARY] 2022-03-01\nTrades: 0\nEquity: 10.000,00",
+			DailySummary(day, nil, 10000, model.NumberFormatEU))
+	})
+}
@@ -1,25 +1,43 @@
 package notification
 
 import (
+	"bytes"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"regexp"
+	"runtime"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	log "github.com/sirupsen/logrus"
+	"github.com/xhit/go-str2duration/v2"
 	tb "gopkg.in/telebot.v3"
 
 	"github.com/rodrigo-brito/ninjabot/exchange"
 	"github.com/rodrigo-brito/ninjabot/model"
 	"github.com/rodrigo-brito/ninjabot/order"
+	"github.com/rodrigo-brito/ninjabot/plot"
 	"github.com/rodrigo-brito/ninjabot/service"
+	"github.com/rodrigo-brito/ninjabot/storage"
+	"github.com/rodrigo-brito/ninjabot/strategy"
 )
 
+// maxMessageLength is the maximum size of a Telegram text message
+const maxMessageLength = 4096
+
+// noteRegexp is the optional note of an order command, eg: note:"breakout test" or note:hedge
+const noteRegexp = `(?:\s+note:(?P<note>["“][^"”]*["”]|\S+))?`
+
+// defaultEquityLookback is the period displayed by /equity without arguments
+const defaultEquityLookback = 7 * 24 * time.Hour
+
 var (
-	buyRegexp  = regexp.MustCompile(`/buy\s+(?P<pair>\w+)\s+(?P<amount>\d+(?:\.\d+)?)(?P<percent>%)?`)
-	sellRegexp = regexp.MustCompile(`/sell\s+(?P<pair>\w+)\s+(?P<amount>\d+(?:\.\d+)?)(?P<percent>%)?`)
+	buyRegexp  = regexp.MustCompile(`/buy\s+(?P<pair>\w+)\s+(?P<amount>\d+(?:\.\d+)?)(?P<percent>%)?` + noteRegexp)
+	sellRegexp = regexp.MustCompile(`/sell\s+(?P<pair>\w+)\s+(?P<amount>\d+(?:\.\d+)?)(?P<percent>%)?` + noteRegexp)
 )
 
 type telegram struct {
@@ -27,11 +45,123 @@ type telegram struct {
 	orderController *order.Controller
 	defaultMenu     *tb.ReplyMarkup
 	client          *tb.Bot
+	dataFeed        *exchange.DataFeedSubscription
+	equity          storage.EquityStorage
+	paperTrading    bool
+	strategy        strategy.Strategy
+	orderMessages   *orderMessages
+	location        *time.Location // display timezone of timestamps
+	snapshots       *PositionSnapshots
+}
+
+// maxFinishedOrders is the number of finished orders kept to ignore their late updates
+const maxFinishedOrders = 1000
+
+// orderMessages are the sent messages of open orders, by order and user, and the last notified status by order
+type orderMessages struct {
+	sync.Mutex
+	messages map[int64]map[int]*tb.Message
+	status   map[int64]model.OrderStatusType
+	finished []int64
+}
+
+// statusRank orders the status of an order, finished orders have the highest rank
+func statusRank(status model.OrderStatusType) int {
+	switch status {
+	case model.OrderStatusTypeNew:
+		return 0
+	case model.OrderStatusTypePartiallyFilled:
+		return 1
+	case model.OrderStatusTypePendingCancel:
+		return 2
+	default:
+		return 3
+	}
+}
+
+// stale returns true when the order status is older than the last notified, eg: the creation of an order
+// published asynchronously after its fill. Otherwise, the status is registered. The caller must hold the lock.
+func (m *orderMessages) stale(order model.Order) bool {
+	if m.status == nil {
+		m.status = make(map[int64]model.OrderStatusType)
+	}
+
+	rank := statusRank(order.Status)
+	if last, ok := m.status[order.ExchangeID]; ok {
+		lastRank := statusRank(last)
+		if rank < lastRank || lastRank == statusRank(model.OrderStatusTypeFilled) {
+			return true
+		}
+	}
+
+	m.status[order.ExchangeID] = order.Status
+	if rank == statusRank(model.OrderStatusTypeFilled) {
+		m.finished = append(m.finished, order.ExchangeID)
+		if len(m.finished) > maxFinishedOrders {
+			delete(m.status, m.finished[0])
+			m.finished = m.finished[1:]
+		}
+	}
+	return false
 }
 
 type Option func(telegram *telegram)
 
+// WithDataFeed exposes the candle feed state in the diagnostics report
+func WithDataFeed(dataFeed *exchange.DataFeedSubscription) Option {
+	return func(telegram *telegram) {
+		telegram.dataFeed = dataFeed
+	}
+}
+
+// WithEquityStorage enables the /equity command with the snapshots of the given storage
+func WithEquityStorage(equity storage.EquityStorage) Option {
+	return func(telegram *telegram) {
+		telegram.equity = equity
+	}
+}
+
+// WithPaperTrading labels the bot status as paper trading, with simulated fills on live data
+func WithPaperTrading(enabled bool) Option {
+	return func(telegram *telegram) {
+		telegram.paperTrading = enabled
+	}
+}
+
+// defaultSnapshotCandles is the number of candles in position snapshots
+const defaultSnapshotCandles = 50
+
+// PositionSnapshots attaches a chart of the recent candles, with the entry marked, to notifications of
+// fills opening a position. Rendering is done for each fill, so it is disabled by default.
+type PositionSnapshots struct {
+	Chart    *plot.Chart
+	Candles  int  // recent candles in the chart, 50 by default
+	AllFills bool // attach the chart to every fill, including fills closing a position
+}
+
+// WithPositionSnapshots enables the chart snapshots in notifications of fills
+func WithPositionSnapshots(snapshots PositionSnapshots) Option {
+	return func(telegram *telegram) {
+		if snapshots.Candles <= 0 {
+			snapshots.Candles = defaultSnapshotCandles
+		}
+		telegram.snapshots = &snapshots
+	}
+}
+
+// WithStrategy enables the /strategy command, describing the strategy of the bot pairs
+func WithStrategy(str strategy.Strategy) Option {
+	return func(telegram *telegram) {
+		telegram.strategy = str
+	}
+}
+
 func NewTelegram(controller *order.Controller, settings model.Settings, options ...Option) (service.Telegram, error) {
+	location, err := settings.Location()
+	if err != nil {
+		return nil, err
+	}
+
 	menu := &tb.ReplyMarkup{ResizeKeyboard: true}
 	poller := &tb.LongPoller{Timeout: 10 * time.Second}
 
@@ -72,13 +202,19 @@ func NewTelegram(controller *order.Controller, settings model.Settings, options
 
 	err = client.SetCommands([]tb.Command{
 		{Text: "/help", Description: "Display help instructions"},
-		{Text: "/stop", Description: "Stop buy and sell coins"},
+		{Text: "/stop", Description: "Stop buy and sell coins, /stop --flatten closes all positions"},
 		{Text: "/start", Description: "Start buy and sell coins"},
 		{Text: "/status", Description: "Check bot status"},
 		{Text: "/balance", Description: "Wallet balance"},
-		{Text: "/profit", Description: "Summary of last trade results"},
+		{Text: "/profit", Description: "Summary of last trade results, eg: /profit strategy=<name>"},
+		{Text: "/pnl", Description: "Realized and unrealized profit by pair"},
+		{Text: "/equity", Description: "Equity chart, eg: /equity 30d"},
 		{Text: "/buy", Description: "open a buy order"},
 		{Text: "/sell", Description: "open a sell order"},
+		{Text: "/diagnostics", Description: "Internal health report"},
+		{Text: "/strategy", Description: "Active strategy and parameters"},
+		{Text: "/feedstatus", Description: "Last candle of each pair"},
+		{Text: "/config", Description: "Settings in use, with secrets redacted"},
 	})
 	if err != nil {
 		return nil, err
@@ -94,6 +230,8 @@ func NewTelegram(controller *order.Controller, settings model.Settings, options
 		client:          client,
 		settings:        settings,
 		defaultMenu:     menu,
+		orderMessages:   &orderMessages{messages: make(map[int64]map[int]*tb.Message)},
+		location:        location,
 	}
 
 	for _, option := range options {
@@ -106,8 +244,14 @@ func NewTelegram(controller *order.Controller, settings model.Settings, options
 	client.Handle("/status", bot.StatusHandle)
 	client.Handle("/balance", bot.BalanceHandle)
 	client.Handle("/profit", bot.ProfitHandle)
+	client.Handle("/pnl", bot.PnLHandle)
+	client.Handle("/equity", bot.EquityHandle)
 	client.Handle("/buy", bot.BuyHandle)
 	client.Handle("/sell", bot.SellHandle)
+	client.Handle("/diagnostics", bot.DiagnosticsHandle)
+	client.Handle("/strategy", bot.StrategyHandle)
+	client.Handle("/feedstatus", bot.FeedStatusHandle)
+	client.Handle("/config", bot.ConfigHandle)
 
 	return bot, nil
 }
@@ -143,6 +287,21 @@ func (t telegram) BalanceHandle(c tb.Context) error {
 		return err
 	}
 
+	if t.settings.Telegram.Balance.TradedOnly {
+		message, err = tradedBalanceMessage(account, t.settings, t.orderController.LastQuote)
+		if err != nil {
+			log.Error(err)
+			t.OnError(err)
+			return err
+		}
+
+		_, err = t.client.Send(c.Sender(), message)
+		if err != nil {
+			log.Error(err)
+		}
+		return err
+	}
+
 	for _, pair := range t.settings.Pairs {
 		assetPair, quotePair := exchange.SplitAssetQuote(pair)
 		assetBalance, quoteBalance := account.Balance(assetPair, quotePair)
@@ -160,15 +319,25 @@ func (t telegram) BalanceHandle(c tb.Context) error {
 		assetValue := assetSize * quote
 		quotesValue[quotePair] = quoteSize
 		total += assetValue
-		message += fmt.Sprintf("%s: `%.4f` ≅ `%.2f` %s \n", assetPair, assetSize, assetValue, quotePair)
+		message += fmt.Sprintf("%s: `%s` ≅ `%s` %s \n", assetPair, t.format(assetSize, 4), t.format(assetValue, 2),
+			quotePair)
 	}
 
 	for quote, value := range quotesValue {
 		total += value
-		message += fmt.Sprintf("%s: `%.4f`\n", quote, value)
+		message += fmt.Sprintf("%s: `%s`\n", quote, t.format(value, 4))
 	}
 
-	message += fmt.Sprintf("-----\nTotal: `%.4f`\n", total)
+	message += fmt.Sprintf("-----\nTotal: `%s`\n", t.format(total, 4))
+
+	quotes := make([]string, 0, len(quotesValue))
+	for quote := range quotesValue {
+		quotes = append(quotes, quote)
+	}
+	sort.Strings(quotes)
+	for _, quote := range quotes {
+		message += capitalMessage(quote, t.orderController.Reserved(quote), total, t.settings.NumberFormat)
+	}
 
 	_, err = t.client.Send(c.Sender(), message)
 	if err != nil {
@@ -177,6 +346,124 @@ func (t telegram) BalanceHandle(c tb.Context) error {
 	return err
 }
 
+// capitalMessage splits the total in capital reserved by milestones and active for trading
+func capitalMessage(quote string, reserved, total float64, f model.NumberFormat) string {
+	if reserved <= 0 {
+		return ""
+	}
+	return fmt.Sprintf("Reserved: `%s` %s\nActive: `%s` %s\n", f.Format(reserved, 4), quote,
+		f.Format(total-reserved, 4), quote)
+}
+
+// tradedBalanceMessage builds the /balance output splitting assets from settings pairs
+// and the include list (traded) from the remaining account balances (other)
+func tradedBalanceMessage(account model.Account, settings model.Settings,
+	lastQuote func(pair string) (float64, error)) (string, error) {
+
+	excluded := make(map[string]bool)
+	for _, asset := range settings.Telegram.Balance.Exclude {
+		excluded[strings.ToUpper(asset)] = true
+	}
+
+	traded := make(map[string]bool)
+	var assets, quotes []string
+	pricing := make(map[string]string)
+	for _, pair := range settings.Pairs {
+		asset, quote := exchange.SplitAssetQuote(pair)
+		if !excluded[asset] && !traded[asset] {
+			traded[asset] = true
+			assets = append(assets, asset)
+			pricing[asset] = pair
+		}
+		if !excluded[quote] && !traded[quote] {
+			traded[quote] = true
+			quotes = append(quotes, quote)
+		}
+	}
+
+	var mainQuote string
+	if len(settings.Pairs) > 0 {
+		_, mainQuote = exchange.SplitAssetQuote(settings.Pairs[0])
+	}
+
+	for _, asset := range settings.Telegram.Balance.Include {
+		asset = strings.ToUpper(asset)
+		if excluded[asset] || traded[asset] {
+			continue
+		}
+		traded[asset] = true
+		assets = append(assets, asset)
+		pricing[asset] = asset + mainQuote
+	}
+
+	balances := make(map[string]float64)
+	for _, balance := range account.Balances {
+		balances[balance.Asset] = balance.Free + balance.Lock
+	}
+
+	// totals are grouped by quote, values in different quotes are not summed
+	totals := make(map[string]float64)
+	var totalQuotes []string
+	addTotal := func(quote string, value float64) {
+		if _, ok := totals[quote]; !ok {
+			totalQuotes = append(totalQuotes, quote)
+		}
+		totals[quote] += value
+	}
+
+	f := settings.NumberFormat
+	message := "*BALANCE*\n*Traded*\n"
+	for _, asset := range assets {
+		size := balances[asset]
+		pair := pricing[asset]
+		_, quote := exchange.SplitAssetQuote(pair)
+		if size == 0 {
+			addTotal(quote, 0)
+			message += fmt.Sprintf("%s: `%s` ≅ `%s` %s \n", asset, f.Format(size, 4), f.Format(0, 2), quote)
+			continue
+		}
+
+		price, err := lastQuote(pair)
+		if err != nil {
+			return "", err
+		}
+
+		value := size * price
+		addTotal(quote, value)
+		message += fmt.Sprintf("%s: `%s` ≅ `%s` %s \n", asset, f.Format(size, 4), f.Format(value, 2), quote)
+	}
+
+	for _, quote := range quotes {
+		addTotal(quote, balances[quote])
+		message += fmt.Sprintf("%s: `%s`\n", quote, f.Format(balances[quote], 4))
+	}
+
+	message += "-----\n"
+	for _, quote := range totalQuotes {
+		message += fmt.Sprintf("Total: `%s` %s\n", f.Format(totals[quote], 4), quote)
+	}
+
+	var other string
+	for _, balance := range account.Balances {
+		size := balance.Free + balance.Lock
+		if traded[balance.Asset] || size == 0 {
+			continue
+		}
+		other += fmt.Sprintf("%s: `%s`\n", balance.Asset, f.Format(size, 4))
+	}
+
+	if other != "" {
+		message += "\n*Other*\n" + other
+	}
+
+	return message, nil
+}
+
+// format returns the value using the number format from settings
+func (t telegram) format(value float64, precision int) string {
+	return t.settings.NumberFormat.Format(value, precision)
+}
+
 func (t telegram) HelpHandle(c tb.Context) error {
 	commands, err := t.client.Commands()
 	if err != nil {
@@ -199,16 +486,34 @@ func (t telegram) HelpHandle(c tb.Context) error {
 }
 
 func (t telegram) ProfitHandle(c tb.Context) error {
-	if len(t.orderController.Results) == 0 {
-		_, err := t.client.Send(c.Sender(), "No trades registered.")
+	results := t.orderController.Results
+	header, empty := "", "No trades registered."
+	if args := c.Args(); len(args) > 0 {
+		name, ok := profitStrategy(args)
+		if !ok {
+			_, err := t.client.Send(c.Sender(), "Invalid filter.\nExamples of usage:\n`/profit`\n\n"+
+				"`/profit strategy=ema-cross`")
+			if err != nil {
+				log.Error(err)
+			}
+			return err
+		}
+		results = t.orderController.StrategyResults(name)
+		header = fmt.Sprintf("*STRATEGY*: `%s`\n", name)
+		empty = fmt.Sprintf("No trades registered for strategy `%s`.", name)
+	}
+
+	if len(results) == 0 {
+		_, err := t.client.Send(c.Sender(), empty)
 		if err != nil {
 			log.Error(err)
 		}
 		return err
 	}
 
-	for pair, summary := range t.orderController.Results {
-		_, err := t.client.Send(c.Sender(), fmt.Sprintf("*PAIR*: `%s`\n`%s`", pair, summary.String()))
+	for pair, summary := range results {
+		_, err := t.client.Send(c.Sender(), fmt.Sprintf("%s*PAIR*: `%s`\n`%s`", header, pair,
+			summary.Format(t.settings.NumberFormat)))
 		if err != nil {
 			log.Error(err)
 		}
@@ -216,10 +521,135 @@ func (t telegram) ProfitHandle(c tb.Context) error {
 	return nil
 }
 
+func (t telegram) PnLHandle(c tb.Context) error {
+	pnl, err := t.orderController.PnL()
+	if err != nil {
+		log.Error(err)
+		t.OnError(err)
+		return err
+	}
+
+	message := "No trades registered."
+	if len(pnl) > 0 {
+		message = pnlMessage(pnl, t.settings.NumberFormat)
+	}
+
+	_, err = t.client.Send(c.Sender(), message)
+	if err != nil {
+		log.Error(err)
+	}
+	return err
+}
+
+// pnlMessage reports realized + unrealized = total profit by pair and by quote
+func pnlMessage(pnl []order.PnL, f model.NumberFormat) string {
+	line := func(name string, value order.PnL, quote string) string {
+		return fmt.Sprintf("%s: `%s` + `%s` = `%s` %s\n", name, f.Format(value.Realized, 4),
+			f.Format(value.Unrealized, 4), f.Format(value.Total(), 4), quote)
+	}
+
+	message := "*PNL* (realized + unrealized = total)\n"
+	for _, value := range pnl {
+		_, quote := exchange.SplitAssetQuote(value.Pair)
+		message += line(value.Pair, value, quote)
+	}
+
+	totals := order.PnLByQuote(pnl)
+	quotes := make([]string, 0, len(totals))
+	for quote := range totals {
+		quotes = append(quotes, quote)
+	}
+	sort.Strings(quotes)
+
+	message += "-----\n"
+	for _, quote := range quotes {
+		message += line("Total", totals[quote], quote)
+	}
+	return message
+}
+
+// profitStrategy returns the strategy of the /profit filter, eg: strategy=ema-cross
+func profitStrategy(args []string) (string, bool) {
+	if len(args) != 1 {
+		return "", false
+	}
+
+	name, ok := strings.CutPrefix(args[0], "strategy=")
+	if !ok || name == "" {
+		return "", false
+	}
+	return name, true
+}
+
+func (t telegram) EquityHandle(c tb.Context) error {
+	if t.equity == nil {
+		_, err := t.client.Send(c.Sender(), "Equity snapshots are not enabled.\n"+
+			"Start the bot with `ninjabot.WithEquitySnapshots` to record them.")
+		if err != nil {
+			log.Error(err)
+		}
+		return err
+	}
+
+	lookback := defaultEquityLookback
+	if args := c.Args(); len(args) > 0 {
+		value, err := str2duration.ParseDuration(args[0])
+		if err != nil || value <= 0 {
+			_, err := t.client.Send(c.Sender(), "Invalid lookback.\nExamples of usage:\n`/equity 24h`\n\n`/equity 30d`")
+			if err != nil {
+				log.Error(err)
+			}
+			return err
+		}
+		lookback = value
+	}
+
+	snapshots, err := t.equity.Equity(time.Now().Add(-lookback))
+	if err != nil {
+		log.Error(err)
+		t.OnError(err)
+		return err
+	}
+
+	if len(snapshots) < 2 {
+		_, err := t.client.Send(c.Sender(), "Not enough equity snapshots in the period.")
+		if err != nil {
+			log.Error(err)
+		}
+		return err
+	}
+
+	values := make([]float64, len(snapshots))
+	for i, snapshot := range snapshots {
+		values[i] = snapshot.Value
+	}
+
+	content, err := plot.Sparkline(values, 400, 100)
+	if err != nil {
+		log.Error(err)
+		t.OnError(err)
+		return err
+	}
+
+	first, last := values[0], values[len(values)-1]
+	photo := &tb.Photo{
+		File: tb.FromReader(bytes.NewReader(content)),
+		Caption: fmt.Sprintf("*EQUITY* (%s)\n`%s` -> `%s` (%s%%)", str2duration.String(lookback),
+			t.format(first, 2), t.format(last, 2), t.format((last/first-1)*100, 2)),
+	}
+
+	_, err = t.client.Send(c.Sender(), photo)
+	if err != nil {
+		log.Error(err)
+	}
+	return err
+}
+
 func (t telegram) BuyHandle(c tb.Context) error {
 	match := buyRegexp.FindStringSubmatch(c.Message().Text)
 	if len(match) == 0 {
-		_, err := t.client.Send(c.Sender(), "Invalid command.\nExamples of usage:\n`/buy BTCUSDT 100`\n\n`/buy BTCUSDT 50%`")
+		_, err := t.client.Send(c.Sender(), "Invalid command.\nExamples of usage:\n`/buy BTCUSDT 100`\n\n`/buy BTCUSDT 50%`"+
+			"\n\n`/buy BTCUSDT 100 note:\"breakout test\"`")
 		if err != nil {
 			log.Error(err)
 		}
@@ -258,7 +688,8 @@ func (t telegram) BuyHandle(c tb.Context) error {
 		amount = amount * quote / 100.0
 	}
 
-	order, err := t.orderController.CreateOrderMarketQuote(model.SideTypeBuy, pair, amount)
+	broker := t.orderController.WithNote(commandNote(command))
+	order, err := broker.CreateOrderMarketQuote(model.SideTypeBuy, pair, amount)
 	if err != nil {
 		return err
 	}
@@ -266,11 +697,17 @@ func (t telegram) BuyHandle(c tb.Context) error {
 	return nil
 }
 
+// commandNote returns the note of an order command without quotes, empty if not given
+func commandNote(command map[string]string) string {
+	return strings.Trim(command["note"], `"“”`)
+}
+
 func (t telegram) SellHandle(c tb.Context) error {
 	match := sellRegexp.FindStringSubmatch(c.Message().Text)
 
 	if len(match) == 0 {
-		_, err := t.client.Send(c.Sender(), "Invalid command.\nExample of usage:\n`/sell BTCUSDT 100`\n\n`/sell BTCUSDT 50%`")
+		_, err := t.client.Send(c.Sender(), "Invalid command.\nExample of usage:\n`/sell BTCUSDT 100`\n\n`/sell BTCUSDT 50%`"+
+			"\n\n`/sell BTCUSDT 100 note:hedge`")
 		if err != nil {
 			log.Error(err)
 		}
@@ -298,6 +735,7 @@ func (t telegram) SellHandle(c tb.Context) error {
 		return err
 	}
 
+	broker := t.orderController.WithNote(commandNote(command))
 	if command["percent"] != "" {
 		asset, _, err := t.orderController.Position(pair)
 		if err != nil {
@@ -305,7 +743,7 @@ func (t telegram) SellHandle(c tb.Context) error {
 		}
 
 		amount = amount * asset / 100.0
-		order, err := t.orderController.CreateOrderMarket(model.SideTypeSell, pair, amount)
+		order, err := broker.CreateOrderMarket(model.SideTypeSell, pair, amount)
 		if err != nil {
 			return err
 		}
@@ -313,7 +751,7 @@ func (t telegram) SellHandle(c tb.Context) error {
 		return nil
 	}
 
-	order, err := t.orderController.CreateOrderMarketQuote(model.SideTypeSell, pair, amount)
+	order, err := broker.CreateOrderMarketQuote(model.SideTypeSell, pair, amount)
 	if err != nil {
 		return err
 	}
@@ -322,12 +760,172 @@ func (t telegram) SellHandle(c tb.Context) error {
 }
 
 func (t telegram) StatusHandle(c tb.Context) error {
-	status := t.orderController.Status()
-	_, err := t.client.Send(c.Sender(), fmt.Sprintf("Status: `%s`", status))
+	_, err := t.client.Send(c.Sender(), statusMessage(t.orderController.Status(), t.paperTrading))
+	if err != nil {
+		log.Error(err)
+	}
+	return err
+}
+
+func statusMessage(status order.Status, paperTrading bool) string {
+	message := fmt.Sprintf("Status: `%s`", status)
+	if paperTrading {
+		message += "\nMode: `PAPER TRADING` (simulated fills on live data)"
+	}
+	return message
+}
+
+func (t telegram) DiagnosticsHandle(c tb.Context) error {
+	message := "*DIAGNOSTICS*\n"
+
+	if t.dataFeed != nil {
+		message += "-----\nFeeds:\n"
+		for _, feed := range t.dataFeed.Status() {
+			state := "connected"
+			if !feed.Connected {
+				state = "disconnected"
+			}
+
+			lastCandle := "-"
+			if !feed.LastCandle.IsZero() {
+				lastCandle = feed.LastCandle.In(t.location).Format(time.RFC3339)
+			}
+
+			message += fmt.Sprintf("%s (%s): `%s` last: `%s` age: `%s`\n",
+				feed.Pair, feed.Timeframe, state, lastCandle, feed.Age().Truncate(time.Second))
+		}
+	}
+
+	diagnostics, err := t.orderController.Diagnostics()
+	if err != nil {
+		log.Error(err)
+		t.OnError(err)
+		return err
+	}
+
+	var memory runtime.MemStats
+	runtime.ReadMemStats(&memory)
+
+	message += "-----\n"
+	message += fmt.Sprintf("API latency: `%s`\n", diagnostics.APILatency.Truncate(time.Millisecond))
+	message += fmt.Sprintf("Open orders: `%d`\n", diagnostics.OpenOrders)
+	message += fmt.Sprintf("Goroutines: `%d`\n", runtime.NumGoroutine())
+	message += fmt.Sprintf("Memory: `%.1f MB` (sys `%.1f MB`)\n",
+		float64(memory.Alloc)/1024/1024, float64(memory.Sys)/1024/1024)
+
+	_, err = t.client.Send(c.Sender(), truncateMessage(message))
+	if err != nil {
+		log.Error(err)
+	}
+	return err
+}
+
+func (t telegram) FeedStatusHandle(c tb.Context) error {
+	message := "Feed status not available."
+	if t.dataFeed != nil {
+		message = feedStatusMessage(t.dataFeed.Status(), t.location)
+	}
+
+	_, err := t.client.Send(c.Sender(), truncateMessage(message))
+	if err != nil {
+		log.Error(err)
+	}
+	return err
+}
+
+// feedStatusMessage lists the last closed candle of each feed, flagging stale feeds with a warning
+func feedStatusMessage(feeds []exchange.FeedStatus, location *time.Location) string {
+	message := "*FEED STATUS*\n"
+	for _, feed := range feeds {
+		flag := ""
+		if feed.Stale() {
+			flag = "⚠️ "
+		}
+
+		if feed.LastCandle.IsZero() {
+			message += fmt.Sprintf("%s%s (%s): no candles yet\n", flag, feed.Pair, feed.Timeframe)
+			continue
+		}
+
+		message += fmt.Sprintf("%s%s (%s): `%s` (%s ago)\n", flag, feed.Pair, feed.Timeframe,
+			feed.LastCandle.In(location).Format(time.RFC3339), feed.Age().Truncate(time.Minute))
+	}
+	return message
+}
+
+func (t telegram) ConfigHandle(c tb.Context) error {
+	message, err := configMessage(t.settings)
+	if err != nil {
+		log.Error(err)
+		t.OnError(err)
+		return err
+	}
+
+	_, err = t.client.Send(c.Sender(), truncateMessage(message))
+	if err != nil {
+		log.Error(err)
+	}
+	return err
+}
+
+// configMessage describes the effective settings in the format of the settings file, without secrets
+func configMessage(settings model.Settings) (string, error) {
+	content, err := json.MarshalIndent(settings.Effective(), "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return "*CONFIG*\n```\n" + string(content) + "\n```", nil
+}
+
+func (t telegram) StrategyHandle(c tb.Context) error {
+	message := "No strategy registered."
+	if t.strategy != nil {
+		message = strategyMessage(t.strategy, t.settings.Pairs)
+	}
+
+	_, err := t.client.Send(c.Sender(), truncateMessage(message))
 	if err != nil {
 		log.Error(err)
 	}
-	return err 
+	return err
+}
+
+// strategyMessage describes the strategy, the pairs and the tunable parameters, sorted by name
+func strategyMessage(str strategy.Strategy, pairs []string) string {
+	message := "*STRATEGY*\n"
+	message += fmt.Sprintf("Name: `%s`\n", strategy.Name(str))
+	message += fmt.Sprintf("Timeframe: `%s`\n", str.Timeframe())
+	message += fmt.Sprintf("Warmup: `%d` candles\n", str.WarmupPeriod())
+	message += fmt.Sprintf("Pairs: `%s`\n", strings.Join(pairs, ", "))
+
+	if str, ok := str.(strategy.ParameterStrategy); ok {
+		parameters := str.Parameters()
+		names := make([]string, 0, len(parameters))
+		for name := range parameters {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		message += "-----\nParameters:\n"
+		for _, name := range names {
+			message += fmt.Sprintf("%s: `%v`\n", name, parameters[name])
+		}
+	}
+
+	return message
+}
+
+// truncateMessage limits the message size to the Telegram maximum, cutting at the last line break
+func truncateMessage(message string) string {
+	if len(message) <= maxMessageLength {
+		return message
+	}
+
+	message = message[:maxMessageLength-4]
+	if index := strings.LastIndex(message, "\n"); index > 0 {
+		message = message[:index+1]
+	}
+	return message + "..."
 }
 
 func (t telegram) StartHandle(c tb.Context) error {
@@ -348,7 +946,8 @@ func (t telegram) StartHandle(c tb.Context) error {
 }
 
 func (t telegram) StopHandle(c tb.Context) error {
-	if t.orderController.Status() == order.StatusStopped {
+	flatten := stopFlatten(c.Args(), t.settings.Telegram.FlattenOnStop)
+	if t.orderController.Status() == order.StatusStopped && !flatten {
 		_, err := t.client.Send(c.Sender(), "Bot is already stopped.", t.defaultMenu)
 		if err != nil {
 			log.Error(err)
@@ -357,13 +956,50 @@ func (t telegram) StopHandle(c tb.Context) error {
 	}
 
 	t.orderController.Stop()
-	_, err := t.client.Send(c.Sender(), "Bot stopped.", t.defaultMenu)
+	message := "Bot stopped."
+	if flatten {
+		flattened, err := t.orderController.Flatten()
+		message += "\n" + flattenMessage(flattened, t.settings.NumberFormat)
+		if err != nil {
+			log.Error(err)
+			message += fmt.Sprintf("\n⚠️ Failures:\n%s", err)
+		}
+	}
+
+	_, err := t.client.Send(c.Sender(), message, t.defaultMenu)
 	if err != nil {
 		log.Error(err)
 	}
 	return err
 }
 
+// stopFlatten returns true if positions are closed on stop, by the --flatten flag or the setting
+func stopFlatten(args []string, setting bool) bool {
+	for _, arg := range args {
+		if arg == "--flatten" {
+			return true
+		}
+	}
+	return setting
+}
+
+// flattenMessage reports the orders canceled and the realized profit of the closed positions by quote
+func flattenMessage(flattened order.Flattened, f model.NumberFormat) string {
+	message := fmt.Sprintf("Canceled orders: %d\nClosed positions: %d\n", flattened.Canceled, len(flattened.Trades))
+
+	profit := flattened.Profit()
+	quotes := make([]string, 0, len(profit))
+	for quote := range profit {
+		quotes = append(quotes, quote)
+	}
+	sort.Strings(quotes)
+
+	for _, quote := range quotes {
+		message += fmt.Sprintf("Realized PnL: %s %s\n", f.Format(profit[quote], 4), quote)
+	}
+	return message
+}
+
 func (t telegram) OnOrder(order model.Order) {
 	title := ""
 	switch order.Status {
@@ -374,8 +1010,108 @@ func (t telegram) OnOrder(order model.Order) {
 	case model.OrderStatusTypeCanceled, model.OrderStatusTypeRejected:
 		title = fmt.Sprintf("❌ ORDER CANCELED / REJECTED - %s", order.Pair)
 	}
-	message := fmt.Sprintf("%s\n-----\n%s", title, order)
-	t.Notify(message)
+	message := orderMessage(title, order, t.settings.NumberFormat, t.location)
+	if snapshot := t.positionSnapshot(order); snapshot != nil {
+		t.notifyPhoto(order, snapshot, message)
+		return
+	}
+
+	if !t.settings.Telegram.EditOrderMessages {
+		t.Notify(message)
+		return
+	}
+	t.notifyOrder(order, message)
+}
+
+// orderMessage describes the order, with the time of its last update in the display timezone and its note
+func orderMessage(title string, order model.Order, f model.NumberFormat, location *time.Location) string {
+	updatedAt := order.UpdatedAt
+	if updatedAt.IsZero() {
+		updatedAt = order.CreatedAt
+	}
+	message := fmt.Sprintf("%s\n-----\n%s\nTime: %s", title, order.Format(f),
+		updatedAt.In(location).Format(time.RFC3339))
+	if order.Note != "" {
+		message += fmt.Sprintf("\nNote: %s", order.Note)
+	}
+	return message
+}
+
+// positionSnapshot returns the PNG chart of the fill, or nil if snapshots are disabled for the order
+// or the chart can't be rendered
+func (t telegram) positionSnapshot(order model.Order) []byte {
+	if t.snapshots == nil || t.snapshots.Chart == nil || order.Status != model.OrderStatusTypeFilled {
+		return nil
+	}
+
+	if !order.Opening && !t.snapshots.AllFills {
+		return nil
+	}
+
+	var content bytes.Buffer
+	if err := t.snapshots.Chart.WriteEntryPNG(&content, order, t.snapshots.Candles, 600, 300); err != nil {
+		log.Warn("telegram/snapshot: ", err)
+		return nil
+	}
+	return content.Bytes()
+}
+
+// notifyPhoto sends the image with the message as caption, it replaces the message edited in place
+func (t telegram) notifyPhoto(order model.Order, content []byte, caption string) {
+	for _, user := range t.settings.Telegram.Users {
+		photo := &tb.Photo{File: tb.FromReader(bytes.NewReader(content)), Caption: caption}
+		_, err := t.client.Send(&tb.User{ID: int64(user)}, photo)
+		if err != nil {
+			log.Error(err)
+		}
+	}
+
+	if t.orderMessages != nil {
+		t.orderMessages.Lock()
+		delete(t.orderMessages.messages, order.ExchangeID)
+		t.orderMessages.Unlock()
+	}
+}
+
+// notifyOrder edits the message of the order for each user, or sends a new message if there is no
+// message of the order or the edit fails. Messages of finished orders are not edited again, and updates
+// older than the last notified status are ignored.
+func (t telegram) notifyOrder(order model.Order, text string) {
+	t.orderMessages.Lock()
+	defer t.orderMessages.Unlock()
+
+	if t.orderMessages.stale(order) {
+		return
+	}
+
+	messages, ok := t.orderMessages.messages[order.ExchangeID]
+	if !ok {
+		messages = make(map[int]*tb.Message)
+	}
+
+	for _, user := range t.settings.Telegram.Users {
+		if sent, ok := messages[user]; ok {
+			_, err := t.client.Edit(sent, text)
+			if err == nil || errors.Is(err, tb.ErrSameMessageContent) || errors.Is(err, tb.ErrMessageNotModified) {
+				continue
+			}
+			log.Warn("telegram/edit: ", err)
+		}
+
+		sent, err := t.client.Send(&tb.User{ID: int64(user)}, text)
+		if err != nil {
+			log.Error(err)
+			continue
+		}
+		messages[user] = sent
+	}
+
+	switch order.Status {
+	case model.OrderStatusTypeNew, model.OrderStatusTypePartiallyFilled, model.OrderStatusTypePendingCancel:
+		t.orderMessages.messages[order.ExchangeID] = messages
+	default:
+		delete(t.orderMessages.messages, order.ExchangeID)
+	}
 }
 
 func (t telegram) OnError(err error) {
@@ -386,9 +1122,9 @@ func (t telegram) OnError(err error) {
 		message := fmt.Sprintf(`%s
 		-----
 		Pair: %s
-		Quantity: %.4f
+		Quantity: %s
 		-----
-		%s`, title, orderError.Pair, orderError.Quantity, orderError.Err)
+		%s`, title, orderError.Pair, t.format(orderError.Quantity, 4), orderError.Err)
 		t.Notify(message)
 		return
 	}
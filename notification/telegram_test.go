@@ -0,0 +1,433 @@
+package notification
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	tb "gopkg.in/telebot.v3"
+
+	"github.com/rodrigo-brito/ninjabot/exchange"
+	"github.com/rodrigo-brito/ninjabot/model"
+	"github.com/rodrigo-brito/ninjabot/order"
+	"github.com/rodrigo-brito/ninjabot/plot"
+	"github.com/rodrigo-brito/ninjabot/storage"
+	"github.com/rodrigo-brito/ninjabot/strategy"
+)
+
+func TestTradedBalanceMessage(t *testing.T) {
+	account := model.Account{
+		Balances: []model.Balance{
+			{Asset: "BTC", Free: 1, Lock: 0.5},
+			{Asset: "USDT", Free: 1000},
+			{Asset: "BNB", Free: 2},
+			{Asset: "DOT", Lock: 10},
+			{Asset: "ETH", Free: 3},
+		},
+	}
+	settings := model.Settings{
+		Pairs: []string{"BTCUSDT", "ETHUSDT"},
+		Telegram: model.TelegramSettings{
+			Balance: model.BalanceSettings{
+				TradedOnly: true,
+				Include:    []string{"bnb"},
+				Exclude:    []string{"ETH"},
+			},
+		},
+	}
+	prices := map[string]float64{"BTCUSDT": 100, "BNBUSDT": 10}
+	lastQuote := func(pair string) (float64, error) {
+		return prices[pair], nil
+	}
+
+	message, err := tradedBalanceMessage(account, settings, lastQuote)
+	require.NoError(t, err)
+	require.Equal(t, "*BALANCE*\n*Traded*\n"+
+		"BTC: `1.5000` ≅ `150.00` USDT \n"+
+		"BNB: `2.0000` ≅ `20.00` USDT \n"+
+		"USDT: `1000.0000`\n"+
+		"-----\nTotal: `1170.0000` USDT\n"+
+		"\n*Other*\n"+
+		"DOT: `10.0000`\n"+
+		"ETH: `3.0000`\n", message)
+
+	t.Run("multiple quotes", func(t *testing.T) {
+		account := model.Account{
+			Balances: []model.Balance{
+				{Asset: "BTC", Free: 1},
+				{Asset: "USDT", Free: 1000},
+				{Asset: "ETH", Free: 2},
+				{Asset: "BUSD", Free: 500},
+			},
+		}
+		settings := model.Settings{
+			Pairs:    []string{"BTCUSDT", "ETHBUSD"},
+			Telegram: model.TelegramSettings{Balance: model.BalanceSettings{TradedOnly: true}},
+		}
+		prices := map[string]float64{"BTCUSDT": 100, "ETHBUSD": 10}
+		lastQuote := func(pair string) (float64, error) {
+			return prices[pair], nil
+		}
+
+		message, err := tradedBalanceMessage(account, settings, lastQuote)
+		require.NoError(t, err)
+		require.Equal(t, "*BALANCE*\n*Traded*\n"+
+			"BTC: `1.0000` ≅ `100.00` USDT \n"+
+			"ETH: `2.0000` ≅ `20.00` BUSD \n"+
+			"USDT: `1000.0000`\n"+
+			"BUSD: `500.0000`\n"+
+			"-----\n"+
+			"Total: `1100.0000` USDT\n"+
+			"Total: `520.0000` BUSD\n", message)
+	})
+}
+
+func TestStatusMessage(t *testing.T) {
+	require.Equal(t, "Status: `running`", statusMessage(order.StatusRunning, false))
+	require.Equal(t, "Status: `stopped`\nMode: `PAPER TRADING` (simulated fills on live data)",
+		statusMessage(order.StatusStopped, true))
+}
+
+type emaStrategy struct {
+	strategy.Strategy
+}
+
+func (s emaStrategy) Timeframe() string {
+	return "4h"
+}
+
+func (s emaStrategy) WarmupPeriod() int {
+	return 21
+}
+
+func (s emaStrategy) Parameters() map[string]any {
+	return map[string]any{"slow": 21, "fast": 8}
+}
+
+func TestStrategyMessage(t *testing.T) {
+	message := strategyMessage(emaStrategy{}, []string{"BTCUSDT", "ETHUSDT"})
+	require.Equal(t, "*STRATEGY*\n"+
+		"Name: `notification.emaStrategy`\n"+
+		"Timeframe: `4h`\n"+
+		"Warmup: `21` candles\n"+
+		"Pairs: `BTCUSDT, ETHUSDT`\n"+
+		"-----\nParameters:\n"+
+		"fast: `8`\n"+
+		"slow: `21`\n", message)
+}
+
+func TestConfigMessage(t *testing.T) {
+	message, err := configMessage(model.Settings{
+		Pairs:    []string{"BTCUSDT"},
+		Exchange: model.ExchangeSettings{APIKey: "api-key", APISecret: "api-secret"},
+		Telegram: model.TelegramSettings{Enabled: true, Token: "bot-token", Users: []int{42}},
+	})
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(message, "*CONFIG*\n```\n"))
+	require.NotContains(t, message, "api-key")
+	require.NotContains(t, message, "api-secret")
+	require.NotContains(t, message, "bot-token")
+	require.Contains(t, message, `"APISecret": "[REDACTED]"`)
+	require.Contains(t, message, `"Token": "[REDACTED]"`)
+	require.Contains(t, message, `"Timezone": "UTC"`)
+	require.Contains(t, message, `"BTCUSDT"`)
+
+	// the output is a valid settings file
+	content := strings.TrimSuffix(strings.TrimPrefix(message, "*CONFIG*\n```\n"), "\n```")
+	var settings model.Settings
+	require.NoError(t, json.Unmarshal([]byte(content), &settings))
+	require.Equal(t, []int{42}, settings.Telegram.Users)
+}
+
+func TestFeedStatusMessage(t *testing.T) {
+	btcCandle := time.Now().Add(-90 * time.Minute)
+	ethCandle := time.Now().Add(-5 * time.Hour)
+	message := feedStatusMessage([]exchange.FeedStatus{
+		{Pair: "BTCUSDT", Timeframe: "1h", LastCandle: btcCandle},
+		{Pair: "ETHUSDT", Timeframe: "1h", LastCandle: ethCandle},
+		{Pair: "BNBUSDT", Timeframe: "1h"},
+	}, time.UTC)
+
+	require.Equal(t, []string{
+		"*FEED STATUS*",
+		"BTCUSDT (1h): `" + btcCandle.UTC().Format(time.RFC3339) + "` (1h30m0s ago)",
+		"⚠️ ETHUSDT (1h): `" + ethCandle.UTC().Format(time.RFC3339) + "` (5h0m0s ago)",
+		"⚠️ BNBUSDT (1h): no candles yet",
+		"",
+	}, strings.Split(message, "\n"))
+}
+
+func TestOrderMessage(t *testing.T) {
+	location, err := time.LoadLocation("America/Sao_Paulo")
+	require.NoError(t, err)
+
+	updatedAt := time.Date(2022, 1, 1, 12, 30, 0, 0, time.UTC)
+	order := model.Order{
+		ID:        1,
+		Pair:      "BTCUSDT",
+		Side:      model.SideTypeBuy,
+		Type:      model.OrderTypeLimit,
+		Status:    model.OrderStatusTypeFilled,
+		Price:     10,
+		Quantity:  1,
+		CreatedAt: updatedAt.Add(-time.Hour),
+		UpdatedAt: updatedAt,
+	}
+
+	message := orderMessage("✅ ORDER FILLED - BTCUSDT", order, model.NumberFormatPlain, location)
+	require.Equal(t, "✅ ORDER FILLED - BTCUSDT\n-----\n"+
+		"[FILLED] BUY BTCUSDT | ID: 1, Type: LIMIT, 1.000000 x $10.000000 (~$10)\n"+
+		"Time: 2022-01-01T09:30:00-03:00", message)
+	require.Equal(t, updatedAt, order.UpdatedAt)
+	require.Equal(t, time.UTC, order.UpdatedAt.Location())
+
+	t.Run("note", func(t *testing.T) {
+		order := model.Order{Status: model.OrderStatusTypeNew, CreatedAt: updatedAt, Note: "hedge"}
+		require.True(t, strings.HasSuffix(orderMessage("", order, model.NumberFormatPlain, time.UTC),
+			"Time: 2022-01-01T12:30:00Z\nNote: hedge"))
+	})
+
+	t.Run("new order", func(t *testing.T) {
+		order := model.Order{Status: model.OrderStatusTypeNew, CreatedAt: updatedAt}
+		require.True(t, strings.HasSuffix(orderMessage("", order, model.NumberFormatPlain, time.UTC),
+			"Time: 2022-01-01T12:30:00Z"))
+	})
+}
+
+func TestCapitalMessage(t *testing.T) {
+	require.Empty(t, capitalMessage("USDT", 0, 1000, model.NumberFormatPlain))
+	require.Equal(t, "Reserved: `55.0000` USDT\nActive: `1045.0000` USDT\n",
+		capitalMessage("USDT", 55, 1100, model.NumberFormatPlain))
+}
+
+func TestProfitStrategy(t *testing.T) {
+	name, ok := profitStrategy([]string{"strategy=ema-cross"})
+	require.True(t, ok)
+	require.Equal(t, "ema-cross", name)
+
+	for _, args := range [][]string{{"ema-cross"}, {"strategy="}, {"strategy=a", "strategy=b"}} {
+		_, ok := profitStrategy(args)
+		require.False(t, ok, args)
+	}
+}
+
+func TestPnLMessage(t *testing.T) {
+	message := pnlMessage([]order.PnL{
+		{Pair: "BTCUSDT", Realized: 100},
+		{Pair: "ETHUSDT", Realized: 10, Unrealized: -20},
+	}, model.NumberFormatPlain)
+	require.Equal(t, []string{
+		"*PNL* (realized + unrealized = total)",
+		"BTCUSDT: `100.0000` + `0.0000` = `100.0000` USDT",
+		"ETHUSDT: `10.0000` + `-20.0000` = `-10.0000` USDT",
+		"-----",
+		"Total: `110.0000` + `-20.0000` = `90.0000` USDT",
+		"",
+	}, strings.Split(message, "\n"))
+}
+
+func TestStopFlatten(t *testing.T) {
+	require.False(t, stopFlatten(nil, false))
+	require.True(t, stopFlatten([]string{"--flatten"}, false))
+	require.True(t, stopFlatten(nil, true))
+
+	message := flattenMessage(order.Flattened{
+		Canceled: 2,
+		Trades: []order.Result{
+			{Pair: "BTCUSDT", ProfitValue: 200},
+			{Pair: "ETHUSDT", ProfitValue: -100},
+			{Pair: "ETHBTC", ProfitValue: 0.001},
+		},
+	}, model.NumberFormatPlain)
+	require.Equal(t, "Canceled orders: 2\nClosed positions: 3\nRealized PnL: 0.0010 BTC\nRealized PnL: 100.0000 USDT\n",
+		message)
+}
+
+func TestTelegram_EditOrderMessages(t *testing.T) {
+	var (
+		mtx      sync.Mutex
+		calls    []string
+		failEdit bool
+		nextID   = 100
+		reply    = `{"ok":true,"result":{"message_id":%d,"chat":{"id":1}}}`
+		notFound = `{"ok":false,"error_code":400,"description":"Bad Request: message to edit not found"}`
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mtx.Lock()
+		defer mtx.Unlock()
+
+		method := r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:]
+		calls = append(calls, method)
+		if method == "editMessageText" && failEdit {
+			fmt.Fprint(w, notFound)
+			return
+		}
+		nextID++
+		fmt.Fprintf(w, reply, nextID)
+	}))
+	defer server.Close()
+
+	client, err := tb.NewBot(tb.Settings{URL: server.URL, Token: "token", Offline: true})
+	require.NoError(t, err)
+
+	bot := telegram{
+		client:        client,
+		orderMessages: &orderMessages{messages: make(map[int64]map[int]*tb.Message)},
+		location:      time.UTC,
+		settings: model.Settings{
+			Telegram: model.TelegramSettings{Users: []int{1}, EditOrderMessages: true},
+		},
+	}
+
+	order := model.Order{ExchangeID: 1, Pair: "BTCUSDT", Side: model.SideTypeBuy, Status: model.OrderStatusTypeNew}
+	bot.OnOrder(order)
+	order.Status = model.OrderStatusTypeFilled
+	bot.OnOrder(order)
+	require.Equal(t, []string{"sendMessage", "editMessageText"}, calls)
+	require.Empty(t, bot.orderMessages.messages)
+
+	t.Run("edit fallback", func(t *testing.T) {
+		calls, failEdit = nil, true
+		order := model.Order{ExchangeID: 2, Pair: "BTCUSDT", Side: model.SideTypeSell, Status: model.OrderStatusTypeNew}
+		bot.OnOrder(order)
+		order.Status = model.OrderStatusTypeCanceled
+		bot.OnOrder(order)
+		require.Equal(t, []string{"sendMessage", "editMessageText", "sendMessage"}, calls)
+	})
+
+	t.Run("late creation", func(t *testing.T) {
+		calls, failEdit = nil, false
+		order := model.Order{ExchangeID: 4, Pair: "BTCUSDT", Side: model.SideTypeBuy, Status: model.OrderStatusTypeFilled}
+		bot.OnOrder(order)
+		order.Status = model.OrderStatusTypeNew
+		bot.OnOrder(order)
+		require.Equal(t, []string{"sendMessage"}, calls)
+		require.Empty(t, bot.orderMessages.messages)
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		calls = nil
+		bot.settings.Telegram.EditOrderMessages = false
+		order := model.Order{ExchangeID: 3, Pair: "BTCUSDT", Side: model.SideTypeSell, Status: model.OrderStatusTypeNew}
+		bot.OnOrder(order)
+		order.Status = model.OrderStatusTypeFilled
+		bot.OnOrder(order)
+		require.Equal(t, []string{"sendMessage", "sendMessage"}, calls)
+	})
+}
+
+func TestTelegram_OrderNote(t *testing.T) {
+	for _, tc := range []struct {
+		text string
+		note string
+	}{
+		{text: "/buy BTCUSDT 100", note: ""},
+		{text: "/buy BTCUSDT 50%", note: ""},
+		{text: `/buy BTCUSDT 100 note:"breakout test"`, note: "breakout test"},
+		{text: "/buy BTCUSDT 100 note:“breakout test”", note: "breakout test"},
+		{text: "/buy BTCUSDT 50% note:hedge", note: "hedge"},
+	} {
+		match := buyRegexp.FindStringSubmatch(tc.text)
+		require.NotEmpty(t, match, tc.text)
+		require.Equal(t, tc.note, commandNote(map[string]string{"note": match[buyRegexp.SubexpIndex("note")]}),
+			tc.text)
+	}
+
+	ctx := context.Background()
+	db, err := storage.FromMemory()
+	require.NoError(t, err)
+	wallet := exchange.NewPaperWallet(ctx, "USDT", exchange.WithPaperAsset("USDT", 1000))
+	controller := order.NewController(ctx, wallet, db, order.NewOrderFeed())
+	wallet.OnCandle(model.Candle{Pair: "BTCUSDT", Close: 100})
+
+	client, err := tb.NewBot(tb.Settings{Token: "token", Offline: true})
+	require.NoError(t, err)
+	bot := telegram{client: client, orderController: controller}
+	command := func(text string) tb.Context {
+		return client.NewContext(tb.Update{Message: &tb.Message{Text: text, Sender: &tb.User{ID: 1}}})
+	}
+
+	require.NoError(t, bot.BuyHandle(command(`/buy BTCUSDT 500 note:"breakout test"`)))
+	require.NoError(t, bot.SellHandle(command("/sell BTCUSDT 100")))
+	require.NoError(t, bot.SellHandle(command("/sell BTCUSDT 100% note:hedge")))
+
+	orders, err := db.Orders()
+	require.NoError(t, err)
+	require.Len(t, orders, 3)
+	require.Equal(t, "breakout test", orders[0].Note)
+	require.Empty(t, orders[1].Note)
+	require.Equal(t, "hedge", orders[2].Note)
+
+	trades := controller.Trades(time.Time{}, time.Now().Add(time.Hour))
+	require.Len(t, trades, 2)
+	require.Empty(t, trades[0].Note)
+	require.Equal(t, "hedge", trades[1].Note)
+}
+
+func TestTelegram_PositionSnapshots(t *testing.T) {
+	var (
+		mtx   sync.Mutex
+		calls []string
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mtx.Lock()
+		defer mtx.Unlock()
+
+		method := r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:]
+		calls = append(calls, method)
+		fmt.Fprint(w, `{"ok":true,"result":{"message_id":1,"chat":{"id":1},`+
+			`"photo":[{"file_id":"photo","width":600,"height":300}]}}`)
+	}))
+	defer server.Close()
+
+	client, err := tb.NewBot(tb.Settings{URL: server.URL, Token: "token", Offline: true})
+	require.NoError(t, err)
+
+	chart, err := plot.NewChart()
+	require.NoError(t, err)
+	start := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 30; i++ {
+		chart.OnCandle(model.Candle{Pair: "BTCUSDT", Time: start.AddDate(0, 0, i), Open: 100, Close: 101,
+			High: 102, Low: 99, Complete: true})
+	}
+
+	bot := telegram{
+		client:        client,
+		location:      time.UTC,
+		orderMessages: &orderMessages{messages: make(map[int64]map[int]*tb.Message)},
+		settings:      model.Settings{Telegram: model.TelegramSettings{Users: []int{1}}},
+	}
+	WithPositionSnapshots(PositionSnapshots{Chart: chart})(&bot)
+	require.Equal(t, defaultSnapshotCandles, bot.snapshots.Candles)
+
+	opening := model.Order{ExchangeID: 1, Pair: "BTCUSDT", Side: model.SideTypeBuy, Status: model.OrderStatusTypeFilled,
+		Price: 101, Quantity: 1, UpdatedAt: start.AddDate(0, 0, 29), Opening: true}
+	closing := opening
+	closing.ExchangeID, closing.Side, closing.Opening = 2, model.SideTypeSell, false
+
+	bot.OnOrder(opening)
+	bot.OnOrder(closing)
+	bot.OnOrder(model.Order{ExchangeID: 3, Pair: "BTCUSDT", Status: model.OrderStatusTypeNew, Opening: true})
+	require.Equal(t, []string{"sendPhoto", "sendMessage", "sendMessage"}, calls)
+
+	t.Run("all fills", func(t *testing.T) {
+		calls = nil
+		bot.snapshots.AllFills = true
+		bot.OnOrder(closing)
+		require.Equal(t, []string{"sendPhoto"}, calls)
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		calls = nil
+		bot.snapshots = nil
+		bot.OnOrder(opening)
+		require.Equal(t, []string{"sendMessage"}, calls)
+	})
+}
@@ -0,0 +1,280 @@
+package order
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/rodrigo-brito/ninjabot/exchange"
+	"github.com/rodrigo-brito/ninjabot/model"
+)
+
+// defaultAuditBuffer is the number of events waiting to be written before new events are dropped
+const defaultAuditBuffer = 1024
+
+// auditEventDropped is the marker line written with the number of events lost when the buffer was full
+const auditEventDropped = "dropped"
+
+// AuditEvent is a line of the audit log
+type AuditEvent struct {
+	Time    time.Time    `json:"time"`
+	Event   string       `json:"event"`
+	Order   *model.Order `json:"order,omitempty"`
+	Error   string       `json:"error,omitempty"`
+	Dropped int          `json:"dropped,omitempty"`
+}
+
+// AuditLog appends the order events to a file, one JSON line by event, separate from the storage.
+// The order controller records the events when they happen, and they are written in the background,
+// so Record never blocks the trading loop. When the buffer is full, events are counted and a
+// "dropped" line is written with the number lost. Files are rotated by size or day, the rotated
+// file keeps the name with the rotation time, eg: audit.20220101T000000.jsonl.
+type AuditLog struct {
+	mtx     sync.Mutex
+	closed  bool
+	dropped int
+	events  chan AuditEvent
+	done    chan struct{}
+
+	path    string
+	maxSize int64
+	daily   bool
+	buffer  int
+
+	// writer state, owned by the writer goroutine
+	file *os.File
+	size int64
+	day  string
+}
+
+type AuditOption func(audit *AuditLog)
+
+// WithAuditMaxSize rotates the file when it exceeds the given size in bytes
+func WithAuditMaxSize(size int64) AuditOption {
+	return func(audit *AuditLog) {
+		audit.maxSize = size
+	}
+}
+
+// WithAuditDailyRotation rotates the file on the first event of each day (UTC)
+func WithAuditDailyRotation() AuditOption {
+	return func(audit *AuditLog) {
+		audit.daily = true
+	}
+}
+
+// WithAuditBuffer sets the number of events waiting to be written, events beyond it are dropped
+func WithAuditBuffer(size int) AuditOption {
+	return func(audit *AuditLog) {
+		audit.buffer = size
+	}
+}
+
+// NewAuditLog opens the file in append mode, creating it if needed, and starts the writer
+func NewAuditLog(path string, options ...AuditOption) (*AuditLog, error) {
+	audit := &AuditLog{
+		path:   path,
+		buffer: defaultAuditBuffer,
+		done:   make(chan struct{}),
+	}
+	for _, option := range options {
+		option(audit)
+	}
+
+	if err := audit.open(); err != nil {
+		return nil, err
+	}
+
+	audit.events = make(chan AuditEvent, audit.buffer)
+	go audit.run()
+	return audit, nil
+}
+
+// Record queues the event of the order, with the error of rejected orders, it never blocks
+func (a *AuditLog) Record(event string, order model.Order, err error) {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+
+	if a.closed {
+		return
+	}
+
+	now := time.Now().UTC()
+	if a.dropped > 0 && !a.enqueue(AuditEvent{Time: now, Event: auditEventDropped, Dropped: a.dropped}) {
+		a.dropped++
+		return
+	}
+	a.dropped = 0
+
+	line := AuditEvent{Time: now, Event: event, Order: &order}
+	if err != nil {
+		line.Error = err.Error()
+	}
+	if !a.enqueue(line) {
+		log.Errorf("[AUDIT] buffer full, dropping events from %s of order %d", event, order.ExchangeID)
+		a.dropped++
+	}
+}
+
+func (a *AuditLog) enqueue(event AuditEvent) bool {
+	select {
+	case a.events <- event:
+		return true
+	default:
+		return false
+	}
+}
+
+// Close writes the pending events, with the count of dropped ones, and closes the file
+func (a *AuditLog) Close() error {
+	a.mtx.Lock()
+	if a.closed {
+		a.mtx.Unlock()
+		return nil
+	}
+	a.closed = true
+	if a.dropped > 0 {
+		// the writer is still draining the buffer, so this send completes
+		a.events <- AuditEvent{Time: time.Now().UTC(), Event: auditEventDropped, Dropped: a.dropped}
+	}
+	close(a.events)
+	a.mtx.Unlock()
+
+	<-a.done
+	return a.file.Close()
+}
+
+func auditEventName(status model.OrderStatusType) string {
+	switch status {
+	case model.OrderStatusTypeNew:
+		return "create"
+	case model.OrderStatusTypePartiallyFilled:
+		return "partial_fill"
+	case model.OrderStatusTypeFilled:
+		return "fill"
+	case model.OrderStatusTypePendingCancel:
+		return "cancel_request"
+	case model.OrderStatusTypeCanceled:
+		return "cancel"
+	case model.OrderStatusTypeRejected:
+		return "reject"
+	case model.OrderStatusTypeExpired:
+		return "expire"
+	}
+	return strings.ToLower(string(status))
+}
+
+func (a *AuditLog) run() {
+	defer close(a.done)
+	for event := range a.events {
+		if err := a.write(event); err != nil {
+			log.Error("[AUDIT] ", err)
+		}
+	}
+}
+
+func (a *AuditLog) write(event AuditEvent) error {
+	line, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	day := event.Time.Format("2006-01-02")
+	if a.size > 0 && ((a.maxSize > 0 && a.size+int64(len(line)) > a.maxSize) || (a.daily && day != a.day)) {
+		if err := a.rotate(event.Time); err != nil {
+			return err
+		}
+	}
+	a.day = day
+
+	n, err := a.file.Write(line)
+	a.size += int64(n)
+	return err
+}
+
+func (a *AuditLog) open() error {
+	file, err := os.OpenFile(a.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	a.file = file
+	a.size = info.Size()
+	a.day = info.ModTime().UTC().Format("2006-01-02")
+	return nil
+}
+
+// rotate renames the current file with the rotation time and opens a new one
+func (a *AuditLog) rotate(at time.Time) error {
+	if err := a.file.Close(); err != nil {
+		return err
+	}
+
+	ext := filepath.Ext(a.path)
+	base := strings.TrimSuffix(a.path, ext)
+	rotated := base + "." + at.Format("20060102T150405") + ext
+	for i := 1; fileExists(rotated); i++ {
+		rotated = base + "." + at.Format("20060102T150405") + "-" + strconv.Itoa(i) + ext
+	}
+
+	if err := os.Rename(a.path, rotated); err != nil {
+		// keep appending to the current file
+		if openErr := a.open(); openErr != nil {
+			return openErr
+		}
+		return err
+	}
+	return a.open()
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// SetAuditLog records the order actions in the audit log when they happen, it must be called before Start
+func (c *Controller) SetAuditLog(audit *AuditLog) {
+	c.auditLog = audit
+}
+
+func (c *Controller) audit(event string, order model.Order, err error) {
+	if c.auditLog != nil {
+		c.auditLog.Record(event, order, err)
+	}
+}
+
+// auditCreate records a created order, followed by its status when it is already filled, eg: market orders
+func (c *Controller) auditCreate(order model.Order) {
+	c.audit(auditEventName(model.OrderStatusTypeNew), order, nil)
+	if order.Status != model.OrderStatusTypeNew {
+		c.audit(auditEventName(order.Status), order, nil)
+	}
+}
+
+// auditReject records an order refused by the controller or the exchange, with the error
+func (c *Controller) auditReject(side model.SideType, pair string, size float64, err error) {
+	event := auditEventName(model.OrderStatusTypeRejected)
+	if errors.Is(err, exchange.ErrOrderTimeout) {
+		event = "timeout"
+	}
+	c.audit(event, model.Order{
+		Pair:     pair,
+		Side:     side,
+		Quantity: size,
+		Status:   model.OrderStatusTypeRejected,
+	}, err)
+}
@@ -0,0 +1,166 @@
+package order
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/rodrigo-brito/ninjabot/exchange"
+	"github.com/rodrigo-brito/ninjabot/model"
+	"github.com/rodrigo-brito/ninjabot/storage"
+)
+
+func readAuditEvents(t *testing.T, path string) []AuditEvent {
+	file, err := os.Open(path)
+	require.NoError(t, err)
+	defer file.Close()
+
+	var events []AuditEvent
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var event AuditEvent
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &event))
+		events = append(events, event)
+	}
+	require.NoError(t, scanner.Err())
+	return events
+}
+
+func TestAuditLog(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	audit, err := NewAuditLog(path)
+	require.NoError(t, err)
+
+	db, err := storage.FromMemory()
+	require.NoError(t, err)
+	wallet := exchange.NewPaperWallet(ctx, "USDT", exchange.WithPaperAsset("USDT", 3000))
+	controller := NewController(ctx, wallet, db, NewOrderFeed())
+	controller.SetAuditLog(audit)
+	wallet.OnCandle(model.Candle{Pair: "BTCUSDT", Close: 1000, High: 1000, Low: 1000})
+	controller.OnCandle(model.Candle{Pair: "BTCUSDT", Close: 1000, High: 1000, Low: 1000})
+
+	market, err := controller.CreateOrderMarket(model.SideTypeBuy, "BTCUSDT", 1)
+	require.NoError(t, err)
+	limit, err := controller.CreateOrderLimit(model.SideTypeBuy, "BTCUSDT", 1, 900)
+	require.NoError(t, err)
+	canceled, err := controller.CreateOrderLimit(model.SideTypeBuy, "BTCUSDT", 0.5, 800)
+	require.NoError(t, err)
+	require.NoError(t, controller.Cancel(canceled))
+	_, err = controller.CreateOrderMarket(model.SideTypeBuy, "BTCUSDT", 10)
+	require.Error(t, err)
+
+	wallet.OnCandle(model.Candle{Pair: "BTCUSDT", Close: 850, High: 1000, Low: 850})
+	controller.updateOrders()
+
+	require.NoError(t, audit.Close())
+	_, err = controller.CreateOrderMarket(model.SideTypeBuy, "BTCUSDT", 0.1) // ignored after close
+	require.NoError(t, err)
+
+	events := readAuditEvents(t, path)
+	expected := []struct {
+		event string
+		id    int64
+	}{
+		{"create", market.ExchangeID},
+		{"fill", market.ExchangeID},
+		{"create", limit.ExchangeID},
+		{"create", canceled.ExchangeID},
+		{"cancel_request", canceled.ExchangeID},
+		{"reject", 0},
+		{"fill", limit.ExchangeID},
+		{"cancel", canceled.ExchangeID},
+	}
+	require.Len(t, events, len(expected))
+	for i, event := range events {
+		require.Equal(t, expected[i].event, event.Event)
+		require.Equal(t, expected[i].id, event.Order.ExchangeID)
+		require.False(t, event.Time.IsZero())
+		if i > 0 {
+			require.False(t, event.Time.Before(events[i-1].Time))
+		}
+	}
+	require.Equal(t, "BTCUSDT", events[5].Order.Pair)
+	require.Equal(t, model.SideTypeBuy, events[5].Order.Side)
+	require.NotEmpty(t, events[5].Error)
+
+	t.Run("append", func(t *testing.T) {
+		audit, err := NewAuditLog(path)
+		require.NoError(t, err)
+		audit.Record("create", model.Order{ExchangeID: 5, Status: model.OrderStatusTypeNew}, nil)
+		require.NoError(t, audit.Close())
+		require.Len(t, readAuditEvents(t, path), len(expected)+1)
+	})
+
+	t.Run("dropped", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "audit.jsonl")
+		audit, err := NewAuditLog(path, WithAuditBuffer(1))
+		require.NoError(t, err)
+
+		const total = 1000
+		for id := int64(1); id <= total; id++ {
+			audit.Record("create", model.Order{ExchangeID: id}, nil)
+		}
+		require.NoError(t, audit.Close())
+
+		// every event is written or counted in a marker line
+		written := 0
+		for _, event := range readAuditEvents(t, path) {
+			if event.Event == auditEventDropped {
+				require.Nil(t, event.Order)
+				written += event.Dropped
+				continue
+			}
+			written++
+		}
+		require.Equal(t, total, written)
+	})
+}
+
+func TestAuditLog_Rotation(t *testing.T) {
+	t.Run("size", func(t *testing.T) {
+		dir := t.TempDir()
+		path := filepath.Join(dir, "audit.jsonl")
+		audit, err := NewAuditLog(path, WithAuditMaxSize(1))
+		require.NoError(t, err)
+
+		for id := int64(1); id <= 3; id++ {
+			audit.Record("create", model.Order{ExchangeID: id, Status: model.OrderStatusTypeNew}, nil)
+		}
+		require.NoError(t, audit.Close())
+
+		files, err := filepath.Glob(filepath.Join(dir, "audit.*.jsonl"))
+		require.NoError(t, err)
+		require.Len(t, files, 2)
+
+		events := readAuditEvents(t, path)
+		require.Len(t, events, 1)
+		require.Equal(t, int64(3), events[0].Order.ExchangeID)
+	})
+
+	t.Run("daily", func(t *testing.T) {
+		dir := t.TempDir()
+		path := filepath.Join(dir, "audit.jsonl")
+		require.NoError(t, os.WriteFile(path, []byte(`{"event":"create"}`+"\n"), 0o644))
+		yesterday := time.Now().AddDate(0, 0, -1)
+		require.NoError(t, os.Chtimes(path, yesterday, yesterday))
+
+		audit, err := NewAuditLog(path, WithAuditDailyRotation())
+		require.NoError(t, err)
+		audit.Record("fill", model.Order{ExchangeID: 1, Status: model.OrderStatusTypeFilled}, nil)
+		audit.Record("fill", model.Order{ExchangeID: 2, Status: model.OrderStatusTypeFilled}, nil)
+		require.NoError(t, audit.Close())
+
+		files, err := filepath.Glob(filepath.Join(dir, "audit.*.jsonl"))
+		require.NoError(t, err)
+		require.Len(t, files, 1)
+		require.Len(t, readAuditEvents(t, files[0]), 1)
+		require.Len(t, readAuditEvents(t, path), 2)
+	})
+}
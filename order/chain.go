@@ -0,0 +1,168 @@
+package order
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/rodrigo-brito/ninjabot/exchange"
+	"github.com/rodrigo-brito/ninjabot/model"
+)
+
+// ChildOrder is a follow-up order submitted by the controller when its parent order is filled.
+// Supported types are MARKET, LIMIT (Price), STOP_LOSS (Stop, sell only) and LIMIT_MAKER with Stop
+// for an OCO bracket. Side defaults to the opposite of the parent and Quantity to the parent quantity, limited
+// to the free asset for sells, since fees paid in the asset reduce the position.
+// Then defines the next step of the chain, attached to the orders created for the child.
+// Child orders are tagged with the strategy of the parent. Children waiting for the parent are kept in memory
+// only: they are lost on restart, and the filled parent of a previous session doesn't submit them.
+type ChildOrder struct {
+	Side     model.SideType
+	Type     model.OrderType
+	Quantity float64
+	Price    float64
+	Stop     float64
+	Then     []ChildOrder
+}
+
+// chainedOrders are the follow-up orders of a filled parent
+type chainedOrders struct {
+	parent   model.Order
+	children []ChildOrder
+}
+
+// Attach registers follow-up orders of a parent order, they are submitted when the parent fills and
+// discarded when the parent is canceled. Children of an already filled parent are submitted at once.
+func (c *Controller) Attach(parent model.Order, children ...ChildOrder) error {
+	for _, child := range children {
+		if err := validateChild(child); err != nil {
+			return err
+		}
+	}
+
+	if parent.Status == model.OrderStatusTypeFilled {
+		c.submitChildren(parent, children)
+		return nil
+	}
+
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	if parent.Status != model.OrderStatusTypeNew && parent.Status != model.OrderStatusTypePartiallyFilled {
+		return fmt.Errorf("invalid parent order %d with status %s", parent.ExchangeID, parent.Status)
+	}
+
+	c.children[parent.ExchangeID] = append(c.children[parent.ExchangeID], children...)
+	return nil
+}
+
+// ChildOrders returns the follow-up orders waiting for the parent order to fill
+func (c *Controller) ChildOrders(parent model.Order) []ChildOrder {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	return append([]ChildOrder(nil), c.children[parent.ExchangeID]...)
+}
+
+func validateChild(child ChildOrder) error {
+	switch child.Type {
+	case model.OrderTypeMarket:
+	case model.OrderTypeLimit:
+		if child.Price <= 0 {
+			return fmt.Errorf("invalid child order: %s requires a price", child.Type)
+		}
+	case model.OrderTypeStopLoss:
+		if child.Stop <= 0 {
+			return fmt.Errorf("invalid child order: %s requires a stop", child.Type)
+		}
+	case model.OrderTypeLimitMaker:
+		if child.Price <= 0 || child.Stop <= 0 {
+			return fmt.Errorf("invalid child order: %s requires a price and a stop", child.Type)
+		}
+	default:
+		return fmt.Errorf("invalid child order type: %s", child.Type)
+	}
+
+	for _, next := range child.Then {
+		if err := validateChild(next); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// releaseChildren removes the follow-up orders of a finished parent, returning them if the parent is filled
+func (c *Controller) releaseChildren(parent model.Order) (chainedOrders, bool) {
+	children, ok := c.children[parent.ExchangeID]
+	if !ok {
+		return chainedOrders{}, false
+	}
+
+	switch parent.Status {
+	case model.OrderStatusTypeFilled:
+		delete(c.children, parent.ExchangeID)
+		return chainedOrders{parent: parent, children: children}, true
+	case model.OrderStatusTypeCanceled, model.OrderStatusTypeExpired, model.OrderStatusTypeRejected:
+		delete(c.children, parent.ExchangeID)
+	}
+	return chainedOrders{}, false
+}
+
+func (c *Controller) submitChildren(parent model.Order, children []ChildOrder) {
+	for _, child := range children {
+		orders, err := c.submitChild(parent, child)
+		if err != nil {
+			c.logger.WithField("parent", parent.ExchangeID).Error("orderController/child: ", err)
+			continue
+		}
+
+		if len(child.Then) == 0 {
+			continue
+		}
+
+		for _, order := range orders {
+			if err := c.Attach(order, child.Then...); err != nil {
+				c.logger.WithField("parent", order.ExchangeID).Error("orderController/child: ", err)
+			}
+		}
+	}
+}
+
+func (c *Controller) submitChild(parent model.Order, child ChildOrder) ([]model.Order, error) {
+	side := child.Side
+	if side == "" {
+		side = model.SideTypeSell
+		if parent.Side == model.SideTypeSell {
+			side = model.SideTypeBuy
+		}
+	}
+
+	quantity := child.Quantity
+	if quantity == 0 {
+		quantity = parent.Quantity
+		if side == model.SideTypeSell {
+			// fees paid in the asset may reduce the balance below the parent quantity
+			account, err := c.exchange.Account()
+			if err != nil {
+				return nil, err
+			}
+			asset, quote := exchange.SplitAssetQuote(parent.Pair)
+			balance, _ := account.Balance(asset, quote)
+			quantity = math.Min(quantity, balance.Free)
+		}
+	}
+
+	c.logger.Infof("[ORDER] Parent %d filled, submitting %s %s child order", parent.ExchangeID, child.Type, side)
+	source := orderSource{strategy: parent.Strategy, exit: true}
+	switch child.Type {
+	case model.OrderTypeMarket:
+		order, err := c.createOrderMarket(source, side, parent.Pair, quantity, 0)
+		return []model.Order{order}, err
+	case model.OrderTypeLimit:
+		order, err := c.createOrderLimit(source, side, parent.Pair, quantity, child.Price)
+		return []model.Order{order}, err
+	case model.OrderTypeStopLoss:
+		order, err := c.createOrderStop(source, parent.Pair, quantity, child.Stop)
+		return []model.Order{order}, err
+	default:
+		return c.createOrderOCO(source, side, parent.Pair, quantity, child.Price, child.Stop, child.Stop)
+	}
+}
@@ -0,0 +1,92 @@
+package order
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/rodrigo-brito/ninjabot/exchange"
+	"github.com/rodrigo-brito/ninjabot/model"
+	"github.com/rodrigo-brito/ninjabot/storage"
+)
+
+func TestController_Attach(t *testing.T) {
+	t.Run("entry then bracket", func(t *testing.T) {
+		db, err := storage.FromMemory()
+		require.NoError(t, err)
+		ctx := context.Background()
+		wallet := exchange.NewPaperWallet(ctx, "USDT", exchange.WithPaperAsset("USDT", 1000))
+		controller := NewController(ctx, wallet, db, NewOrderFeed())
+		wallet.OnCandle(model.Candle{Time: time.Now(), Pair: "BTCUSDT", Close: 100, High: 100, Low: 100})
+
+		entry, err := controller.CreateOrderLimit(model.SideTypeBuy, "BTCUSDT", 1, 90)
+		require.NoError(t, err)
+		require.NoError(t, controller.Attach(entry, ChildOrder{Type: model.OrderTypeLimitMaker, Price: 120, Stop: 80}))
+
+		// the bracket waits for the entry
+		controller.updateOrders()
+		orders, err := db.Orders()
+		require.NoError(t, err)
+		require.Len(t, orders, 1)
+
+		wallet.OnCandle(model.Candle{Time: time.Now(), Pair: "BTCUSDT", Close: 90, High: 95, Low: 90})
+		controller.updateOrders()
+
+		orders, err = db.Orders(storage.WithStatus(model.OrderStatusTypeNew))
+		require.NoError(t, err)
+		require.Len(t, orders, 2)
+		for _, order := range orders {
+			require.Equal(t, model.SideTypeSell, order.Side)
+			require.Equal(t, 1.0, order.Quantity)
+			require.NotNil(t, order.GroupID)
+		}
+		require.Empty(t, controller.ChildOrders(entry))
+	})
+
+	t.Run("cancel parent discards children", func(t *testing.T) {
+		db, err := storage.FromMemory()
+		require.NoError(t, err)
+		ctx := context.Background()
+		wallet := exchange.NewPaperWallet(ctx, "USDT", exchange.WithPaperAsset("USDT", 1000))
+		controller := NewController(ctx, wallet, db, NewOrderFeed())
+		wallet.OnCandle(model.Candle{Time: time.Now(), Pair: "BTCUSDT", Close: 100, High: 100, Low: 100})
+
+		entry, err := controller.CreateOrderLimit(model.SideTypeBuy, "BTCUSDT", 1, 90)
+		require.NoError(t, err)
+		require.NoError(t, controller.Attach(entry, ChildOrder{Type: model.OrderTypeLimit, Price: 120}))
+		require.Len(t, controller.ChildOrders(entry), 1)
+
+		require.NoError(t, controller.Cancel(entry))
+		require.Empty(t, controller.ChildOrders(entry))
+	})
+
+	t.Run("child limited to the free asset", func(t *testing.T) {
+		db, err := storage.FromMemory()
+		require.NoError(t, err)
+		ctx := context.Background()
+		wallet := exchange.NewPaperWallet(ctx, "USDT", exchange.WithPaperAsset("USDT", 1000),
+			exchange.WithPaperFee(0.001, 0.001))
+		controller := NewController(ctx, wallet, db, NewOrderFeed())
+		wallet.OnCandle(model.Candle{Time: time.Now(), Pair: "BTCUSDT", Close: 100, High: 100, Low: 100})
+
+		// the buy fee is paid in BTC, the position is below the entry quantity
+		entry, err := controller.CreateOrderMarket(model.SideTypeBuy, "BTCUSDT", 2)
+		require.NoError(t, err)
+		require.NoError(t, controller.Attach(entry, ChildOrder{Type: model.OrderTypeLimit, Price: 120}))
+
+		orders, err := db.Orders(storage.WithStatus(model.OrderStatusTypeNew))
+		require.NoError(t, err)
+		require.Len(t, orders, 1)
+		require.Equal(t, model.SideTypeSell, orders[0].Side)
+		require.InDelta(t, 1.998, orders[0].Quantity, 1e-9)
+	})
+
+	t.Run("invalid child", func(t *testing.T) {
+		controller := NewController(context.Background(), nil, nil, NewOrderFeed())
+		err := controller.Attach(model.Order{Status: model.OrderStatusTypeNew},
+			ChildOrder{Type: model.OrderTypeLimit})
+		require.EqualError(t, err, "invalid child order: LIMIT requires a price")
+	})
+}
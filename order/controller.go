@@ -2,12 +2,15 @@ package order
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"math"
 	"os"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/rodrigo-brito/ninjabot/exchange"
@@ -32,6 +35,27 @@ type summary struct {
 	Volume           float64
 }
 
+// add registers the profit of a closed trade
+func (s *summary) add(result Result) {
+	if result.ProfitPercent >= 0 {
+		if result.Side == model.SideTypeBuy {
+			s.WinLong = append(s.WinLong, result.ProfitValue)
+			s.WinLongPercent = append(s.WinLongPercent, result.ProfitPercent)
+		} else {
+			s.WinShort = append(s.WinShort, result.ProfitValue)
+			s.WinShortPercent = append(s.WinShortPercent, result.ProfitPercent)
+		}
+	} else {
+		if result.Side == model.SideTypeBuy {
+			s.LoseLong = append(s.LoseLong, result.ProfitValue)
+			s.LoseLongPercent = append(s.LoseLongPercent, result.ProfitPercent)
+		} else {
+			s.LoseShort = append(s.LoseShort, result.ProfitValue)
+			s.LoseShortPercent = append(s.LoseShortPercent, result.ProfitPercent)
+		}
+	}
+}
+
 func (s summary) Win() []float64 {
 	return append(s.WinLong, s.WinShort...)
 }
@@ -111,6 +135,11 @@ func (s summary) WinPercentage() float64 {
 }
 
 func (s summary) String() string {
+	return s.Format(model.NumberFormatPlain)
+}
+
+// Format returns the summary table with numbers in the given format
+func (s summary) Format(f model.NumberFormat) string {
 	tableString := &strings.Builder{}
 	table := tablewriter.NewWriter(tableString)
 	_, quote := exchange.SplitAssetQuote(s.Pair)
@@ -119,11 +148,11 @@ func (s summary) String() string {
 		{"Trades", strconv.Itoa(len(s.Lose()) + len(s.Win()))},
 		{"Win", strconv.Itoa(len(s.Win()))},
 		{"Loss", strconv.Itoa(len(s.Lose()))},
-		{"% Win", fmt.Sprintf("%.1f", s.WinPercentage())},
-		{"Payoff", fmt.Sprintf("%.1f", s.Payoff()*100)},
-		{"Pr.Fact", fmt.Sprintf("%.1f", s.Payoff()*100)},
-		{"Profit", fmt.Sprintf("%.4f %s", s.Profit(), quote)},
-		{"Volume", fmt.Sprintf("%.4f %s", s.Volume, quote)},
+		{"% Win", f.Format(s.WinPercentage(), 1)},
+		{"Payoff", f.Format(s.Payoff()*100, 1)},
+		{"Pr.Fact", f.Format(s.Payoff()*100, 1)},
+		{"Profit", fmt.Sprintf("%s %s", f.Format(s.Profit(), 4), quote)},
+		{"Volume", fmt.Sprintf("%s %s", f.Format(s.Volume, 4), quote)},
 	}
 	table.AppendBulk(data)
 	table.SetColumnAlignment([]int{tablewriter.ALIGN_LEFT, tablewriter.ALIGN_RIGHT})
@@ -164,6 +193,8 @@ const (
 
 type Result struct {
 	Pair          string
+	Strategy      string // strategy of the order closing the trade
+	Note          string // note of the order closing the trade
 	ProfitPercent float64
 	ProfitValue   float64
 	Side          model.SideType
@@ -176,6 +207,7 @@ type Position struct {
 	AvgPrice  float64
 	Quantity  float64
 	CreatedAt time.Time
+	Fees      float64 // entry fees in quote of the open quantity
 }
 
 func (p *Position) Update(order *model.Order) (result *Result, finished bool) {
@@ -187,25 +219,36 @@ func (p *Position) Update(order *model.Order) (result *Result, finished bool) {
 	if p.Side == order.Side {
 		p.AvgPrice = (p.AvgPrice*p.Quantity + price*order.Quantity) / (p.Quantity + order.Quantity)
 		p.Quantity += order.Quantity
+		p.Fees += order.Fee
 	} else {
+		// fees of the closed quantity, from entry and exit orders
+		closed := math.Min(p.Quantity, order.Quantity)
+		entryFees := p.Fees * closed / p.Quantity
+		fees := entryFees + order.Fee*closed/order.Quantity
+		p.Fees -= entryFees
+
 		if p.Quantity == order.Quantity {
 			finished = true
 		} else if p.Quantity > order.Quantity {
 			p.Quantity -= order.Quantity
 		} else {
+			order.Opening = true
 			p.Quantity = order.Quantity - p.Quantity
 			p.Side = order.Side
 			p.CreatedAt = order.CreatedAt
 			p.AvgPrice = price
+			p.Fees = order.Fee - order.Fee*closed/order.Quantity
 		}
 
 		quantity := math.Min(p.Quantity, order.Quantity)
-		order.Profit = (price - p.AvgPrice) / p.AvgPrice
-		order.ProfitValue = (price - p.AvgPrice) * quantity
+		order.Profit = (price-p.AvgPrice)/p.AvgPrice - fees/(p.AvgPrice*closed)
+		order.ProfitValue = (price-p.AvgPrice)*quantity - fees
 
 		result = &Result{
 			CreatedAt:     order.CreatedAt,
 			Pair:          order.Pair,
+			Strategy:      order.Strategy,
+			Note:          order.Note,
 			Duration:      order.CreatedAt.Sub(p.CreatedAt),
 			ProfitPercent: order.Profit,
 			ProfitValue:   order.ProfitValue,
@@ -218,22 +261,120 @@ func (p *Position) Update(order *model.Order) (result *Result, finished bool) {
 	return nil, false
 }
 
+const (
+	// reconcileWindow is the period in which a timed out order is searched in the exchange
+	reconcileWindow = 10 * time.Minute
+	// reconcileLookback is the number of recent exchange orders inspected to reconcile a timed out order
+	reconcileLookback = 10
+	// startupLookback is the number of recent exchange orders by pair fetched in the startup reconciliation
+	startupLookback = 100
+	// maxCatchUpOrders is the maximum number of orders listed in the startup catch-up notification
+	maxCatchUpOrders = 10
+	// tradesRetention is the period of closed trades kept in memory, relative to the last trade
+	tradesRetention = 7 * 24 * time.Hour
+)
+
+// orderLister is implemented by exchanges able to list the recent orders of a pair
+type orderLister interface {
+	Orders(pair string, limit int) ([]model.Order, error)
+}
+
+// fillEstimator is implemented by exchanges able to estimate the average fill price of a market order
+type fillEstimator interface {
+	EstimateFill(ctx context.Context, side model.SideType, pair string, size float64) (float64, error)
+}
+
+// touchFiller is implemented by simulated exchanges, to fill touched virtual orders at the trigger price
+// instead of the candle close
+type touchFiller interface {
+	CreateOrderMarketAt(side model.SideType, pair string, size, price float64) (model.Order, error)
+}
+
+// bookTicker is implemented by exchanges able to return the best bid and ask of a pair
+type bookTicker interface {
+	BookTicker(ctx context.Context, pair string) (bid, ask float64, err error)
+}
+
+// timedOutOrder is an order request without answer, it may be accepted by the exchange later
+type timedOutOrder struct {
+	pair        string
+	side        model.SideType
+	requestedAt time.Time
+}
+
 type Controller struct {
-	mtx            sync.Mutex
-	ctx            context.Context
-	exchange       service.Exchange
-	storage        storage.Storage
-	orderFeed      *Feed
-	notifier       service.Notifier
-	Results        map[string]*summary
-	lastPrice      map[string]float64
-	tickerInterval time.Duration
-	finish         chan bool
-	status         Status
+	mtx              sync.Mutex
+	ctx              context.Context
+	exchange         service.Exchange
+	storage          storage.Storage
+	orderFeed        *Feed
+	notifier         service.Notifier
+	Results          map[string]*summary
+	lastPrice        map[string]float64
+	tickerInterval   time.Duration
+	finish           chan bool
+	status           Status
+	apiLatency       atomic.Int64
+	timedOut         []timedOutOrder
+	touchOrders      []model.Order
+	touchSeq         int64
+	trailingOrders   []*trailingTakeProfit
+	numberFormat     model.NumberFormat
+	minOrderQuote    map[string]float64
+	maxSlippage      float64
+	slippageLimit    bool
+	autoReduce       bool
+	trades           []Result               // trades closed within the retention
+	closedTrades     int                    // number of trades closed since the start
+	children         map[int64][]ChildOrder // follow-up orders by parent exchange id
+	gridOrders       map[int64]gridLevel    // orders of grids by exchange id
+	milestoneRule    *MilestoneRule
+	milestonePairs   []string
+	milestones       map[string]*milestoneState
+	milestoneStorage storage.MilestoneStorage       // persists the milestones progress once restored
+	lastSweep        time.Time                      // open time of the candle of the last milestones sweep
+	reserved         map[string]float64             // capital reserved by milestones, by quote asset
+	strategies       map[string]map[string]*summary // results by strategy and pair
+	entryCooldown    time.Duration
+	lastEntry        map[string]entry     // last entry by pair
+	candleTime       map[string]time.Time // time of the last candle by pair
+	auditLog         *AuditLog
+	logger           *log.Logger
 
 	position map[string]*Position
 }
 
+// ErrOrderBelowMinimum is returned when the order value is below the minimum defined in settings
+var ErrOrderBelowMinimum = errors.New("order value below the minimum")
+
+// ErrOrderBelowLotSize is returned when the order quantity rounds down to zero with the lot size of the pair
+var ErrOrderBelowLotSize = errors.New("order quantity below the lot size")
+
+// ErrSlippageExceeded is returned when the estimated fill of a market order exceeds the maximum slippage
+var ErrSlippageExceeded = errors.New("estimated slippage above the maximum")
+
+// ErrInvalidTickSize is returned when a price offset in ticks is requested for a pair without tick size
+var ErrInvalidTickSize = errors.New("invalid tick size")
+
+// ErrInvalidTrigger is returned when a market-if-touched trigger is on the wrong side of the price
+var ErrInvalidTrigger = errors.New("invalid trigger price")
+
+// trailingTakeProfit is the state of a virtual trailing take-profit order
+type trailingTakeProfit struct {
+	order      model.Order
+	entry      float64
+	activation float64
+	trail      float64
+	peak       float64
+	active     bool
+}
+
+// Diagnostics is a snapshot of the order controller health
+type Diagnostics struct {
+	OpenOrders int
+	APILatency time.Duration // duration of the most recent exchange call
+}
+
 func NewController(ctx context.Context, exchange service.Exchange, storage storage.Storage,
 	orderFeed *Feed) *Controller {
 
@@ -247,26 +388,255 @@ func NewController(ctx context.Context, exchange service.Exchange, storage stora
 		tickerInterval: time.Second,
 		finish:         make(chan bool),
 		position:       make(map[string]*Position),
+		children:       make(map[int64][]ChildOrder),
+		gridOrders:     make(map[int64]gridLevel),
+		milestones:     make(map[string]*milestoneState),
+		reserved:       make(map[string]float64),
+		strategies:     make(map[string]map[string]*summary),
+		lastEntry:      make(map[string]entry),
+		candleTime:     make(map[string]time.Time),
+		logger:         log.StandardLogger(),
 	}
 }
 
+// SetLogger sets the logger of the controller, the standard logger by default
+func (c *Controller) SetLogger(logger *log.Logger) {
+	c.logger = logger
+}
+
 func (c *Controller) SetNotifier(notifier service.Notifier) {
 	c.notifier = notifier
 }
 
+// SetNumberFormat sets the format of numbers in profit notifications
+func (c *Controller) SetNumberFormat(format model.NumberFormat) {
+	c.numberFormat = format
+}
+
+// SetMinOrderQuote sets the minimum order value in quote currency by pair
+func (c *Controller) SetMinOrderQuote(limits map[string]float64) {
+	c.minOrderQuote = limits
+}
+
+// SetMaxSlippage sets the default price protection of market orders, eg: 0.01 for 1%. Orders with an
+// estimated fill above the maximum are rejected, or converted to limit orders at the maximum price with
+// convertToLimit. The protection requires an exchange able to estimate fills, eg: Binance spot or paper wallet.
+// Internal exits, eg: flatten, virtual orders, milestone sales and chain children, are not protected.
+func (c *Controller) SetMaxSlippage(maxSlippage float64, convertToLimit bool) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	c.maxSlippage = maxSlippage
+	c.slippageLimit = convertToLimit
+}
+
+// checkSlippage compares the estimated fill of a market order with the last quote, the size of orders by
+// quote amount is estimated with the last quote. It returns the price of a protective limit order when the
+// maximum slippage is exceeded and orders are converted to limit orders.
+func (c *Controller) checkSlippage(side model.SideType, pair string, size, amount,
+	maxSlippage float64) (float64, error) {
+	estimator, ok := c.exchange.(fillEstimator)
+	if !ok || maxSlippage <= 0 {
+		return 0, nil
+	}
+
+	c.mtx.Lock()
+	expected, ok := c.lastPrice[pair]
+	c.mtx.Unlock()
+
+	start := time.Now()
+	defer c.trackLatency(start)
+	if !ok {
+		var err error
+		expected, err = c.exchange.LastQuote(c.ctx, pair)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	if size == 0 {
+		size = amount / expected
+	}
+
+	estimated, err := estimator.EstimateFill(c.ctx, side, pair, size)
+	if err != nil {
+		return 0, err
+	}
+
+	slippage := (estimated - expected) / expected
+	limit := expected * (1 + maxSlippage)
+	if side == model.SideTypeSell {
+		slippage = -slippage
+		limit = expected * (1 - maxSlippage)
+	}
+
+	if slippage <= maxSlippage {
+		return 0, nil
+	}
+
+	if c.slippageLimit {
+		c.logger.Warnf("[ORDER] Estimated slippage of %s%% for %s %s, converting to LIMIT order at %s",
+			c.numberFormat.Format(slippage*100, 2), side, pair, c.numberFormat.Format(limit, 6))
+		return limit, nil
+	}
+
+	err = fmt.Errorf("%w: %s %s estimated at %s, %s%% from the last quote of %s", ErrSlippageExceeded,
+		side, pair, c.numberFormat.Format(estimated, 6), c.numberFormat.Format(slippage*100, 2),
+		c.numberFormat.Format(expected, 6))
+	c.notifyError(err)
+	c.auditReject(side, pair, size, err)
+	return 0, err
+}
+
+// checkMinOrder rejects orders adding to a position with value below the pair minimum. Internal exits and
+// orders reducing the position are exempt, so positions below the minimum can still be closed.
+// The caller must hold the lock.
+func (c *Controller) checkMinOrder(source orderSource, side model.SideType, pair string, size,
+	value float64) error {
+	minimum, ok := c.minOrderQuote[pair]
+	if !ok || minimum <= 0 || source.exit || c.reducesPosition(side, pair) {
+		return nil
+	}
+
+	if value < minimum {
+		err := fmt.Errorf("%w: %s %s of %s is below the minimum of %s", ErrOrderBelowMinimum, side, pair,
+			c.numberFormat.Format(value, 2), c.numberFormat.Format(minimum, 2))
+		c.notifyError(err)
+		c.auditReject(side, pair, size, err)
+		return err
+	}
+	return nil
+}
+
+// reducesPosition returns true when the order side is opposite to the open position, the caller must hold the lock
+func (c *Controller) reducesPosition(side model.SideType, pair string) bool {
+	position, ok := c.position[pair]
+	return ok && position.Quantity > 0 && position.Side != side
+}
+
+// marketPrice returns the last price of the pair to value market orders, when required by the order minimum,
+// the auto reduce or the milestones reserve. The last quote is requested to the exchange without candles, so it must be called
+// without the lock.
+func (c *Controller) marketPrice(side model.SideType, pair string) (float64, error) {
+	c.mtx.Lock()
+	price, ok := c.lastPrice[pair]
+	_, quote := exchange.SplitAssetQuote(pair)
+	required := c.minOrderQuote[pair] > 0 ||
+		(side == model.SideTypeBuy && (c.autoReduce || c.reserved[quote] > 0))
+	c.mtx.Unlock()
+
+	if ok || !required {
+		return price, nil
+	}
+	return c.exchange.LastQuote(c.ctx, pair)
+}
+
 func (c *Controller) OnCandle(candle model.Candle) {
+	c.mtx.Lock()
 	c.lastPrice[candle.Pair] = candle.Close
+	c.candleTime[candle.Pair] = candle.Time
+	if candle.UpdatedAt.After(candle.Time) {
+		c.candleTime[candle.Pair] = candle.UpdatedAt
+	}
+	touched := c.touchedOrders(candle)
+	trailed := c.trailedOrders(candle)
+	sweep := c.milestonesDue(candle)
+	c.mtx.Unlock()
+
+	var sales []model.Order
+	if sweep {
+		sales = c.milestonesSweep()
+	}
+
+	for _, sale := range sales {
+		c.logger.Infof("[ORDER] Selling %f %s to reserve milestone profit", sale.Quantity, sale.Pair)
+		_, err := c.createOrderMarket(orderSource{note: "milestone", exit: true}, sale.Side, sale.Pair, sale.Quantity, 0)
+		if err != nil {
+			c.logger.Error(err)
+		}
+	}
+
+	// exits of virtual orders keep the strategy of the order, without slippage protection: they are
+	// triggered on wide candles, when the estimated fill is far from the last quote
+	for _, touch := range touched {
+		c.logger.Infof("[ORDER] %s touched at %f", touch, candle.Close)
+		source := exitOf(touch)
+		source.trigger = touch.Price
+		_, err := c.createOrderMarket(source, touch.Side, touch.Pair, touch.Quantity, 0)
+		if err != nil {
+			c.logger.Error(err)
+		}
+	}
+
+	for _, trail := range trailed {
+		c.logger.Infof("[ORDER] %s triggered at %f", trail, candle.Close)
+		_, err := c.createOrderMarket(exitOf(trail), trail.Side, trail.Pair, trail.Quantity, 0)
+		if err != nil {
+			c.logger.Error(err)
+		}
+	}
+}
+
+// trailedOrders updates the peak of trailing take-profit orders and removes and returns the triggered ones.
+// An order is triggered when the close retraces below the trailing level, but only if the close is above entry.
+func (c *Controller) trailedOrders(candle model.Candle) []model.Order {
+	var triggered []model.Order
+	pending := c.trailingOrders[:0]
+	for _, trailing := range c.trailingOrders {
+		if trailing.order.Pair != candle.Pair {
+			pending = append(pending, trailing)
+			continue
+		}
+
+		if !trailing.active && candle.High >= trailing.activation {
+			trailing.active = true
+		}
+
+		if trailing.active {
+			trailing.peak = math.Max(trailing.peak, candle.High)
+			stop := math.Max(trailing.peak*(1-trailing.trail), trailing.entry)
+			trailing.order.Stop = &stop
+
+			if candle.Close <= stop && candle.Close > trailing.entry {
+				triggered = append(triggered, trailing.order)
+				continue
+			}
+		}
+		pending = append(pending, trailing)
+	}
+	c.trailingOrders = pending
+	return triggered
+}
+
+// touchedOrders removes and returns the market-if-touched orders triggered by the candle
+func (c *Controller) touchedOrders(candle model.Candle) []model.Order {
+	var touched []model.Order
+	pending := c.touchOrders[:0]
+	for _, order := range c.touchOrders {
+		if order.Pair == candle.Pair &&
+			((order.Side == model.SideTypeBuy && candle.Low <= order.Price) ||
+				(order.Side == model.SideTypeSell && candle.High >= order.Price)) {
+			touched = append(touched, order)
+			continue
+		}
+		pending = append(pending, order)
+	}
+	c.touchOrders = pending
+	return touched
 }
 
-func (c *Controller) updatePosition(o *model.Order) {
+// updatePosition updates the position of the pair with the filled order. With record, the closed trade is
+// added to the results and notified, otherwise only the position is updated, eg: rebuilding positions at startup.
+func (c *Controller) updatePosition(o *model.Order, record bool) {
 	// get filled orders before the current order
 	position, ok := c.position[o.Pair]
 	if !ok {
+		o.Opening = true
 		c.position[o.Pair] = &Position{
 			AvgPrice:  o.Price,
 			Quantity:  o.Quantity,
 			CreatedAt: o.CreatedAt,
 			Side:      o.Side,
+			Fees:      o.Fee,
 		}
 		return
 	}
@@ -276,51 +646,237 @@ func (c *Controller) updatePosition(o *model.Order) {
 		delete(c.position, o.Pair)
 	}
 
-	if result != nil {
+	if result != nil && record {
+		c.recordTrade(*result)
+
 		// TODO: replace by a slice of Result
-		if result.ProfitPercent >= 0 {
-			if result.Side == model.SideTypeBuy {
-				c.Results[o.Pair].WinLong = append(c.Results[o.Pair].WinLong, result.ProfitValue)
-				c.Results[o.Pair].WinLongPercent = append(c.Results[o.Pair].WinLongPercent, result.ProfitPercent)
-			} else {
-				c.Results[o.Pair].WinShort = append(c.Results[o.Pair].WinShort, result.ProfitValue)
-				c.Results[o.Pair].WinShortPercent = append(c.Results[o.Pair].WinShortPercent, result.ProfitPercent)
-			}
-		} else {
-			if result.Side == model.SideTypeBuy {
-				c.Results[o.Pair].LoseLong = append(c.Results[o.Pair].LoseLong, result.ProfitValue)
-				c.Results[o.Pair].LoseLongPercent = append(c.Results[o.Pair].LoseLongPercent, result.ProfitPercent)
-			} else {
-				c.Results[o.Pair].LoseShort = append(c.Results[o.Pair].LoseShort, result.ProfitValue)
-				c.Results[o.Pair].LoseShortPercent = append(c.Results[o.Pair].LoseShortPercent, result.ProfitPercent)
-			}
+		c.Results[o.Pair].add(*result)
+		if o.Strategy != "" {
+			c.strategySummary(o.Strategy, o.Pair).add(*result)
 		}
 
 		_, quote := exchange.SplitAssetQuote(o.Pair)
 		c.notify(fmt.Sprintf(
-			"[PROFIT] %f %s (%f %%)\n`%s`",
-			result.ProfitValue,
+			"[PROFIT] %s %s (%s %%)\n`%s`",
+			c.numberFormat.Format(result.ProfitValue, 6),
 			quote,
-			result.ProfitPercent*100,
-			c.Results[o.Pair].String(),
+			c.numberFormat.Format(result.ProfitPercent*100, 6),
+			c.Results[o.Pair].Format(c.numberFormat),
 		))
 	}
 }
 
+// recordTrade keeps the closed trade, dropping trades older than the retention
+func (c *Controller) recordTrade(result Result) {
+	c.closedTrades++
+	c.trades = append(c.trades, result)
+
+	cutoff := result.CreatedAt.Add(-tradesRetention)
+	expired := 0
+	for expired < len(c.trades) && c.trades[expired].CreatedAt.Before(cutoff) {
+		expired++
+	}
+	if expired > 0 {
+		c.trades = append(c.trades[:0], c.trades[expired:]...)
+	}
+}
+
 func (c *Controller) notify(message string) {
-	log.Info(message)
+	c.logger.Info(message)
 	if c.notifier != nil {
 		c.notifier.Notify(message)
 	}
 }
 
 func (c *Controller) notifyError(err error) {
-	log.Error(err)
+	c.logger.Error(err)
 	if c.notifier != nil {
 		c.notifier.OnError(err)
 	}
 }
 
+// onOrderError notifies the error and keeps track of timed out requests to reconcile them later
+func (c *Controller) onOrderError(err error, side model.SideType, pair string, size float64,
+	requestedAt time.Time) {
+	c.notifyError(err)
+	c.auditReject(side, pair, size, err)
+	if errors.Is(err, exchange.ErrOrderTimeout) {
+		c.timedOut = append(c.timedOut, timedOutOrder{
+			pair:        pair,
+			side:        side,
+			requestedAt: requestedAt,
+		})
+	}
+}
+
+// reconcileTimedOut looks for orders accepted by the exchange after the request timed out
+// and registers them, so fills are reflected in positions and results
+func (c *Controller) reconcileTimedOut() {
+	lister, ok := c.exchange.(orderLister)
+	if !ok || len(c.timedOut) == 0 {
+		return
+	}
+
+	pending := make([]timedOutOrder, 0, len(c.timedOut))
+	for _, request := range c.timedOut {
+		found, err := c.reconcileOrder(lister, request)
+		if err != nil {
+			c.logger.WithField("pair", request.pair).Error("orderController/reconcile: ", err)
+		}
+
+		if !found && time.Since(request.requestedAt) < reconcileWindow {
+			pending = append(pending, request)
+		}
+	}
+	c.timedOut = pending
+}
+
+func (c *Controller) reconcileOrder(lister orderLister, request timedOutOrder) (bool, error) {
+	orders, err := lister.Orders(request.pair, reconcileLookback)
+	if err != nil {
+		return false, err
+	}
+
+	for i := range orders {
+		order := orders[i]
+		// only orders submitted by ninjabot are adopted, manual orders are kept out of positions
+		if order.Side != request.side || order.CreatedAt.Before(request.requestedAt.Add(-time.Minute)) ||
+			!exchange.IsClientOrderID(order.ClientOrderID) {
+			continue
+		}
+
+		stored, err := c.storage.Orders(storage.WithPair(order.Pair), storage.WithExchangeID(order.ExchangeID))
+		if err != nil {
+			return false, err
+		}
+
+		if len(stored) > 0 {
+			continue
+		}
+
+		err = c.storage.CreateOrder(&order)
+		if err != nil {
+			return false, err
+		}
+
+		c.logger.Infof("[ORDER RECONCILED] %s", order)
+		c.auditCreate(order)
+		c.processTrade(&order)
+		go c.orderFeed.Publish(order, true)
+		return true, nil
+	}
+
+	return false, nil
+}
+
+// Reconcile synchronizes the local storage with the exchange at startup, since orders may be filled or
+// canceled while the bot is offline. Positions are rebuilt from filled orders, without adding past trades
+// to the results, pending orders are updated with the exchange status and a single catch-up notification
+// summarizes the changes.
+func (c *Controller) Reconcile() error {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	filled, err := c.storage.Orders(storage.WithStatus(model.OrderStatusTypeFilled))
+	if err != nil {
+		return err
+	}
+
+	sort.SliceStable(filled, func(i, j int) bool {
+		return filled[i].UpdatedAt.Before(filled[j].UpdatedAt)
+	})
+
+	// past trades only rebuild the positions, they are not added to the results or notified again
+	for _, order := range filled {
+		c.updatePosition(order, false)
+	}
+
+	pending, err := c.storage.Orders(storage.WithStatusIn(
+		model.OrderStatusTypeNew,
+		model.OrderStatusTypePartiallyFilled,
+		model.OrderStatusTypePendingCancel,
+	))
+	if err != nil {
+		return err
+	}
+
+	// open and recently closed orders, fetched once by pair when supported by the exchange
+	exchangeOrders := make(map[int64]model.Order)
+	if lister, ok := c.exchange.(orderLister); ok {
+		fetched := make(map[string]bool)
+		for _, order := range pending {
+			if fetched[order.Pair] {
+				continue
+			}
+			fetched[order.Pair] = true
+
+			orders, err := lister.Orders(order.Pair, startupLookback)
+			if err != nil {
+				c.logger.WithField("pair", order.Pair).Error("orderController/reconcile: ", err)
+				continue
+			}
+
+			for _, excOrder := range orders {
+				exchangeOrders[excOrder.ExchangeID] = excOrder
+			}
+		}
+	}
+
+	var updatedOrders []model.Order
+	for _, order := range pending {
+		excOrder, ok := exchangeOrders[order.ExchangeID]
+		if !ok {
+			excOrder, err = c.exchange.Order(order.Pair, order.ExchangeID)
+			if err != nil {
+				c.logger.WithField("id", order.ExchangeID).Error("orderController/reconcile: ", err)
+				continue
+			}
+		}
+
+		if excOrder.Status == order.Status {
+			continue
+		}
+
+		excOrder.ID = order.ID
+		excOrder.ClientOrderID = order.ClientOrderID
+		excOrder.Strategy = order.Strategy
+		excOrder.Note = order.Note
+		err = c.storage.UpdateOrder(&excOrder)
+		if err != nil {
+			c.notifyError(err)
+			continue
+		}
+
+		c.logger.Infof("[ORDER RECONCILED] %s", excOrder)
+		c.audit(auditEventName(excOrder.Status), excOrder, nil)
+		c.processTrade(&excOrder)
+		updatedOrders = append(updatedOrders, excOrder)
+	}
+
+	for _, order := range updatedOrders {
+		c.orderFeed.Publish(order, false)
+	}
+
+	if len(updatedOrders) > 0 {
+		c.notify(catchUpMessage(updatedOrders, c.numberFormat))
+	}
+
+	return nil
+}
+
+// catchUpMessage summarizes the orders updated while the bot was offline, limited to maxCatchUpOrders
+func catchUpMessage(orders []model.Order, format model.NumberFormat) string {
+	lines := []string{fmt.Sprintf("[RECONCILED] %d orders updated while offline", len(orders))}
+	for i, order := range orders {
+		if i == maxCatchUpOrders {
+			lines = append(lines, fmt.Sprintf("... and %d more", len(orders)-maxCatchUpOrders))
+			break
+		}
+		lines = append(lines, order.Format(format))
+	}
+	return strings.Join(lines, "\n")
+}
+
 func (c *Controller) processTrade(order *model.Order) {
 	if order.Status != model.OrderStatusTypeFilled {
 		return
@@ -333,12 +889,27 @@ func (c *Controller) processTrade(order *model.Order) {
 
 	// register order volume
 	c.Results[order.Pair].Volume += order.Price * order.Quantity
+	if order.Strategy != "" {
+		c.strategySummary(order.Strategy, order.Pair).Volume += order.Price * order.Quantity
+	}
 
 	// update position size / avg price
-	c.updatePosition(order)
+	c.updatePosition(order, true)
 }
 
 func (c *Controller) updateOrders() {
+	chains, fills := c.refreshOrders()
+	for _, chain := range chains {
+		c.submitChildren(chain.parent, chain.children)
+	}
+	for _, fill := range fills {
+		c.rearmGrid(fill)
+	}
+}
+
+// refreshOrders updates the pending orders with the exchange state and returns the follow-up orders and
+// grid levels of filled orders, which are submitted after the lock is released
+func (c *Controller) refreshOrders() ([]chainedOrders, []gridFill) {
 	c.mtx.Lock()
 	defer c.mtx.Unlock()
 
@@ -350,16 +921,17 @@ func (c *Controller) updateOrders() {
 	))
 	if err != nil {
 		c.notifyError(err)
-		c.mtx.Unlock()
-		return
+		return nil, nil
 	}
 
 	// For each pending order, check for updates
 	var updatedOrders []model.Order
 	for _, order := range orders {
+		start := time.Now()
 		excOrder, err := c.exchange.Order(order.Pair, order.ExchangeID)
+		c.trackLatency(start)
 		if err != nil {
-			log.WithField("id", order.ExchangeID).Error("orderControler/get: ", err)
+			c.logger.WithField("id", order.ExchangeID).Error("orderControler/get: ", err)
 			continue
 		}
 
@@ -369,20 +941,36 @@ func (c *Controller) updateOrders() {
 		}
 
 		excOrder.ID = order.ID
+		excOrder.Strategy = order.Strategy
+		excOrder.Note = order.Note
 		err = c.storage.UpdateOrder(&excOrder)
 		if err != nil {
 			c.notifyError(err)
 			continue
 		}
 
-		log.Infof("[ORDER %s] %s", excOrder.Status, excOrder)
+		c.logger.Infof("[ORDER %s] %s", excOrder.Status, excOrder)
+		c.audit(auditEventName(excOrder.Status), excOrder, nil)
 		updatedOrders = append(updatedOrders, excOrder)
 	}
 
+	var (
+		fired []chainedOrders
+		fills []gridFill
+	)
 	for _, processOrder := range updatedOrders {
 		c.processTrade(&processOrder)
 		c.orderFeed.Publish(processOrder, false)
+		if chain, ok := c.releaseChildren(processOrder); ok {
+			fired = append(fired, chain)
+		}
+		if fill, ok := c.releaseGridOrder(processOrder); ok {
+			fills = append(fills, fill)
+		}
 	}
+
+	c.reconcileTimedOut()
+	return fired, fills
 }
 
 func (c *Controller) Status() Status {
@@ -404,7 +992,7 @@ func (c *Controller) Start() {
 				}
 			}
 		}()
-		log.Info("Bot started.")
+		c.logger.Info("Bot started.")
 	}
 }
 
@@ -413,7 +1001,7 @@ func (c *Controller) Stop() {
 		c.status = StatusStopped
 		c.updateOrders()
 		c.finish <- true
-		log.Info("Bot stopped.")
+		c.logger.Info("Bot stopped.")
 	}
 }
 
@@ -421,14 +1009,61 @@ func (c *Controller) Account() (model.Account, error) {
 	return c.exchange.Account()
 }
 
+// Trades returns the results of trades closed between start (inclusive) and end (exclusive)
+func (c *Controller) Trades(start, end time.Time) []Result {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	trades := make([]Result, 0)
+	for _, trade := range c.trades {
+		if !trade.CreatedAt.Before(start) && trade.CreatedAt.Before(end) {
+			trades = append(trades, trade)
+		}
+	}
+	return trades
+}
+
+// Position returns the asset and quote balances of the pair, the quote reserved by milestones is excluded
 func (c *Controller) Position(pair string) (asset, quote float64, err error) {
-	return c.exchange.Position(pair)
+	asset, quote, err = c.exchange.Position(pair)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	_, quoteAsset := exchange.SplitAssetQuote(pair)
+	if reserved := c.Reserved(quoteAsset); reserved > 0 {
+		quote = math.Max(quote-reserved, 0)
+	}
+	return asset, quote, nil
 }
 
 func (c *Controller) LastQuote(pair string) (float64, error) {
+	start := time.Now()
+	defer c.trackLatency(start)
 	return c.exchange.LastQuote(c.ctx, pair)
 }
 
+func (c *Controller) trackLatency(start time.Time) {
+	c.apiLatency.Store(int64(time.Since(start)))
+}
+
+// Diagnostics returns the number of open orders and the latency of the last exchange call
+func (c *Controller) Diagnostics() (Diagnostics, error) {
+	orders, err := c.storage.Orders(storage.WithStatusIn(
+		model.OrderStatusTypeNew,
+		model.OrderStatusTypePartiallyFilled,
+		model.OrderStatusTypePendingCancel,
+	))
+	if err != nil {
+		return Diagnostics{}, err
+	}
+
+	return Diagnostics{
+		OpenOrders: len(orders),
+		APILatency: time.Duration(c.apiLatency.Load()),
+	}, nil
+}
+
 func (c *Controller) PositionValue(pair string) (float64, error) {
 	asset, _, err := c.exchange.Position(pair)
 	if err != nil {
@@ -442,23 +1077,39 @@ func (c *Controller) Order(pair string, id int64) (model.Order, error) {
 }
 
 func (c *Controller) CreateOrderOCO(side model.SideType, pair string, size, price, stop,
+	stopLimit float64) ([]model.Order, error) {
+	return c.createOrderOCO(orderSource{}, side, pair, size, price, stop, stopLimit)
+}
+
+func (c *Controller) createOrderOCO(source orderSource, side model.SideType, pair string, size, price, stop,
 	stopLimit float64) ([]model.Order, error) {
 	c.mtx.Lock()
 	defer c.mtx.Unlock()
 
-	log.Infof("[ORDER] Creating OCO order for %s", pair)
+	if err := c.checkMinOrder(source, side, pair, size, size*price); err != nil {
+		return nil, err
+	}
+
+	if err := c.checkReserve(source, side, pair, size, size*price); err != nil {
+		return nil, err
+	}
+
+	c.logger.Infof("[ORDER] Creating OCO order for %s", pair)
+	requestedAt := time.Now()
 	orders, err := c.exchange.CreateOrderOCO(side, pair, size, price, stop, stopLimit)
 	if err != nil {
-		c.notifyError(err)
+		c.onOrderError(err, side, pair, size, requestedAt)
 		return nil, err
 	}
 
 	for i := range orders {
+		source.tag(&orders[i])
 		err := c.storage.CreateOrder(&orders[i])
 		if err != nil {
 			c.notifyError(err)
 			return nil, err
 		}
+		c.auditCreate(orders[i])
 		go c.orderFeed.Publish(orders[i], true)
 	}
 
@@ -466,104 +1117,490 @@ func (c *Controller) CreateOrderOCO(side model.SideType, pair string, size, pric
 }
 
 func (c *Controller) CreateOrderLimit(side model.SideType, pair string, size, limit float64) (model.Order, error) {
+	return c.createOrderLimit(orderSource{}, side, pair, size, limit)
+}
+
+func (c *Controller) createOrderLimit(source orderSource, side model.SideType, pair string, size,
+	limit float64) (model.Order, error) {
 	c.mtx.Lock()
 	defer c.mtx.Unlock()
 
-	log.Infof("[ORDER] Creating LIMIT %s order for %s", side, pair)
+	entry, err := c.checkEntryCooldown(side, pair)
+	if err != nil {
+		return model.Order{}, err
+	}
+
+	order, err := c.submitOrderLimit(source, side, pair, size, limit)
+	if err == nil && entry {
+		c.registerEntry(side, pair)
+	}
+	return order, err
+}
+
+// submitOrderLimit creates a limit order, the caller must hold the lock
+func (c *Controller) submitOrderLimit(source orderSource, side model.SideType, pair string, size,
+	limit float64) (model.Order, error) {
+	size, err := c.affordableSize(side, pair, size, limit)
+	if err != nil {
+		return model.Order{}, err
+	}
+
+	if err := c.checkMinOrder(source, side, pair, size, size*limit); err != nil {
+		return model.Order{}, err
+	}
+
+	if err := c.checkReserve(source, side, pair, size, size*limit); err != nil {
+		return model.Order{}, err
+	}
+
+	c.logger.Infof("[ORDER] Creating LIMIT %s order for %s", side, pair)
+	requestedAt := time.Now()
 	order, err := c.exchange.CreateOrderLimit(side, pair, size, limit)
 	if err != nil {
-		c.notifyError(err)
+		c.onOrderError(err, side, pair, size, requestedAt)
 		return model.Order{}, err
 	}
 
+	source.tag(&order)
 	err = c.storage.CreateOrder(&order)
 	if err != nil {
 		c.notifyError(err)
 		return model.Order{}, err
 	}
+	c.auditCreate(order)
 	go c.orderFeed.Publish(order, true)
-	log.Infof("[ORDER CREATED] %s", order)
+	c.logger.Infof("[ORDER CREATED] %s", order)
 	return order, nil
 }
 
+// CreateOrderLimitQuote creates a limit order of a quote amount, eg: 100 USDT of BTC at 25000.
+// The base quantity is the amount divided by the price, rounded down to the lot size of the pair.
+func (c *Controller) CreateOrderLimitQuote(side model.SideType, pair string, amount,
+	limit float64) (model.Order, error) {
+	return c.createOrderLimitQuote(orderSource{}, side, pair, amount, limit)
+}
+
+func (c *Controller) createOrderLimitQuote(source orderSource, side model.SideType, pair string, amount,
+	limit float64) (model.Order, error) {
+	if limit <= 0 {
+		return model.Order{}, fmt.Errorf("invalid limit price %f for %s", limit, pair)
+	}
+
+	info := c.exchange.AssetsInfo(pair)
+	quantity := exchange.AmountToLotSize(info, amount/limit)
+	if quantity <= 0 {
+		return model.Order{}, fmt.Errorf("%w: %f %s is below the lot size of %s at %f", ErrOrderBelowLotSize,
+			amount, info.QuoteAsset, pair, limit)
+	}
+	return c.createOrderLimit(source, side, pair, quantity, limit)
+}
+
+// CreateOrderLimitOffset creates a limit order at the mid-price offset by the given number of ticks,
+// below the mid for buy orders and above for sell orders. The mid-price is calculated with the best bid
+// and ask when supported by the exchange, or the last quote otherwise.
+func (c *Controller) CreateOrderLimitOffset(side model.SideType, pair string, size float64,
+	ticks int) (model.Order, error) {
+	return c.createOrderLimitOffset(orderSource{}, side, pair, size, ticks)
+}
+
+func (c *Controller) createOrderLimitOffset(source orderSource, side model.SideType, pair string, size float64,
+	ticks int) (model.Order, error) {
+
+	mid, err := c.midPrice(pair)
+	if err != nil {
+		c.notifyError(err)
+		return model.Order{}, err
+	}
+
+	tickSize := c.exchange.AssetsInfo(pair).TickSize
+	if tickSize <= 0 {
+		return model.Order{}, ErrInvalidTickSize
+	}
+
+	return c.createOrderLimit(source, side, pair, size, offsetPrice(side, mid, tickSize, ticks))
+}
+
+func (c *Controller) midPrice(pair string) (float64, error) {
+	start := time.Now()
+	defer c.trackLatency(start)
+
+	if ticker, ok := c.exchange.(bookTicker); ok {
+		bid, ask, err := ticker.BookTicker(c.ctx, pair)
+		if err != nil {
+			return 0, err
+		}
+		return (bid + ask) / 2, nil
+	}
+	return c.exchange.LastQuote(c.ctx, pair)
+}
+
+// offsetPrice rounds the mid-price to the tick size on the passive side and moves it by `ticks`
+// away from the mid: down for buy orders and up for sell orders
+func offsetPrice(side model.SideType, mid, tickSize float64, ticks int) float64 {
+	// tolerance for floating point errors of prices already on the tick grid
+	const epsilon = 1e-9
+
+	units := mid / tickSize
+	if side == model.SideTypeBuy {
+		return (math.Floor(units+epsilon) - float64(ticks)) * tickSize
+	}
+	return (math.Ceil(units-epsilon) + float64(ticks)) * tickSize
+}
+
 func (c *Controller) CreateOrderMarketQuote(side model.SideType, pair string, amount float64) (model.Order, error) {
+	return c.createOrderMarketQuote(orderSource{}, side, pair, amount)
+}
+
+func (c *Controller) createOrderMarketQuote(source orderSource, side model.SideType, pair string,
+	amount float64) (model.Order, error) {
+	limit, err := c.checkSlippage(side, pair, 0, amount, c.maxSlippage)
+	if err != nil {
+		return model.Order{}, err
+	}
+	if limit > 0 {
+		return c.createOrderLimit(source, side, pair, amount/limit, limit)
+	}
+
 	c.mtx.Lock()
 	defer c.mtx.Unlock()
 
-	log.Infof("[ORDER] Creating MARKET %s order for %s", side, pair)
+	entry, err := c.checkEntryCooldown(side, pair)
+	if err != nil {
+		return model.Order{}, err
+	}
+
+	amount, err = c.affordableQuote(side, pair, amount)
+	if err != nil {
+		return model.Order{}, err
+	}
+
+	if err := c.checkMinOrder(source, side, pair, 0, amount); err != nil {
+		return model.Order{}, err
+	}
+
+	if err := c.checkReserve(source, side, pair, 0, amount); err != nil {
+		return model.Order{}, err
+	}
+
+	c.logger.Infof("[ORDER] Creating MARKET %s order for %s", side, pair)
+	requestedAt := time.Now()
 	order, err := c.exchange.CreateOrderMarketQuote(side, pair, amount)
 	if err != nil {
-		c.notifyError(err)
+		c.onOrderError(err, side, pair, 0, requestedAt)
 		return model.Order{}, err
 	}
 
+	if entry {
+		c.registerEntry(side, pair)
+	}
+
+	source.tag(&order)
 	err = c.storage.CreateOrder(&order)
 	if err != nil {
 		c.notifyError(err)
 		return model.Order{}, err
 	}
+	c.auditCreate(order)
 
 	// calculate profit
 	c.processTrade(&order)
 	go c.orderFeed.Publish(order, true)
-	log.Infof("[ORDER CREATED] %s", order)
+	c.logger.Infof("[ORDER CREATED] %s", order)
 	return order, err
 }
 
 func (c *Controller) CreateOrderMarket(side model.SideType, pair string, size float64) (model.Order, error) {
+	return c.createOrderMarket(orderSource{}, side, pair, size, c.maxSlippage)
+}
+
+// CreateOrderMarketSlippage creates a market order with a custom price protection, eg: 0.01 for 1%,
+// overriding the default maximum slippage
+func (c *Controller) CreateOrderMarketSlippage(side model.SideType, pair string, size,
+	maxSlippage float64) (model.Order, error) {
+	return c.createOrderMarket(orderSource{}, side, pair, size, maxSlippage)
+}
+
+func (c *Controller) createOrderMarket(source orderSource, side model.SideType, pair string,
+	size, maxSlippage float64) (model.Order, error) {
+	if source.exit {
+		maxSlippage = 0
+	}
+
+	limit, err := c.checkSlippage(side, pair, size, 0, maxSlippage)
+	if err != nil {
+		return model.Order{}, err
+	}
+	if limit > 0 {
+		return c.createOrderLimit(source, side, pair, size, limit)
+	}
+
+	price, err := c.marketPrice(side, pair)
+	if err != nil {
+		c.notifyError(err)
+		return model.Order{}, err
+	}
+
 	c.mtx.Lock()
 	defer c.mtx.Unlock()
 
-	log.Infof("[ORDER] Creating MARKET %s order for %s", side, pair)
-	order, err := c.exchange.CreateOrderMarket(side, pair, size)
+	entry, err := c.checkEntryCooldown(side, pair)
 	if err != nil {
-		c.notifyError(err)
 		return model.Order{}, err
 	}
 
+	size, err = c.affordableSize(side, pair, size, price)
+	if err != nil {
+		return model.Order{}, err
+	}
+
+	if err := c.checkMinOrder(source, side, pair, size, size*price); err != nil {
+		return model.Order{}, err
+	}
+
+	if err := c.checkReserve(source, side, pair, size, size*price); err != nil {
+		return model.Order{}, err
+	}
+
+	c.logger.Infof("[ORDER] Creating MARKET %s order for %s", side, pair)
+	requestedAt := time.Now()
+	order, err := c.submitOrderMarket(source, side, pair, size)
+	if err != nil {
+		c.onOrderError(err, side, pair, size, requestedAt)
+		return model.Order{}, err
+	}
+
+	if entry {
+		c.registerEntry(side, pair)
+	}
+
+	source.tag(&order)
 	err = c.storage.CreateOrder(&order)
 	if err != nil {
 		c.notifyError(err)
 		return model.Order{}, err
 	}
+	c.auditCreate(order)
 
 	// calculate profit
 	c.processTrade(&order)
 	go c.orderFeed.Publish(order, true)
-	log.Infof("[ORDER CREATED] %s", order)
+	c.logger.Infof("[ORDER CREATED] %s", order)
 	return order, err
 }
 
+// submitOrderMarket sends the market order, exits of touched virtual orders are filled at the trigger price by
+// simulated exchanges
+func (c *Controller) submitOrderMarket(source orderSource, side model.SideType, pair string,
+	size float64) (model.Order, error) {
+	if filler, ok := c.exchange.(touchFiller); ok && source.trigger > 0 {
+		return filler.CreateOrderMarketAt(side, pair, size, source.trigger)
+	}
+	return c.exchange.CreateOrderMarket(side, pair, size)
+}
+
 func (c *Controller) CreateOrderStop(pair string, size float64, limit float64) (model.Order, error) {
+	return c.createOrderStop(orderSource{}, pair, size, limit)
+}
+
+func (c *Controller) createOrderStop(source orderSource, pair string, size float64, limit float64) (model.Order, error) {
 	c.mtx.Lock()
 	defer c.mtx.Unlock()
 
-	log.Infof("[ORDER] Creating STOP order for %s", pair)
+	if err := c.checkMinOrder(source, model.SideTypeSell, pair, size, size*limit); err != nil {
+		return model.Order{}, err
+	}
+
+	c.logger.Infof("[ORDER] Creating STOP order for %s", pair)
+	requestedAt := time.Now()
 	order, err := c.exchange.CreateOrderStop(pair, size, limit)
 	if err != nil {
-		c.notifyError(err)
+		c.onOrderError(err, model.SideTypeSell, pair, size, requestedAt)
 		return model.Order{}, err
 	}
 
+	source.tag(&order)
 	err = c.storage.CreateOrder(&order)
 	if err != nil {
 		c.notifyError(err)
 		return model.Order{}, err
 	}
+	c.auditCreate(order)
 	go c.orderFeed.Publish(order, true)
-	log.Infof("[ORDER CREATED] %s", order)
+	c.logger.Infof("[ORDER CREATED] %s", order)
 	return order, nil
 }
 
+// CreateOrderMarketIfTouched creates a virtual order that sends a market order when the price touches
+// the trigger. Unlike stop orders, a buy is triggered when price falls to the trigger and a sell when
+// price rises to the trigger, so the trigger must be below (buy) or above (sell) the current price.
+// Virtual orders are checked on complete candles, the paper wallet fills touched orders at the trigger price.
+func (c *Controller) CreateOrderMarketIfTouched(side model.SideType, pair string,
+	quantity, triggerPrice float64) (model.Order, error) {
+	return c.createOrderMarketIfTouched(orderSource{}, side, pair, quantity, triggerPrice)
+}
+
+func (c *Controller) createOrderMarketIfTouched(source orderSource, side model.SideType, pair string,
+	quantity, triggerPrice float64) (model.Order, error) {
+
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	if quantity <= 0 {
+		return model.Order{}, exchange.ErrInvalidQuantity
+	}
+
+	price, ok := c.lastPrice[pair]
+	if !ok {
+		var err error
+		price, err = c.exchange.LastQuote(c.ctx, pair)
+		if err != nil {
+			c.notifyError(err)
+			return model.Order{}, err
+		}
+	}
+
+	if side == model.SideTypeBuy && triggerPrice >= price {
+		return model.Order{}, fmt.Errorf("%w: buy trigger %f must be below price %f",
+			ErrInvalidTrigger, triggerPrice, price)
+	}
+
+	if side == model.SideTypeSell && triggerPrice <= price {
+		return model.Order{}, fmt.Errorf("%w: sell trigger %f must be above price %f",
+			ErrInvalidTrigger, triggerPrice, price)
+	}
+
+	c.touchSeq++
+	order := model.Order{
+		ClientOrderID: fmt.Sprintf("mit-%d", c.touchSeq),
+		Pair:          pair,
+		Side:          side,
+		Type:          model.OrderTypeMarketIfTouched,
+		Status:        model.OrderStatusTypeNew,
+		Price:         triggerPrice,
+		Quantity:      quantity,
+		RefPrice:      price,
+	}
+	source.tag(&order)
+	c.touchOrders = append(c.touchOrders, order)
+	c.logger.Infof("[ORDER CREATED] %s", order)
+	return order, nil
+}
+
+// TouchOrders returns the pending market-if-touched orders
+func (c *Controller) TouchOrders() []model.Order {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	return append([]model.Order(nil), c.touchOrders...)
+}
+
+// CreateOrderTrailingTakeProfit creates a virtual order that sells the quantity to lock profit of a long position.
+// Once the price reaches the activation, the order trails below the peak price by the given fraction
+// (eg: 0.02 for 2%) and sends a market order when the price retraces to that level. The order is never
+// triggered below the entry price, which is the position average price or the current price.
+func (c *Controller) CreateOrderTrailingTakeProfit(pair string, quantity, activationPrice,
+	trail float64) (model.Order, error) {
+	return c.createOrderTrailingTakeProfit(orderSource{}, pair, quantity, activationPrice, trail)
+}
+
+func (c *Controller) createOrderTrailingTakeProfit(source orderSource, pair string, quantity, activationPrice,
+	trail float64) (model.Order, error) {
+
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	if quantity <= 0 {
+		return model.Order{}, exchange.ErrInvalidQuantity
+	}
+
+	if trail <= 0 || trail >= 1 {
+		return model.Order{}, fmt.Errorf("invalid trail %f, it must be between 0 and 1", trail)
+	}
+
+	entry, ok := c.lastPrice[pair]
+	if !ok {
+		var err error
+		entry, err = c.exchange.LastQuote(c.ctx, pair)
+		if err != nil {
+			c.notifyError(err)
+			return model.Order{}, err
+		}
+	}
+
+	if position, ok := c.position[pair]; ok && position.Side == model.SideTypeBuy && position.Quantity > 0 {
+		entry = position.AvgPrice
+	}
+
+	if activationPrice <= entry {
+		return model.Order{}, fmt.Errorf("%w: activation %f must be above entry %f",
+			ErrInvalidTrigger, activationPrice, entry)
+	}
+
+	c.touchSeq++
+	trailing := &trailingTakeProfit{
+		order: model.Order{
+			ClientOrderID: fmt.Sprintf("ttp-%d", c.touchSeq),
+			Pair:          pair,
+			Side:          model.SideTypeSell,
+			Type:          model.OrderTypeTrailingTakeProfit,
+			Status:        model.OrderStatusTypeNew,
+			Price:         activationPrice,
+			Quantity:      quantity,
+			RefPrice:      entry,
+		},
+		entry:      entry,
+		activation: activationPrice,
+		trail:      trail,
+	}
+	source.tag(&trailing.order)
+	c.trailingOrders = append(c.trailingOrders, trailing)
+	c.logger.Infof("[ORDER CREATED] %s", trailing.order)
+	return trailing.order, nil
+}
+
+// TrailingOrders returns the pending trailing take-profit orders, with the current trailing level as stop
+func (c *Controller) TrailingOrders() []model.Order {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	orders := make([]model.Order, 0, len(c.trailingOrders))
+	for _, trailing := range c.trailingOrders {
+		orders = append(orders, trailing.order)
+	}
+	return orders
+}
+
 func (c *Controller) Cancel(order model.Order) error {
 	c.mtx.Lock()
 	defer c.mtx.Unlock()
 
-	log.Infof("[ORDER] Cancelling order for %s", order.Pair)
+	if order.Type == model.OrderTypeTrailingTakeProfit {
+		for i, trailing := range c.trailingOrders {
+			if trailing.order.ClientOrderID == order.ClientOrderID {
+				c.trailingOrders = append(c.trailingOrders[:i], c.trailingOrders[i+1:]...)
+				c.logger.Infof("[ORDER CANCELED] %s", order)
+				return nil
+			}
+		}
+		return fmt.Errorf("order %s not found", order.ClientOrderID)
+	}
+
+	if order.Type == model.OrderTypeMarketIfTouched {
+		for i, touch := range c.touchOrders {
+			if touch.ClientOrderID == order.ClientOrderID {
+				c.touchOrders = append(c.touchOrders[:i], c.touchOrders[i+1:]...)
+				c.logger.Infof("[ORDER CANCELED] %s", order)
+				return nil
+			}
+		}
+		return fmt.Errorf("order %s not found", order.ClientOrderID)
+	}
+
+	c.logger.Infof("[ORDER] Cancelling order for %s", order.Pair)
 	err := c.exchange.Cancel(order)
 	if err != nil {
 		return err
 	}
+	delete(c.children, order.ExchangeID)
 
 	order.Status = model.OrderStatusTypePendingCancel
 	err = c.storage.UpdateOrder(&order)
@@ -571,6 +1608,7 @@ func (c *Controller) Cancel(order model.Order) error {
 		c.notifyError(err)
 		return err
 	}
-	log.Infof("[ORDER CANCELED] %s", order)
+	c.audit(auditEventName(order.Status), order, nil)
+	c.logger.Infof("[ORDER CANCELED] %s", order)
 	return nil
 }
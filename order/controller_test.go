@@ -2,6 +2,9 @@ package order
 
 import (
 	"context"
+	"errors"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -10,9 +13,31 @@ import (
 
 	"github.com/rodrigo-brito/ninjabot/exchange"
 	"github.com/rodrigo-brito/ninjabot/model"
+	"github.com/rodrigo-brito/ninjabot/service"
 	"github.com/rodrigo-brito/ninjabot/storage"
 )
 
+func TestController_OpeningFills(t *testing.T) {
+	db, err := storage.FromMemory()
+	require.NoError(t, err)
+	ctx := context.Background()
+	wallet := exchange.NewPaperWallet(ctx, "USDT", exchange.WithPaperAsset("USDT", 3000))
+	controller := NewController(ctx, wallet, db, NewOrderFeed())
+	wallet.OnCandle(model.Candle{Pair: "BTCUSDT", Close: 1000})
+
+	order, err := controller.CreateOrderMarket(model.SideTypeBuy, "BTCUSDT", 1)
+	require.NoError(t, err)
+	require.True(t, order.Opening)
+
+	order, err = controller.CreateOrderMarket(model.SideTypeBuy, "BTCUSDT", 1)
+	require.NoError(t, err)
+	require.False(t, order.Opening)
+
+	order, err = controller.CreateOrderMarket(model.SideTypeSell, "BTCUSDT", 2)
+	require.NoError(t, err)
+	require.False(t, order.Opening)
+}
+
 func TestController_updatePosition(t *testing.T) {
 	t.Run("market orders", func(t *testing.T) {
 		storage, err := storage.FromMemory()
@@ -161,6 +186,28 @@ func TestController_updatePosition(t *testing.T) {
 		require.Equal(t, -0.5, controller.Results["BTCUSDT"].LoseLongPercent[0])
 	})
 
+	t.Run("market orders with fees", func(t *testing.T) {
+		storage, err := storage.FromMemory()
+		require.NoError(t, err)
+		ctx := context.Background()
+		wallet := exchange.NewPaperWallet(ctx, "USDT", exchange.WithPaperAsset("USDT", 3000),
+			exchange.WithPaperFee(0.001, 0.001))
+		controller := NewController(ctx, wallet, storage, NewOrderFeed())
+
+		wallet.OnCandle(model.Candle{Pair: "BTCUSDT", Close: 1000})
+		_, err = controller.CreateOrderMarket(model.SideTypeBuy, "BTCUSDT", 1)
+		require.NoError(t, err)
+		require.Equal(t, 1.0, controller.position["BTCUSDT"].Fees)
+
+		// entry and exit fees are discounted from profit
+		wallet.OnCandle(model.Candle{Pair: "BTCUSDT", Close: 2000})
+		order, err := controller.CreateOrderMarket(model.SideTypeSell, "BTCUSDT", 1)
+		require.NoError(t, err)
+		require.Nil(t, controller.position["BTCUSDT"])
+		require.InDelta(t, 997.0, order.ProfitValue, 1e-9)
+		require.InDelta(t, 0.997, order.Profit, 1e-9)
+	})
+
 	t.Run("short market", func(t *testing.T) {
 		storage, err := storage.FromMemory()
 		require.NoError(t, err)
@@ -222,3 +269,716 @@ func TestController_Position(t *testing.T) {
 	assert.Equal(t, 1.0, asset)
 	assert.Equal(t, 1500.0, quote)
 }
+
+func TestController_Diagnostics(t *testing.T) {
+	storage, err := storage.FromMemory()
+	require.NoError(t, err)
+	ctx := context.Background()
+	wallet := exchange.NewPaperWallet(ctx, "USDT", exchange.WithPaperAsset("USDT", 3000))
+	controller := NewController(ctx, wallet, storage, NewOrderFeed())
+	wallet.OnCandle(model.Candle{Time: time.Now(), Pair: "BTCUSDT", Close: 1500})
+
+	_, err = controller.CreateOrderLimit(model.SideTypeBuy, "BTCUSDT", 1, 1000)
+	require.NoError(t, err)
+	_, err = controller.CreateOrderMarket(model.SideTypeBuy, "BTCUSDT", 1)
+	require.NoError(t, err)
+
+	controller.updateOrders()
+
+	diagnostics, err := controller.Diagnostics()
+	require.NoError(t, err)
+	require.Equal(t, 1, diagnostics.OpenOrders)
+	require.Greater(t, diagnostics.APILatency, time.Duration(0))
+}
+
+// slowExchange accepts market orders, but the response is lost due a timeout
+type slowExchange struct {
+	*exchange.PaperWallet
+	mtx    sync.Mutex
+	orders []model.Order
+}
+
+func (s *slowExchange) CreateOrderMarket(side model.SideType, pair string, size float64) (model.Order, error) {
+	order, err := s.PaperWallet.CreateOrderMarket(side, pair, size)
+	if err != nil {
+		return model.Order{}, err
+	}
+
+	s.mtx.Lock()
+	s.orders = append(s.orders, order)
+	s.mtx.Unlock()
+	return model.Order{}, &exchange.OrderError{Err: exchange.ErrOrderTimeout, Pair: pair, Quantity: size}
+}
+
+func (s *slowExchange) Orders(_ string, _ int) ([]model.Order, error) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	return s.orders, nil
+}
+
+func TestController_OrderTimeout(t *testing.T) {
+	storage, err := storage.FromMemory()
+	require.NoError(t, err)
+	ctx := context.Background()
+	wallet := exchange.NewPaperWallet(ctx, "USDT", exchange.WithPaperAsset("USDT", 3000))
+	slow := &slowExchange{PaperWallet: wallet}
+	controller := NewController(ctx, slow, storage, NewOrderFeed())
+	wallet.OnCandle(model.Candle{Time: time.Now(), Pair: "BTCUSDT", Close: 1000})
+
+	// manual order placed in the exchange account is not adopted
+	slow.orders = append(slow.orders, model.Order{ExchangeID: 42, ClientOrderID: "web_manual", Pair: "BTCUSDT",
+		Side: model.SideTypeBuy, Type: model.OrderTypeMarket, Status: model.OrderStatusTypeFilled, Price: 1000,
+		Quantity: 2, CreatedAt: time.Now(), UpdatedAt: time.Now()})
+
+	_, err = controller.CreateOrderMarket(model.SideTypeBuy, "BTCUSDT", 1)
+	require.ErrorIs(t, err, exchange.ErrOrderTimeout)
+	require.Nil(t, controller.position["BTCUSDT"])
+	require.Len(t, controller.timedOut, 1)
+
+	// order filled by the exchange is registered in the next update
+	controller.updateOrders()
+	require.Empty(t, controller.timedOut)
+	require.NotNil(t, controller.position["BTCUSDT"])
+	require.Equal(t, 1.0, controller.position["BTCUSDT"].Quantity)
+
+	orders, err := storage.Orders()
+	require.NoError(t, err)
+	require.Len(t, orders, 1)
+
+	// reconciliation does not duplicate orders
+	controller.timedOut = append(controller.timedOut, timedOutOrder{
+		pair: "BTCUSDT", side: model.SideTypeBuy, requestedAt: time.Now(),
+	})
+	controller.updateOrders()
+	orders, err = storage.Orders()
+	require.NoError(t, err)
+	require.Len(t, orders, 1)
+}
+
+func TestController_CreateOrderMarketIfTouched(t *testing.T) {
+	storage, err := storage.FromMemory()
+	require.NoError(t, err)
+	ctx := context.Background()
+	wallet := exchange.NewPaperWallet(ctx, "USDT", exchange.WithPaperAsset("USDT", 1000))
+	controller := NewController(ctx, wallet, storage, NewOrderFeed())
+
+	onCandle := func(low, close, high float64) {
+		candle := model.Candle{Time: time.Now(), Pair: "BTCUSDT", Low: low, Close: close, High: high, Complete: true}
+		wallet.OnCandle(candle)
+		controller.OnCandle(candle)
+	}
+	onCandle(100, 100, 100)
+
+	// trigger must be below price for buy and above price for sell
+	_, err = controller.CreateOrderMarketIfTouched(model.SideTypeBuy, "BTCUSDT", 1, 110)
+	require.ErrorIs(t, err, ErrInvalidTrigger)
+	_, err = controller.CreateOrderMarketIfTouched(model.SideTypeSell, "BTCUSDT", 1, 90)
+	require.ErrorIs(t, err, ErrInvalidTrigger)
+
+	buy, err := controller.CreateOrderMarketIfTouched(model.SideTypeBuy, "BTCUSDT", 2, 90)
+	require.NoError(t, err)
+	require.Equal(t, model.OrderTypeMarketIfTouched, buy.Type)
+
+	cancel, err := controller.CreateOrderMarketIfTouched(model.SideTypeBuy, "BTCUSDT", 1, 80)
+	require.NoError(t, err)
+	require.NoError(t, controller.Cancel(cancel))
+	require.Len(t, controller.TouchOrders(), 1)
+
+	// price approaches, but does not touch the trigger
+	onCandle(91, 95, 96)
+	asset, _, err := wallet.Position("BTCUSDT")
+	require.NoError(t, err)
+	require.Equal(t, 0.0, asset)
+
+	// price touches the trigger, the order is filled at the trigger price
+	onCandle(89, 92, 95)
+	asset, quote, err := wallet.Position("BTCUSDT")
+	require.NoError(t, err)
+	require.Equal(t, 2.0, asset)
+	require.Equal(t, 820.0, quote)
+	require.Empty(t, controller.TouchOrders())
+
+	// sell is triggered when price rises to the trigger
+	_, err = controller.CreateOrderMarketIfTouched(model.SideTypeSell, "BTCUSDT", 2, 100)
+	require.NoError(t, err)
+	onCandle(90, 98, 101)
+	asset, quote, err = wallet.Position("BTCUSDT")
+	require.NoError(t, err)
+	require.Equal(t, 0.0, asset)
+	require.Equal(t, 1020.0, quote)
+
+	orders, err := storage.Orders()
+	require.NoError(t, err)
+	require.Len(t, orders, 2)
+}
+
+func TestController_CreateOrderTrailingTakeProfit(t *testing.T) {
+	storage, err := storage.FromMemory()
+	require.NoError(t, err)
+	ctx := context.Background()
+	wallet := exchange.NewPaperWallet(ctx, "USDT", exchange.WithPaperAsset("USDT", 1000))
+	controller := NewController(ctx, wallet, storage, NewOrderFeed())
+
+	onCandle := func(low, close, high float64) {
+		candle := model.Candle{Time: time.Now(), Pair: "BTCUSDT", Low: low, Close: close, High: high, Complete: true}
+		wallet.OnCandle(candle)
+		controller.OnCandle(candle)
+	}
+	onCandle(100, 100, 100)
+
+	_, err = controller.CreateOrderMarket(model.SideTypeBuy, "BTCUSDT", 1)
+	require.NoError(t, err)
+
+	// activation must be above entry
+	_, err = controller.CreateOrderTrailingTakeProfit("BTCUSDT", 1, 95, 0.05)
+	require.ErrorIs(t, err, ErrInvalidTrigger)
+
+	order, err := controller.CreateOrderTrailingTakeProfit("BTCUSDT", 1, 110, 0.05)
+	require.NoError(t, err)
+	require.Equal(t, model.OrderTypeTrailingTakeProfit, order.Type)
+	require.Equal(t, 100.0, order.RefPrice)
+
+	// price retraces before activation, order is not triggered
+	onCandle(95, 96, 108)
+	onCandle(90, 92, 97)
+	require.Len(t, controller.TrailingOrders(), 1)
+
+	// price reaches the activation and trails the peak
+	onCandle(105, 115, 118)
+	onCandle(117, 119, 120)
+	orders := controller.TrailingOrders()
+	require.Len(t, orders, 1)
+	require.Equal(t, 114.0, *orders[0].Stop)
+
+	asset, _, err := wallet.Position("BTCUSDT")
+	require.NoError(t, err)
+	require.Equal(t, 1.0, asset)
+
+	// price retraces below trailing level, position is closed above entry
+	onCandle(112, 113, 119)
+	require.Empty(t, controller.TrailingOrders())
+
+	asset, quote, err := wallet.Position("BTCUSDT")
+	require.NoError(t, err)
+	require.Equal(t, 0.0, asset)
+	require.Equal(t, 1013.0, quote)
+}
+
+func TestController_TrailingTakeProfitNeverBelowEntry(t *testing.T) {
+	storage, err := storage.FromMemory()
+	require.NoError(t, err)
+	ctx := context.Background()
+	wallet := exchange.NewPaperWallet(ctx, "USDT", exchange.WithPaperAsset("USDT", 1000))
+	controller := NewController(ctx, wallet, storage, NewOrderFeed())
+
+	onCandle := func(low, close, high float64) {
+		candle := model.Candle{Time: time.Now(), Pair: "BTCUSDT", Low: low, Close: close, High: high, Complete: true}
+		wallet.OnCandle(candle)
+		controller.OnCandle(candle)
+	}
+	onCandle(100, 100, 100)
+
+	_, err = controller.CreateOrderMarket(model.SideTypeBuy, "BTCUSDT", 1)
+	require.NoError(t, err)
+
+	// wide trail, the trailing level is limited by the entry
+	_, err = controller.CreateOrderTrailingTakeProfit("BTCUSDT", 1, 105, 0.5)
+	require.NoError(t, err)
+
+	onCandle(100, 106, 106)
+	require.Equal(t, 100.0, *controller.TrailingOrders()[0].Stop)
+
+	// price gaps below entry, the loss is not realized
+	onCandle(90, 95, 101)
+	require.Len(t, controller.TrailingOrders(), 1)
+
+	asset, _, err := wallet.Position("BTCUSDT")
+	require.NoError(t, err)
+	require.Equal(t, 1.0, asset)
+}
+
+// offlineExchange returns the state of orders updated while the bot was offline
+type offlineExchange struct {
+	service.Exchange
+	listed map[string][]model.Order
+	orders map[int64]model.Order
+}
+
+func (e offlineExchange) Orders(pair string, _ int) ([]model.Order, error) {
+	return e.listed[pair], nil
+}
+
+func (e offlineExchange) Order(_ string, id int64) (model.Order, error) {
+	return e.orders[id], nil
+}
+
+type messageNotifier struct {
+	messages []string
+}
+
+func (n *messageNotifier) Notify(message string) {
+	n.messages = append(n.messages, message)
+}
+
+func (n *messageNotifier) OnOrder(model.Order) {}
+
+func (n *messageNotifier) OnError(error) {}
+
+// unavailableStorage fails to list orders
+type unavailableStorage struct {
+	storage.Storage
+}
+
+func (unavailableStorage) Orders(...storage.OrderFilter) ([]*model.Order, error) {
+	return nil, errors.New("database is locked")
+}
+
+func TestController_updateOrdersStorageError(t *testing.T) {
+	db, err := storage.FromMemory()
+	require.NoError(t, err)
+	ctx := context.Background()
+	wallet := exchange.NewPaperWallet(ctx, "USDT", exchange.WithPaperAsset("USDT", 1000))
+	controller := NewController(ctx, wallet, unavailableStorage{db}, NewOrderFeed())
+
+	// the lock is released once, the controller keeps working after the error
+	require.NotPanics(t, controller.updateOrders)
+	require.NotPanics(t, controller.updateOrders)
+	wallet.OnCandle(model.Candle{Pair: "BTCUSDT", Close: 100})
+	_, err = controller.CreateOrderMarket(model.SideTypeBuy, "BTCUSDT", 1)
+	require.NoError(t, err)
+}
+
+func TestController_Reconcile(t *testing.T) {
+	db, err := storage.FromMemory()
+	require.NoError(t, err)
+
+	start := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	local := []model.Order{
+		{ExchangeID: 1, Pair: "BTCUSDT", Side: model.SideTypeBuy, Type: model.OrderTypeMarket,
+			Status: model.OrderStatusTypeFilled, Price: 100, Quantity: 2, UpdatedAt: start},
+		{ExchangeID: 2, Pair: "BTCUSDT", Side: model.SideTypeSell, Type: model.OrderTypeLimit,
+			Status: model.OrderStatusTypeNew, Price: 120, Quantity: 1, UpdatedAt: start.Add(time.Hour)},
+		{ExchangeID: 3, Pair: "ETHUSDT", Side: model.SideTypeBuy, Type: model.OrderTypeLimit,
+			Status: model.OrderStatusTypeNew, Price: 10, Quantity: 1, UpdatedAt: start.Add(time.Hour)},
+		{ExchangeID: 4, Pair: "ETHUSDT", Side: model.SideTypeBuy, Type: model.OrderTypeLimit,
+			Status: model.OrderStatusTypeNew, Price: 9, Quantity: 1, UpdatedAt: start.Add(time.Hour)},
+		// trade closed in a previous session
+		{ExchangeID: 5, Pair: "BNBUSDT", Side: model.SideTypeBuy, Type: model.OrderTypeMarket,
+			Status: model.OrderStatusTypeFilled, Price: 10, Quantity: 1, UpdatedAt: start},
+		{ExchangeID: 6, Pair: "BNBUSDT", Side: model.SideTypeSell, Type: model.OrderTypeMarket,
+			Status: model.OrderStatusTypeFilled, Price: 12, Quantity: 1, UpdatedAt: start.Add(time.Hour)},
+	}
+	for i := range local {
+		require.NoError(t, db.CreateOrder(&local[i]))
+	}
+
+	exch := offlineExchange{
+		// limit sell of BTC filled while offline, listed in recent orders
+		listed: map[string][]model.Order{
+			"BTCUSDT": {withStatus(local[1], model.OrderStatusTypeFilled)},
+		},
+		// ETH orders are not listed, but available by id
+		orders: map[int64]model.Order{
+			3: withStatus(local[2], model.OrderStatusTypeCanceled),
+			4: local[3],
+		},
+	}
+
+	notifier := &messageNotifier{}
+	controller := NewController(context.Background(), exch, db, NewOrderFeed())
+	controller.SetNotifier(notifier)
+	require.NoError(t, controller.Reconcile())
+
+	orders, err := db.Orders()
+	require.NoError(t, err)
+	require.Len(t, orders, 6)
+	status := make(map[int64]model.OrderStatusType)
+	for _, order := range orders {
+		status[order.ExchangeID] = order.Status
+	}
+	require.Equal(t, model.OrderStatusTypeFilled, status[2])
+	require.Equal(t, model.OrderStatusTypeCanceled, status[3])
+	require.Equal(t, model.OrderStatusTypeNew, status[4])
+
+	// position is rebuilt from the filled buy and reduced by the reconciled sell
+	require.Equal(t, 1.0, controller.position["BTCUSDT"].Quantity)
+	require.Equal(t, 100.0, controller.position["BTCUSDT"].AvgPrice)
+
+	// past trades only rebuild positions, the reconciled trade is the only result
+	require.NotContains(t, controller.position, "BNBUSDT")
+	require.NotContains(t, controller.Results, "BNBUSDT")
+	require.Len(t, controller.trades, 1)
+	require.Equal(t, "BTCUSDT", controller.trades[0].Pair)
+
+	// profit of the reconciled trade and a single catch-up message
+	require.Len(t, notifier.messages, 2)
+	require.Contains(t, notifier.messages[0], "[PROFIT]")
+	require.Contains(t, notifier.messages[1], "[RECONCILED] 2 orders updated while offline")
+}
+
+func TestController_TradesRetention(t *testing.T) {
+	controller := NewController(context.Background(), nil, nil, NewOrderFeed())
+
+	start := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	for day := 0; day < 10; day++ {
+		controller.recordTrade(Result{Pair: "BTCUSDT", CreatedAt: start.AddDate(0, 0, day)})
+	}
+
+	// trades older than the retention of the last trade are dropped
+	require.Equal(t, 10, controller.closedTrades)
+	require.Len(t, controller.trades, 8)
+	require.Equal(t, start.AddDate(0, 0, 2), controller.trades[0].CreatedAt)
+	require.Len(t, controller.Trades(start, start.AddDate(0, 0, 10)), 8)
+}
+
+func withStatus(order model.Order, status model.OrderStatusType) model.Order {
+	order.ID = 0
+	order.Status = status
+	return order
+}
+
+func TestCatchUpMessage(t *testing.T) {
+	orders := make([]model.Order, maxCatchUpOrders+3)
+	message := catchUpMessage(orders, model.NumberFormatPlain)
+	require.Contains(t, message, "[RECONCILED] 13 orders updated while offline")
+	require.Contains(t, message, "... and 3 more")
+	require.Len(t, strings.Split(message, "\n"), maxCatchUpOrders+2)
+}
+
+func TestController_MinOrderQuote(t *testing.T) {
+	db, err := storage.FromMemory()
+	require.NoError(t, err)
+	ctx := context.Background()
+	wallet := exchange.NewPaperWallet(ctx, "USDT", exchange.WithPaperAsset("USDT", 1000))
+	controller := NewController(ctx, wallet, db, NewOrderFeed())
+	controller.SetMinOrderQuote(map[string]float64{"BTCUSDT": 20})
+
+	notifier := &messageNotifier{}
+	controller.SetNotifier(notifier)
+
+	candle := model.Candle{Time: time.Now(), Pair: "BTCUSDT", Close: 100, Low: 100, High: 100, Complete: true}
+	wallet.OnCandle(candle)
+	controller.OnCandle(candle)
+
+	// accepted by the exchange, but below the user minimum
+	_, err = controller.CreateOrderMarket(model.SideTypeBuy, "BTCUSDT", 0.1)
+	require.ErrorIs(t, err, ErrOrderBelowMinimum)
+	require.EqualError(t, err, "order value below the minimum: BUY BTCUSDT of 10.00 is below the minimum of 20.00")
+
+	_, err = controller.CreateOrderMarketQuote(model.SideTypeBuy, "BTCUSDT", 15)
+	require.ErrorIs(t, err, ErrOrderBelowMinimum)
+
+	_, err = controller.CreateOrderLimit(model.SideTypeBuy, "BTCUSDT", 0.3, 50)
+	require.ErrorIs(t, err, ErrOrderBelowMinimum)
+
+	orders, err := db.Orders()
+	require.NoError(t, err)
+	require.Empty(t, orders)
+
+	// orders above the minimum and pairs without minimum are not affected
+	_, err = controller.CreateOrderMarket(model.SideTypeBuy, "BTCUSDT", 0.2)
+	require.NoError(t, err)
+	_, err = controller.CreateOrderLimit(model.SideTypeBuy, "BTCUSDT", 0.5, 50)
+	require.NoError(t, err)
+
+	// positions below the minimum can be reduced and flattened
+	_, err = controller.CreateOrderMarket(model.SideTypeSell, "BTCUSDT", 0.15)
+	require.NoError(t, err)
+	require.InDelta(t, 0.05, controller.position["BTCUSDT"].Quantity, 1e-9)
+
+	flattened, err := controller.Flatten()
+	require.NoError(t, err)
+	require.Len(t, flattened.Trades, 1)
+	require.Nil(t, controller.position["BTCUSDT"])
+}
+
+func TestController_AutoReduce(t *testing.T) {
+	db, err := storage.FromMemory()
+	require.NoError(t, err)
+	ctx := context.Background()
+	wallet := exchange.NewPaperWallet(ctx, "USDT", exchange.WithPaperAsset("USDT", 1000))
+	controller := NewController(ctx, wallet, db, NewOrderFeed())
+	controller.SetMinOrderQuote(map[string]float64{"BTCUSDT": 20})
+
+	candle := model.Candle{Time: time.Now(), Pair: "BTCUSDT", Close: 100, Low: 100, High: 100, Complete: true}
+	wallet.OnCandle(candle)
+	controller.OnCandle(candle)
+
+	// rejected without the setting
+	_, err = controller.CreateOrderMarket(model.SideTypeBuy, "BTCUSDT", 20)
+	require.Error(t, err)
+
+	controller.SetAutoReduce(true)
+	order, err := controller.CreateOrderLimit(model.SideTypeBuy, "BTCUSDT", 20, 80)
+	require.NoError(t, err)
+	require.InDelta(t, 12.4375, order.Quantity, 1e-9)
+	require.NoError(t, controller.Cancel(order))
+
+	order, err = controller.CreateOrderMarket(model.SideTypeBuy, "BTCUSDT", 20)
+	require.NoError(t, err)
+	require.InDelta(t, 9.95, order.Quantity, 1e-6)
+
+	// free quote below the minimum
+	_, err = controller.CreateOrderMarket(model.SideTypeBuy, "BTCUSDT", 1)
+	require.ErrorIs(t, err, ErrOrderBelowMinimum)
+	_, err = controller.CreateOrderMarketQuote(model.SideTypeBuy, "BTCUSDT", 100)
+	require.ErrorIs(t, err, ErrOrderBelowMinimum)
+
+	t.Run("with fees", func(t *testing.T) {
+		db, err := storage.FromMemory()
+		require.NoError(t, err)
+		wallet := exchange.NewPaperWallet(ctx, "USDT", exchange.WithPaperAsset("USDT", 1000),
+			exchange.WithPaperFee(0.001, 0.001))
+		controller := NewController(ctx, wallet, db, NewOrderFeed())
+		controller.SetAutoReduce(true)
+		wallet.OnCandle(candle)
+		controller.OnCandle(candle)
+
+		// the fee of the reduced order is paid with the asset bought
+		order, err := controller.CreateOrderMarket(model.SideTypeBuy, "BTCUSDT", 20)
+		require.NoError(t, err)
+		require.InDelta(t, 9.95, order.Quantity, 1e-6)
+
+		account, err := wallet.Account()
+		require.NoError(t, err)
+		asset, quote := account.Balance("BTC", "USDT")
+		require.InDelta(t, 1000-995, quote.Free, 1e-5)
+		require.InDelta(t, 9.95*0.999, asset.Free, 1e-5)
+
+		order, err = controller.CreateOrderMarketQuote(model.SideTypeBuy, "BTCUSDT", 100)
+		require.NoError(t, err)
+		require.Greater(t, order.Quantity, 0.0)
+	})
+}
+
+func TestController_PairFees(t *testing.T) {
+	db, err := storage.FromMemory()
+	require.NoError(t, err)
+	ctx := context.Background()
+	wallet := exchange.NewPaperWallet(ctx, "USDT",
+		exchange.WithPaperAsset("USDT", 10000),
+		exchange.WithPaperFee(0.001, 0.001),
+		exchange.WithPaperPairFee("ETHUSDT", 0, 0.0005),
+	)
+	controller := NewController(ctx, wallet, db, NewOrderFeed())
+
+	for _, pair := range []string{"BTCUSDT", "ETHUSDT"} {
+		candle := model.Candle{Time: time.Now(), Pair: pair, Close: 100, Low: 100, High: 100, Complete: true}
+		wallet.OnCandle(candle)
+		controller.OnCandle(candle)
+		_, err = controller.CreateOrderMarket(model.SideTypeBuy, pair, 10)
+		require.NoError(t, err)
+
+		candle.Close, candle.Low, candle.High = 110, 110, 110
+		wallet.OnCandle(candle)
+		controller.OnCandle(candle)
+		_, err = controller.CreateOrderMarket(model.SideTypeSell, pair, 10)
+		require.NoError(t, err)
+	}
+
+	// profit of 100 minus the fees of 1000 in and 1100 out
+	require.InDelta(t, 100-2.1, controller.Results["BTCUSDT"].Profit(), 1e-9)
+	require.InDelta(t, 100-1.05, controller.Results["ETHUSDT"].Profit(), 1e-9)
+
+	quote, _ := wallet.Fees()
+	require.InDelta(t, 2.1+1.05, quote, 1e-9)
+}
+
+func TestController_AllInFees(t *testing.T) {
+	db, err := storage.FromMemory()
+	require.NoError(t, err)
+	ctx := context.Background()
+	wallet := exchange.NewPaperWallet(ctx, "USDT", exchange.WithPaperAsset("USDT", 1000),
+		exchange.WithPaperFee(0.001, 0.001))
+	controller := NewController(ctx, wallet, db, NewOrderFeed())
+
+	candle := model.Candle{Time: time.Now(), Pair: "BTCUSDT", Close: 100, Low: 100, High: 100, Complete: true}
+	wallet.OnCandle(candle)
+	controller.OnCandle(candle)
+
+	// buy with the whole free quote, the fee is paid with the asset bought
+	_, quote, err := controller.Position("BTCUSDT")
+	require.NoError(t, err)
+	order, err := controller.CreateOrderMarketQuote(model.SideTypeBuy, "BTCUSDT", quote)
+	require.NoError(t, err)
+	require.Equal(t, 10.0, order.Quantity)
+
+	asset, quote, err := controller.Position("BTCUSDT")
+	require.NoError(t, err)
+	require.InDelta(t, 9.99, asset, 1e-9)
+	require.InDelta(t, 0, quote, 1e-9)
+
+	_, err = controller.CreateOrderMarket(model.SideTypeSell, "BTCUSDT", asset)
+	require.NoError(t, err)
+	_, quote, err = controller.Position("BTCUSDT")
+	require.NoError(t, err)
+	require.InDelta(t, 999-0.999, quote, 1e-9)
+}
+
+func TestController_MaxSlippage(t *testing.T) {
+	db, err := storage.FromMemory()
+	require.NoError(t, err)
+	ctx := context.Background()
+	wallet := exchange.NewPaperWallet(ctx, "USDT", exchange.WithPaperAsset("USDT", 1000),
+		exchange.WithPaperAsset("BTC", 1))
+	controller := NewController(ctx, wallet, db, NewOrderFeed())
+	controller.SetMaxSlippage(0.05, false)
+
+	// the strategy expects 100, but the next candle gaps up to 109
+	controller.OnCandle(model.Candle{Time: time.Now(), Pair: "BTCUSDT", Close: 100, Low: 100, High: 100})
+	wallet.OnCandle(model.Candle{Time: time.Now(), Pair: "BTCUSDT", Open: 108, Close: 109, Low: 108, High: 110})
+
+	_, err = controller.CreateOrderMarket(model.SideTypeBuy, "BTCUSDT", 1)
+	require.ErrorIs(t, err, ErrSlippageExceeded)
+	require.EqualError(t, err, "estimated slippage above the maximum: BUY BTCUSDT estimated at 109.000000, "+
+		"9.00% from the last quote of 100.000000")
+
+	_, err = controller.CreateOrderMarketQuote(model.SideTypeBuy, "BTCUSDT", 100)
+	require.ErrorIs(t, err, ErrSlippageExceeded)
+
+	orders, err := db.Orders()
+	require.NoError(t, err)
+	require.Empty(t, orders)
+
+	// internal exits, eg: milestone sales and chain children, are not protected
+	order, err := controller.createOrderMarket(orderSource{exit: true}, model.SideTypeBuy, "BTCUSDT", 0.1,
+		controller.maxSlippage)
+	require.NoError(t, err)
+	require.Equal(t, model.OrderTypeMarket, order.Type)
+
+	// sell orders are filled above the expected price
+	_, err = controller.CreateOrderMarket(model.SideTypeSell, "BTCUSDT", 0.5)
+	require.NoError(t, err)
+
+	// custom protection of the order
+	order, err = controller.CreateOrderMarketSlippage(model.SideTypeBuy, "BTCUSDT", 0.5, 0.15)
+	require.NoError(t, err)
+	require.Equal(t, model.OrderTypeMarket, order.Type)
+
+	t.Run("convert to limit", func(t *testing.T) {
+		controller.SetMaxSlippage(0.05, true)
+		order, err := controller.CreateOrderMarket(model.SideTypeBuy, "BTCUSDT", 0.5)
+		require.NoError(t, err)
+		require.Equal(t, model.OrderTypeLimit, order.Type)
+		require.Equal(t, model.OrderStatusTypeNew, order.Status)
+		require.InDelta(t, 105.0, order.Price, 1e-9)
+	})
+
+	t.Run("virtual exits", func(t *testing.T) {
+		db, err := storage.FromMemory()
+		require.NoError(t, err)
+		wallet := exchange.NewPaperWallet(ctx, "USDT", exchange.WithPaperAsset("USDT", 0),
+			exchange.WithPaperAsset("BTC", 1))
+		controller := NewController(ctx, wallet, db, NewOrderFeed())
+		controller.SetMaxSlippage(0.05, false)
+
+		candle := model.Candle{Time: time.Now(), Pair: "BTCUSDT", Close: 100, Low: 100, High: 100, Complete: true}
+		wallet.OnCandle(candle)
+		controller.OnCandle(candle)
+		_, err = controller.CreateOrderMarketIfTouched(model.SideTypeSell, "BTCUSDT", 1, 105)
+		require.NoError(t, err)
+
+		// wide candle touching the trigger, far from the close
+		candle = model.Candle{Time: time.Now(), Pair: "BTCUSDT", Close: 100, Low: 90, High: 106, Complete: true}
+		wallet.OnCandle(candle)
+		controller.OnCandle(candle)
+
+		asset, _, err := wallet.Position("BTCUSDT")
+		require.NoError(t, err)
+		require.Equal(t, 0.0, asset)
+	})
+}
+
+// spreadExchange is a paper wallet with a fixed order book and tick size
+type spreadExchange struct {
+	*exchange.PaperWallet
+}
+
+func (s spreadExchange) AssetsInfo(pair string) model.AssetInfo {
+	info := s.PaperWallet.AssetsInfo(pair)
+	info.TickSize = 0.5
+	return info
+}
+
+func (s spreadExchange) BookTicker(_ context.Context, _ string) (bid, ask float64, err error) {
+	return 100.2, 100.6, nil
+}
+
+// lotSizeExchange is a paper wallet with a lot size of 0.001
+type lotSizeExchange struct {
+	*exchange.PaperWallet
+}
+
+func (l lotSizeExchange) AssetsInfo(pair string) model.AssetInfo {
+	info := l.PaperWallet.AssetsInfo(pair)
+	info.StepSize = 0.001
+	info.BaseAssetPrecision = 3
+	return info
+}
+
+func TestController_CreateOrderLimitQuote(t *testing.T) {
+	db, err := storage.FromMemory()
+	require.NoError(t, err)
+	ctx := context.Background()
+	wallet := exchange.NewPaperWallet(ctx, "USDT", exchange.WithPaperAsset("USDT", 1000))
+	wallet.OnCandle(model.Candle{Time: time.Now(), Pair: "BTCUSDT", Close: 31000, High: 31000, Low: 31000})
+
+	order, err := NewController(ctx, wallet, db, NewOrderFeed()).
+		CreateOrderLimitQuote(model.SideTypeBuy, "BTCUSDT", 100, 30000)
+	require.NoError(t, err)
+	require.Equal(t, 0.00333333, order.Quantity)
+
+	// rounded down to the lot size
+	controller := NewController(ctx, lotSizeExchange{wallet}, db, NewOrderFeed())
+	order, err = controller.CreateOrderLimitQuote(model.SideTypeBuy, "BTCUSDT", 100, 30000)
+	require.NoError(t, err)
+	require.Equal(t, 0.003, order.Quantity)
+	require.Equal(t, 30000.0, order.Price)
+
+	_, err = controller.CreateOrderLimitQuote(model.SideTypeBuy, "BTCUSDT", 10, 30000)
+	require.ErrorIs(t, err, ErrOrderBelowLotSize)
+
+	_, err = controller.CreateOrderLimitQuote(model.SideTypeBuy, "BTCUSDT", 100, 0)
+	require.Error(t, err)
+
+	wallet.OnCandle(model.Candle{Time: time.Now(), Pair: "BTCUSDT", Close: 29000, High: 31000, Low: 29000})
+	account, err := wallet.Account()
+	require.NoError(t, err)
+	btc, usdt := account.Balance("BTC", "USDT")
+	require.InDelta(t, 0.00633333, btc.Free, 1e-9)
+	require.InDelta(t, 1000-0.00633333*30000, usdt.Free, 1e-6)
+}
+
+func TestController_CreateOrderLimitOffset(t *testing.T) {
+	db, err := storage.FromMemory()
+	require.NoError(t, err)
+	ctx := context.Background()
+	wallet := exchange.NewPaperWallet(ctx, "USDT", exchange.WithPaperAsset("USDT", 1000),
+		exchange.WithPaperAsset("BTC", 1))
+	controller := NewController(ctx, spreadExchange{wallet}, db, NewOrderFeed())
+	wallet.OnCandle(model.Candle{Time: time.Now(), Pair: "BTCUSDT", Close: 100.4, High: 100.4, Low: 100.4})
+
+	// mid-price of 100.4, rounded to the passive tick and 2 ticks away
+	order, err := controller.CreateOrderLimitOffset(model.SideTypeBuy, "BTCUSDT", 1, 2)
+	require.NoError(t, err)
+	require.Equal(t, 99.0, order.Price)
+
+	order, err = controller.CreateOrderLimitOffset(model.SideTypeSell, "BTCUSDT", 1, 2)
+	require.NoError(t, err)
+	require.Equal(t, 101.5, order.Price)
+}
+
+func TestOffsetPrice(t *testing.T) {
+	// prices on the tick grid are not rounded
+	require.InDelta(t, 100.0, offsetPrice(model.SideTypeBuy, 100, 0.01, 0), 1e-9)
+	require.InDelta(t, 100.0, offsetPrice(model.SideTypeSell, 100, 0.01, 0), 1e-9)
+
+	// buy below and sell above the mid
+	require.InDelta(t, 100.12, offsetPrice(model.SideTypeBuy, 100.125, 0.01, 0), 1e-9)
+	require.InDelta(t, 100.13, offsetPrice(model.SideTypeSell, 100.125, 0.01, 0), 1e-9)
+	require.InDelta(t, 100.09, offsetPrice(model.SideTypeBuy, 100.125, 0.01, 3), 1e-9)
+	require.InDelta(t, 100.16, offsetPrice(model.SideTypeSell, 100.125, 0.01, 3), 1e-9)
+
+	// negative offsets cross the mid
+	require.InDelta(t, 100.14, offsetPrice(model.SideTypeBuy, 100.125, 0.01, -2), 1e-9)
+}
@@ -0,0 +1,65 @@
+package order
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/rodrigo-brito/ninjabot/model"
+)
+
+// ErrEntryCooldown is returned when an entry is placed before the cooldown of the last entry of the same side
+var ErrEntryCooldown = errors.New("entry cooldown")
+
+// entry is the last order opening or increasing the position of a pair
+type entry struct {
+	side model.SideType
+	time time.Time
+}
+
+// SetEntryCooldown sets the minimum time between two entries of the same side in a pair, eg: two buys.
+// Orders reducing the open position are exempt. The time is given by candles, so it also works in backtests.
+func (c *Controller) SetEntryCooldown(cooldown time.Duration) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	c.entryCooldown = cooldown
+}
+
+// now returns the time of the last candle of the pair, or the current time without candles
+func (c *Controller) now(pair string) time.Time {
+	if now, ok := c.candleTime[pair]; ok {
+		return now
+	}
+	return time.Now()
+}
+
+// checkEntryCooldown rejects a same side entry within the cooldown, it returns true when the order is an
+// entry to be registered after its creation. The caller must hold the lock.
+func (c *Controller) checkEntryCooldown(side model.SideType, pair string) (bool, error) {
+	if c.entryCooldown <= 0 {
+		return false, nil
+	}
+
+	if position, ok := c.position[pair]; ok && position.Quantity > 0 && position.Side != side {
+		return false, nil
+	}
+
+	last, ok := c.lastEntry[pair]
+	if !ok || last.side != side {
+		return true, nil
+	}
+
+	if elapsed := c.now(pair).Sub(last.time); elapsed < c.entryCooldown {
+		err := fmt.Errorf("%w: %s %s %s after the last entry, the minimum is %s", ErrEntryCooldown, side, pair,
+			elapsed, c.entryCooldown)
+		c.logger.Warn("orderController/cooldown: ", err)
+		c.auditReject(side, pair, 0, err)
+		return false, err
+	}
+	return true, nil
+}
+
+// registerEntry starts the cooldown of the pair, the caller must hold the lock
+func (c *Controller) registerEntry(side model.SideType, pair string) {
+	c.lastEntry[pair] = entry{side: side, time: c.now(pair)}
+}
@@ -0,0 +1,56 @@
+package order
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/rodrigo-brito/ninjabot/exchange"
+	"github.com/rodrigo-brito/ninjabot/model"
+	"github.com/rodrigo-brito/ninjabot/storage"
+)
+
+func TestController_EntryCooldown(t *testing.T) {
+	db, err := storage.FromMemory()
+	require.NoError(t, err)
+	ctx := context.Background()
+	wallet := exchange.NewPaperWallet(ctx, "USDT", exchange.WithPaperAsset("USDT", 10000))
+	controller := NewController(ctx, wallet, db, NewOrderFeed())
+	controller.SetEntryCooldown(time.Hour)
+
+	start := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	candle := func(minutes int) {
+		candle := model.Candle{Time: start.Add(time.Duration(minutes) * time.Minute), Pair: "BTCUSDT",
+			Close: 100, High: 100, Low: 100}
+		wallet.OnCandle(candle)
+		controller.OnCandle(candle)
+	}
+
+	candle(0)
+	_, err = controller.CreateOrderMarket(model.SideTypeBuy, "BTCUSDT", 1)
+	require.NoError(t, err)
+
+	candle(30)
+	_, err = controller.CreateOrderMarket(model.SideTypeBuy, "BTCUSDT", 1)
+	require.ErrorIs(t, err, ErrEntryCooldown)
+	_, err = controller.CreateOrderLimit(model.SideTypeBuy, "BTCUSDT", 1, 90)
+	require.ErrorIs(t, err, ErrEntryCooldown)
+	_, err = controller.CreateOrderMarketQuote(model.SideTypeBuy, "BTCUSDT", 100)
+	require.ErrorIs(t, err, ErrEntryCooldown)
+
+	// closing the position is exempt
+	_, err = controller.CreateOrderMarket(model.SideTypeSell, "BTCUSDT", 0.5)
+	require.NoError(t, err)
+
+	candle(60)
+	_, err = controller.CreateOrderMarket(model.SideTypeBuy, "BTCUSDT", 1)
+	require.NoError(t, err)
+
+	t.Run("disabled", func(t *testing.T) {
+		controller.SetEntryCooldown(0)
+		_, err = controller.CreateOrderMarket(model.SideTypeBuy, "BTCUSDT", 1)
+		require.NoError(t, err)
+	})
+}
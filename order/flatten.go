@@ -0,0 +1,104 @@
+package order
+
+import (
+	"errors"
+	"math"
+	"sort"
+
+	"github.com/rodrigo-brito/ninjabot/exchange"
+	"github.com/rodrigo-brito/ninjabot/model"
+	"github.com/rodrigo-brito/ninjabot/storage"
+)
+
+// Flattened is the outcome of closing all positions
+type Flattened struct {
+	Canceled int      // resting orders canceled
+	Trades   []Result // trades closed by the market orders
+}
+
+// Flatten cancels the resting and virtual orders and closes every open position with market orders,
+// without price protection. Failures don't stop the remaining orders and are returned joined.
+func (c *Controller) Flatten() (Flattened, error) {
+	c.mtx.Lock()
+	c.touchOrders = nil
+	c.trailingOrders = nil
+	c.children = make(map[int64][]ChildOrder)
+	c.mtx.Unlock()
+
+	var (
+		flattened Flattened
+		errs      []error
+	)
+
+	orders, err := c.storage.Orders(storage.WithStatusIn(
+		model.OrderStatusTypeNew,
+		model.OrderStatusTypePartiallyFilled,
+	))
+	if err != nil {
+		return flattened, err
+	}
+
+	// legs of an OCO are canceled together
+	canceledGroups := make(map[int64]bool)
+	for _, order := range orders {
+		if order.GroupID != nil {
+			if canceledGroups[*order.GroupID] {
+				continue
+			}
+			canceledGroups[*order.GroupID] = true
+		}
+
+		if err := c.Cancel(*order); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		flattened.Canceled++
+	}
+
+	// update canceled orders and positions of orders filled in the meantime
+	c.updateOrders()
+
+	c.mtx.Lock()
+	start := c.closedTrades
+	positions := make(map[string]Position, len(c.position))
+	pairs := make([]string, 0, len(c.position))
+	for pair, position := range c.position {
+		positions[pair] = *position
+		pairs = append(pairs, pair)
+	}
+	c.mtx.Unlock()
+
+	sort.Strings(pairs)
+	for _, pair := range pairs {
+		position := positions[pair]
+		side, quantity := model.SideTypeSell, position.Quantity
+		if position.Side == model.SideTypeSell {
+			side = model.SideTypeBuy
+		} else if asset, _, err := c.exchange.Position(pair); err == nil {
+			// fees paid in the asset may reduce the balance below the position
+			quantity = math.Min(quantity, asset)
+		}
+
+		c.logger.Infof("[FLATTEN] Closing %s position of %s", pair, c.numberFormat.Format(quantity, 6))
+		if _, err := c.createOrderMarket(orderSource{exit: true}, side, pair, quantity, 0); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	c.mtx.Lock()
+	closed := min(c.closedTrades-start, len(c.trades))
+	flattened.Trades = append([]Result(nil), c.trades[len(c.trades)-closed:]...)
+	c.mtx.Unlock()
+
+	return flattened, errors.Join(errs...)
+}
+
+// Profit returns the realized profit of closed trades by quote asset
+func (f Flattened) Profit() map[string]float64 {
+	profit := make(map[string]float64)
+	for _, trade := range f.Trades {
+		_, quote := exchange.SplitAssetQuote(trade.Pair)
+		profit[quote] += trade.ProfitValue
+	}
+	return profit
+}
@@ -0,0 +1,77 @@
+package order
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/rodrigo-brito/ninjabot/exchange"
+	"github.com/rodrigo-brito/ninjabot/model"
+	"github.com/rodrigo-brito/ninjabot/storage"
+)
+
+func TestController_Flatten(t *testing.T) {
+	setup := func(t *testing.T) (*Controller, storage.Storage) {
+		db, err := storage.FromMemory()
+		require.NoError(t, err)
+		ctx := context.Background()
+		wallet := exchange.NewPaperWallet(ctx, "USDT", exchange.WithPaperAsset("USDT", 10000))
+		controller := NewController(ctx, wallet, db, NewOrderFeed())
+
+		wallet.OnCandle(model.Candle{Time: time.Now(), Pair: "BTCUSDT", Close: 1000, High: 1000, Low: 1000})
+		wallet.OnCandle(model.Candle{Time: time.Now(), Pair: "ETHUSDT", Close: 100, High: 100, Low: 100})
+		_, err = controller.CreateOrderMarket(model.SideTypeBuy, "BTCUSDT", 2)
+		require.NoError(t, err)
+		_, err = controller.CreateOrderMarket(model.SideTypeBuy, "ETHUSDT", 10)
+		require.NoError(t, err)
+
+		// resting orders
+		_, err = controller.CreateOrderLimit(model.SideTypeSell, "BTCUSDT", 1, 1500)
+		require.NoError(t, err)
+		_, err = controller.CreateOrderLimit(model.SideTypeBuy, "ETHUSDT", 5, 80)
+		require.NoError(t, err)
+
+		wallet.OnCandle(model.Candle{Time: time.Now(), Pair: "BTCUSDT", Close: 1100, High: 1100, Low: 1100})
+		wallet.OnCandle(model.Candle{Time: time.Now(), Pair: "ETHUSDT", Close: 90, High: 90, Low: 90})
+		return controller, db
+	}
+
+	t.Run("flatten", func(t *testing.T) {
+		controller, db := setup(t)
+		controller.Start()
+		controller.Stop()
+
+		flattened, err := controller.Flatten()
+		require.NoError(t, err)
+		require.Equal(t, 2, flattened.Canceled)
+		require.Len(t, flattened.Trades, 2)
+		require.InDelta(t, 100.0, flattened.Profit()["USDT"], 1e-9)
+		require.Empty(t, controller.position)
+
+		account, err := controller.Account()
+		require.NoError(t, err)
+		btc, usdt := account.Balance("BTC", "USDT")
+		eth, _ := account.Balance("ETH", "USDT")
+		require.Zero(t, btc.Free+btc.Lock)
+		require.Zero(t, eth.Free+eth.Lock)
+		require.InDelta(t, 10100.0, usdt.Free, 1e-9)
+		require.Zero(t, usdt.Lock)
+
+		pending, err := db.Orders(storage.WithStatusIn(model.OrderStatusTypeNew))
+		require.NoError(t, err)
+		require.Empty(t, pending)
+	})
+
+	t.Run("plain stop", func(t *testing.T) {
+		controller, db := setup(t)
+		controller.Start()
+		controller.Stop()
+
+		require.Len(t, controller.position, 2)
+		pending, err := db.Orders(storage.WithStatusIn(model.OrderStatusTypeNew))
+		require.NoError(t, err)
+		require.Len(t, pending, 2)
+	})
+}
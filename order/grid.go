@@ -0,0 +1,258 @@
+package order
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+
+	"github.com/rodrigo-brito/ninjabot/exchange"
+	"github.com/rodrigo-brito/ninjabot/model"
+)
+
+// ErrInvalidGrid is returned when a grid is created with an invalid range, levels or quantity
+var ErrInvalidGrid = errors.New("invalid grid")
+
+// GridConfig is a grid of limit orders between Lower and Upper prices, with levels spaced by the same
+// percentage, eg: 90 ~ 110 with 5 levels is 90, 94.62, 99.49, 104.6 and 110 (+5.14% by level).
+// Each order has the Quantity of the asset.
+type GridConfig struct {
+	Pair     string
+	Lower    float64
+	Upper    float64
+	Levels   int
+	Quantity float64
+}
+
+// Grid keeps buy orders below and sell orders above the price, the level closest to the price is empty.
+// When a buy fills, a sell is armed one level above, and when a sell fills, a buy is armed one level below.
+// A sell armed by a buy fill closes a grid trade, with the profit of the difference to the buy price.
+// The initial sells only sell the inventory, without a grid trade.
+type Grid struct {
+	config GridConfig
+	prices []float64
+	source orderSource
+
+	mtx     sync.Mutex
+	orders  map[int]model.Order // active order by level
+	bought  map[int]float64     // buy price of the sell armed by level
+	profit  float64
+	trades  int
+	stopped bool
+}
+
+// gridLevel is the level of a grid order
+type gridLevel struct {
+	grid  *Grid
+	level int
+}
+
+// gridFill is a filled order of a grid level
+type gridFill struct {
+	gridLevel
+	order model.Order
+}
+
+// gridPrices returns the prices of the levels, spaced by the same percentage
+func gridPrices(lower, upper float64, levels int) []float64 {
+	ratio := math.Pow(upper/lower, 1/float64(levels-1))
+	prices := make([]float64, levels)
+	for i := range prices {
+		prices[i] = lower * math.Pow(ratio, float64(i))
+	}
+	prices[levels-1] = upper
+	return prices
+}
+
+// CreateGrid starts a grid with buy orders below and sell orders above the current price.
+// The sell orders require the asset in the wallet.
+func (c *Controller) CreateGrid(config GridConfig) (*Grid, error) {
+	return c.createGrid(orderSource{}, config)
+}
+
+func (c *Controller) createGrid(source orderSource, config GridConfig) (*Grid, error) {
+	if config.Levels < 2 || config.Lower <= 0 || config.Upper <= config.Lower || config.Quantity <= 0 {
+		return nil, fmt.Errorf("%w: %d levels of %f between %f and %f", ErrInvalidGrid, config.Levels,
+			config.Quantity, config.Lower, config.Upper)
+	}
+
+	c.mtx.Lock()
+	price, ok := c.lastPrice[config.Pair]
+	c.mtx.Unlock()
+	if !ok {
+		var err error
+		price, err = c.exchange.LastQuote(c.ctx, config.Pair)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	grid := &Grid{
+		config: config,
+		prices: gridPrices(config.Lower, config.Upper, config.Levels),
+		source: source,
+		orders: make(map[int]model.Order),
+		bought: make(map[int]float64),
+	}
+
+	closest := 0
+	for i, levelPrice := range grid.prices {
+		if math.Abs(levelPrice-price) < math.Abs(grid.prices[closest]-price) {
+			closest = i
+		}
+	}
+
+	for level := range grid.prices {
+		if level == closest {
+			continue
+		}
+
+		side := model.SideTypeSell
+		if level < closest {
+			side = model.SideTypeBuy
+		}
+
+		if err := c.placeGridOrder(grid, level, side); err != nil {
+			if cancelErr := c.CancelGrid(grid); cancelErr != nil {
+				c.logger.Error("orderController/grid: ", cancelErr)
+			}
+			return nil, err
+		}
+	}
+
+	c.logger.Infof("[GRID] Started %s grid of %d levels between %s and %s", config.Pair, config.Levels,
+		c.numberFormat.Format(config.Lower, 6), c.numberFormat.Format(config.Upper, 6))
+	return grid, nil
+}
+
+// CancelGrid stops re-arming the grid and cancels its active orders
+func (c *Controller) CancelGrid(grid *Grid) error {
+	grid.mtx.Lock()
+	grid.stopped = true
+	orders := make([]model.Order, 0, len(grid.orders))
+	for _, order := range grid.orders {
+		orders = append(orders, order)
+	}
+	grid.mtx.Unlock()
+
+	var errs []error
+	for _, order := range orders {
+		if err := c.Cancel(order); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// placeGridOrder creates the order of the level, registered in the same lock to not miss a fast fill
+func (c *Controller) placeGridOrder(grid *Grid, level int, side model.SideType) error {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	price := grid.prices[level]
+	if tickSize := c.exchange.AssetsInfo(grid.config.Pair).TickSize; tickSize > 0 {
+		price = math.Round(price/tickSize) * tickSize
+	}
+
+	order, err := c.submitOrderLimit(grid.source, side, grid.config.Pair, grid.config.Quantity, price)
+	if err != nil {
+		return err
+	}
+
+	c.gridOrders[order.ExchangeID] = gridLevel{grid: grid, level: level}
+	grid.mtx.Lock()
+	grid.orders[level] = order
+	grid.mtx.Unlock()
+	return nil
+}
+
+// releaseGridOrder removes a finished grid order, returning the level if the order is filled
+func (c *Controller) releaseGridOrder(order model.Order) (gridFill, bool) {
+	level, ok := c.gridOrders[order.ExchangeID]
+	if !ok {
+		return gridFill{}, false
+	}
+
+	switch order.Status {
+	case model.OrderStatusTypeFilled:
+		delete(c.gridOrders, order.ExchangeID)
+		return gridFill{gridLevel: level, order: order}, true
+	case model.OrderStatusTypeCanceled, model.OrderStatusTypeExpired, model.OrderStatusTypeRejected:
+		delete(c.gridOrders, order.ExchangeID)
+		level.grid.mtx.Lock()
+		delete(level.grid.orders, level.level)
+		level.grid.mtx.Unlock()
+	}
+	return gridFill{}, false
+}
+
+// rearmGrid places the opposite order of a filled level and registers the profit of sells paired with a buy
+func (c *Controller) rearmGrid(fill gridFill) {
+	grid := fill.grid
+	side, level := model.SideTypeSell, fill.level+1
+
+	grid.mtx.Lock()
+	delete(grid.orders, fill.level)
+	buyPrice, paired := grid.bought[fill.level]
+	if fill.order.Side == model.SideTypeBuy && level < len(grid.prices) {
+		grid.bought[level] = fill.order.Price
+	} else if fill.order.Side == model.SideTypeSell {
+		side, level = model.SideTypeBuy, fill.level-1
+		delete(grid.bought, fill.level)
+		if paired {
+			grid.profit += (fill.order.Price - buyPrice) * fill.order.Quantity
+			grid.trades++
+		}
+	}
+	stopped, profit := grid.stopped, grid.profit
+	grid.mtx.Unlock()
+
+	if fill.order.Side == model.SideTypeSell && paired {
+		_, quote := exchange.SplitAssetQuote(grid.config.Pair)
+		c.notify(fmt.Sprintf("[GRID] %s sold at %s, grid profit %s %s", grid.config.Pair,
+			c.numberFormat.Format(fill.order.Price, 6), c.numberFormat.Format(profit, 4), quote))
+	}
+
+	if stopped || level < 0 || level >= len(grid.prices) {
+		return
+	}
+
+	if err := c.placeGridOrder(grid, level, side); err != nil {
+		c.notifyError(fmt.Errorf("grid %s: level %d not re-armed: %w", grid.config.Pair, level, err))
+	}
+}
+
+// Prices returns the prices of the grid levels
+func (g *Grid) Prices() []float64 {
+	return append([]float64(nil), g.prices...)
+}
+
+// Orders returns the active orders of the grid, sorted by price
+func (g *Grid) Orders() []model.Order {
+	g.mtx.Lock()
+	defer g.mtx.Unlock()
+
+	orders := make([]model.Order, 0, len(g.orders))
+	for _, order := range g.orders {
+		orders = append(orders, order)
+	}
+	sort.Slice(orders, func(i, j int) bool {
+		return orders[i].Price < orders[j].Price
+	})
+	return orders
+}
+
+// Profit returns the realized profit of the grid in quote, without fees
+func (g *Grid) Profit() float64 {
+	g.mtx.Lock()
+	defer g.mtx.Unlock()
+	return g.profit
+}
+
+// Trades returns the number of filled sells paired with a grid buy
+func (g *Grid) Trades() int {
+	g.mtx.Lock()
+	defer g.mtx.Unlock()
+	return g.trades
+}
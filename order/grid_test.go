@@ -0,0 +1,80 @@
+package order
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/rodrigo-brito/ninjabot/exchange"
+	"github.com/rodrigo-brito/ninjabot/model"
+	"github.com/rodrigo-brito/ninjabot/storage"
+)
+
+func TestController_Grid(t *testing.T) {
+	db, err := storage.FromMemory()
+	require.NoError(t, err)
+	ctx := context.Background()
+	wallet := exchange.NewPaperWallet(ctx, "USDT", exchange.WithPaperAsset("USDT", 10000),
+		exchange.WithPaperAsset("BTC", 1))
+	controller := NewController(ctx, wallet, db, NewOrderFeed())
+	candle := model.Candle{Time: time.Now(), Pair: "BTCUSDT", Close: 100, High: 100, Low: 100}
+	wallet.OnCandle(candle)
+	controller.OnCandle(candle)
+
+	_, err = controller.CreateGrid(GridConfig{Pair: "BTCUSDT", Lower: 110, Upper: 90, Levels: 5, Quantity: 0.1})
+	require.ErrorIs(t, err, ErrInvalidGrid)
+
+	grid, err := controller.CreateGrid(GridConfig{Pair: "BTCUSDT", Lower: 90, Upper: 110, Levels: 5, Quantity: 0.1})
+	require.NoError(t, err)
+
+	prices := grid.Prices()
+	require.Len(t, prices, 5)
+	require.InDelta(t, prices[1]/prices[0], prices[4]/prices[3], 1e-9)
+
+	// level closest to the price is empty
+	orders := grid.Orders()
+	require.Len(t, orders, 4)
+	for i, level := range []int{0, 1, 3, 4} {
+		require.InDelta(t, prices[level], orders[i].Price, 1e-6)
+	}
+	require.Equal(t, model.SideTypeBuy, orders[1].Side)
+	require.Equal(t, model.SideTypeSell, orders[2].Side)
+
+	// buy filled at level 1, sell armed at level 2
+	wallet.OnCandle(model.Candle{Time: time.Now(), Pair: "BTCUSDT", Close: 94, High: 96, Low: 94})
+	controller.updateOrders()
+
+	orders = grid.Orders()
+	require.Len(t, orders, 4)
+	require.Equal(t, model.SideTypeSell, orders[1].Side)
+	require.InDelta(t, prices[2], orders[1].Price, 1e-6)
+	require.Zero(t, grid.Trades())
+
+	// sell filled at level 2, buy armed again at level 1
+	wallet.OnCandle(model.Candle{Time: time.Now(), Pair: "BTCUSDT", Close: 100, High: 100, Low: 96})
+	controller.updateOrders()
+
+	orders = grid.Orders()
+	require.Len(t, orders, 4)
+	require.Equal(t, model.SideTypeBuy, orders[1].Side)
+	require.InDelta(t, prices[1], orders[1].Price, 1e-6)
+	require.Equal(t, 1, grid.Trades())
+	require.InDelta(t, (prices[2]-prices[1])*0.1, grid.Profit(), 1e-6)
+
+	// initial inventory sell filled at level 3, without a grid trade
+	wallet.OnCandle(model.Candle{Time: time.Now(), Pair: "BTCUSDT", Close: 105, High: 105, Low: 100})
+	controller.updateOrders()
+
+	orders = grid.Orders()
+	require.Len(t, orders, 4)
+	require.Equal(t, model.SideTypeBuy, orders[2].Side)
+	require.InDelta(t, prices[2], orders[2].Price, 1e-6)
+	require.Equal(t, 1, grid.Trades())
+	require.InDelta(t, (prices[2]-prices[1])*0.1, grid.Profit(), 1e-6)
+
+	require.NoError(t, controller.CancelGrid(grid))
+	controller.updateOrders()
+	require.Empty(t, grid.Orders())
+}
@@ -0,0 +1,214 @@
+package order
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/rodrigo-brito/ninjabot/exchange"
+	"github.com/rodrigo-brito/ninjabot/model"
+	"github.com/rodrigo-brito/ninjabot/storage"
+)
+
+// ErrCapitalReserved is returned when a buy order spends the capital reserved by milestones
+var ErrCapitalReserved = errors.New("capital reserved by milestones")
+
+// MilestoneRule reserves a fraction of the equity each time it grows by a step from the start,
+// eg: Step 0.1 and Sweep 0.05 reserve 5% of the equity at +10%, +20%, ... The reserved capital is
+// kept in the quote asset and buy orders spending it are rejected.
+type MilestoneRule struct {
+	Step  float64
+	Sweep float64
+}
+
+// milestoneState is the progress of the milestones of a quote asset
+type milestoneState struct {
+	start   float64
+	crossed int
+}
+
+// SetMilestones enables the profit-taking rule on equity milestones of the given pairs, by quote asset.
+// The start equity of a quote is taken once all its pairs have a price.
+func (c *Controller) SetMilestones(rule MilestoneRule, pairs []string) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	c.milestoneRule = &rule
+	c.milestonePairs = append([]string(nil), pairs...)
+	sort.Strings(c.milestonePairs)
+}
+
+// RestoreMilestones loads the milestones progress from storages implementing storage.MilestoneStorage,
+// eg: Bunt and SQL, and saves the next changes. A restart keeps the reserved capital and doesn't trigger
+// the milestones already reached. Backtests and paper trading don't restore it, they start with a new wallet.
+func (c *Controller) RestoreMilestones() error {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	milestones, ok := c.storage.(storage.MilestoneStorage)
+	if !ok {
+		return nil
+	}
+
+	saved, err := milestones.Milestones()
+	if err != nil {
+		return err
+	}
+
+	for _, milestone := range saved {
+		c.milestones[milestone.Quote] = &milestoneState{start: milestone.Start, crossed: milestone.Crossed}
+		c.reserved[milestone.Quote] = milestone.Reserved
+	}
+	c.milestoneStorage = milestones
+	return nil
+}
+
+// saveMilestone persists the progress of the quote once restored. The caller must hold the lock.
+func (c *Controller) saveMilestone(quote string) {
+	if c.milestoneStorage == nil {
+		return
+	}
+
+	state := c.milestones[quote]
+	err := c.milestoneStorage.SaveMilestone(storage.Milestone{
+		Quote:    quote,
+		Start:    state.start,
+		Crossed:  state.crossed,
+		Reserved: c.reserved[quote],
+	})
+	if err != nil {
+		c.notifyError(err)
+	}
+}
+
+// checkReserve rejects buy orders with value above the free quote not reserved by milestones. Internal exits
+// and orders reducing a position are exempt. The caller must hold the lock.
+func (c *Controller) checkReserve(source orderSource, side model.SideType, pair string, size,
+	value float64) error {
+	_, quote := exchange.SplitAssetQuote(pair)
+	reserved := c.reserved[quote]
+	if reserved <= 0 || side != model.SideTypeBuy || source.exit || c.reducesPosition(side, pair) {
+		return nil
+	}
+
+	account, err := c.exchange.Account()
+	if err != nil {
+		return err
+	}
+
+	_, quoteBalance := account.Balance("", quote)
+	if free := quoteBalance.Free - reserved; value > free {
+		err := fmt.Errorf("%w: %s %s of %s exceeds the free %s of %s", ErrCapitalReserved, side, pair,
+			c.numberFormat.Format(value, 2), quote, c.numberFormat.Format(math.Max(free, 0), 2))
+		c.notifyError(err)
+		c.auditReject(side, pair, size, err)
+		return err
+	}
+	return nil
+}
+
+// Reserved returns the capital reserved by milestones in the given quote asset
+func (c *Controller) Reserved(quote string) float64 {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	return c.reserved[quote]
+}
+
+// milestonesDue returns true once by candle period, so pairs closing together share a single sweep.
+// The caller must hold the lock.
+func (c *Controller) milestonesDue(candle model.Candle) bool {
+	if c.milestoneRule == nil || c.milestoneRule.Step <= 0 || !candle.Complete || !candle.Time.After(c.lastSweep) {
+		return false
+	}
+	c.lastSweep = candle.Time
+	return true
+}
+
+// milestonesSweep checks the equity of each quote and reserves the sweep of each crossed milestone.
+// It returns the assets to sell when the free quote is not enough to cover the reserve.
+func (c *Controller) milestonesSweep() []model.Order {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	// the account is read under the lock, so the balance is not changed by orders in the meantime
+	start := time.Now()
+	account, err := c.exchange.Account()
+	c.trackLatency(start)
+	if err != nil {
+		c.logger.Error("orderController/milestones: ", err)
+		return nil
+	}
+
+	pairsByQuote := make(map[string][]string)
+	for _, pair := range c.milestonePairs {
+		_, quote := exchange.SplitAssetQuote(pair)
+		pairsByQuote[quote] = append(pairsByQuote[quote], pair)
+	}
+
+	var sales []model.Order
+	for quote, pairs := range pairsByQuote {
+		equity, priced := 0.0, true
+		for _, pair := range pairs {
+			price, ok := c.lastPrice[pair]
+			if !ok {
+				priced = false
+				break
+			}
+
+			asset, _ := exchange.SplitAssetQuote(pair)
+			balance, _ := account.Balance(asset, quote)
+			equity += (balance.Free + balance.Lock) * price
+		}
+
+		// without the price of all pairs, held assets would be counted as profit once priced
+		if !priced {
+			continue
+		}
+
+		_, quoteBalance := account.Balance("", quote)
+		equity += quoteBalance.Free + quoteBalance.Lock
+
+		state, ok := c.milestones[quote]
+		if !ok {
+			c.milestones[quote] = &milestoneState{start: equity}
+			c.saveMilestone(quote)
+			continue
+		}
+
+		// tolerance for floating point errors of equity exactly on a milestone
+		crossed := int(math.Floor((equity/state.start-1)/c.milestoneRule.Step + 1e-9))
+		for step := state.crossed + 1; step <= crossed; step++ {
+			amount := equity * c.milestoneRule.Sweep
+			c.reserved[quote] += amount
+			c.notify(fmt.Sprintf("[MILESTONE] Equity +%s%%, %s %s reserved (total %s %s)",
+				c.numberFormat.Format(float64(step)*c.milestoneRule.Step*100, 0),
+				c.numberFormat.Format(amount, 2), quote, c.numberFormat.Format(c.reserved[quote], 2), quote))
+		}
+		if crossed <= state.crossed {
+			continue
+		}
+		state.crossed = crossed
+		c.saveMilestone(quote)
+
+		// sell assets to cover the reserve not available in quote
+		missing := c.reserved[quote] - quoteBalance.Free
+		for _, pair := range pairs {
+			if missing <= 0 {
+				break
+			}
+
+			asset, _ := exchange.SplitAssetQuote(pair)
+			balance, _ := account.Balance(asset, quote)
+			quantity := math.Min(balance.Free, missing/c.lastPrice[pair])
+			if quantity <= 0 {
+				continue
+			}
+
+			sales = append(sales, model.Order{Pair: pair, Side: model.SideTypeSell, Quantity: quantity})
+			missing -= quantity * c.lastPrice[pair]
+		}
+	}
+
+	return sales
+}
@@ -0,0 +1,151 @@
+package order
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/rodrigo-brito/ninjabot/exchange"
+	"github.com/rodrigo-brito/ninjabot/model"
+	"github.com/rodrigo-brito/ninjabot/storage"
+)
+
+func TestController_Milestones(t *testing.T) {
+	newCandle := func(price float64) model.Candle {
+		return model.Candle{Time: time.Now(), Pair: "BTCUSDT", Close: price, High: price, Low: price, Complete: true}
+	}
+
+	t.Run("reserve from free quote once", func(t *testing.T) {
+		db, err := storage.FromMemory()
+		require.NoError(t, err)
+		ctx := context.Background()
+		wallet := exchange.NewPaperWallet(ctx, "USDT", exchange.WithPaperAsset("USDT", 1000))
+		controller := NewController(ctx, wallet, db, NewOrderFeed())
+		controller.SetMilestones(MilestoneRule{Step: 0.1, Sweep: 0.05}, []string{"BTCUSDT"})
+
+		onCandle := func(price float64) {
+			wallet.OnCandle(newCandle(price))
+			controller.OnCandle(newCandle(price))
+		}
+
+		onCandle(100)
+		_, err = controller.CreateOrderMarket(model.SideTypeBuy, "BTCUSDT", 5)
+		require.NoError(t, err)
+		onCandle(100) // equity at start: 1000
+
+		onCandle(110)
+		require.Equal(t, 0.0, controller.Reserved("USDT"))
+
+		// +10%: 5% of 1100 reserved
+		onCandle(120)
+		require.InDelta(t, 55.0, controller.Reserved("USDT"), 1e-9)
+
+		onCandle(121)
+		require.InDelta(t, 55.0, controller.Reserved("USDT"), 1e-9)
+
+		_, quote, err := controller.Position("BTCUSDT")
+		require.NoError(t, err)
+		require.InDelta(t, 445.0, quote, 1e-9)
+
+		// buy orders can't spend the reserve
+		_, err = controller.CreateOrderMarket(model.SideTypeBuy, "BTCUSDT", 4)
+		require.ErrorIs(t, err, ErrCapitalReserved)
+		_, err = controller.CreateOrderLimit(model.SideTypeBuy, "BTCUSDT", 4, 120)
+		require.ErrorIs(t, err, ErrCapitalReserved)
+		_, err = controller.CreateOrderMarketQuote(model.SideTypeBuy, "BTCUSDT", 500)
+		require.ErrorIs(t, err, ErrCapitalReserved)
+
+		_, err = controller.CreateOrderMarketQuote(model.SideTypeBuy, "BTCUSDT", 400)
+		require.NoError(t, err)
+	})
+
+	t.Run("restore after restart", func(t *testing.T) {
+		db, err := storage.FromMemory()
+		require.NoError(t, err)
+		ctx := context.Background()
+		wallet := exchange.NewPaperWallet(ctx, "USDT", exchange.WithPaperAsset("USDT", 500),
+			exchange.WithPaperAsset("BTC", 5))
+		newController := func() *Controller {
+			controller := NewController(ctx, wallet, db, NewOrderFeed())
+			controller.SetMilestones(MilestoneRule{Step: 0.1, Sweep: 0.05}, []string{"BTCUSDT"})
+			require.NoError(t, controller.RestoreMilestones())
+			return controller
+		}
+
+		controller := newController()
+		for _, price := range []float64{100, 120} {
+			wallet.OnCandle(newCandle(price))
+			controller.OnCandle(newCandle(price))
+		}
+		require.InDelta(t, 55.0, controller.Reserved("USDT"), 1e-9)
+
+		// the reserve is kept and the milestone reached is not triggered again
+		controller = newController()
+		require.InDelta(t, 55.0, controller.Reserved("USDT"), 1e-9)
+		for _, price := range []float64{120, 121} {
+			wallet.OnCandle(newCandle(price))
+			controller.OnCandle(newCandle(price))
+		}
+		require.InDelta(t, 55.0, controller.Reserved("USDT"), 1e-9)
+
+		orders, err := db.Orders()
+		require.NoError(t, err)
+		require.Empty(t, orders)
+	})
+
+	t.Run("sell assets to cover reserve", func(t *testing.T) {
+		db, err := storage.FromMemory()
+		require.NoError(t, err)
+		ctx := context.Background()
+		wallet := exchange.NewPaperWallet(ctx, "USDT", exchange.WithPaperAsset("USDT", 0),
+			exchange.WithPaperAsset("BTC", 10))
+		controller := NewController(ctx, wallet, db, NewOrderFeed())
+		controller.SetMilestones(MilestoneRule{Step: 0.1, Sweep: 0.05}, []string{"BTCUSDT"})
+
+		wallet.OnCandle(newCandle(100))
+		controller.OnCandle(newCandle(100))
+
+		// +20%: two milestones, 5% of 1200 each sold to quote
+		wallet.OnCandle(newCandle(120))
+		controller.OnCandle(newCandle(120))
+		require.InDelta(t, 120.0, controller.Reserved("USDT"), 1e-9)
+
+		asset, quote, err := wallet.Position("BTCUSDT")
+		require.NoError(t, err)
+		require.InDelta(t, 9.0, asset, 1e-9)
+		require.InDelta(t, 120.0, quote, 1e-9)
+
+		_, quote, err = controller.Position("BTCUSDT")
+		require.NoError(t, err)
+		require.Equal(t, 0.0, quote)
+	})
+
+	t.Run("start once all pairs are priced", func(t *testing.T) {
+		db, err := storage.FromMemory()
+		require.NoError(t, err)
+		ctx := context.Background()
+		wallet := exchange.NewPaperWallet(ctx, "USDT", exchange.WithPaperAsset("USDT", 1000),
+			exchange.WithPaperAsset("ETH", 10))
+		controller := NewController(ctx, wallet, db, NewOrderFeed())
+		controller.SetMilestones(MilestoneRule{Step: 0.1, Sweep: 0.05}, []string{"BTCUSDT", "ETHUSDT"})
+
+		onCandle := func(pair string, price float64) {
+			candle := newCandle(price)
+			candle.Pair = pair
+			wallet.OnCandle(candle)
+			controller.OnCandle(candle)
+		}
+
+		// the held ETH is not counted as profit when priced after BTC
+		onCandle("BTCUSDT", 100)
+		onCandle("ETHUSDT", 100)
+		onCandle("BTCUSDT", 100)
+		require.Equal(t, 0.0, controller.Reserved("USDT"))
+
+		// +10% of the 2000 start
+		onCandle("ETHUSDT", 120)
+		require.InDelta(t, 110.0, controller.Reserved("USDT"), 1e-9)
+	})
+}
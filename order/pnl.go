@@ -0,0 +1,87 @@
+package order
+
+import (
+	"sort"
+
+	"github.com/rodrigo-brito/ninjabot/exchange"
+	"github.com/rodrigo-brito/ninjabot/model"
+)
+
+// PnL is the profit of a pair in quote, realized by closed trades and unrealized in the open position.
+// The open position is marked at the last quote, with the entry fees deducted.
+type PnL struct {
+	Pair       string
+	Realized   float64
+	Unrealized float64
+}
+
+// Total returns the realized and unrealized profit
+func (p PnL) Total() float64 {
+	return p.Realized + p.Unrealized
+}
+
+// PnL returns the profit of the pairs with closed trades or open positions, sorted by pair.
+// Positions are marked at the last price received, or the last quote of the exchange.
+func (c *Controller) PnL() ([]PnL, error) {
+	c.mtx.Lock()
+	byPair := make(map[string]*PnL)
+	for pair, summary := range c.Results {
+		byPair[pair] = &PnL{Pair: pair, Realized: summary.Profit()}
+	}
+
+	positions := make(map[string]Position, len(c.position))
+	prices := make(map[string]float64, len(c.position))
+	for pair, position := range c.position {
+		if position.Quantity > 0 {
+			positions[pair] = *position
+			if price, ok := c.lastPrice[pair]; ok {
+				prices[pair] = price
+			}
+		}
+	}
+	c.mtx.Unlock()
+
+	for pair, position := range positions {
+		price, ok := prices[pair]
+		if !ok {
+			var err error
+			price, err = c.LastQuote(pair)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		if _, ok := byPair[pair]; !ok {
+			byPair[pair] = &PnL{Pair: pair}
+		}
+
+		unrealized := (price - position.AvgPrice) * position.Quantity
+		if position.Side == model.SideTypeSell {
+			unrealized = -unrealized
+		}
+		byPair[pair].Unrealized = unrealized - position.Fees
+	}
+
+	pnl := make([]PnL, 0, len(byPair))
+	for _, value := range byPair {
+		pnl = append(pnl, *value)
+	}
+	sort.Slice(pnl, func(i, j int) bool {
+		return pnl[i].Pair < pnl[j].Pair
+	})
+	return pnl, nil
+}
+
+// PnLByQuote returns the total profit of the pairs by quote asset, eg: USDT
+func PnLByQuote(pnl []PnL) map[string]PnL {
+	totals := make(map[string]PnL)
+	for _, value := range pnl {
+		_, quote := exchange.SplitAssetQuote(value.Pair)
+		total := totals[quote]
+		total.Pair = quote
+		total.Realized += value.Realized
+		total.Unrealized += value.Unrealized
+		totals[quote] = total
+	}
+	return totals
+}
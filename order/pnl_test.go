@@ -0,0 +1,53 @@
+package order
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/rodrigo-brito/ninjabot/exchange"
+	"github.com/rodrigo-brito/ninjabot/model"
+	"github.com/rodrigo-brito/ninjabot/storage"
+)
+
+func TestController_PnL(t *testing.T) {
+	db, err := storage.FromMemory()
+	require.NoError(t, err)
+	ctx := context.Background()
+	wallet := exchange.NewPaperWallet(ctx, "USDT", exchange.WithPaperAsset("USDT", 10000))
+	controller := NewController(ctx, wallet, db, NewOrderFeed())
+
+	candle := func(pair string, price float64) {
+		candle := model.Candle{Time: time.Now(), Pair: pair, Close: price, High: price, Low: price}
+		wallet.OnCandle(candle)
+		controller.OnCandle(candle)
+	}
+
+	// closed trade
+	candle("BTCUSDT", 1000)
+	_, err = controller.CreateOrderMarket(model.SideTypeBuy, "BTCUSDT", 1)
+	require.NoError(t, err)
+	candle("BTCUSDT", 1100)
+	_, err = controller.CreateOrderMarket(model.SideTypeSell, "BTCUSDT", 1)
+	require.NoError(t, err)
+
+	// open position
+	candle("ETHUSDT", 100)
+	_, err = controller.CreateOrderMarket(model.SideTypeBuy, "ETHUSDT", 2)
+	require.NoError(t, err)
+	candle("ETHUSDT", 90)
+
+	pnl, err := controller.PnL()
+	require.NoError(t, err)
+	require.Equal(t, []PnL{
+		{Pair: "BTCUSDT", Realized: 100},
+		{Pair: "ETHUSDT", Unrealized: -20},
+	}, pnl)
+
+	total := PnLByQuote(pnl)["USDT"]
+	require.Equal(t, 100.0, total.Realized)
+	require.Equal(t, -20.0, total.Unrealized)
+	require.Equal(t, 80.0, total.Total())
+}
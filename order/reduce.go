@@ -0,0 +1,99 @@
+package order
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/rodrigo-brito/ninjabot/exchange"
+	"github.com/rodrigo-brito/ninjabot/model"
+)
+
+// autoReduceMargin is the share of the free quote left out of reduced orders, room for the fee and
+// an up-tick of the price before a market order fills
+const autoReduceMargin = 0.005
+
+// SetAutoReduce reduces buy orders above the free quote to the maximum affordable size, instead of
+// sending them to be rejected by the exchange. Reduced orders leave a margin of 0.5% of the free quote
+// for fees and price changes, and are still subject to the order minimums.
+func (c *Controller) SetAutoReduce(enabled bool) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	c.autoReduce = enabled
+}
+
+// affordableSize returns the size of a buy order limited to the free quote, excluding the capital reserved
+// by milestones. The caller must hold the lock.
+func (c *Controller) affordableSize(side model.SideType, pair string, size, price float64) (float64, error) {
+	if !c.autoReduce || side != model.SideTypeBuy {
+		return size, nil
+	}
+
+	amount, err := c.affordableAmount(pair, size*price)
+	if err != nil || amount == size*price {
+		return size, err
+	}
+
+	info := c.exchange.AssetsInfo(pair)
+	affordable := exchange.AmountToLotSize(info, amount/price)
+	if affordable <= 0 || affordable < info.MinQuantity {
+		reason := ErrOrderBelowMinimum
+		if affordable <= 0 {
+			reason = ErrOrderBelowLotSize
+		}
+		err := fmt.Errorf("%w: %s %s of %f can't be reduced to the free %s of %s", reason, side,
+			pair, size, info.QuoteAsset, c.numberFormat.Format(amount, 2))
+		c.notifyError(err)
+		c.auditReject(side, pair, size, err)
+		return 0, err
+	}
+
+	c.logger.Warnf("[ORDER] Insufficient %s for %s %s of %f, reducing to %f", info.QuoteAsset, side, pair, size,
+		affordable)
+	return affordable, nil
+}
+
+// affordableQuote returns the amount of a buy order by quote limited to the free quote. The caller must hold the lock.
+func (c *Controller) affordableQuote(side model.SideType, pair string, amount float64) (float64, error) {
+	if !c.autoReduce || side != model.SideTypeBuy {
+		return amount, nil
+	}
+
+	affordable, err := c.affordableAmount(pair, amount)
+	if err != nil || affordable == amount {
+		return amount, err
+	}
+
+	_, quote := exchange.SplitAssetQuote(pair)
+	if affordable <= 0 {
+		err := fmt.Errorf("%w: %s %s of %s %s can't be reduced to the free %s of 0", ErrOrderBelowMinimum, side,
+			pair, c.numberFormat.Format(amount, 2), quote, quote)
+		c.notifyError(err)
+		c.auditReject(side, pair, 0, err)
+		return 0, err
+	}
+
+	c.logger.Warnf("[ORDER] Insufficient %s for %s %s of %s %s, reducing to %s %s", quote, side, pair,
+		c.numberFormat.Format(amount, 2), quote, c.numberFormat.Format(affordable, 2), quote)
+	return affordable, nil
+}
+
+// affordableAmount returns the quote amount of a buy order limited to the free quote, minus the margin.
+// Orders covering a short position are not reduced. The caller must hold the lock.
+func (c *Controller) affordableAmount(pair string, amount float64) (float64, error) {
+	account, err := c.exchange.Account()
+	if err != nil {
+		return 0, err
+	}
+
+	asset, quote := exchange.SplitAssetQuote(pair)
+	assetBalance, quoteBalance := account.Balance(asset, quote)
+	if assetBalance.Free < 0 {
+		return amount, nil
+	}
+
+	free := math.Max(quoteBalance.Free-c.reserved[quote], 0)
+	if amount <= free {
+		return amount, nil
+	}
+	return free * (1 - autoReduceMargin), nil
+}
@@ -0,0 +1,143 @@
+package order
+
+import (
+	"sort"
+
+	"github.com/rodrigo-brito/ninjabot/model"
+)
+
+// StrategyBroker is a broker that tags the created orders with the name of the strategy,
+// the results of tagged orders are also summarized by strategy
+type StrategyBroker struct {
+	*Controller
+	source orderSource
+}
+
+// orderSource is the origin of the created orders, the strategy and an optional note.
+// Exits are internal orders closing a position, eg: flatten, virtual orders and milestone sales.
+type orderSource struct {
+	strategy string
+	note     string
+	exit     bool
+	trigger  float64 // price of the touched virtual order
+}
+
+func (s orderSource) tag(order *model.Order) {
+	order.Strategy = s.strategy
+	order.Note = s.note
+}
+
+// sourceOf returns the source of an order, eg: to tag the orders created by a virtual order
+func sourceOf(order model.Order) orderSource {
+	return orderSource{strategy: order.Strategy, note: order.Note}
+}
+
+// exitOf returns the source of an internal exit created by the order
+func exitOf(order model.Order) orderSource {
+	source := sourceOf(order)
+	source.exit = true
+	return source
+}
+
+// ForStrategy returns a broker that tags the orders created by the given strategy
+func (c *Controller) ForStrategy(name string) *StrategyBroker {
+	return &StrategyBroker{Controller: c, source: orderSource{strategy: name}}
+}
+
+// WithNote returns a broker that annotates the created orders with the note, eg: "hedge"
+func (c *Controller) WithNote(note string) *StrategyBroker {
+	return &StrategyBroker{Controller: c, source: orderSource{note: note}}
+}
+
+// Strategy returns the name used to tag the orders
+func (b *StrategyBroker) Strategy() string {
+	return b.source.strategy
+}
+
+func (b *StrategyBroker) CreateOrderOCO(side model.SideType, pair string, size, price, stop,
+	stopLimit float64) ([]model.Order, error) {
+	return b.createOrderOCO(b.source, side, pair, size, price, stop, stopLimit)
+}
+
+func (b *StrategyBroker) CreateOrderLimit(side model.SideType, pair string, size,
+	limit float64) (model.Order, error) {
+	return b.createOrderLimit(b.source, side, pair, size, limit)
+}
+
+func (b *StrategyBroker) CreateOrderLimitQuote(side model.SideType, pair string, amount,
+	limit float64) (model.Order, error) {
+	return b.createOrderLimitQuote(b.source, side, pair, amount, limit)
+}
+
+func (b *StrategyBroker) CreateOrderLimitOffset(side model.SideType, pair string, size float64,
+	ticks int) (model.Order, error) {
+	return b.createOrderLimitOffset(b.source, side, pair, size, ticks)
+}
+
+func (b *StrategyBroker) CreateOrderMarket(side model.SideType, pair string, size float64) (model.Order, error) {
+	return b.createOrderMarket(b.source, side, pair, size, b.maxSlippage)
+}
+
+func (b *StrategyBroker) CreateOrderMarketSlippage(side model.SideType, pair string, size,
+	maxSlippage float64) (model.Order, error) {
+	return b.createOrderMarket(b.source, side, pair, size, maxSlippage)
+}
+
+func (b *StrategyBroker) CreateOrderMarketQuote(side model.SideType, pair string,
+	amount float64) (model.Order, error) {
+	return b.createOrderMarketQuote(b.source, side, pair, amount)
+}
+
+func (b *StrategyBroker) CreateOrderStop(pair string, size float64, limit float64) (model.Order, error) {
+	return b.createOrderStop(b.source, pair, size, limit)
+}
+
+func (b *StrategyBroker) CreateOrderMarketIfTouched(side model.SideType, pair string,
+	quantity, triggerPrice float64) (model.Order, error) {
+	return b.createOrderMarketIfTouched(b.source, side, pair, quantity, triggerPrice)
+}
+
+func (b *StrategyBroker) CreateOrderTrailingTakeProfit(pair string, quantity, activationPrice,
+	trail float64) (model.Order, error) {
+	return b.createOrderTrailingTakeProfit(b.source, pair, quantity, activationPrice, trail)
+}
+
+func (b *StrategyBroker) CreateGrid(config GridConfig) (*Grid, error) {
+	return b.createGrid(b.source, config)
+}
+
+// Strategies returns the names of strategies with filled orders, sorted by name
+func (c *Controller) Strategies() []string {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	names := make([]string, 0, len(c.strategies))
+	for name := range c.strategies {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// StrategyResults returns the results by pair of the orders tagged with the given strategy
+func (c *Controller) StrategyResults(name string) map[string]*summary {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	results := make(map[string]*summary, len(c.strategies[name]))
+	for pair, result := range c.strategies[name] {
+		copied := *result
+		results[pair] = &copied
+	}
+	return results
+}
+
+func (c *Controller) strategySummary(name, pair string) *summary {
+	if _, ok := c.strategies[name]; !ok {
+		c.strategies[name] = make(map[string]*summary)
+	}
+	if _, ok := c.strategies[name][pair]; !ok {
+		c.strategies[name][pair] = &summary{Pair: pair}
+	}
+	return c.strategies[name][pair]
+}
@@ -0,0 +1,122 @@
+package order
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/rodrigo-brito/ninjabot/exchange"
+	"github.com/rodrigo-brito/ninjabot/model"
+	"github.com/rodrigo-brito/ninjabot/storage"
+)
+
+func TestController_ForStrategy(t *testing.T) {
+	db, err := storage.FromMemory()
+	require.NoError(t, err)
+	ctx := context.Background()
+	wallet := exchange.NewPaperWallet(ctx, "USDT", exchange.WithPaperAsset("USDT", 10000))
+	controller := NewController(ctx, wallet, db, NewOrderFeed())
+	trend := controller.ForStrategy("trend")
+	meanReversion := controller.ForStrategy("mean-reversion")
+
+	wallet.OnCandle(model.Candle{Time: time.Now(), Pair: "BTCUSDT", Close: 1000})
+	wallet.OnCandle(model.Candle{Time: time.Now(), Pair: "ETHUSDT", Close: 100})
+
+	// trend wins with BTC, mean reversion loses with ETH
+	order, err := trend.CreateOrderMarket(model.SideTypeBuy, "BTCUSDT", 1)
+	require.NoError(t, err)
+	require.Equal(t, "trend", order.Strategy)
+	_, err = meanReversion.CreateOrderMarket(model.SideTypeBuy, "ETHUSDT", 10)
+	require.NoError(t, err)
+
+	wallet.OnCandle(model.Candle{Time: time.Now(), Pair: "BTCUSDT", Close: 1200})
+	wallet.OnCandle(model.Candle{Time: time.Now(), Pair: "ETHUSDT", Close: 90})
+	_, err = trend.CreateOrderMarket(model.SideTypeSell, "BTCUSDT", 1)
+	require.NoError(t, err)
+
+	// limit orders keep the tag when filled
+	_, err = meanReversion.CreateOrderLimit(model.SideTypeSell, "ETHUSDT", 10, 95)
+	require.NoError(t, err)
+	wallet.OnCandle(model.Candle{Time: time.Now(), Pair: "ETHUSDT", High: 95, Close: 95})
+	controller.updateOrders()
+
+	require.Equal(t, []string{"mean-reversion", "trend"}, controller.Strategies())
+
+	trendResults := controller.StrategyResults("trend")
+	require.Len(t, trendResults, 1)
+	require.Equal(t, []float64{200}, trendResults["BTCUSDT"].Win())
+	require.Empty(t, trendResults["BTCUSDT"].Lose())
+	require.Equal(t, 2200.0, trendResults["BTCUSDT"].Volume)
+
+	meanReversionResults := controller.StrategyResults("mean-reversion")
+	require.Len(t, meanReversionResults, 1)
+	require.Equal(t, []float64{-50}, meanReversionResults["ETHUSDT"].Lose())
+	require.Empty(t, meanReversionResults["ETHUSDT"].Win())
+
+	trades := controller.Trades(time.Time{}, time.Now().Add(time.Hour))
+	require.Len(t, trades, 2)
+	require.Equal(t, "trend", trades[0].Strategy)
+	require.Equal(t, "mean-reversion", trades[1].Strategy)
+
+	// orders outside strategies are summarized only by pair
+	_, err = controller.CreateOrderMarket(model.SideTypeBuy, "BTCUSDT", 1)
+	require.NoError(t, err)
+	require.Len(t, controller.Strategies(), 2)
+	require.Empty(t, controller.StrategyResults("unknown"))
+
+	orders, err := db.Orders(storage.WithPair("ETHUSDT"))
+	require.NoError(t, err)
+	for _, order := range orders {
+		require.Equal(t, "mean-reversion", order.Strategy)
+	}
+}
+
+func TestStrategyBroker_VirtualExits(t *testing.T) {
+	db, err := storage.FromMemory()
+	require.NoError(t, err)
+	ctx := context.Background()
+	wallet := exchange.NewPaperWallet(ctx, "USDT", exchange.WithPaperAsset("USDT", 10000))
+	controller := NewController(ctx, wallet, db, NewOrderFeed())
+	trend := controller.ForStrategy("trend")
+	breakout := controller.ForStrategy("breakout")
+
+	onCandle := func(pair string, low, close, high float64) {
+		candle := model.Candle{Time: time.Now(), Pair: pair, Low: low, Close: close, High: high, Complete: true}
+		wallet.OnCandle(candle)
+		controller.OnCandle(candle)
+	}
+	onCandle("BTCUSDT", 1000, 1000, 1000)
+	onCandle("ETHUSDT", 100, 100, 100)
+
+	// trend exits by a trailing take-profit, breakout by a market-if-touched order
+	_, err = trend.CreateOrderMarket(model.SideTypeBuy, "BTCUSDT", 1)
+	require.NoError(t, err)
+	trailing, err := trend.CreateOrderTrailingTakeProfit("BTCUSDT", 1, 1100, 0.05)
+	require.NoError(t, err)
+	require.Equal(t, "trend", trailing.Strategy)
+
+	_, err = breakout.CreateOrderMarket(model.SideTypeBuy, "ETHUSDT", 10)
+	require.NoError(t, err)
+	touch, err := breakout.CreateOrderMarketIfTouched(model.SideTypeSell, "ETHUSDT", 10, 120)
+	require.NoError(t, err)
+	require.Equal(t, "breakout", touch.Strategy)
+
+	onCandle("BTCUSDT", 1000, 1200, 1200)
+	onCandle("BTCUSDT", 1100, 1130, 1200)
+	onCandle("ETHUSDT", 100, 115, 121)
+	require.Empty(t, controller.TrailingOrders())
+	require.Empty(t, controller.TouchOrders())
+
+	require.Equal(t, []float64{130}, controller.StrategyResults("trend")["BTCUSDT"].Win())
+	// market-if-touched exit filled at the trigger price
+	require.Equal(t, []float64{200}, controller.StrategyResults("breakout")["ETHUSDT"].Win())
+
+	orders, err := db.Orders(storage.WithPair("ETHUSDT"))
+	require.NoError(t, err)
+	require.Len(t, orders, 2)
+	for _, order := range orders {
+		require.Equal(t, "breakout", order.Strategy)
+	}
+}
@@ -209,6 +209,8 @@ document.addEventListener("DOMContentLoaded", function () {
         },
         0
       );
+      const timeframes = data.timeframes || [];
+      const subplots = standaloneIndicators + timeframes.length;
 
       let layout = {
         template: "ggplot2",
@@ -221,10 +223,10 @@ document.addEventListener("DOMContentLoaded", function () {
           autorange: true,
           rangeslider: { visible: false },
           showline: true,
-          anchor: standaloneIndicators > 0 ? "y3" : "y2",
+          anchor: subplots > 0 ? "y3" : "y2",
         },
         yaxis2: {
-          domain: standaloneIndicators > 0 ? [0.4, 0.9] : [0, 0.9],
+          domain: subplots > 0 ? [0.4, 0.9] : [0, 0.9],
           autorange: true,
           mirror: true,
           showline: true,
@@ -250,7 +252,7 @@ document.addEventListener("DOMContentLoaded", function () {
         sellData,
       ];
 
-      const indicatorsHeight = 0.39 / standaloneIndicators;
+      const indicatorsHeight = 0.39 / subplots;
       let standaloneIndicatorIndex = 0;
       data.indicators.forEach((indicator) => {
         const axisNumber = standaloneIndicatorIndex + 3;
@@ -275,6 +277,7 @@ document.addEventListener("DOMContentLoaded", function () {
             x: metric.time,
             y: metric.value,
             type: metric.style,
+            connectgaps: false,
             line: {
               color: metric.color,
             },
@@ -287,6 +290,35 @@ document.addEventListener("DOMContentLoaded", function () {
           plotData.push(data);
         });
       });
+
+      // candles of higher timeframes, below the indicators in the same time axis
+      timeframes.forEach((timeframe) => {
+        const axisNumber = standaloneIndicatorIndex + 3;
+        const heightStart = standaloneIndicatorIndex * indicatorsHeight;
+        layout["yaxis" + axisNumber] = {
+          title: timeframe.timeframe,
+          domain: [heightStart, heightStart + indicatorsHeight],
+          autorange: true,
+          mirror: true,
+          showline: true,
+          linecolor: "black",
+          gridcolor: "#ddd",
+        };
+        standaloneIndicatorIndex++;
+
+        plotData.push({
+          name: `Candles (${timeframe.timeframe})`,
+          x: unpack(timeframe.candles, "time"),
+          close: unpack(timeframe.candles, "close"),
+          open: unpack(timeframe.candles, "open"),
+          low: unpack(timeframe.candles, "low"),
+          high: unpack(timeframe.candles, "high"),
+          type: "candlestick",
+          xaxis: "x1",
+          yaxis: "y" + axisNumber,
+        });
+      });
+
       Plotly.newPlot("graph", plotData, layout);
     });
 });
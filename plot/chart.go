@@ -7,9 +7,10 @@ import (
 	"encoding/json"
 	"fmt"
 	"html/template"
+	"math"
 	"net/http"
 	"sort"
-	"strings"
+	"strconv"
 	"sync"
 	"time"
 
@@ -41,6 +42,10 @@ type Chart struct {
 	indexHTML       *template.Template
 	strategy        strategy.Strategy
 	lastUpdate      time.Time
+	warmupZeros     bool
+
+	timeframes         []string
+	timeframeDurations map[string]time.Duration
 }
 
 type Candle struct {
@@ -66,12 +71,38 @@ type assetValue struct {
 	Value float64   `json:"value"`
 }
 
+// indicatorValues encodes NaN values as null, displayed as gaps in the chart
+type indicatorValues []float64
+
+func (v indicatorValues) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('[')
+	for i, value := range v {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+
+		if math.IsNaN(value) || math.IsInf(value, 0) {
+			buf.WriteString("null")
+			continue
+		}
+
+		content, err := json.Marshal(value)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(content)
+	}
+	buf.WriteByte(']')
+	return buf.Bytes(), nil
+}
+
 type indicatorMetric struct {
-	Name   string      `json:"name"`
-	Time   []time.Time `json:"time"`
-	Values []float64   `json:"value"`
-	Color  string      `json:"color"`
-	Style  string      `json:"style"`
+	Name   string          `json:"name"`
+	Time   []time.Time     `json:"time"`
+	Values indicatorValues `json:"value"`
+	Color  string          `json:"color"`
+	Style  string          `json:"style"`
 }
 
 type plotIndicator struct {
@@ -175,6 +206,21 @@ func (c *Chart) equityValuesByPair(pair string) (asset []assetValue, quote []ass
 	return assetValues, equityValues
 }
 
+// warmupGaps replaces the values in the warmup period, the declared warmup or the leading NaN values, by NaN.
+// The values are rendered as gaps, so the y-axis scale is not dragged to the zeros of talib.
+func (c *Chart) warmupGaps(values []float64, warmup int) indicatorValues {
+	result := make(indicatorValues, len(values))
+	copy(result, values)
+	if c.warmupZeros {
+		return result
+	}
+
+	for i := 0; i < model.WarmupLength(values, warmup); i++ {
+		result[i] = math.NaN()
+	}
+	return result
+}
+
 func (c *Chart) indicatorsByPair(pair string) []plotIndicator {
 	indicators := make([]plotIndicator, 0)
 	for _, i := range c.indicators {
@@ -189,7 +235,7 @@ func (c *Chart) indicatorsByPair(pair string) []plotIndicator {
 		for _, metric := range i.Metrics() {
 			indicator.Metrics = append(indicator.Metrics, indicatorMetric{
 				Name:   metric.Name,
-				Values: metric.Values,
+				Values: c.warmupGaps(metric.Values, 0),
 				Time:   metric.Time,
 				Color:  metric.Color,
 				Style:  metric.Style,
@@ -217,7 +263,7 @@ func (c *Chart) indicatorsByPair(pair string) []plotIndicator {
 
 				indicator.Metrics = append(indicator.Metrics, indicatorMetric{
 					Time:   i.Time[i.Warmup:],
-					Values: metric.Values[i.Warmup:],
+					Values: c.warmupGaps(metric.Values[i.Warmup:], warmup-i.Warmup),
 					Name:   metric.Name,
 					Color:  metric.Color,
 					Style:  string(metric.Style),
@@ -291,18 +337,24 @@ func (c *Chart) shapesByPair(pair string) []Shape {
 	return shapes
 }
 
-func (c *Chart) orderStringByPair(pair string) [][]string {
+// orderStringByPair returns the CSV rows of the pair orders, only of the given strategy if not empty
+func (c *Chart) orderStringByPair(pair, strategyName string) [][]string {
 	orders := make([][]string, 0)
 	for id := range c.ordersIDsByPair[pair].Iter() {
 		o := c.orderByID[id]
+		if strategyName != "" && o.Strategy != strategyName {
+			continue
+		}
+
 		var profit string
 		if o.Profit != 0 {
 			profit = fmt.Sprintf("%.2f", o.Profit)
 		}
-		orderString := fmt.Sprintf("%s,%s,%s,%d,%s,%f,%f,%.2f,%s",
-			o.CreatedAt, o.Status, o.Side, o.ID, o.Type, o.Quantity, o.Price, o.Quantity*o.Price, profit)
-		order := strings.Split(orderString, ",")
-		orders = append(orders, order)
+		orders = append(orders, []string{
+			o.CreatedAt.String(), string(o.Status), string(o.Side), strconv.FormatInt(o.ID, 10), string(o.Type),
+			fmt.Sprintf("%f", o.Quantity), fmt.Sprintf("%f", o.Price), fmt.Sprintf("%.2f", o.Quantity*o.Price),
+			profit, o.Strategy, o.Note,
+		})
 	}
 	return orders
 }
@@ -372,6 +424,7 @@ func (c *Chart) handleData(w http.ResponseWriter, r *http.Request) {
 		"quote":         quote,
 		"asset":         asset,
 		"max_drawdown":  maxDrawdown,
+		"timeframes":    c.timeframesByPair(pair),
 	})
 	if err != nil {
 		log.Error(err)
@@ -389,11 +442,12 @@ func (c *Chart) handleTradingHistoryData(w http.ResponseWriter, r *http.Request)
 	w.Header().Set("Content-Disposition", "attachment;filename=history_"+pair+".csv")
 	w.Header().Set("Transfer-Encoding", "chunked")
 
-	orders := c.orderStringByPair(pair)
+	orders := c.orderStringByPair(pair, r.URL.Query().Get("strategy"))
 
 	buffer := bytes.NewBuffer(nil)
 	csvWriter := csv.NewWriter(buffer)
-	err := csvWriter.Write([]string{"created_at", "status", "side", "id", "type", "quantity", "price", "total", "profit"})
+	err := csvWriter.Write([]string{"created_at", "status", "side", "id", "type", "quantity", "price", "total", "profit",
+		"strategy", "note"})
 	if err != nil {
 		log.Errorf("failed writing header file: %s", err.Error())
 		w.WriteHeader(http.StatusBadRequest)
@@ -417,6 +471,33 @@ func (c *Chart) handleTradingHistoryData(w http.ResponseWriter, r *http.Request)
 	}
 }
 
+func (c *Chart) handleIndicatorsData(w http.ResponseWriter, r *http.Request) {
+	pair := r.URL.Query().Get("pair")
+	if pair == "" {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	buffer := bytes.NewBuffer(nil)
+	write, contentType, extension := c.WriteIndicatorsCSV, "text/csv", "csv"
+	if r.URL.Query().Get("format") == "json" {
+		write, contentType, extension = c.WriteIndicatorsJSON, "text/json", "json"
+	}
+
+	if err := write(buffer, pair); err != nil {
+		log.Errorf("failed writing indicators: %s", err.Error())
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-type", contentType)
+	w.Header().Set("Content-Disposition", "attachment;filename=indicators_"+pair+"."+extension)
+	_, err := w.Write(buffer.Bytes())
+	if err != nil {
+		log.Errorf("failed writing response: %s", err.Error())
+	}
+}
+
 func (c *Chart) Start() error {
 	http.Handle(
 		"/assets/",
@@ -430,6 +511,7 @@ func (c *Chart) Start() error {
 
 	http.HandleFunc("/health", c.handleHealth)
 	http.HandleFunc("/history", c.handleTradingHistoryData)
+	http.HandleFunc("/indicators", c.handleIndicatorsData)
 	http.HandleFunc("/data", c.handleData)
 	http.HandleFunc("/", c.handleIndex)
 
@@ -464,6 +546,13 @@ func WithDebug() Option {
 	}
 }
 
+// WithWarmupZeros renders the warmup values of indicators as zeros, instead of gaps
+func WithWarmupZeros() Option {
+	return func(chart *Chart) {
+		chart.warmupZeros = true
+	}
+}
+
 func WithCustomIndicators(indicators ...Indicator) Option {
 	return func(chart *Chart) {
 		chart.indicators = indicators
@@ -483,6 +572,12 @@ func NewChart(options ...Option) (*Chart, error) {
 		option(chart)
 	}
 
+	var err error
+	chart.timeframeDurations, err = parseTimeframes(chart.timeframes)
+	if err != nil {
+		return nil, err
+	}
+
 	chartJS, err := staticFiles.ReadFile("assets/chart.js")
 	if err != nil {
 		return nil, err
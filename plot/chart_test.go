@@ -1,13 +1,23 @@
 package plot
 
 import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"image/png"
+	"math"
+	"os"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/rodrigo-brito/ninjabot/exchange"
 	"github.com/rodrigo-brito/ninjabot/model"
+	"github.com/rodrigo-brito/ninjabot/service"
+	"github.com/rodrigo-brito/ninjabot/strategy"
 
 	"github.com/StudioSol/set"
+	"github.com/markcheno/go-talib"
 	"github.com/stretchr/testify/require"
 )
 
@@ -196,6 +206,8 @@ func TestChart_OrderStringByPair(t *testing.T) {
 		Status:    "FILLED",
 		Price:     3607.42,
 		Quantity:  0.75152,
+		Strategy:  "ema-cross",
+		Note:      "breakout test, 2nd entry",
 		CreatedAt: time.Date(2021, 10, 13, 20, 0, 0, 0, time.UTC),
 	}
 
@@ -220,16 +232,275 @@ func TestChart_OrderStringByPair(t *testing.T) {
 	c.orderByID[order3.ID] = order3
 
 	expectPair1 := [][]string{
-		{"2021-09-26 20:00:00 +0000 UTC", "FILLED", "SELL", "1", "MARKET", "4783.340000", "3059.370000", "14634006.90", ""},
-		{"2021-10-13 20:00:00 +0000 UTC", "FILLED", "BUY", "2", "MARKET", "0.751520", "3607.420000", "2711.05", ""},
+		{"2021-09-26 20:00:00 +0000 UTC", "FILLED", "SELL", "1", "MARKET", "4783.340000", "3059.370000", "14634006.90", "", "", ""},
+		{"2021-10-13 20:00:00 +0000 UTC", "FILLED", "BUY", "2", "MARKET", "0.751520", "3607.420000", "2711.05", "",
+			"ema-cross", "breakout test, 2nd entry"},
 	}
 
-	ordersPair1 := c.orderStringByPair(pair1)
+	ordersPair1 := c.orderStringByPair(pair1, "")
 	require.Equal(t, expectPair1, ordersPair1)
+	require.Equal(t, expectPair1[1:], c.orderStringByPair(pair1, "ema-cross"))
 
 	expectPair2 := [][]string{
-		{"2021-10-13 20:00:00 +0000 UTC", "FILLED", "BUY", "13", "MARKET", "12.083240", "470.000000", "5679.12", ""},
+		{"2021-10-13 20:00:00 +0000 UTC", "FILLED", "BUY", "13", "MARKET", "12.083240", "470.000000", "5679.12", "", "", ""},
 	}
-	ordersPair2 := c.orderStringByPair(pair2)
+	ordersPair2 := c.orderStringByPair(pair2, "")
 	require.Equal(t, expectPair2, ordersPair2)
 }
+
+type warmupStrategy struct{}
+
+func (s warmupStrategy) Timeframe() string {
+	return "1d"
+}
+
+func (s warmupStrategy) WarmupPeriod() int {
+	return 2
+}
+
+func (s warmupStrategy) Indicators(df *model.Dataframe) []strategy.ChartIndicator {
+	return []strategy.ChartIndicator{
+		{
+			Time:      df.Time,
+			GroupName: "SMA",
+			Overlay:   true,
+			Metrics: []strategy.IndicatorMetric{
+				{Values: talib.Sma(df.Close, 3)},
+			},
+		},
+		{
+			Time:      df.Time,
+			GroupName: "CUSTOM",
+			Metrics: []strategy.IndicatorMetric{
+				{Values: []float64{math.NaN(), math.NaN(), 3, 4, 5}},
+			},
+		},
+	}
+}
+
+func (s warmupStrategy) OnCandle(_ *model.Dataframe, _ service.Broker) {}
+
+func TestChart_IndicatorWarmupGaps(t *testing.T) {
+	c, err := NewChart(WithStrategyIndicators(warmupStrategy{}))
+	require.NoError(t, err)
+
+	start := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 5; i++ {
+		c.OnCandle(model.Candle{
+			Pair:     "BTCUSDT",
+			Time:     start.AddDate(0, 0, i),
+			Close:    float64(100 + i),
+			Complete: true,
+		})
+	}
+
+	content, err := json.Marshal(c.indicatorsByPair("BTCUSDT"))
+	require.NoError(t, err)
+
+	var indicators []struct {
+		Metrics []struct {
+			Values []*float64 `json:"value"`
+		} `json:"metrics"`
+	}
+	require.NoError(t, json.Unmarshal(content, &indicators))
+	require.Len(t, indicators, 2)
+
+	// warmup and NaN values are gaps, instead of zeros
+	for _, indicator := range indicators {
+		values := indicator.Metrics[0].Values
+		require.Len(t, values, 5)
+		require.Nil(t, values[0])
+		require.Nil(t, values[1])
+		require.NotNil(t, values[2])
+		require.NotZero(t, *values[2])
+	}
+
+	t.Run("zeros after the warmup", func(t *testing.T) {
+		values := c.warmupGaps([]float64{0, 0, 0, 1}, 1)
+		require.True(t, math.IsNaN(values[0]))
+		require.Equal(t, []float64{0, 0, 1}, []float64(values[1:]))
+	})
+
+	t.Run("warmup zeros", func(t *testing.T) {
+		c.warmupZeros = true
+		indicators := c.indicatorsByPair("BTCUSDT")
+		require.Equal(t, 0.0, indicators[0].Metrics[0].Values[0])
+		require.Equal(t, 101.0, indicators[0].Metrics[0].Values[2])
+	})
+}
+
+func TestChart_Export(t *testing.T) {
+	c, err := NewChart(WithStrategyIndicators(warmupStrategy{}))
+	require.NoError(t, err)
+
+	start := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 50; i++ {
+		price := 100 + 10*math.Sin(float64(i)/5)
+		c.OnCandle(model.Candle{
+			Pair:     "BTCUSDT",
+			Time:     start.AddDate(0, 0, i),
+			Open:     price - 1,
+			Close:    price,
+			High:     price + 2,
+			Low:      price - 2,
+			Complete: true,
+		})
+	}
+	c.OnOrder(model.Order{ID: 1, Pair: "BTCUSDT", Side: model.SideTypeBuy, Status: model.OrderStatusTypeFilled,
+		Price: 100, UpdatedAt: start.AddDate(0, 0, 5)})
+	c.OnOrder(model.Order{ID: 2, Pair: "BTCUSDT", Side: model.SideTypeSell, Status: model.OrderStatusTypeFilled,
+		Price: 108, UpdatedAt: start.AddDate(0, 0, 10)})
+
+	dir := t.TempDir()
+	pngFile, svgFile := dir+"/chart.png", dir+"/chart.svg"
+	require.NoError(t, c.SavePNG(pngFile, "BTCUSDT", 800, 600))
+	require.NoError(t, c.SaveSVG(svgFile, "BTCUSDT", 800, 600))
+
+	content, err := os.ReadFile(pngFile)
+	require.NoError(t, err)
+	require.Greater(t, len(content), 2000)
+	img, err := png.Decode(bytes.NewReader(content))
+	require.NoError(t, err)
+	require.Equal(t, 800, img.Bounds().Dx())
+
+	content, err = os.ReadFile(svgFile)
+	require.NoError(t, err)
+	require.Greater(t, len(content), 2000)
+	svg := string(content)
+	require.Equal(t, 50, strings.Count(svg, "<rect")-1) // candle bodies and background
+	require.Equal(t, 2, strings.Count(svg, "<polygon")) // trade markers
+	require.Contains(t, svg, "CUSTOM")                  // subplot indicator
+
+	err = c.SavePNG(dir+"/eth.png", "ETHUSDT", 800, 600)
+	require.Error(t, err)
+	require.NoFileExists(t, dir+"/eth.png")
+
+	t.Run("entry", func(t *testing.T) {
+		entry := model.Order{ID: 3, Pair: "BTCUSDT", Side: model.SideTypeBuy, Status: model.OrderStatusTypeFilled,
+			Price: 95, UpdatedAt: start.AddDate(0, 0, 49)}
+
+		cv := newSVGCanvas(800, 600)
+		require.NoError(t, c.render(cv, "BTCUSDT", 800, 600, exportView{recent: 20, entry: &entry}))
+		svg := cv.buf.String()
+		require.Equal(t, 20, strings.Count(svg, "<rect")-1) // recent candles only
+		require.Zero(t, strings.Count(svg, "<polygon"))     // trades before the recent candles
+
+		var buf bytes.Buffer
+		require.NoError(t, c.WriteEntryPNG(&buf, entry, 20, 600, 300))
+		img, err := png.Decode(&buf)
+		require.NoError(t, err)
+		require.Equal(t, 600, img.Bounds().Dx())
+	})
+}
+
+func TestChart_IndicatorsExport(t *testing.T) {
+	c, err := NewChart(WithStrategyIndicators(warmupStrategy{}))
+	require.NoError(t, err)
+
+	start := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 5; i++ {
+		c.OnCandle(model.Candle{
+			Pair:     "BTCUSDT",
+			Time:     start.AddDate(0, 0, i),
+			Close:    float64(100 + i),
+			Volume:   10,
+			Complete: true,
+		})
+	}
+
+	t.Run("csv", func(t *testing.T) {
+		var buf bytes.Buffer
+		require.NoError(t, c.WriteIndicatorsCSV(&buf, "BTCUSDT"))
+
+		rows, err := csv.NewReader(&buf).ReadAll()
+		require.NoError(t, err)
+		require.Len(t, rows, 6)
+		require.Equal(t, []string{"time", "open", "high", "low", "close", "volume", "SMA", "CUSTOM"}, rows[0])
+		require.Equal(t, []string{"2022-01-01T00:00:00Z", "0", "0", "0", "100", "10", "", ""}, rows[1])
+		require.Equal(t, []string{"", ""}, rows[2][6:])
+		require.Equal(t, []string{"101", "3"}, rows[3][6:])
+		require.Equal(t, []string{"103", "5"}, rows[5][6:])
+	})
+
+	t.Run("json", func(t *testing.T) {
+		file := t.TempDir() + "/indicators.json"
+		require.NoError(t, c.SaveIndicators(file, "BTCUSDT"))
+
+		content, err := os.ReadFile(file)
+		require.NoError(t, err)
+		var rows []map[string]interface{}
+		require.NoError(t, json.Unmarshal(content, &rows))
+		require.Len(t, rows, 5)
+		require.Equal(t, "2022-01-01T00:00:00Z", rows[0]["time"])
+		require.Contains(t, rows[0], "SMA")
+		require.Nil(t, rows[0]["SMA"])
+		require.Nil(t, rows[1]["CUSTOM"])
+		require.Equal(t, 102.0, rows[3]["SMA"])
+		require.Equal(t, 4.0, rows[3]["CUSTOM"])
+	})
+
+	err = c.SaveIndicators(t.TempDir()+"/eth.csv", "ETHUSDT")
+	require.Error(t, err)
+}
+
+func TestChart_Timeframes(t *testing.T) {
+	t.Run("aggregate", func(t *testing.T) {
+		start := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+		candles := make([]Candle, 0)
+		for i := 0; i < 50; i++ {
+			candles = append(candles, Candle{
+				Time:   start.Add(time.Duration(i) * time.Hour),
+				Open:   float64(i),
+				Close:  float64(i + 1),
+				High:   float64(i + 2),
+				Low:    float64(i),
+				Volume: 1,
+			})
+		}
+
+		daily := aggregateCandles(candles, 24*time.Hour)
+		require.Len(t, daily, 3)
+		require.Equal(t, start, daily[0].Time)
+		require.Equal(t, 0.0, daily[0].Open)
+		require.Equal(t, 24.0, daily[0].Close)
+		require.Equal(t, 25.0, daily[0].High)
+		require.Equal(t, 0.0, daily[0].Low)
+		require.Equal(t, 24.0, daily[0].Volume)
+
+		// last period not closed yet
+		require.Equal(t, start.Add(48*time.Hour), daily[2].Time)
+		require.Equal(t, 2.0, daily[2].Volume)
+
+		require.Len(t, aggregateCandles(candles, 4*time.Hour), 13)
+	})
+
+	t.Run("chart", func(t *testing.T) {
+		c, err := NewChart(WithTimeframes("4h", "1d"))
+		require.NoError(t, err)
+
+		start := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+		for i := 0; i < 10; i++ {
+			c.OnCandle(model.Candle{
+				Pair:     "BTCUSDT",
+				Time:     start.Add(time.Duration(i) * time.Hour),
+				Open:     1,
+				Close:    1,
+				High:     1,
+				Low:      1,
+				Complete: true,
+			})
+		}
+
+		timeframes := c.timeframesByPair("BTCUSDT")
+		require.Len(t, timeframes, 2)
+		require.Equal(t, "4h", timeframes[0].Timeframe)
+		require.Len(t, timeframes[0].Candles, 3)
+		require.Equal(t, "1d", timeframes[1].Timeframe)
+		require.Len(t, timeframes[1].Candles, 1)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		_, err := NewChart(WithTimeframes("4x"))
+		require.Error(t, err)
+	})
+}
@@ -0,0 +1,137 @@
+package plot
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// indicatorColumn is a metric of an indicator, with the values by candle time
+type indicatorColumn struct {
+	name   string
+	values map[int64]float64
+}
+
+// indicatorColumns returns the metrics of the chart and strategy indicators, without warmup and NaN values
+func (c *Chart) indicatorColumns(pair string) []indicatorColumn {
+	columns := make([]indicatorColumn, 0)
+	for _, indicator := range c.indicatorsByPair(pair) {
+		for _, metric := range indicator.Metrics {
+			name := indicator.Name
+			if metric.Name != "" && metric.Name != indicator.Name {
+				name += "/" + metric.Name
+			}
+
+			values := make(map[int64]float64, len(metric.Values))
+			for i, value := range metric.Values {
+				if i < len(metric.Time) && !math.IsNaN(value) && !math.IsInf(value, 0) {
+					values[metric.Time[i].UnixNano()] = value
+				}
+			}
+			columns = append(columns, indicatorColumn{name: name, values: values})
+		}
+	}
+	return columns
+}
+
+// WriteIndicatorsCSV writes the candles of the pair with a column for each indicator metric, eg: "MA's/EMA 8".
+// Cells are empty where the indicator is undefined, eg: in the warmup period.
+func (c *Chart) WriteIndicatorsCSV(w io.Writer, pair string) error {
+	c.Lock()
+	defer c.Unlock()
+
+	if len(c.candles[pair]) == 0 {
+		return fmt.Errorf("no candles for pair %s", pair)
+	}
+
+	columns := c.indicatorColumns(pair)
+	header := []string{"time", "open", "high", "low", "close", "volume"}
+	for _, column := range columns {
+		header = append(header, column.name)
+	}
+
+	writer := csv.NewWriter(w)
+	if err := writer.Write(header); err != nil {
+		return err
+	}
+
+	for _, candle := range c.candles[pair] {
+		row := []string{
+			candle.Time.UTC().Format(time.RFC3339),
+			formatValue(candle.Open),
+			formatValue(candle.High),
+			formatValue(candle.Low),
+			formatValue(candle.Close),
+			formatValue(candle.Volume),
+		}
+		for _, column := range columns {
+			value, ok := column.values[candle.Time.UnixNano()]
+			if !ok {
+				row = append(row, "")
+				continue
+			}
+			row = append(row, formatValue(value))
+		}
+
+		if err := writer.Write(row); err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+// WriteIndicatorsJSON writes the candles of the pair as a list of objects, with a key for each indicator metric.
+// Values are null where the indicator is undefined, eg: in the warmup period.
+func (c *Chart) WriteIndicatorsJSON(w io.Writer, pair string) error {
+	c.Lock()
+	defer c.Unlock()
+
+	if len(c.candles[pair]) == 0 {
+		return fmt.Errorf("no candles for pair %s", pair)
+	}
+
+	columns := c.indicatorColumns(pair)
+	rows := make([]map[string]interface{}, 0, len(c.candles[pair]))
+	for _, candle := range c.candles[pair] {
+		row := map[string]interface{}{
+			"time":   candle.Time.UTC(),
+			"open":   candle.Open,
+			"high":   candle.High,
+			"low":    candle.Low,
+			"close":  candle.Close,
+			"volume": candle.Volume,
+		}
+		for _, column := range columns {
+			row[column.name] = nil
+			if value, ok := column.values[candle.Time.UnixNano()]; ok {
+				row[column.name] = value
+			}
+		}
+		rows = append(rows, row)
+	}
+
+	return json.NewEncoder(w).Encode(rows)
+}
+
+// SaveIndicators writes the candles and indicators of the pair to a file, in JSON for the .json extension
+// and CSV otherwise
+func (c *Chart) SaveIndicators(file, pair string) error {
+	return saveFile(file, func(w io.Writer) error {
+		if strings.EqualFold(filepath.Ext(file), ".json") {
+			return c.WriteIndicatorsJSON(w, pair)
+		}
+		return c.WriteIndicatorsCSV(w, pair)
+	})
+}
+
+func formatValue(value float64) string {
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
@@ -0,0 +1,493 @@
+package plot
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rodrigo-brito/ninjabot/model"
+	"github.com/rodrigo-brito/ninjabot/strategy"
+)
+
+const (
+	exportPadding     = 10.0
+	exportLabelsWidth = 70.0
+	// exportMainHeight is the fraction of the height used by candles when there are indicators in subplots
+	exportMainHeight = 0.7
+)
+
+var namedColors = map[string]color.RGBA{
+	"black":   {A: 255},
+	"white":   {R: 255, G: 255, B: 255, A: 255},
+	"red":     {R: 255, A: 255},
+	"green":   {G: 128, A: 255},
+	"blue":    {B: 255, A: 255},
+	"yellow":  {R: 255, G: 255, A: 255},
+	"orange":  {R: 255, G: 165, A: 255},
+	"purple":  {R: 128, B: 128, A: 255},
+	"cyan":    {G: 255, B: 255, A: 255},
+	"magenta": {R: 255, B: 255, A: 255},
+	"brown":   {R: 165, G: 42, B: 42, A: 255},
+	"pink":    {R: 255, G: 192, B: 203, A: 255},
+	"gray":    {R: 128, G: 128, B: 128, A: 255},
+	"grey":    {R: 128, G: 128, B: 128, A: 255},
+}
+
+// canvas is a drawing surface of a static chart
+type canvas interface {
+	rect(x0, y0, x1, y1 float64, fill string)
+	polyline(points [][2]float64, stroke string)
+	triangle(points [3][2]float64, fill string)
+	text(x, y float64, value string)
+}
+
+// panel maps values to the vertical position of a chart area
+type panel struct {
+	top, bottom float64
+	min, max    float64
+}
+
+func newPanel(top, bottom float64, values ...[]float64) panel {
+	p := panel{top: top, bottom: bottom, min: math.Inf(1), max: math.Inf(-1)}
+	for _, series := range values {
+		for _, value := range series {
+			if math.IsNaN(value) || math.IsInf(value, 0) {
+				continue
+			}
+			p.min = math.Min(p.min, value)
+			p.max = math.Max(p.max, value)
+		}
+	}
+
+	if math.IsInf(p.min, 0) {
+		p.min, p.max = 0, 1
+	}
+
+	if p.min == p.max {
+		p.min, p.max = p.min-1, p.max+1
+	}
+	return p
+}
+
+func (p panel) y(value float64) float64 {
+	return p.bottom - (value-p.min)/(p.max-p.min)*(p.bottom-p.top)
+}
+
+// WritePNG renders the chart of the pair as a static PNG image, without a browser.
+// The image includes candles, indicators and filled orders, but no text labels.
+func (c *Chart) WritePNG(w io.Writer, pair string, width, height int) error {
+	cv := newPNGCanvas(width, height)
+	if err := c.render(cv, pair, width, height, exportView{}); err != nil {
+		return err
+	}
+	return png.Encode(w, cv.img)
+}
+
+// WriteEntryPNG renders the last candles of the order pair as a static PNG image, with the entry price
+// marked by a line, eg: to illustrate the opening of a position
+func (c *Chart) WriteEntryPNG(w io.Writer, entry model.Order, candles, width, height int) error {
+	cv := newPNGCanvas(width, height)
+	if err := c.render(cv, entry.Pair, width, height, exportView{recent: candles, entry: &entry}); err != nil {
+		return err
+	}
+	return png.Encode(w, cv.img)
+}
+
+// WriteSVG renders the chart of the pair as a static SVG image, without a browser.
+func (c *Chart) WriteSVG(w io.Writer, pair string, width, height int) error {
+	cv := newSVGCanvas(width, height)
+	if err := c.render(cv, pair, width, height, exportView{}); err != nil {
+		return err
+	}
+	cv.buf.WriteString("</svg>\n")
+	_, err := w.Write(cv.buf.Bytes())
+	return err
+}
+
+// SavePNG renders the chart of the pair to a PNG file
+func (c *Chart) SavePNG(file, pair string, width, height int) error {
+	return saveFile(file, func(w io.Writer) error {
+		return c.WritePNG(w, pair, width, height)
+	})
+}
+
+// SaveSVG renders the chart of the pair to a SVG file
+func (c *Chart) SaveSVG(file, pair string, width, height int) error {
+	return saveFile(file, func(w io.Writer) error {
+		return c.WriteSVG(w, pair, width, height)
+	})
+}
+
+// saveFile writes the content to the file, the file is not created if the writing fails
+func saveFile(file string, write func(w io.Writer) error) error {
+	var buf bytes.Buffer
+	if err := write(&buf); err != nil {
+		return err
+	}
+	return os.WriteFile(file, buf.Bytes(), 0o644)
+}
+
+// exportView restricts the rendered chart to the recent candles (all if 0) and marks an entry order
+type exportView struct {
+	recent int
+	entry  *model.Order
+}
+
+func (c *Chart) render(cv canvas, pair string, width, height int, view exportView) error {
+	c.Lock()
+	defer c.Unlock()
+
+	if width < 100 || height < 100 {
+		return fmt.Errorf("invalid image size %dx%d", width, height)
+	}
+
+	if len(c.candles[pair]) == 0 {
+		return fmt.Errorf("no candles for pair %s", pair)
+	}
+
+	candles := c.candlesByPair(pair)
+	indicators := c.indicatorsByPair(pair)
+	if view.recent > 0 && len(candles) > view.recent {
+		candles = candles[len(candles)-view.recent:]
+		indicators = indicatorsFrom(indicators, candles[0].Time)
+	}
+
+	var overlays, subplots []plotIndicator
+	for _, indicator := range indicators {
+		if indicator.Overlay {
+			overlays = append(overlays, indicator)
+		} else {
+			subplots = append(subplots, indicator)
+		}
+	}
+
+	left, right := exportPadding, float64(width)-exportLabelsWidth
+	top, bottom := exportPadding, float64(height)-exportPadding
+	mainBottom := bottom
+	if len(subplots) > 0 {
+		mainBottom = top + (bottom-top)*exportMainHeight
+	}
+
+	start, end := candles[0].Time, candles[len(candles)-1].Time
+	x := func(t time.Time) float64 {
+		if !end.After(start) {
+			return (left + right) / 2
+		}
+		return left + float64(t.Sub(start))/float64(end.Sub(start))*(right-left)
+	}
+
+	cv.rect(0, 0, float64(width), float64(height), "white")
+
+	// candles and overlay indicators
+	prices := [][]float64{make([]float64, 0, len(candles)*2+1)}
+	for _, candle := range candles {
+		prices[0] = append(prices[0], candle.Low, candle.High)
+	}
+	if view.entry != nil {
+		prices[0] = append(prices[0], view.entry.Price)
+	}
+	for _, indicator := range overlays {
+		for _, metric := range indicator.Metrics {
+			prices = append(prices, metric.Values)
+		}
+	}
+	main := newPanel(top, mainBottom, prices...)
+	drawLabels(cv, right, main)
+
+	bodyWidth := math.Max(1, (right-left)/float64(len(candles))*0.6)
+	for _, candle := range candles {
+		fill := "#26a69a"
+		if candle.Close < candle.Open {
+			fill = "#ef5350"
+		}
+
+		center := x(candle.Time)
+		cv.polyline([][2]float64{{center, main.y(candle.High)}, {center, main.y(candle.Low)}}, fill)
+
+		bodyTop, bodyBottom := main.y(math.Max(candle.Open, candle.Close)), main.y(math.Min(candle.Open, candle.Close))
+		cv.rect(center-bodyWidth/2, bodyTop, center+bodyWidth/2, math.Max(bodyBottom, bodyTop+1), fill)
+	}
+
+	for _, indicator := range overlays {
+		drawIndicator(cv, indicator, main, x)
+	}
+
+	// trade markers
+	size := math.Max(4, bodyWidth)
+	for _, candle := range candles {
+		for _, order := range candle.Orders {
+			if order.Status != model.OrderStatusTypeFilled {
+				continue
+			}
+
+			center, price := x(candle.Time), main.y(order.Price)
+			if order.Side == model.SideTypeBuy {
+				cv.triangle([3][2]float64{{center, price}, {center - size, price + size*1.5},
+					{center + size, price + size*1.5}}, "green")
+			} else {
+				cv.triangle([3][2]float64{{center, price}, {center - size, price - size*1.5},
+					{center + size, price - size*1.5}}, "red")
+			}
+		}
+	}
+
+	if view.entry != nil {
+		entry := main.y(view.entry.Price)
+		stroke := "green"
+		if view.entry.Side == model.SideTypeSell {
+			stroke = "red"
+		}
+		cv.polyline([][2]float64{{left, entry}, {right, entry}}, stroke)
+	}
+
+	// indicators in subplots
+	if len(subplots) > 0 {
+		subplotHeight := (bottom - mainBottom) / float64(len(subplots))
+		for i, indicator := range subplots {
+			subplotTop := mainBottom + float64(i)*subplotHeight + exportPadding
+			values := make([][]float64, 0, len(indicator.Metrics))
+			for _, metric := range indicator.Metrics {
+				values = append(values, metric.Values)
+			}
+
+			p := newPanel(subplotTop, mainBottom+float64(i+1)*subplotHeight, values...)
+			cv.polyline([][2]float64{{left, subplotTop - exportPadding/2}, {right, subplotTop - exportPadding/2}},
+				"#dddddd")
+			drawLabels(cv, right, p)
+			cv.text(left, subplotTop+exportPadding, indicator.Name)
+			drawIndicator(cv, indicator, p, x)
+		}
+	}
+
+	return nil
+}
+
+// indicatorsFrom returns the indicators without the values before the start time
+func indicatorsFrom(indicators []plotIndicator, start time.Time) []plotIndicator {
+	result := make([]plotIndicator, len(indicators))
+	for i, indicator := range indicators {
+		result[i] = indicator
+		result[i].Metrics = make([]indicatorMetric, len(indicator.Metrics))
+		for j, metric := range indicator.Metrics {
+			first := sort.Search(len(metric.Time), func(k int) bool {
+				return !metric.Time[k].Before(start)
+			})
+			if first > len(metric.Values) {
+				first = len(metric.Values)
+			}
+			metric.Time = metric.Time[first:]
+			metric.Values = metric.Values[first:]
+			result[i].Metrics[j] = metric
+		}
+	}
+	return result
+}
+
+// drawLabels writes the value range of the panel in the right side
+func drawLabels(cv canvas, right float64, p panel) {
+	cv.text(right+exportPadding/2, p.top+exportPadding, strconv.FormatFloat(p.max, 'f', 2, 64))
+	cv.text(right+exportPadding/2, p.bottom, strconv.FormatFloat(p.min, 'f', 2, 64))
+}
+
+// drawIndicator draws the metrics of the indicator, NaN values are gaps in lines
+func drawIndicator(cv canvas, indicator plotIndicator, p panel, x func(time.Time) float64) {
+	for _, metric := range indicator.Metrics {
+		stroke := metric.Color
+		if stroke == "" {
+			stroke = "gray"
+		}
+
+		var segment [][2]float64
+		for j, value := range metric.Values {
+			if j >= len(metric.Time) {
+				break
+			}
+
+			if math.IsNaN(value) || math.IsInf(value, 0) {
+				cv.polyline(segment, stroke)
+				segment = nil
+				continue
+			}
+
+			switch metric.Style {
+			case strategy.StyleBar, strategy.StyleHistogram:
+				base := p.y(math.Max(p.min, math.Min(0, p.max)))
+				cv.rect(x(metric.Time[j])-1, math.Min(base, p.y(value)), x(metric.Time[j])+1,
+					math.Max(base, p.y(value)), stroke)
+			case strategy.StyleScatter:
+				cv.rect(x(metric.Time[j])-1, p.y(value)-1, x(metric.Time[j])+1, p.y(value)+1, stroke)
+			default:
+				segment = append(segment, [2]float64{x(metric.Time[j]), p.y(value)})
+			}
+		}
+		cv.polyline(segment, stroke)
+	}
+}
+
+type svgCanvas struct {
+	buf *bytes.Buffer
+}
+
+func newSVGCanvas(width, height int) *svgCanvas {
+	buf := new(bytes.Buffer)
+	fmt.Fprintf(buf, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d">`+"\n",
+		width, height, width, height)
+	return &svgCanvas{buf: buf}
+}
+
+func (s *svgCanvas) rect(x0, y0, x1, y1 float64, fill string) {
+	fmt.Fprintf(s.buf, `<rect x="%.1f" y="%.1f" width="%.1f" height="%.1f" fill="%s"/>`+"\n",
+		x0, y0, x1-x0, y1-y0, escape(fill))
+}
+
+func (s *svgCanvas) polyline(points [][2]float64, stroke string) {
+	if len(points) < 2 {
+		return
+	}
+
+	coordinates := make([]string, len(points))
+	for i, point := range points {
+		coordinates[i] = fmt.Sprintf("%.1f,%.1f", point[0], point[1])
+	}
+	fmt.Fprintf(s.buf, `<polyline points="%s" fill="none" stroke="%s" stroke-width="1"/>`+"\n",
+		strings.Join(coordinates, " "), escape(stroke))
+}
+
+func (s *svgCanvas) triangle(points [3][2]float64, fill string) {
+	fmt.Fprintf(s.buf, `<polygon points="%.1f,%.1f %.1f,%.1f %.1f,%.1f" fill="%s"/>`+"\n",
+		points[0][0], points[0][1], points[1][0], points[1][1], points[2][0], points[2][1], escape(fill))
+}
+
+func (s *svgCanvas) text(x, y float64, value string) {
+	fmt.Fprintf(s.buf, `<text x="%.1f" y="%.1f" font-family="sans-serif" font-size="10">%s</text>`+"\n",
+		x, y, escape(value))
+}
+
+func escape(value string) string {
+	var buf bytes.Buffer
+	_ = xml.EscapeText(&buf, []byte(value))
+	return buf.String()
+}
+
+type pngCanvas struct {
+	img *image.RGBA
+}
+
+func newPNGCanvas(width, height int) *pngCanvas {
+	return &pngCanvas{img: image.NewRGBA(image.Rect(0, 0, width, height))}
+}
+
+func (p *pngCanvas) rect(x0, y0, x1, y1 float64, fill string) {
+	c := parseColor(fill)
+	for x := int(math.Round(x0)); x < int(math.Round(x1)); x++ {
+		for y := int(math.Round(y0)); y < int(math.Round(y1)); y++ {
+			p.img.Set(x, y, c)
+		}
+	}
+}
+
+func (p *pngCanvas) polyline(points [][2]float64, stroke string) {
+	c := parseColor(stroke)
+	for i := 1; i < len(points); i++ {
+		drawLine(p.img, int(math.Round(points[i-1][0])), int(math.Round(points[i-1][1])),
+			int(math.Round(points[i][0])), int(math.Round(points[i][1])), c)
+	}
+}
+
+func (p *pngCanvas) triangle(points [3][2]float64, fill string) {
+	c := parseColor(fill)
+	minX := math.Min(points[0][0], math.Min(points[1][0], points[2][0]))
+	maxX := math.Max(points[0][0], math.Max(points[1][0], points[2][0]))
+	minY := math.Min(points[0][1], math.Min(points[1][1], points[2][1]))
+	maxY := math.Max(points[0][1], math.Max(points[1][1], points[2][1]))
+
+	sign := func(a, b [2]float64, x, y float64) float64 {
+		return (x-b[0])*(a[1]-b[1]) - (a[0]-b[0])*(y-b[1])
+	}
+
+	for x := int(minX); x <= int(maxX); x++ {
+		for y := int(minY); y <= int(maxY); y++ {
+			px, py := float64(x), float64(y)
+			d1 := sign(points[0], points[1], px, py)
+			d2 := sign(points[1], points[2], px, py)
+			d3 := sign(points[2], points[0], px, py)
+			negative := d1 < 0 || d2 < 0 || d3 < 0
+			positive := d1 > 0 || d2 > 0 || d3 > 0
+			if !(negative && positive) {
+				p.img.Set(x, y, c)
+			}
+		}
+	}
+}
+
+// drawLine draws a line between two points using Bresenham's algorithm
+func drawLine(img *image.RGBA, x0, y0, x1, y1 int, c color.Color) {
+	dx, dy := abs(x1-x0), -abs(y1-y0)
+	sx, sy := 1, 1
+	if x0 > x1 {
+		sx = -1
+	}
+	if y0 > y1 {
+		sy = -1
+	}
+
+	err := dx + dy
+	for {
+		img.Set(x0, y0, c)
+		img.Set(x0, y0+1, c)
+		if x0 == x1 && y0 == y1 {
+			return
+		}
+
+		e2 := 2 * err
+		if e2 >= dy {
+			err += dy
+			x0 += sx
+		}
+		if e2 <= dx {
+			err += dx
+			y0 += sy
+		}
+	}
+}
+
+func abs(value int) int {
+	if value < 0 {
+		return -value
+	}
+	return value
+}
+
+// text is not supported in PNG images, there is no font rendering in the standard library
+func (p *pngCanvas) text(_, _ float64, _ string) {}
+
+// parseColor converts named colors and hex colors (eg: #f00 or #ff0000), used in indicators, to RGBA
+func parseColor(value string) color.RGBA {
+	value = strings.ToLower(strings.TrimSpace(value))
+	if c, ok := namedColors[value]; ok {
+		return c
+	}
+
+	if strings.HasPrefix(value, "#") {
+		hex := value[1:]
+		if len(hex) == 3 {
+			hex = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]})
+		}
+
+		if rgb, err := strconv.ParseUint(hex, 16, 32); err == nil && len(hex) == 6 {
+			return color.RGBA{R: uint8(rgb >> 16), G: uint8(rgb >> 8), B: uint8(rgb), A: 255}
+		}
+	}
+
+	return namedColors["gray"]
+}
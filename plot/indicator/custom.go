@@ -0,0 +1,68 @@
+package indicator
+
+import (
+	"time"
+
+	"github.com/rodrigo-brito/ninjabot/indicator"
+	"github.com/rodrigo-brito/ninjabot/model"
+	"github.com/rodrigo-brito/ninjabot/plot"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Custom plots a custom indicator, the same given to the bot with ninjabot.WithIndicator,
+// without its warmup values
+func Custom(definition indicator.Custom, color string, overlay bool) plot.Indicator {
+	return &custom{
+		definition: definition,
+		Color:      color,
+		overlay:    overlay,
+	}
+}
+
+type custom struct {
+	definition indicator.Custom
+	overlay    bool
+	Color      string
+	Values     model.Series[float64]
+	Time       []time.Time
+}
+
+func (c custom) Warmup() int {
+	return c.definition.Warmup
+}
+
+func (c custom) Name() string {
+	return c.definition.Name
+}
+
+func (c custom) Overlay() bool {
+	return c.overlay
+}
+
+func (c *custom) Load(dataframe *model.Dataframe) {
+	c.Values, c.Time = nil, nil
+	if len(dataframe.Time) < c.definition.Warmup {
+		return
+	}
+
+	values, err := c.definition.Compute(dataframe)
+	if err != nil {
+		log.Error("plot/indicator: ", err)
+		return
+	}
+
+	c.Values = values[c.definition.Warmup:]
+	c.Time = dataframe.Time[c.definition.Warmup:]
+}
+
+func (c custom) Metrics() []plot.IndicatorMetric {
+	return []plot.IndicatorMetric{
+		{
+			Style:  "line",
+			Color:  c.Color,
+			Values: c.Values,
+			Time:   c.Time,
+		},
+	}
+}
@@ -0,0 +1,46 @@
+package plot
+
+import (
+	"bytes"
+	"errors"
+	"image/png"
+)
+
+const (
+	sparklineUp   = "#26a69a"
+	sparklineDown = "#ef5350"
+)
+
+// Sparkline renders a small PNG line chart of the values, without axes or labels.
+// The line is green when the last value is greater or equal than the first one, red otherwise.
+func Sparkline(values []float64, width, height int) ([]byte, error) {
+	if len(values) < 2 {
+		return nil, errors.New("sparkline requires at least two values")
+	}
+
+	if width < 2 || height < 2 {
+		return nil, errors.New("invalid sparkline size")
+	}
+
+	stroke := sparklineUp
+	if values[len(values)-1] < values[0] {
+		stroke = sparklineDown
+	}
+
+	padding := 2.0
+	cv := newPNGCanvas(width, height)
+	cv.rect(0, 0, float64(width), float64(height), "white")
+
+	p := newPanel(padding, float64(height-1)-padding, values)
+	points := make([][2]float64, len(values))
+	for i, value := range values {
+		points[i] = [2]float64{padding + float64(i)*(float64(width-1)-2*padding)/float64(len(values)-1), p.y(value)}
+	}
+	cv.polyline(points, stroke)
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, cv.img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
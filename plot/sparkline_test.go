@@ -0,0 +1,30 @@
+package plot
+
+import (
+	"bytes"
+	"image/png"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSparkline(t *testing.T) {
+	content, err := Sparkline([]float64{100, 110, 105, 120}, 200, 50)
+	require.NoError(t, err)
+
+	img, err := png.Decode(bytes.NewReader(content))
+	require.NoError(t, err)
+	require.Equal(t, 200, img.Bounds().Dx())
+	require.Equal(t, 50, img.Bounds().Dy())
+
+	// last point is at the top right corner, with the up color
+	r, g, b, _ := img.At(197, 2).RGBA()
+	require.Equal(t, []uint32{38, 166, 154}, []uint32{r >> 8, g >> 8, b >> 8})
+
+	_, err = Sparkline([]float64{100}, 200, 50)
+	require.Error(t, err)
+
+	// flat values are rendered in the middle
+	_, err = Sparkline([]float64{100, 100}, 200, 50)
+	require.NoError(t, err)
+}
@@ -0,0 +1,77 @@
+package plot
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/xhit/go-str2duration/v2"
+
+	"github.com/rodrigo-brito/ninjabot/model"
+)
+
+// timeframeCandles are the candles of a pair aggregated in a higher timeframe
+type timeframeCandles struct {
+	Timeframe string   `json:"timeframe"`
+	Candles   []Candle `json:"candles"`
+}
+
+// WithTimeframes displays the candles aggregated in higher timeframes in subplots below the price,
+// in the same time axis, eg: WithTimeframes("1h", "4h") for a 5m strategy
+func WithTimeframes(timeframes ...string) Option {
+	return func(chart *Chart) {
+		chart.timeframes = append(chart.timeframes, timeframes...)
+	}
+}
+
+func parseTimeframes(timeframes []string) (map[string]time.Duration, error) {
+	durations := make(map[string]time.Duration, len(timeframes))
+	for _, timeframe := range timeframes {
+		duration, err := str2duration.ParseDuration(timeframe)
+		if err != nil || duration <= 0 {
+			return nil, fmt.Errorf("invalid chart timeframe: %s", timeframe)
+		}
+		durations[timeframe] = duration
+	}
+	return durations, nil
+}
+
+// timeframesByPair returns the candles of the pair in each configured timeframe
+func (c *Chart) timeframesByPair(pair string) []timeframeCandles {
+	result := make([]timeframeCandles, 0, len(c.timeframes))
+	for _, timeframe := range c.timeframes {
+		result = append(result, timeframeCandles{
+			Timeframe: timeframe,
+			Candles:   aggregateCandles(c.candles[pair], c.timeframeDurations[timeframe]),
+		})
+	}
+	return result
+}
+
+// aggregateCandles groups the candles by period of the timeframe, aligned to UTC boundaries.
+// The last period is included even if not closed yet, like the forming candle of the exchange.
+func aggregateCandles(candles []Candle, timeframe time.Duration) []Candle {
+	aggregated := make([]Candle, 0)
+	for _, candle := range candles {
+		start := candle.Time.UTC().Truncate(timeframe)
+		last := len(aggregated) - 1
+		if last >= 0 && aggregated[last].Time.Equal(start) {
+			aggregated[last].Close = candle.Close
+			aggregated[last].High = math.Max(aggregated[last].High, candle.High)
+			aggregated[last].Low = math.Min(aggregated[last].Low, candle.Low)
+			aggregated[last].Volume += candle.Volume
+			continue
+		}
+
+		aggregated = append(aggregated, Candle{
+			Time:   start,
+			Open:   candle.Open,
+			Close:  candle.Close,
+			High:   candle.High,
+			Low:    candle.Low,
+			Volume: candle.Volume,
+			Orders: make([]model.Order, 0),
+		})
+	}
+	return aggregated
+}
@@ -0,0 +1,106 @@
+package ninjabot
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rodrigo-brito/ninjabot/exchange"
+	"github.com/rodrigo-brito/ninjabot/model"
+	"github.com/rodrigo-brito/ninjabot/storage"
+	"github.com/rodrigo-brito/ninjabot/strategy"
+)
+
+// maxRegressionDiffs is the number of divergences reported by a regression comparison
+const maxRegressionDiffs = 10
+
+// RegressionTrade is a filled order of a regression run
+type RegressionTrade struct {
+	Time     time.Time       `json:"time"`
+	Pair     string          `json:"pair"`
+	Side     model.SideType  `json:"side"`
+	Type     model.OrderType `json:"type"`
+	Quantity float64         `json:"quantity"`
+	Price    float64         `json:"price"`
+}
+
+// RegressionResult is the outcome of a backtest, compared with a golden result to detect strategy regressions
+type RegressionResult struct {
+	Trades []RegressionTrade `json:"trades"`
+	Equity float64           `json:"equity"` // final equity in the quote of the wallet
+}
+
+// Regression runs a silent backtest of the strategy and returns its trades and final equity. The candles
+// are the clock of the backtest, so the result is the same for the same dataset and wallet.
+// newWallet must return a new paper wallet with a fresh data feed.
+func Regression(ctx context.Context, settings model.Settings, str strategy.Strategy,
+	newWallet func() (*exchange.PaperWallet, error)) (*RegressionResult, error) {
+
+	// notifications are not useful for simulations
+	settings.Telegram.Enabled = false
+
+	_, bot, wallet, err := benchmarkRun(ctx, settings, str, newWallet)
+	if err != nil {
+		return nil, err
+	}
+
+	orders, err := bot.storage.Orders(storage.WithStatus(model.OrderStatusTypeFilled))
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(orders, func(i, j int) bool {
+		return orders[i].ID < orders[j].ID
+	})
+
+	result := &RegressionResult{Trades: make([]RegressionTrade, 0, len(orders)), Equity: wallet.Equity()}
+	for _, order := range orders {
+		result.Trades = append(result.Trades, RegressionTrade{
+			Time:     order.UpdatedAt.UTC(),
+			Pair:     order.Pair,
+			Side:     order.Side,
+			Type:     order.Type,
+			Quantity: order.Quantity,
+			Price:    order.Price,
+		})
+	}
+	return result, nil
+}
+
+// Compare returns an error describing the divergences from the golden result. Quantities, prices and equity
+// may differ by the relative tolerance, eg: 0.001 for 0.1%, and the other fields must be equal.
+func (r RegressionResult) Compare(golden RegressionResult, tolerance float64) error {
+	var diffs []string
+	if len(r.Trades) != len(golden.Trades) {
+		diffs = append(diffs, fmt.Sprintf("trades: got %d, expected %d", len(r.Trades), len(golden.Trades)))
+	}
+
+	for i := 0; i < len(r.Trades) && i < len(golden.Trades); i++ {
+		got, expected := r.Trades[i], golden.Trades[i]
+		if !got.Time.Equal(expected.Time) || got.Pair != expected.Pair || got.Side != expected.Side ||
+			got.Type != expected.Type || !withinTolerance(got.Quantity, expected.Quantity, tolerance) ||
+			!withinTolerance(got.Price, expected.Price, tolerance) {
+			diffs = append(diffs, fmt.Sprintf("trade %d: got %+v, expected %+v", i, got, expected))
+		}
+	}
+
+	if !withinTolerance(r.Equity, golden.Equity, tolerance) {
+		diffs = append(diffs, fmt.Sprintf("equity: got %f, expected %f", r.Equity, golden.Equity))
+	}
+
+	if len(diffs) == 0 {
+		return nil
+	}
+
+	if len(diffs) > maxRegressionDiffs {
+		diffs = append(diffs[:maxRegressionDiffs], fmt.Sprintf("... %d more", len(diffs)-maxRegressionDiffs))
+	}
+	return fmt.Errorf("regression: %s", strings.Join(diffs, "\n"))
+}
+
+func withinTolerance(value, expected, tolerance float64) bool {
+	return math.Abs(value-expected) <= tolerance*math.Max(math.Abs(value), math.Abs(expected))
+}
@@ -34,6 +34,24 @@ type Broker interface {
 	Cancel(model.Order) error
 }
 
+// LimitOffsetBroker places passive limit orders at the mid-price offset by a number of ticks,
+// it is implemented by the order controller given to strategies
+type LimitOffsetBroker interface {
+	CreateOrderLimitOffset(side model.SideType, pair string, size float64, ticks int) (model.Order, error)
+}
+
+// LimitQuoteBroker places limit orders of a quote amount, eg: 100 USDT of BTC at 25000,
+// it is implemented by the order controller given to strategies
+type LimitQuoteBroker interface {
+	CreateOrderLimitQuote(side model.SideType, pair string, amount, limit float64) (model.Order, error)
+}
+
+// SlippageBroker creates market orders with a custom price protection, eg: 0.01 for 1%,
+// it is implemented by the order controller given to strategies
+type SlippageBroker interface {
+	CreateOrderMarketSlippage(side model.SideType, pair string, size, maxSlippage float64) (model.Order, error)
+}
+
 type Notifier interface {
 	Notify(string)
 	OnOrder(order model.Order)
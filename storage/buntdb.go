@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"log"
 	"strconv"
+	"strings"
 	"sync/atomic"
 
 	"github.com/tidwall/buntdb"
@@ -11,6 +12,9 @@ import (
 	"github.com/rodrigo-brito/ninjabot/model"
 )
 
+// milestonePrefix is the key prefix of milestones, stored along with orders
+const milestonePrefix = "milestone:"
+
 type Bunt struct {
 	lastID int64
 	db     *buntdb.DB
@@ -40,6 +44,11 @@ func newBunt(sourceFile string) (Storage, error) {
 	}, nil
 }
 
+// Close closes the database
+func (b *Bunt) Close() error {
+	return b.db.Close()
+}
+
 func (b *Bunt) getID() int64 {
 	return atomic.AddInt64(&b.lastID, 1)
 }
@@ -74,7 +83,11 @@ func (b Bunt) UpdateOrder(order *model.Order) error {
 func (b Bunt) Orders(filters ...OrderFilter) ([]*model.Order, error) {
 	orders := make([]*model.Order, 0)
 	err := b.db.View(func(tx *buntdb.Tx) error {
-		err := tx.Ascend("update_index", func(_, value string) bool {
+		err := tx.Ascend("update_index", func(key, value string) bool {
+			if strings.HasPrefix(key, milestonePrefix) {
+				return true
+			}
+
 			var order model.Order
 			err := json.Unmarshal([]byte(value), &order)
 			if err != nil {
@@ -99,3 +112,36 @@ func (b Bunt) Orders(filters ...OrderFilter) ([]*model.Order, error) {
 	}
 	return orders, nil
 }
+
+// SaveMilestone creates or updates the milestones progress of the quote asset
+func (b *Bunt) SaveMilestone(milestone Milestone) error {
+	return b.db.Update(func(tx *buntdb.Tx) error {
+		content, err := json.Marshal(milestone)
+		if err != nil {
+			return err
+		}
+
+		_, _, err = tx.Set(milestonePrefix+milestone.Quote, string(content), nil)
+		return err
+	})
+}
+
+// Milestones returns the milestones progress of each quote asset
+func (b *Bunt) Milestones() ([]Milestone, error) {
+	milestones := make([]Milestone, 0)
+	err := b.db.View(func(tx *buntdb.Tx) error {
+		return tx.AscendKeys(milestonePrefix+"*", func(_, value string) bool {
+			var milestone Milestone
+			if err := json.Unmarshal([]byte(value), &milestone); err != nil {
+				log.Println(err)
+				return true
+			}
+			milestones = append(milestones, milestone)
+			return true
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return milestones, nil
+}
@@ -23,4 +23,10 @@ func TestNewBunt(t *testing.T) {
 	require.NoError(t, err)
 
 	storageUseCase(repo, t)
+
+	t.Run("milestones", func(t *testing.T) {
+		repo, err := FromMemory()
+		require.NoError(t, err)
+		milestoneUseCase(repo, t)
+	})
 }
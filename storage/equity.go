@@ -0,0 +1,110 @@
+package storage
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"os"
+	"sync"
+	"time"
+)
+
+// EquitySnapshot is the account value, in quote currency, at a given time
+type EquitySnapshot struct {
+	Time  time.Time `json:"time"`
+	Value float64   `json:"value"`
+}
+
+// EquityStorage persists periodic snapshots of the account equity
+type EquityStorage interface {
+	SaveEquity(snapshot EquitySnapshot) error
+	Equity(since time.Time) ([]EquitySnapshot, error)
+	Close() error
+}
+
+type equity struct {
+	mtx       sync.RWMutex
+	file      *os.File
+	snapshots []EquitySnapshot
+}
+
+// EquityFromMemory creates an equity storage that keeps snapshots in memory
+func EquityFromMemory() EquityStorage {
+	return &equity{}
+}
+
+// EquityFromFile creates an equity storage backed by a file with one JSON snapshot per line.
+// Existing snapshots are loaded and new snapshots are appended to the file.
+func EquityFromFile(file string) (EquityStorage, error) {
+	f, err := os.OpenFile(file, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+
+	storage := &equity{file: f}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var snapshot EquitySnapshot
+		if err := json.Unmarshal(scanner.Bytes(), &snapshot); err != nil {
+			f.Close()
+			return nil, err
+		}
+		storage.snapshots = append(storage.snapshots, snapshot)
+	}
+
+	if err := scanner.Err(); err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	return storage, nil
+}
+
+func (e *equity) SaveEquity(snapshot EquitySnapshot) error {
+	e.mtx.Lock()
+	defer e.mtx.Unlock()
+
+	if n := len(e.snapshots); n > 0 && snapshot.Time.Before(e.snapshots[n-1].Time) {
+		return errors.New("equity snapshot older than the last one")
+	}
+
+	if e.file != nil {
+		content, err := json.Marshal(snapshot)
+		if err != nil {
+			return err
+		}
+
+		if _, err := e.file.Write(append(content, '\n')); err != nil {
+			return err
+		}
+	}
+
+	e.snapshots = append(e.snapshots, snapshot)
+	return nil
+}
+
+func (e *equity) Equity(since time.Time) ([]EquitySnapshot, error) {
+	e.mtx.RLock()
+	defer e.mtx.RUnlock()
+
+	result := make([]EquitySnapshot, 0)
+	for _, snapshot := range e.snapshots {
+		if !snapshot.Time.Before(since) {
+			result = append(result, snapshot)
+		}
+	}
+	return result, nil
+}
+
+// Close closes the file of the storage, snapshots in memory are still available
+func (e *equity) Close() error {
+	e.mtx.Lock()
+	defer e.mtx.Unlock()
+
+	if e.file == nil {
+		return nil
+	}
+	err := e.file.Close()
+	e.file = nil
+	return err
+}
@@ -0,0 +1,40 @@
+package storage
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEquityFromFile(t *testing.T) {
+	file, err := os.CreateTemp(os.TempDir(), "*.jsonl")
+	require.NoError(t, err)
+	defer os.RemoveAll(file.Name())
+
+	start := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	db, err := EquityFromFile(file.Name())
+	require.NoError(t, err)
+
+	for i := 0; i < 3; i++ {
+		err = db.SaveEquity(EquitySnapshot{Time: start.Add(time.Duration(i) * time.Hour), Value: float64(100 + i)})
+		require.NoError(t, err)
+	}
+
+	err = db.SaveEquity(EquitySnapshot{Time: start, Value: 1})
+	require.Error(t, err)
+	require.NoError(t, db.Close())
+
+	// reload snapshots from file
+	db, err = EquityFromFile(file.Name())
+	require.NoError(t, err)
+	defer db.Close()
+
+	snapshots, err := db.Equity(start.Add(time.Hour))
+	require.NoError(t, err)
+	require.Len(t, snapshots, 2)
+	require.Equal(t, 101.0, snapshots[0].Value)
+	require.Equal(t, 102.0, snapshots[1].Value)
+	require.True(t, start.Add(2*time.Hour).Equal(snapshots[1].Time))
+}
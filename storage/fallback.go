@@ -0,0 +1,205 @@
+package storage
+
+import (
+	"context"
+	"io"
+	"sort"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/rodrigo-brito/ninjabot/model"
+)
+
+// Fallback is a storage that degrades to memory when the durable storage can't be opened.
+// Orders saved in memory are flushed to the durable storage once it is available again.
+type Fallback struct {
+	mtx     sync.Mutex
+	open    func() (Storage, error)
+	durable Storage
+	memory  Storage
+	flushed map[int64]model.Order // orders flushed to the durable storage, by id in memory
+}
+
+// NewFallback opens the durable storage, or starts in degraded mode with a memory storage
+func NewFallback(open func() (Storage, error)) (*Fallback, error) {
+	fallback := &Fallback{
+		open:    open,
+		flushed: make(map[int64]model.Order),
+	}
+
+	durable, err := open()
+	if err == nil {
+		fallback.durable = durable
+		return fallback, nil
+	}
+
+	log.Warnf("[STORAGE] !!! durable storage unavailable, orders are kept in memory until it is back: %v", err)
+	fallback.memory, err = FromMemory()
+	if err != nil {
+		return nil, err
+	}
+	return fallback, nil
+}
+
+// Degraded returns true while orders are kept in memory only
+func (f *Fallback) Degraded() bool {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+	return f.durable == nil
+}
+
+// Reconnect tries to open the durable storage and flush the orders kept in memory.
+// It returns true when the durable storage is in use. A flush that fails halfway is resumed on the next
+// attempt: orders already flushed are updated with their last state instead of created again.
+func (f *Fallback) Reconnect() bool {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+
+	if f.durable != nil {
+		return true
+	}
+
+	durable, err := f.open()
+	if err != nil {
+		log.Debug("[STORAGE] durable storage still unavailable: ", err)
+		return false
+	}
+
+	if err := f.flush(durable); err != nil {
+		log.Error("[STORAGE] flushing orders: ", err)
+		if closer, ok := durable.(io.Closer); ok {
+			if err := closer.Close(); err != nil {
+				log.Error("[STORAGE] closing durable storage: ", err)
+			}
+		}
+		return false
+	}
+
+	f.durable = durable
+	f.memory = nil
+	log.Infof("[STORAGE] durable storage is back, %d orders flushed", len(f.flushed))
+	return true
+}
+
+// flush copies the orders in memory to the durable storage, recording the id of each flushed order
+func (f *Fallback) flush(durable Storage) error {
+	orders, err := f.memory.Orders()
+	if err != nil {
+		return err
+	}
+
+	sort.Slice(orders, func(i, j int) bool {
+		return orders[i].ID < orders[j].ID
+	})
+
+	for _, order := range orders {
+		memoryID := order.ID
+		if flushed, ok := f.flushed[memoryID]; ok {
+			order.ID = flushed.ID
+			if err := durable.UpdateOrder(order); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if err := durable.CreateOrder(order); err != nil {
+			return err
+		}
+		f.flushed[memoryID] = *order
+	}
+
+	return flushMilestones(f.memory, durable)
+}
+
+// flushMilestones copies the milestones saved in memory, newer than the ones of the durable storage
+func flushMilestones(memory, durable Storage) error {
+	source, ok := memory.(MilestoneStorage)
+	if !ok {
+		return nil
+	}
+
+	target, ok := durable.(MilestoneStorage)
+	if !ok {
+		return nil
+	}
+
+	milestones, err := source.Milestones()
+	if err != nil {
+		return err
+	}
+
+	for _, milestone := range milestones {
+		if err := target.SaveMilestone(milestone); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Retry attempts to reconnect in each interval, until the durable storage is back or the context is done
+func (f *Fallback) Retry(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if f.Reconnect() {
+				return
+			}
+		}
+	}
+}
+
+func (f *Fallback) active() Storage {
+	if f.durable != nil {
+		return f.durable
+	}
+	return f.memory
+}
+
+func (f *Fallback) CreateOrder(order *model.Order) error {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+	return f.active().CreateOrder(order)
+}
+
+func (f *Fallback) UpdateOrder(order *model.Order) error {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+
+	// orders created before the flush may still have the id of the memory storage
+	if flushed, ok := f.flushed[order.ID]; ok && f.durable != nil && flushed.ExchangeID == order.ExchangeID {
+		order.ID = flushed.ID
+	}
+	return f.active().UpdateOrder(order)
+}
+
+func (f *Fallback) Orders(filters ...OrderFilter) ([]*model.Order, error) {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+	return f.active().Orders(filters...)
+}
+
+// SaveMilestone saves the milestones progress, it is ignored when the durable storage doesn't support milestones
+func (f *Fallback) SaveMilestone(milestone Milestone) error {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+	if milestones, ok := f.active().(MilestoneStorage); ok {
+		return milestones.SaveMilestone(milestone)
+	}
+	return nil
+}
+
+// Milestones returns the milestones progress, empty when the durable storage doesn't support milestones
+func (f *Fallback) Milestones() ([]Milestone, error) {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+	if milestones, ok := f.active().(MilestoneStorage); ok {
+		return milestones.Milestones()
+	}
+	return nil, nil
+}
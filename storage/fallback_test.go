@@ -0,0 +1,120 @@
+package storage
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/rodrigo-brito/ninjabot/model"
+)
+
+func TestFallback(t *testing.T) {
+	durable, err := FromMemory()
+	require.NoError(t, err)
+	require.NoError(t, durable.CreateOrder(&model.Order{ExchangeID: 1, Status: model.OrderStatusTypeFilled}))
+	require.NoError(t, durable.CreateOrder(&model.Order{ExchangeID: 2, Status: model.OrderStatusTypeFilled}))
+
+	available := false
+	fallback, err := NewFallback(func() (Storage, error) {
+		if !available {
+			return nil, errors.New("database is locked")
+		}
+		return durable, nil
+	})
+	require.NoError(t, err)
+	require.True(t, fallback.Degraded())
+
+	// orders are kept in memory while degraded
+	order := &model.Order{ExchangeID: 10, Status: model.OrderStatusTypeNew}
+	require.NoError(t, fallback.CreateOrder(order))
+	require.Equal(t, int64(1), order.ID)
+	require.NoError(t, fallback.SaveMilestone(Milestone{Quote: "USDT", Start: 1000, Crossed: 1, Reserved: 55}))
+	require.False(t, fallback.Reconnect())
+
+	orders, err := fallback.Orders()
+	require.NoError(t, err)
+	require.Len(t, orders, 1)
+
+	// flush when the durable storage is back
+	available = true
+	require.True(t, fallback.Reconnect())
+	require.False(t, fallback.Degraded())
+
+	orders, err = durable.Orders(WithExchangeID(10))
+	require.NoError(t, err)
+	require.Len(t, orders, 1)
+	require.Equal(t, int64(3), orders[0].ID)
+
+	milestones, err := durable.(MilestoneStorage).Milestones()
+	require.NoError(t, err)
+	require.Equal(t, []Milestone{{Quote: "USDT", Start: 1000, Crossed: 1, Reserved: 55}}, milestones)
+
+	// order with the id of the memory storage is updated in the durable storage
+	order.Status = model.OrderStatusTypeFilled
+	require.NoError(t, fallback.UpdateOrder(order))
+	require.Equal(t, int64(3), order.ID)
+
+	orders, err = fallback.Orders(WithStatus(model.OrderStatusTypeFilled))
+	require.NoError(t, err)
+	require.Len(t, orders, 3)
+}
+
+// flakyStorage fails to create orders after a number of them
+type flakyStorage struct {
+	Storage
+	creates int
+	closed  int
+}
+
+func (s *flakyStorage) CreateOrder(order *model.Order) error {
+	if s.creates == 0 {
+		return errors.New("disk I/O error")
+	}
+	s.creates--
+	return s.Storage.CreateOrder(order)
+}
+
+func (s *flakyStorage) Close() error {
+	s.closed++
+	return nil
+}
+
+func TestFallback_PartialFlush(t *testing.T) {
+	memory, err := FromMemory()
+	require.NoError(t, err)
+	durable := &flakyStorage{Storage: memory, creates: 1}
+
+	available := false
+	fallback, err := NewFallback(func() (Storage, error) {
+		if !available {
+			return nil, errors.New("database is locked")
+		}
+		return durable, nil
+	})
+	require.NoError(t, err)
+
+	first := &model.Order{ExchangeID: 1, Status: model.OrderStatusTypeNew}
+	require.NoError(t, fallback.CreateOrder(first))
+	require.NoError(t, fallback.CreateOrder(&model.Order{ExchangeID: 2, Status: model.OrderStatusTypeNew}))
+
+	// the second order fails, the durable storage is closed and memory is still in use
+	available = true
+	require.False(t, fallback.Reconnect())
+	require.True(t, fallback.Degraded())
+	require.Equal(t, 1, durable.closed)
+
+	first.Status = model.OrderStatusTypeFilled
+	require.NoError(t, fallback.UpdateOrder(first))
+	require.Equal(t, int64(1), first.ID)
+
+	// the flush resumes without duplicates, with the last state of flushed orders
+	durable.creates = 1
+	require.True(t, fallback.Reconnect())
+	orders, err := memory.Orders()
+	require.NoError(t, err)
+	require.Len(t, orders, 2)
+	require.Equal(t, int64(1), orders[0].ExchangeID)
+	require.Equal(t, model.OrderStatusTypeFilled, orders[0].Status)
+	require.Equal(t, int64(2), orders[1].ExchangeID)
+}
@@ -0,0 +1,16 @@
+package storage
+
+// Milestone is the progress of the equity milestones of a quote asset
+type Milestone struct {
+	Quote    string  `gorm:"primaryKey" json:"quote"`
+	Start    float64 `json:"start"`    // equity when the milestones started
+	Crossed  int     `json:"crossed"`  // number of milestones reached
+	Reserved float64 `json:"reserved"` // capital reserved in quote
+}
+
+// MilestoneStorage is implemented by storages able to persist the milestones progress, so the reserved
+// capital and the milestones already reached are kept across restarts
+type MilestoneStorage interface {
+	SaveMilestone(milestone Milestone) error
+	Milestones() ([]Milestone, error)
+}
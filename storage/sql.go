@@ -35,7 +35,7 @@ func FromSQL(dialect gorm.Dialector, opts ...gorm.Option) (Storage, error) {
 	sqlDB.SetMaxOpenConns(100)
 	sqlDB.SetConnMaxLifetime(time.Hour)
 
-	err = db.AutoMigrate(&model.Order{})
+	err = db.AutoMigrate(&model.Order{}, &Milestone{})
 	if err != nil {
 		return nil, err
 	}
@@ -45,6 +45,15 @@ func FromSQL(dialect gorm.Dialector, opts ...gorm.Option) (Storage, error) {
 	}, nil
 }
 
+// Close closes the database connections
+func (s *SQL) Close() error {
+	sqlDB, err := s.db.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.Close()
+}
+
 // CreateOrder creates a new order in a SQL database
 func (s *SQL) CreateOrder(order *model.Order) error {
 	result := s.db.Create(order) // pass pointer of data to Create
@@ -78,3 +87,17 @@ func (s *SQL) Orders(filters ...OrderFilter) ([]*model.Order, error) {
 		return true
 	}), nil
 }
+
+// SaveMilestone creates or updates the milestones progress of the quote asset
+func (s *SQL) SaveMilestone(milestone Milestone) error {
+	return s.db.Save(&milestone).Error
+}
+
+// Milestones returns the milestones progress of each quote asset
+func (s *SQL) Milestones() ([]Milestone, error) {
+	milestones := make([]Milestone, 0)
+	if err := s.db.Find(&milestones).Error; err != nil {
+		return nil, err
+	}
+	return milestones, nil
+}
@@ -2,6 +2,7 @@ package storage
 
 import (
 	"os"
+	"path/filepath"
 	"testing"
 
 	"gorm.io/gorm"
@@ -21,4 +22,10 @@ func TestFromSQL(t *testing.T) {
 	require.NoError(t, err)
 
 	storageUseCase(repo, t)
+
+	t.Run("milestones", func(t *testing.T) {
+		repo, err := FromSQL(sqlite.Open(filepath.Join(t.TempDir(), "milestones.db")), &gorm.Config{})
+		require.NoError(t, err)
+		milestoneUseCase(repo, t)
+	})
 }
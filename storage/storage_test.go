@@ -82,3 +82,29 @@ func storageUseCase(repo Storage, t *testing.T) {
 		require.Equal(t, firstOrder.Quantity, orders[0].Quantity)
 	})
 }
+
+func milestoneUseCase(repo Storage, t *testing.T) {
+	t.Helper()
+	milestones, ok := repo.(MilestoneStorage)
+	require.True(t, ok)
+
+	saved, err := milestones.Milestones()
+	require.NoError(t, err)
+	require.Empty(t, saved)
+
+	require.NoError(t, milestones.SaveMilestone(Milestone{Quote: "USDT", Start: 1000}))
+	require.NoError(t, milestones.SaveMilestone(Milestone{Quote: "USDT", Start: 1000, Crossed: 1, Reserved: 55}))
+	require.NoError(t, milestones.SaveMilestone(Milestone{Quote: "BUSD", Start: 500}))
+
+	saved, err = milestones.Milestones()
+	require.NoError(t, err)
+	require.ElementsMatch(t, []Milestone{
+		{Quote: "USDT", Start: 1000, Crossed: 1, Reserved: 55},
+		{Quote: "BUSD", Start: 500},
+	}, saved)
+
+	// milestones are not listed as orders
+	orders, err := repo.Orders()
+	require.NoError(t, err)
+	require.Empty(t, orders)
+}
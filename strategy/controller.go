@@ -3,15 +3,18 @@ package strategy
 import (
 	log "github.com/sirupsen/logrus"
 
+	"github.com/rodrigo-brito/ninjabot/indicator"
 	"github.com/rodrigo-brito/ninjabot/model"
 	"github.com/rodrigo-brito/ninjabot/service"
 )
 
 type Controller struct {
-	strategy  Strategy
-	dataframe *model.Dataframe
-	broker    service.Broker
-	started   bool
+	strategy   Strategy
+	dataframe  *model.Dataframe
+	broker     service.Broker
+	indicators *indicator.Registry
+	logger     *log.Logger
+	started    bool
 }
 
 func NewStrategyController(pair string, strategy Strategy, broker service.Broker) *Controller {
@@ -24,9 +27,20 @@ func NewStrategyController(pair string, strategy Strategy, broker service.Broker
 		dataframe: dataframe,
 		strategy:  strategy,
 		broker:    broker,
+		logger:    log.StandardLogger(),
 	}
 }
 
+// SetLogger sets the logger of the controller, the standard logger by default
+func (s *Controller) SetLogger(logger *log.Logger) {
+	s.logger = logger
+}
+
+// SetIndicators sets the custom indicators computed before the strategy indicators
+func (s *Controller) SetIndicators(indicators *indicator.Registry) {
+	s.indicators = indicators
+}
+
 func (s *Controller) Start() {
 	s.started = true
 }
@@ -35,6 +49,7 @@ func (s *Controller) OnPartialCandle(candle model.Candle) {
 	if !candle.Complete && len(s.dataframe.Close) >= s.strategy.WarmupPeriod() {
 		if str, ok := s.strategy.(HighFrequencyStrategy); ok {
 			s.updateDataFrame(candle)
+			s.loadCustomIndicators(s.dataframe)
 			str.Indicators(s.dataframe)
 			str.OnPartialCandle(s.dataframe, s.broker)
 		}
@@ -69,7 +84,7 @@ func (s *Controller) updateDataFrame(candle model.Candle) {
 
 func (s *Controller) OnCandle(candle model.Candle) {
 	if len(s.dataframe.Time) > 0 && candle.Time.Before(s.dataframe.Time[len(s.dataframe.Time)-1]) {
-		log.Errorf("late candle received: %#v", candle)
+		s.logger.Errorf("late candle received: %#v", candle)
 		return
 	}
 
@@ -77,9 +92,20 @@ func (s *Controller) OnCandle(candle model.Candle) {
 
 	if len(s.dataframe.Close) >= s.strategy.WarmupPeriod() {
 		sample := s.dataframe.Sample(s.strategy.WarmupPeriod())
+		s.loadCustomIndicators(&sample)
 		s.strategy.Indicators(&sample)
 		if s.started {
 			s.strategy.OnCandle(&sample, s.broker)
 		}
 	}
 }
+
+// loadCustomIndicators computes the registered custom indicators before the strategy indicators
+func (s *Controller) loadCustomIndicators(df *model.Dataframe) {
+	if s.indicators == nil {
+		return
+	}
+	if err := s.indicators.Load(df); err != nil {
+		s.logger.Error("strategyController/indicators: ", err)
+	}
+}
@@ -0,0 +1,81 @@
+package strategy
+
+import (
+	"math/rand"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/rodrigo-brito/ninjabot/model"
+	"github.com/rodrigo-brito/ninjabot/service"
+)
+
+// Random is a baseline strategy with random entries, used to benchmark a real strategy.
+// Each candle without a position opens a trade with probability EntryChance, and the
+// position is closed after Holding candles. Each trade buys with the quote divided by Pairs,
+// less the Fee rate, so all pairs can hold a position.
+type Random struct {
+	timeframe   string
+	warmup      int
+	EntryChance float64
+	Holding     int
+	Pairs       int
+	Fee         float64
+
+	random  *rand.Rand
+	candles map[string]int
+}
+
+func NewRandom(timeframe string, warmup int, entryChance float64, holding int, seed int64) *Random {
+	if holding < 1 {
+		holding = 1
+	}
+
+	return &Random{
+		timeframe:   timeframe,
+		warmup:      warmup,
+		EntryChance: entryChance,
+		Holding:     holding,
+		random:      rand.New(rand.NewSource(seed)),
+		candles:     make(map[string]int),
+	}
+}
+
+func (r Random) Timeframe() string {
+	return r.timeframe
+}
+
+func (r Random) WarmupPeriod() int {
+	return r.warmup
+}
+
+func (r Random) Indicators(_ *model.Dataframe) []ChartIndicator {
+	return nil
+}
+
+func (r *Random) OnCandle(df *model.Dataframe, broker service.Broker) {
+	assetPosition, quotePosition, err := broker.Position(df.Pair)
+	if err != nil {
+		log.Error(err)
+		return
+	}
+
+	if assetPosition > 0 {
+		r.candles[df.Pair]++
+		if r.candles[df.Pair] >= r.Holding {
+			r.candles[df.Pair] = 0
+			_, err := broker.CreateOrderMarket(model.SideTypeSell, df.Pair, assetPosition)
+			if err != nil {
+				log.Error(err)
+			}
+		}
+		return
+	}
+
+	amount := quotePosition / float64(max(r.Pairs, 1)) * (1 - r.Fee)
+	if amount >= 10 && r.random.Float64() < r.EntryChance {
+		_, err := broker.CreateOrderMarket(model.SideTypeBuy, df.Pair, amount/df.Close.Last(0))
+		if err != nil {
+			log.Error(err)
+		}
+	}
+}
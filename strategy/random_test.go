@@ -0,0 +1,39 @@
+package strategy
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/rodrigo-brito/ninjabot/model"
+	"github.com/rodrigo-brito/ninjabot/service"
+)
+
+type fakeBroker struct {
+	service.Broker
+	quote float64
+	sizes []float64
+}
+
+func (b *fakeBroker) Position(_ string) (asset, quote float64, err error) {
+	return 0, b.quote, nil
+}
+
+func (b *fakeBroker) CreateOrderMarket(_ model.SideType, _ string, size float64) (model.Order, error) {
+	b.sizes = append(b.sizes, size)
+	return model.Order{}, nil
+}
+
+func TestRandom_OnCandle(t *testing.T) {
+	random := NewRandom("1h", 0, 1, 1, 42)
+	random.Pairs, random.Fee = 2, 0.001
+	broker := &fakeBroker{quote: 1000}
+
+	df := &model.Dataframe{Pair: "BTCUSDT", Close: model.Series[float64]{100}, Time: []time.Time{time.Now()}}
+	random.OnCandle(df, broker)
+
+	// half of the quote for each pair, less the fee
+	require.Len(t, broker.sizes, 1)
+	require.InDelta(t, 500*0.999/100, broker.sizes[0], 1e-9)
+}
@@ -1,6 +1,9 @@
 package strategy
 
 import (
+	"fmt"
+	"strings"
+
 	"github.com/rodrigo-brito/ninjabot/model"
 	"github.com/rodrigo-brito/ninjabot/service"
 )
@@ -24,3 +27,26 @@ type HighFrequencyStrategy interface {
 	// OnPartialCandle will be executed for each new partial candle, after indicators are filled.
 	OnPartialCandle(df *model.Dataframe, broker service.Broker)
 }
+
+// ParameterStrategy exposes the tunable parameters of a strategy, eg: {"fast": 8, "slow": 21}
+// Parameters are reported by the Telegram /strategy command.
+type ParameterStrategy interface {
+	Strategy
+
+	Parameters() map[string]any
+}
+
+// NamedStrategy defines the name used to tag the orders of the strategy, eg: "ema-cross"
+type NamedStrategy interface {
+	Strategy
+
+	Name() string
+}
+
+// Name returns the name of the strategy, or the type name if the strategy has no name
+func Name(str Strategy) string {
+	if str, ok := str.(NamedStrategy); ok {
+		return str.Name()
+	}
+	return strings.TrimPrefix(fmt.Sprintf("%T", str), "*")
+}
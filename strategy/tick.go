@@ -0,0 +1,59 @@
+package strategy
+
+import (
+	"time"
+
+	"github.com/rodrigo-brito/ninjabot/service"
+)
+
+// TickStrategy is a strategy with time-based logic (eg: rebalance every hour), independent of candles
+type TickStrategy interface {
+	Strategy
+
+	// TickInterval is the time between two executions of OnTick, eg: time.Hour
+	TickInterval() time.Duration
+	// OnTick will be executed in each interval of the bot clock. The clock is the candle time in backtests
+	// and the wall clock in live trading. Ticks are aligned to the interval, eg: each full hour.
+	// Missed ticks are executed one by one in backtests, and coalesced in the last one in live trading.
+	OnTick(now time.Time, broker service.Broker)
+}
+
+// Ticker executes the OnTick function of a strategy when the clock reaches the next tick
+type Ticker struct {
+	strategy TickStrategy
+	broker   service.Broker
+	replay   bool
+	next     time.Time
+}
+
+// NewTicker creates a ticker of the strategy, replay executes every missed tick, used in backtests
+func NewTicker(strategy TickStrategy, broker service.Broker, replay bool) *Ticker {
+	return &Ticker{
+		strategy: strategy,
+		broker:   broker,
+		replay:   replay,
+	}
+}
+
+// Advance moves the clock to the given time and executes the ticks due until then, in order. Without replay,
+// the due ticks are coalesced in the last one, eg: after the machine sleeps.
+func (t *Ticker) Advance(now time.Time) {
+	interval := t.strategy.TickInterval()
+	if interval <= 0 {
+		return
+	}
+
+	if t.next.IsZero() {
+		t.next = now.Truncate(interval).Add(interval)
+		return
+	}
+
+	if !t.replay && !now.Before(t.next) {
+		t.next = t.next.Add(now.Sub(t.next) / interval * interval)
+	}
+
+	for !now.Before(t.next) {
+		t.strategy.OnTick(t.next, t.broker)
+		t.next = t.next.Add(interval)
+	}
+}
@@ -0,0 +1,79 @@
+package strategy
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/rodrigo-brito/ninjabot/model"
+	"github.com/rodrigo-brito/ninjabot/service"
+)
+
+type hourlyStrategy struct {
+	ticks []time.Time
+}
+
+func (s hourlyStrategy) Timeframe() string {
+	return "1d"
+}
+
+func (s hourlyStrategy) WarmupPeriod() int {
+	return 0
+}
+
+func (s hourlyStrategy) Indicators(_ *model.Dataframe) []ChartIndicator {
+	return nil
+}
+
+func (s hourlyStrategy) OnCandle(_ *model.Dataframe, _ service.Broker) {}
+
+func (s hourlyStrategy) TickInterval() time.Duration {
+	return time.Hour
+}
+
+func (s *hourlyStrategy) OnTick(now time.Time, _ service.Broker) {
+	s.ticks = append(s.ticks, now)
+}
+
+func TestTicker_Advance(t *testing.T) {
+	str := &hourlyStrategy{}
+	ticker := NewTicker(str, nil, true)
+
+	// fake clock, starting in the middle of an hour
+	clock := time.Date(2022, 1, 1, 10, 30, 0, 0, time.UTC)
+	ticker.Advance(clock)
+	require.Empty(t, str.ticks)
+
+	ticker.Advance(clock.Add(20 * time.Minute))
+	require.Empty(t, str.ticks)
+
+	ticker.Advance(clock.Add(30 * time.Minute))
+	require.Equal(t, []time.Time{time.Date(2022, 1, 1, 11, 0, 0, 0, time.UTC)}, str.ticks)
+
+	// sparse updates replay all the missed ticks, in order
+	ticker.Advance(clock.Add(3*time.Hour + 10*time.Minute))
+	require.Equal(t, []time.Time{
+		time.Date(2022, 1, 1, 11, 0, 0, 0, time.UTC),
+		time.Date(2022, 1, 1, 12, 0, 0, 0, time.UTC),
+		time.Date(2022, 1, 1, 13, 0, 0, 0, time.UTC),
+	}, str.ticks)
+}
+
+func TestTicker_AdvanceLive(t *testing.T) {
+	str := &hourlyStrategy{}
+	ticker := NewTicker(str, nil, false)
+
+	clock := time.Date(2022, 1, 1, 10, 30, 0, 0, time.UTC)
+	ticker.Advance(clock)
+
+	// sparse updates execute only the last missed tick
+	ticker.Advance(clock.Add(3*time.Hour + 10*time.Minute))
+	require.Equal(t, []time.Time{time.Date(2022, 1, 1, 13, 0, 0, 0, time.UTC)}, str.ticks)
+
+	ticker.Advance(clock.Add(3*time.Hour + 30*time.Minute))
+	require.Equal(t, []time.Time{
+		time.Date(2022, 1, 1, 13, 0, 0, 0, time.UTC),
+		time.Date(2022, 1, 1, 14, 0, 0, 0, time.UTC),
+	}, str.ticks)
+}
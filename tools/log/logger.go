@@ -14,8 +14,17 @@ var (
 type (
 	TextFormatter = logrus.TextFormatter
 	Level         = logrus.Level
+	Logger        = logrus.Logger
 )
 
+// New returns a logger with the format and level of the standard logger
+func New() *Logger {
+	logger := logrus.New()
+	logger.SetFormatter(logrus.StandardLogger().Formatter)
+	logger.SetLevel(logrus.GetLevel())
+	return logger
+}
+
 func CheckErr(level logrus.Level, err error) {
 	if err != nil {
 		Log(level, err)
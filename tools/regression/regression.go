@@ -0,0 +1,68 @@
+// Package regression compares backtests of strategies with golden files, to be used in tests.
+package regression
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/rodrigo-brito/ninjabot"
+)
+
+// EnvUpdate updates the golden files with the current results when set, eg: NINJABOT_UPDATE_GOLDEN=1 go test ./...
+const EnvUpdate = "NINJABOT_UPDATE_GOLDEN"
+
+// Check fails the test when the result diverges from the golden file beyond the relative tolerance.
+// The golden file is written with the result when EnvUpdate is set, a missing golden file fails the test.
+func Check(t testing.TB, golden string, result *ninjabot.RegressionResult, tolerance float64) {
+	t.Helper()
+
+	if os.Getenv(EnvUpdate) != "" {
+		if err := Save(golden, result); err != nil {
+			t.Fatalf("regression: updating golden file %s: %v", golden, err)
+		}
+		t.Logf("regression: golden file %s updated", golden)
+		return
+	}
+
+	expected, err := Load(golden)
+	if errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("regression: golden file %s not found, run with %s=1 to create it", golden, EnvUpdate)
+	}
+	if err != nil {
+		t.Fatalf("regression: reading golden file %s: %v", golden, err)
+	}
+
+	if err := result.Compare(*expected, tolerance); err != nil {
+		t.Errorf("%v\nrun with %s=1 to update %s if the change is intended", err, EnvUpdate, golden)
+	}
+}
+
+// Load reads a golden file
+func Load(file string) (*ninjabot.RegressionResult, error) {
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	var result ninjabot.RegressionResult
+	if err := json.Unmarshal(content, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// Save writes the result in a golden file, creating its directory if needed
+func Save(file string, result *ninjabot.RegressionResult) error {
+	content, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(file), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(file, append(content, '\n'), 0o644)
+}
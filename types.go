@@ -7,6 +7,9 @@ import (
 type (
 	Settings         = model.Settings
 	TelegramSettings = model.TelegramSettings
+	BalanceSettings  = model.BalanceSettings
+	ExchangeSettings = model.ExchangeSettings
+	NumberFormat     = model.NumberFormat
 	Dataframe        = model.Dataframe
 	Series           = model.Series[float64]
 	SideType         = model.SideType